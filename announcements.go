@@ -0,0 +1,35 @@
+package main
+
+// 定期报告披露日历：预约披露日 (交易所提前公布的计划日期) 与实际披露日。
+// 派生字段见视图 v_earnings_days，也可以直接作为因子名使用：
+//   - days_to_earnings    距下一次披露的自然日数 (优先用预约日，实际日期当时并不可知)
+//   - days_since_earnings 距上一次实际披露的自然日数
+//
+// 索引：0:代码, 1:报告期, 2:预约披露日, 3:实际披露日 (可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "财报披露日历",
+		Pattern: PathAnnouncements,
+		Table:   "announcements",
+		DDL: `CREATE TABLE IF NOT EXISTS announcements (
+			symbol          TEXT NOT NULL,
+			report_period   TEXT NOT NULL,
+			scheduled_date  TEXT,
+			actual_date     TEXT,
+			PRIMARY KEY (symbol, report_period)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 3,
+		Mapper: func(record []string) []any {
+			symbol := normText(record[0])
+			period := normDate(record[1])
+			scheduled, actual := normDate(record[2]), normDate(col(record, 3))
+			if symbol == nil || period == nil || (scheduled == nil && actual == nil) {
+				return nil
+			}
+			return []any{symbol, period, scheduled, actual}
+		},
+	})
+
+	registerDerivedColumn("days_to_earnings", "v_earnings_days")
+	registerDerivedColumn("days_since_earnings", "v_earnings_days")
+}
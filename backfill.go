@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 缺口回补
+// ---------------------------------------------------------
+// 缺口：某只股票在自己首末日期之间、该市场开市 (见 tradingDates) 但库中没有数据的日期，
+// 相邻的缺失交易日合并为一段。停牌也会表现为缺口，数据源拉不到数据时原样保留。
+//
+// 回补按数据源链 (见 chain.go) 拉取缺口范围，只写入库中没有的行，不覆盖已有数据；
+// 补上的行记入 history_provenance (来源、时间)，报告中逐段列出各来源补上的天数。
+// 不同数据源的后复权价都以上市首日为基准，补进来的复权价可与原序列衔接。
+//
+//	chronos backfill --dry-run                        # 只列出缺口
+//	chronos backfill --source eastmoney,baostock --from 2020-01-01
+
+type historyGap struct {
+	Symbol   string
+	From, To string // YYYY-MM-DD，含
+	Days     int    // 缺失的交易日数
+}
+
+// 找出 market 中 from 之后的缺口 (from 为空表示全部)
+func findHistoryGaps(db *sql.DB, market, from string) []historyGap {
+	dates := tradingDates(db, market)
+	pos := make(map[string]int, len(dates))
+	for k, d := range dates {
+		pos[d] = k
+	}
+	rows, err := db.Query(`
+		WITH cal AS (SELECT DISTINCT date FROM stock_history WHERE market = ?1 AND date >= ?2),
+		span AS (SELECT symbol, min(date) AS first, max(date) AS last FROM stock_history WHERE market = ?1 GROUP BY symbol)
+		SELECT s.symbol, c.date
+		FROM span s JOIN cal c ON c.date BETWEEN s.first AND s.last
+		WHERE NOT EXISTS (SELECT 1 FROM stock_history h WHERE h.symbol = s.symbol AND h.date = c.date)
+		ORDER BY s.symbol, c.date`, market, from)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var gaps []historyGap
+	for rows.Next() {
+		var symbol, date string
+		if err := rows.Scan(&symbol, &date); err != nil {
+			log.Fatal(err)
+		}
+		if n := len(gaps); n > 0 && gaps[n-1].Symbol == symbol && pos[gaps[n-1].To]+1 == pos[date] {
+			gaps[n-1].To = date
+			gaps[n-1].Days++
+			continue
+		}
+		gaps = append(gaps, historyGap{Symbol: symbol, From: date, To: date, Days: 1})
+	}
+	return gaps
+}
+
+// chronos backfill [--source eastmoney,baostock] [--market CN] [--from 2020-01-01] [--dry-run]
+func runBackfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件")
+	source := fs.String("source", "", "回补数据源，逗号分隔时按顺序回退；默认取 sources.yaml 中的 chains.daily，再默认 eastmoney (港股/美股用 yahoo)")
+	market := fs.String("market", MarketCN, "市场: CN / HK / US")
+	from := fs.String("from", "", "只回补该日期 (YYYY-MM-DD) 之后的缺口")
+	dryRun := fs.Bool("dry-run", false, "只列出缺口，不回补")
+	fs.Parse(args)
+
+	chainName := "daily"
+	if *market != MarketCN {
+		chainName += "_" + strings.ToLower(*market)
+	}
+	chain, err := loadChain(*config, chainName, *source)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if len(chain) == 0 {
+		chain = []string{"eastmoney"}
+		if *market != MarketCN {
+			chain = []string{"yahoo"}
+		}
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+	if err := setupSources(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	gaps := findHistoryGaps(db, *market, *from)
+	days := 0
+	for _, g := range gaps {
+		days += g.Days
+	}
+	log.Printf(">>> %s 市场共 %d 段缺口, %d 个 (股票, 交易日)", *market, len(gaps), days)
+	if *dryRun || len(gaps) == 0 {
+		for _, g := range gaps {
+			fmt.Printf("%-12s %s ~ %s  %4d 天\n", g.Symbol, g.From, g.To, g.Days)
+		}
+		return
+	}
+
+	since := time.Now().Format(time.DateTime)
+	fillChain(context.Background(), db, *config, *market, chain, gaps)
+
+	// 报告：逐段列出补上的天数及来源
+	filledStmt, err := db.Prepare(`SELECT source, count(*) FROM history_provenance
+		WHERE symbol = ? AND date BETWEEN ? AND ? AND fetched_at >= ? GROUP BY source ORDER BY source`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer filledStmt.Close()
+	fmt.Printf("\n%-12s %-23s %6s %6s  来源\n", "代码", "缺口", "缺失", "补上")
+	filled := 0
+	for _, g := range gaps {
+		rows, err := filledStmt.Query(g.Symbol, g.From, g.To, since)
+		if err != nil {
+			log.Fatal(err)
+		}
+		n, sources := 0, []string{}
+		for rows.Next() {
+			var src string
+			var k int
+			rows.Scan(&src, &k)
+			n += k
+			sources = append(sources, fmt.Sprintf("%s:%d", src, k))
+		}
+		rows.Close()
+		fmt.Printf("%-12s %s ~ %s %6d %6d  %s\n", g.Symbol, g.From, g.To, g.Days, n, strings.Join(sources, " "))
+		filled += n
+	}
+
+	createViews(db)
+	logAPIUsage()
+	log.Printf(">>> ✅ 回补完成: %d/%d 天已补上 (其余多为停牌), 耗时: %s", filled, days, time.Since(start))
+}
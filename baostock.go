@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// Baostock (免费，无需注册)
+// ---------------------------------------------------------
+// Baostock 没有 HTTP 接口，这里按官方 Python 客户端的 TCP 协议实现：
+//
+//	报文 = 头部 + 正文 + \x01 + crc32(头部+正文) + "<![CDATA[]]>\n"
+//	头部 = 客户端版本 \x01 消息类型 \x01 正文长度 (10 位补零)，共 21 字节
+//
+// 正文各字段以 \x01 分隔；K 线查询的响应正文经 zlib 压缩。
+// 个股拉取不复权 (含成交量、PE-TTM) 与后复权两份日线，写入 staging 表后走 mergeStaging 合并。
+//
+// 服务器、账号可在 sources.yaml 的 baostock 节中配置 (server / user / password)，默认匿名登录。
+
+const (
+	baostockServer  = "public-api.baostock.com:10030"
+	baostockVersion = "00.8.90"
+	baostockSplit   = "\x01"
+	baostockEnd     = "<![CDATA[]]>\n"
+	baostockPage    = 10000
+
+	baostockMsgLogin    = "00"
+	baostockMsgLogout   = "02"
+	baostockMsgKData    = "95"
+	baostockMsgKDataRsp = "96"
+)
+
+type baostockClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	user   string
+}
+
+func dialBaostock(ctx context.Context, configPath string) (*baostockClient, error) {
+	cfg, err := loadSourceConfig(configPath, "baostock")
+	if err != nil {
+		return nil, err
+	}
+	server, user, password := yamlString(cfg, "server"), yamlString(cfg, "user"), yamlString(cfg, "password")
+	if server == "" {
+		server = baostockServer
+	}
+	if user == "" {
+		user, password = "anonymous", "123456"
+	}
+
+	conn, err := dialTCP(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	c := &baostockClient{conn: conn, reader: bufio.NewReader(conn), user: user}
+	if _, err := c.call(baostockMsgLogin, "login", user, password, "0"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("baostock 登录失败: %v", err)
+	}
+	return c, nil
+}
+
+func (c *baostockClient) Close() error {
+	c.send(baostockMsgLogout, "logout", c.user, time.Now().Format("20060102150405"))
+	return c.conn.Close()
+}
+
+func (c *baostockClient) send(msgType string, fields ...string) error {
+	body := strings.Join(fields, baostockSplit)
+	head := fmt.Sprintf("%s%s%s%s%010d", baostockVersion, baostockSplit, msgType, baostockSplit, len(body))
+	msg := head + body
+	msg += baostockSplit + strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte(msg))), 10) + baostockEnd
+	c.conn.SetDeadline(time.Now().Add(60 * time.Second))
+	_, err := io.WriteString(c.conn, msg)
+	return err
+}
+
+// 发送请求并读取一条响应，返回正文字段；错误码非 0 时返回错误
+func (c *baostockClient) call(msgType string, fields ...string) ([]string, error) {
+	if err := c.send(msgType, fields...); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for !bytes.HasSuffix(buf.Bytes(), []byte(baostockEnd)) {
+		chunk, err := c.reader.ReadBytes('\n')
+		buf.Write(chunk)
+		if err != nil {
+			return nil, err
+		}
+	}
+	raw := bytes.TrimSuffix(buf.Bytes(), []byte(baostockEnd))
+	if len(raw) < 21 {
+		return nil, fmt.Errorf("baostock 响应过短")
+	}
+	head, body := raw[:21], raw[21:]
+	if string(head[len(baostockVersion)+1:len(baostockVersion)+3]) == baostockMsgKDataRsp {
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+
+	out := strings.Split(string(body), baostockSplit)
+	if len(out) < 2 {
+		return nil, fmt.Errorf("baostock 响应格式错误")
+	}
+	if out[0] != "0" {
+		return nil, fmt.Errorf("baostock: %s (错误码 %s)", out[1], out[0])
+	}
+	return out, nil
+}
+
+// 日 K 线；adjust: "3" 不复权 / "1" 后复权 / "2" 前复权。返回按 fields 顺序的字符串行
+func (c *baostockClient) kData(code, fields, start, end, adjust string) ([][]string, error) {
+	var rows [][]string
+	for page := 1; ; page++ {
+		key := strings.Join([]string{code, fields, start, end, adjust, strconv.Itoa(page)}, "|")
+		cached, err := cachedFetch(context.Background(), "baostock", key, func() ([]byte, error) {
+			if err := apiWait(context.Background(), "baostock"); err != nil {
+				return nil, err
+			}
+			resp, err := c.call(baostockMsgKData, "query_history_k_data_plus", c.user, strconv.Itoa(page), strconv.Itoa(baostockPage),
+				code, fields, start, end, "d", adjust)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(resp)
+		})
+		if err != nil {
+			return nil, err
+		}
+		var resp []string
+		if err := json.Unmarshal(cached, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp) < 7 {
+			return nil, fmt.Errorf("baostock K 线响应字段不足")
+		}
+		var data struct {
+			Record [][]string `json:"record"`
+		}
+		if resp[6] != "" {
+			if err := json.Unmarshal([]byte(resp[6]), &data); err != nil {
+				return nil, err
+			}
+		}
+		rows = append(rows, data.Record...)
+		if len(data.Record) < baostockPage {
+			return rows, nil
+		}
+	}
+}
+
+// 个股日线写入 staging 表，返回行数
+func (c *baostockClient) stageStock(tx *sql.Tx, symbol, start, end string) (int, error) {
+	_, canonical, err := emSecID(symbol)
+	if err != nil {
+		return 0, err
+	}
+	code := strings.ToLower(canonical[7:]) + "." + canonical[:6] // 600000.SH -> sh.600000
+	raw, err := c.kData(code, "date,close,volume,peTTM,tradestatus", start, end, "3")
+	if err != nil {
+		return 0, err
+	}
+	adj, err := c.kData(code, "date,open,high,low,close", start, end, "1")
+	if err != nil {
+		return 0, err
+	}
+	rawByDate := make(map[string][]string, len(raw))
+	for _, r := range raw {
+		if len(r) == 5 && r[4] == "1" { // 只保留正常交易日，停牌日不入库
+			rawByDate[r[0]] = r
+		}
+	}
+
+	n := 0
+	for _, a := range adj {
+		r, ok := rawByDate[a[0]]
+		if !ok || len(a) < 5 {
+			continue
+		}
+		date := strings.ReplaceAll(a[0], "-", "")
+		if _, err := tx.Exec("INSERT INTO staging_tech VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			canonical, date, r[1], a[4], a[1], a[2], a[3], r[2]); err != nil {
+			return n, err
+		}
+		if _, err := tx.Exec("INSERT INTO staging_daily VALUES (?, ?, ?)", canonical, date, r[3]); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// chronos baostock --symbols 600000.SH,000001.SZ [--from 2010-01-01] [--to 2024-12-31]
+func runBaostock(args []string) {
+	fs := flag.NewFlagSet("baostock", flag.ExitOnError)
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件")
+	symbolsArg := fs.String("symbols", "", "股票代码列表文件或逗号分隔的代码 (默认库中全部 A 股)")
+	from := fs.String("from", "", "起始日期 (YYYY-MM-DD)，默认每只股票从库中最后一天之后续拉")
+	to := fs.String("to", time.Now().Format(time.DateOnly), "结束日期 (YYYY-MM-DD，含)")
+	batch := fs.Int("batch", 50, "每拉取多少只股票合并一次")
+	fs.Parse(args)
+
+	start := time.Now()
+	db := openOrCreateDB()
+	defer db.Close()
+	if err := setupSources(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	var symbols []string
+	if *symbolsArg != "" {
+		var err error
+		if symbols, err = parseSymbols(*symbolsArg); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		symbols = marketSymbols(db, MarketCN)
+	}
+	if len(symbols) == 0 {
+		log.Fatal("[ERROR] 没有要拉取的代码 (请指定 --symbols)")
+	}
+
+	client, err := dialBaostock(context.Background(), *config)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	defer client.Close()
+
+	total := 0
+	for k := 0; k < len(symbols); k += *batch {
+		chunk := symbols[k:min(k+*batch, len(symbols))]
+		tx, err := db.Begin()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range chunk {
+			_, canonical, err := emSecID(s)
+			if err != nil {
+				log.Printf("[WARN] %v", err)
+				continue
+			}
+			begin := *from
+			if begin == "" {
+				begin = "1990-12-19"
+				if next := nextFetchDate(db, "SELECT MAX(date) FROM stock_history WHERE symbol = ?", canonical, ""); next != "" {
+					begin = normDate(next).(string)
+				}
+			}
+			n, err := client.stageStock(tx, s, begin, *to)
+			if err != nil {
+				tx.Rollback()
+				log.Fatalf("[ERROR] %s: %v", s, err)
+			}
+			total += n
+		}
+		if err := tx.Commit(); err != nil {
+			log.Fatal(err)
+		}
+		mergeStaging(db)
+		log.Printf(">>> 已合并 %d/%d 只股票", k+len(chunk), len(symbols))
+	}
+
+	mustExec(db, "DROP TABLE IF EXISTS staging_tech;")
+	mustExec(db, "DROP TABLE IF EXISTS staging_daily;")
+	createViews(db)
+	logAPIUsage()
+	log.Printf(">>> ✅ Baostock 拉取完成: %d 行, 耗时: %s", total, time.Since(start))
+}
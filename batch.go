@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// 批量写入
+// ---------------------------------------------------------
+// 大批量导入时每行执行一次 INSERT，每次都有语句调用与参数绑定的开销 (cgo 驱动每个参数都要跨一次 cgo)。
+// batchInserter 把多行拼成一条 INSERT ... VALUES (...), (...), ... 执行 (预编译满批的语句，
+// 剩余行用单行语句)。某一批出错时逐行重试，其余行照常写入，与逐行执行时的行为一致；出错的各行连同
+// 值与来源 (addAt 给出的位置) 以 batchErrors 返回 (见 importerr.go)。
+//
+// 每批行数默认为 insertBatchRows，由驱动决定 (driver_*.go)，依据为 BenchmarkBatchInserter (batch_test.go)：
+//
+//	默认的纯 Go 驱动   不做多行批量。绑定时对每个参数在参数表中线性查找，一条语句的绑定开销随参数个数
+//	                   平方增长 (10 列: 逐行约 17 万行/秒，每批 20 行约 15 万，100 行 10 万，500 行 3 万)；
+//	                   绕开逐个绑定也不划算：值直接拼进 SQL 文本约 11 万 (解析 SQL 的开销取代了绑定，
+//	                   浮点数还要经过一次文本往返)，整批作为一个 JSON 参数经 json_each 展开约 6.5 万。
+//	                   写入本身 (B 树、约束) 已占逐行耗时的一半以上，同一事务内预编译语句逐行执行最快，
+//	                   所以 insertBatchRows = 1，导入速度与引入 batchInserter 之前相同
+//	cgo 驱动           多行语句略快 (约 1.2 倍)，每批 500 行
+//
+// 可以在 sources.yaml 的 import.batch_rows 中覆盖 (如在别的机器上跑出不同的基准结果时)，1 为逐行。
+// 需要更快的导入时用 cgo 驱动编译 (driver_cgo.go)，或用 import --streaming 省掉写 staging 表的一遍。
+
+// SQLite 单条语句的参数上限 (SQLITE_MAX_VARIABLE_NUMBER)
+const sqliteMaxVars = 32766
+
+type batchInserter struct {
+	tx     *sql.Tx
+	query  func(rows int) string
+	width  int
+	size   int
+	full   *sql.Stmt
+	single *sql.Stmt
+	buf    []any
+	at     []rowPos
+	rows   int
+}
+
+// 一批中出错的各行 (至少一个)
+type batchErrors []*importError
+
+func (e batchErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%v (另有 %d 行出错)", e[0], len(e)-1)
+}
+
+func (e batchErrors) Unwrap() error { return e[0] }
+
+// verb 为 INSERT / INSERT OR REPLACE 等，columns 为空时按表的列顺序写入，conflict 追加在 VALUES 之后。
+// 单行语句在这里预编译，表或列不存在等错误立即返回
+func newBatchInserter(tx *sql.Tx, verb, table string, columns []string, conflict string, width int) (*batchInserter, error) {
+	target := table
+	if len(columns) > 0 {
+		target = fmt.Sprintf("%s (%s)", table, strings.Join(columns, ", "))
+	}
+	tuple := "(" + strings.TrimSuffix(strings.Repeat("?,", width), ",") + ")"
+	b := &batchInserter{
+		tx: tx,
+		query: func(rows int) string {
+			return fmt.Sprintf("%s INTO %s VALUES %s %s", verb, target, strings.TrimSuffix(strings.Repeat(tuple+",", rows), ","), conflict)
+		},
+		width: width,
+		size:  max(1, min(importOptions.batchRows, sqliteMaxVars/max(width, 1))),
+	}
+	stmt, err := tx.Prepare(b.query(1))
+	if err != nil {
+		return nil, err
+	}
+	b.single = stmt
+	return b, nil
+}
+
+func (b *batchInserter) add(row []any) error {
+	return b.addAt(row, rowPos{})
+}
+
+// 同 add，at 为该行在源文件中的位置，出错时写进错误
+func (b *batchInserter) addAt(row []any, at rowPos) error {
+	if len(row) != b.width {
+		return batchErrors{{at, formatRowValues(row), fmt.Errorf("%w: 有 %d 个值, 需要 %d 个", errRowWidth, len(row), b.width)}}
+	}
+	b.buf = append(b.buf, row...)
+	b.at = append(b.at, at)
+	b.rows++
+	if b.rows < b.size {
+		return nil
+	}
+	return b.flush()
+}
+
+// 写入缓冲中的行
+func (b *batchInserter) flush() error {
+	if b.rows == 0 {
+		return nil
+	}
+	buf, at, rows := b.buf, b.at, b.rows
+	b.buf, b.at, b.rows = b.buf[:0], b.at[:0], 0
+	if rows == b.size && b.size > 1 {
+		if b.full == nil {
+			stmt, err := b.tx.Prepare(b.query(b.size))
+			if err != nil {
+				return err
+			}
+			b.full = stmt
+		}
+		if _, err := b.full.Exec(buf...); err == nil {
+			return nil
+		}
+		// 整批失败时语句已回滚，逐行重试找出出错的行
+	}
+	var errs batchErrors
+	for k := 0; k < rows; k++ {
+		row := buf[k*b.width : (k+1)*b.width]
+		if _, err := b.single.Exec(row...); err != nil {
+			errs = append(errs, &importError{at[k], formatRowValues(row), err})
+		}
+	}
+	if errs == nil {
+		return nil
+	}
+	return errs
+}
+
+// 写入剩余的行并释放语句
+func (b *batchInserter) close() error {
+	err := b.flush()
+	if b.full != nil {
+		b.full.Close()
+	}
+	b.single.Close()
+	return err
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 每批行数的依据：10 列的表写入 2 万行，比较逐行与各种批大小 (insertBatchRows 见 driver_*.go)，
+// 以及不经过逐个参数绑定的两种写法：把值直接拼进 SQL 文本 (literal)、整批作为一个 JSON 参数 (json)
+//
+//	go test -run - -bench BatchInserter .
+//	go test -tags sqlite_cgo -run - -bench BatchInserter .
+func BenchmarkBatchInserter(b *testing.B) {
+	const rows, width = 20000, 10
+	cols := "symbol TEXT NOT NULL, date TEXT NOT NULL"
+	sel := "value->>0, value->>1"
+	for k := 2; k < width; k++ {
+		cols += fmt.Sprintf(", c%d REAL", k)
+		sel += fmt.Sprintf(", value->>%d", k)
+	}
+	data := make([][]any, rows)
+	for i := range data {
+		row := []any{fmt.Sprintf("%06d.SZ", i%500), fmt.Sprintf("%04d-%02d-%02d", 2000+i/500/336, 1+i/500/28%12, 1+i/500%28)}
+		for k := 2; k < width; k++ {
+			row = append(row, float64(i)+float64(k)/100)
+		}
+		data[i] = row
+	}
+	run := func(name string, write func(tx *sql.Tx) error) {
+		b.Run(name, func(b *testing.B) {
+			db := openTestDB(b, "batch.db")
+			for n := 0; n < b.N; n++ {
+				mustExec(db, "DROP TABLE IF EXISTS t")
+				mustExec(db, "CREATE TABLE t ("+cols+", PRIMARY KEY (symbol, date)) STRICT")
+				tx, err := db.Begin()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := write(tx); err != nil {
+					b.Fatal(err)
+				}
+				if err := tx.Commit(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(rows*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+
+	for _, size := range []int{1, 5, 20, 100, 500} {
+		run(fmt.Sprintf("rows=%d", size), func(tx *sql.Tx) error {
+			batch, err := newBatchInserter(tx, "INSERT", "t", nil, "", width)
+			if err != nil {
+				return err
+			}
+			batch.size = min(size, sqliteMaxVars/width)
+			for _, row := range data {
+				if err := batch.add(row); err != nil {
+					return err
+				}
+			}
+			return batch.close()
+		})
+	}
+
+	// 测试数据只有字符串与有限的浮点数
+	literal := func(sb *strings.Builder, v any, quote func(string) string) {
+		if s, ok := v.(string); ok {
+			sb.WriteString(quote(s))
+			return
+		}
+		sb.WriteString(strconv.FormatFloat(v.(float64), 'f', -1, 64))
+	}
+	sqlText := func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+	tuples := func(sb *strings.Builder, rows [][]any, open, close string, quote func(string) string) {
+		for r, row := range rows {
+			if r > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(open)
+			for k, v := range row {
+				if k > 0 {
+					sb.WriteByte(',')
+				}
+				literal(sb, v, quote)
+			}
+			sb.WriteString(close)
+		}
+	}
+	for _, size := range []int{100, 500} {
+		run(fmt.Sprintf("literal/rows=%d", size), func(tx *sql.Tx) error {
+			var sb strings.Builder
+			for i := 0; i < rows; i += size {
+				sb.Reset()
+				sb.WriteString("INSERT INTO t VALUES ")
+				tuples(&sb, data[i:min(i+size, rows)], "(", ")", sqlText)
+				if _, err := tx.Exec(sb.String()); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		run(fmt.Sprintf("json/rows=%d", size), func(tx *sql.Tx) error {
+			stmt, err := tx.Prepare("INSERT INTO t SELECT " + sel + " FROM json_each(?)")
+			if err != nil {
+				return err
+			}
+			defer stmt.Close()
+			var sb strings.Builder
+			for i := 0; i < rows; i += size {
+				sb.Reset()
+				sb.WriteByte('[')
+				tuples(&sb, data[i:min(i+size, rows)], "[", "]", strconv.Quote)
+				sb.WriteByte(']')
+				if _, err := stmt.Exec(sb.String()); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// import.batch_rows 覆盖驱动默认的批大小；满批中有坏行时逐行重试，其余行照常写入
+func TestBatchInserterBatchRows(t *testing.T) {
+	defer func(n int) { importOptions.batchRows = n }(importOptions.batchRows)
+	importOptions.batchRows = 3
+
+	db := openTestDB(t, "batch.db")
+	mustExec(db, "CREATE TABLE t (symbol TEXT NOT NULL, v REAL NOT NULL) STRICT")
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := newBatchInserter(tx, "INSERT", "t", nil, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.size != 3 {
+		t.Fatalf("size = %d, 期望 3", batch.size)
+	}
+	var failed []int
+	for i := range 7 {
+		var v any = float64(i)
+		if i == 4 {
+			v = nil // 第二批中的坏行
+		}
+		err := batch.addAt([]any{fmt.Sprintf("s%d", i), v}, rowPos{line: i + 1})
+		var errs batchErrors
+		if errors.As(err, &errs) {
+			for _, e := range errs {
+				failed = append(failed, e.at.line)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := batch.close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0] != 5 {
+		t.Errorf("出错的行 = %v, 期望 [5]", failed)
+	}
+	if got, want := dumpTable(t, db, "SELECT group_concat(symbol) FROM t"), "s0,s1,s2,s3,s5,s6\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
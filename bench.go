@@ -0,0 +1,200 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 性能基准
+// ---------------------------------------------------------
+// 在当前机器、驱动与 PRAGMA 设置下跑一遍合成的导入流程，分别测量解析、写入 staging 与合并的吞吐，
+// 便于比较各种调优选项，报告性能问题时也有可复现的数字：
+//
+//	chronos bench --rows 5000000
+//	chronos bench --rows 5000000 --merge sort --max-memory 2GB --pragmas "synchronous=NORMAL"
+//	chronos bench --rows 5000000 --streaming
+//
+// 数据用 mock 生成 (import 布局，技术因子 + 每日指标各 rows 行)，默认写在临时目录并在结束后删除；
+// 用 --dir 指定目录时保留，再次运行时直接复用已生成的文件。解析参数取 sources.yaml 的 import 节。
+
+// 基准数据的日期范围 (每只股票平均约 2500 根日线)
+var benchFrom, benchTo = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)
+
+type benchResult struct {
+	Phase   string
+	Rows    int
+	Elapsed time.Duration
+}
+
+// 生成约 rows 行 (最后一只股票截断)，返回实际行数
+func generateBenchData(dir string, rows int, seed int64) (int, error) {
+	symbols := mockSymbols(seed, min(rows/2000+1, 8000))
+	total := 0
+	for _, s := range symbols {
+		if total >= rows {
+			break
+		}
+		bars := mockSeries(seed, s, benchFrom, benchTo)
+		bars = bars[:min(len(bars), rows-total)]
+		if len(bars) == 0 {
+			continue
+		}
+		if err := writeMockImportFiles(dir, s, bars); err != nil {
+			return total, err
+		}
+		total += len(bars)
+	}
+	if total < rows {
+		return total, fmt.Errorf("只能生成 %d 行 (股票代码不够)，请减小 --rows", total)
+	}
+	return total, nil
+}
+
+// 只解析、映射，不写库
+func benchParse(pattern string, minCols int, newMapper func(header []string) rowMapper) int {
+	files, _ := filepath.Glob(pattern)
+	n := 0
+	for _, pf := range parseCSVFiles(files, minCols, newMapper) {
+		for chunk := range pf.chunks {
+			n += len(chunk.rows)
+		}
+	}
+	return n
+}
+
+func dirSize(pattern string) int64 {
+	files, _ := filepath.Glob(pattern)
+	var n int64
+	for _, f := range files {
+		if st, err := os.Stat(f); err == nil {
+			n += st.Size()
+		}
+	}
+	return n
+}
+
+// chronos bench [--rows 1000000] [--dir D:\bench] [--merge sql|sort] [--streaming] [--max-memory 2GB] [--pragmas "k=v,..."]
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	rows := fs.Int("rows", 1000000, "技术因子与每日指标各生成的行数")
+	dirArg := fs.String("dir", "", "数据与数据库目录 (指定时保留并复用，默认用临时目录)")
+	seed := fs.Int64("seed", 1, "随机种子")
+	merge := fs.String("merge", "sql", "staging 合并方式: sql 或 sort")
+	streaming := fs.Bool("streaming", false, "测量流式合并 (不经过 staging 表)")
+	maxMemory := fs.String("max-memory", "", "内存预算 (见 memory.go)")
+	pragmas := fs.String("pragmas", "journal_mode=WAL,synchronous=OFF,temp_store=MEMORY", "打开数据库后执行的 PRAGMA (逗号分隔，默认同 import)")
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件 (import 节)")
+	fs.Parse(args)
+	checkMergeMethod(*merge)
+	if *rows < 1 {
+		log.Fatal("[ERROR] --rows 至少为 1")
+	}
+	if err := setupImportOptions(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if err := setMaxMemory(*maxMemory); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	dir := *dirArg
+	if dir == "" {
+		tmp, err := os.MkdirTemp(importOptions.tempDir, "chronos-bench-*")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	}
+	techPattern := filepath.Join(dir, "技术因子_复权数据", "*.csv")
+	dailyPattern := filepath.Join(dir, "每日指标", "*.csv")
+
+	var results []benchResult
+	if files, _ := filepath.Glob(techPattern); len(files) > 0 {
+		log.Printf(">>> 复用 %s 中已生成的 %d 个文件", dir, len(files))
+	} else {
+		log.Printf(">>> 正在生成 %d 行基准数据 -> %s", *rows, dir)
+		start := time.Now()
+		n, err := generateBenchData(dir, *rows, *seed)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		results = append(results, benchResult{"生成数据", n * 2, time.Since(start)})
+	}
+
+	profiles := builtinHeaderProfiles()
+	techMapper := profileMapper(profiles, "staging_tech", mapTechFactors, 19)
+	dailyMapper := profileMapper(profiles, "staging_daily", mapDailyMetrics, 15)
+
+	log.Println(">>> 解析 (不写库)...")
+	start := time.Now()
+	n := benchParse(techPattern, 2, techMapper) + benchParse(dailyPattern, 2, dailyMapper)
+	results = append(results, benchResult{"解析", n, time.Since(start)})
+
+	dbPath := filepath.Join(dir, "bench.db")
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(dbPath + suffix)
+	}
+	registerUDFs()
+	db, err := sql.Open(sqliteDriver, dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	for _, p := range splitList(*pragmas) {
+		mustExec(db, "PRAGMA "+p+";")
+	}
+	applyTempDir(db)
+	applyMemoryBudget(db)
+	applyAutoVacuum(db)
+	createTables(db)
+
+	count := func(table string) int {
+		var n int
+		db.QueryRow("SELECT count(*) FROM " + table).Scan(&n)
+		return n
+	}
+	if *streaming {
+		start = time.Now()
+		streamMergeStaging(db, techPattern, dailyPattern, techMapper, dailyMapper)
+		results = append(results, benchResult{"流式合并", count("stock_history"), time.Since(start)})
+	} else {
+		start = time.Now()
+		importCSVFiles(db, techPattern, "staging_tech", "", 2, techMapper)
+		importCSVFiles(db, dailyPattern, "staging_daily", "", 2, dailyMapper)
+		results = append(results, benchResult{"写入 staging", count("staging_tech") + count("staging_daily"), time.Since(start)})
+
+		start = time.Now()
+		mergeStagingBy(db, *merge)
+		results = append(results, benchResult{"合并 (" + *merge + ")", count("stock_history"), time.Since(start)})
+	}
+	var dbSize int64
+	if st, err := os.Stat(dbPath); err == nil {
+		dbSize = st.Size()
+	}
+
+	fmt.Printf("\n%s %s/%s, %d 核, 驱动 %s (每批 %d 行), 解析 %d 路, csv=%s, 内存预算 %s\n",
+		runtime.Version(), runtime.GOOS, runtime.GOARCH, runtime.NumCPU(), sqliteDriver, importOptions.batchRows,
+		importParseWorkers, importOptions.csv, benchMemory())
+	fmt.Printf("PRAGMA: %s\n", *pragmas)
+	fmt.Printf("CSV %s, 数据库 %s\n\n", formatBytes(float64(dirSize(techPattern)+dirSize(dailyPattern))), formatBytes(float64(dbSize)))
+	fmt.Printf("%12s %12s %12s  %s\n", "行数", "耗时", "行/秒", "阶段")
+	fmt.Println(strings.Repeat("-", 56))
+	for _, r := range results {
+		fmt.Printf("%12d %12s %12.0f  %s\n", r.Rows, r.Elapsed.Round(time.Millisecond), float64(r.Rows)/r.Elapsed.Seconds(), r.Phase)
+	}
+}
+
+func benchMemory() string {
+	if importOptions.maxMemory <= 0 {
+		return "不限"
+	}
+	return formatBytes(float64(importOptions.maxMemory))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 接口响应磁盘缓存
+// ---------------------------------------------------------
+// 历史行情接口的原始响应按 数据源 + 接口地址/参数 的哈希存到 cache/<source>/ 下，有效期内直接读盘，
+// 不发请求也不占额度 (见 ratelimit.go)。拉取中断后重跑，已下载过的部分瞬间跳过。
+// 只缓存成功的响应；实时行情 (quotes) 不走缓存。
+//
+// 在 sources.yaml 中配置 (ttl 为 0 关闭缓存)：
+//
+//	cache:
+//	  dir: D:\chronos_cache   # 默认为数据库所在目录下的 cache
+//	  ttl: 24h
+
+const defaultCacheTTL = 24 * time.Hour
+
+var responseCache struct {
+	sync.Mutex
+	dir string // 为空表示未启用
+	ttl time.Duration
+}
+
+func setupResponseCache(configPath string) error {
+	cfg, err := loadSourceConfig(configPath, "cache")
+	if err != nil {
+		return err
+	}
+	dir, ttl := yamlString(cfg, "dir"), defaultCacheTTL
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(DBPath), "cache")
+	}
+	if s := yamlString(cfg, "ttl"); s != "" {
+		if ttl, err = time.ParseDuration(s); err != nil {
+			return fmt.Errorf("cache.ttl: %v", err)
+		}
+	}
+	responseCache.Lock()
+	defer responseCache.Unlock()
+	responseCache.dir, responseCache.ttl = "", ttl
+	if ttl > 0 {
+		responseCache.dir = dir
+	}
+	return nil
+}
+
+func cachePath(dir, source, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, source, hex.EncodeToString(sum[:]))
+}
+
+// 有效期内的缓存直接返回，否则调用 fetch 并在成功时写入缓存
+func cachedFetch(ctx context.Context, source, key string, fetch func() ([]byte, error)) ([]byte, error) {
+	responseCache.Lock()
+	dir, ttl := responseCache.dir, responseCache.ttl
+	responseCache.Unlock()
+	if dir == "" {
+		return fetch()
+	}
+
+	path := cachePath(dir, source, key)
+	if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) < ttl {
+		if data, err := os.ReadFile(path); err == nil {
+			return data, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	// 先写临时文件再改名，中途崩溃不会留下半截缓存
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return nil, err
+	}
+	return data, os.Rename(tmp, path)
+}
+
+// 响应内容表明请求失败 (如接口返回错误码) 时删除缓存，下次重新请求
+func cacheDrop(source, key string) {
+	responseCache.Lock()
+	defer responseCache.Unlock()
+	if responseCache.dir != "" {
+		os.Remove(cachePath(responseCache.dir, source, key))
+	}
+}
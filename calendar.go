@@ -0,0 +1,68 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// 交易日历：以 stock_history 中该市场出现过的日期为准，market 为空时取所有市场的并集
+func tradingDates(db *sql.DB, market string) []string {
+	rows, err := db.Query("SELECT DISTINCT date FROM stock_history WHERE ? = '' OR market = ? ORDER BY date", market, market)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var dates []string
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			log.Fatal(err)
+		}
+		dates = append(dates, d)
+	}
+	return dates
+}
+
+// 节前/节后标记：相邻两个交易日之间如果有工作日 (周一至周五) 不开市，视为节假日休市。
+// pre[k] 表示 dates[k] 是节前最后一个交易日，post[k] 表示 dates[k] 是节后第一个交易日。
+func holidayFlags(dates []string) (pre, post []bool) {
+	pre = make([]bool, len(dates))
+	post = make([]bool, len(dates))
+	for k := 0; k+1 < len(dates); k++ {
+		a, err1 := time.Parse(time.DateOnly, dates[k])
+		b, err2 := time.Parse(time.DateOnly, dates[k+1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		for d := a.AddDate(0, 0, 1); d.Before(b); d = d.AddDate(0, 0, 1) {
+			if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+				pre[k], post[k+1] = true, true
+				break
+			}
+		}
+	}
+	return pre, post
+}
+
+// 月内交易日序号：正数为月初第 N 个交易日 (1 开始)，负数为月末倒数第 N 个 (-1 为月末最后一天)
+func monthOffsets(dates []string) (fromStart, fromEnd []int) {
+	fromStart = make([]int, len(dates))
+	fromEnd = make([]int, len(dates))
+	for k := range dates {
+		if k > 0 && dates[k][:7] == dates[k-1][:7] {
+			fromStart[k] = fromStart[k-1] + 1
+		} else {
+			fromStart[k] = 1
+		}
+	}
+	for k := len(dates) - 1; k >= 0; k-- {
+		if k+1 < len(dates) && dates[k][:7] == dates[k+1][:7] {
+			fromEnd[k] = fromEnd[k+1] - 1
+		} else {
+			fromEnd[k] = -1
+		}
+	}
+	return fromStart, fromEnd
+}
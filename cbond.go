@@ -0,0 +1,50 @@
+package main
+
+// 可转债日行情。转股溢价率、到期收益率为百分数 (23.5 = 23.5%)，与主流供应商一致。
+// 正股通过 underlying 与 stock_history.symbol 关联，见视图 v_cb_arbitrage。
+//
+// 索引：0:转债代码, 1:日期, 2:正股代码, 3:开盘, 4:最高, 5:最低, 6:收盘, 7:成交量,
+// 8:转股价, 9:转股价值, 10:转股溢价率, 11:到期收益率 (9 之后可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "可转债日线",
+		Pattern: PathConvertibleBonds,
+		Table:   "cb_daily",
+		DDL: `CREATE TABLE IF NOT EXISTS cb_daily (
+			bond_code     TEXT NOT NULL,
+			date          TEXT NOT NULL,
+			underlying    TEXT,
+			open          REAL,
+			high          REAL,
+			low           REAL,
+			close         REAL,
+			volume        REAL,
+			conv_price    REAL,
+			conv_value    REAL,
+			conv_premium  REAL,
+			ytm           REAL,
+			PRIMARY KEY (bond_code, date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 9,
+		Mapper: func(record []string) []any {
+			date := normDate(record[1])
+			if date == nil {
+				return nil
+			}
+			return []any{
+				normText(record[0]),
+				date,
+				normText(record[2]),
+				normNum(record[3]),
+				normNum(record[4]),
+				normNum(record[5]),
+				normNum(record[6]),
+				normNum(record[7]),
+				normNum(record[8]),
+				normNum(col(record, 9)),
+				normNum(col(record, 10)),
+				normNum(col(record, 11)),
+			}
+		},
+	})
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 数据源优先级与回退链
+// ---------------------------------------------------------
+// 日线可以由一串按优先级排列的数据源提供：先用第一个源拉取，它失败或缺了的 (股票, 日期) 再交给下一个源，
+// 依此类推。链在 sources.yaml 中配置，也可以用 --chain 临时指定：
+//
+//	chains:
+//	  daily: [tushare, eastmoney, baostock]
+//	  daily_hk: [yahoo]
+//
+// 链上的数据源只补库中没有的行，不覆盖已有数据；每一行来自哪个源记入 history_provenance。
+// 没有来源记录的行来自本地文件导入 (import / vendor / tdx 等)。backfill 也走同一套逻辑。
+
+func ensureProvenanceTable(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS history_provenance (
+		symbol      TEXT NOT NULL,
+		date        TEXT NOT NULL,
+		source      TEXT NOT NULL,
+		fetched_at  TEXT NOT NULL,
+		PRIMARY KEY (symbol, date)
+	) WITHOUT ROWID, STRICT;`)
+	mustExec(db, `CREATE TABLE IF NOT EXISTS staging_symbols (symbol TEXT NOT NULL PRIMARY KEY) WITHOUT ROWID;`)
+}
+
+// 各数据源把缺口范围内的日线写入 staging 表，由 mergeFetched 过滤后合并
+var historySources = map[string]func(ctx context.Context, db *sql.DB, config string, gaps []historyGap) error{
+	"eastmoney": func(ctx context.Context, db *sql.DB, config string, gaps []historyGap) error {
+		return stageGaps(db, gaps, func(tx *sql.Tx, g historyGap) error {
+			_, err := stageEastmoneyStock(ctx, tx, g.Symbol, strings.ReplaceAll(g.From, "-", ""), strings.ReplaceAll(g.To, "-", ""))
+			return err
+		})
+	},
+	"baostock": func(ctx context.Context, db *sql.DB, config string, gaps []historyGap) error {
+		client, err := dialBaostock(ctx, config)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		return stageGaps(db, gaps, func(tx *sql.Tx, g historyGap) error {
+			_, err := client.stageStock(tx, g.Symbol, g.From, g.To)
+			return err
+		})
+	},
+	"tushare": func(ctx context.Context, db *sql.DB, config string, gaps []historyGap) error {
+		client, err := newTushareClient(config)
+		if err != nil {
+			return err
+		}
+		// Tushare 按交易日拉全市场，同一天的多个缺口只拉一次
+		days := map[string]bool{}
+		for _, g := range gaps {
+			dates, err := client.tradeDates(ctx, strings.ReplaceAll(g.From, "-", ""), strings.ReplaceAll(g.To, "-", ""))
+			if err != nil {
+				return err
+			}
+			for _, d := range dates {
+				days[d] = true
+			}
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for d := range days {
+			if _, err := client.stageDay(ctx, tx, d); err != nil {
+				return fmt.Errorf("%s: %v", d, err)
+			}
+		}
+		return tx.Commit()
+	},
+	"yahoo": func(ctx context.Context, db *sql.DB, config string, gaps []historyGap) error {
+		return stageGaps(db, gaps, func(tx *sql.Tx, g historyGap) error {
+			from, _ := time.Parse(time.DateOnly, g.From)
+			to, _ := time.Parse(time.DateOnly, g.To)
+			rows, err := yahooDaily(ctx, g.Symbol, from, to.AddDate(0, 0, 1))
+			if err != nil {
+				return err
+			}
+			for _, r := range rows {
+				date := strings.ReplaceAll(r[1].(string), "-", "")
+				if _, err := tx.Exec("INSERT INTO staging_tech VALUES (?, ?, ?, ?, ?, ?, ?, ?)", r[0], date, r[2], r[3], r[4], r[5], r[6], r[8]); err != nil {
+					return err
+				}
+				if _, err := tx.Exec("INSERT INTO staging_daily VALUES (?, ?, NULL)", r[0], date); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	},
+	"mock": stageMock,
+}
+
+func stageGaps(db *sql.DB, gaps []historyGap, stage func(tx *sql.Tx, g historyGap) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, g := range gaps {
+		if err := stage(tx, g); err != nil {
+			return fmt.Errorf("%s: %v", g.Symbol, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// 只合并库中还没有的、且属于本次股票范围 (staging_symbols) 的行，并记录来源；返回新增行数
+func mergeFetched(db *sql.DB, source, fetchedAt string) (int, error) {
+	// 分钟线聚合而来的行不算已有 (见 intraday.go)
+	for _, t := range []string{"staging_tech", "staging_daily"} {
+		mustExec(db, fmt.Sprintf(`DELETE FROM %[1]s WHERE symbol NOT IN (SELECT symbol FROM staging_symbols)
+			OR EXISTS (SELECT 1 FROM stock_history h WHERE h.symbol = %[1]s.symbol
+				AND h.date = substr(%[1]s.date, 1, 4) || '-' || substr(%[1]s.date, 5, 2) || '-' || substr(%[1]s.date, 7, 2)
+				AND %[2]s)`, t, notMinuteRowSQL))
+	}
+	res, err := db.Exec(`INSERT OR REPLACE INTO history_provenance
+		SELECT DISTINCT t.symbol, substr(t.date, 1, 4) || '-' || substr(t.date, 5, 2) || '-' || substr(t.date, 7, 2), ?, ?
+		FROM staging_tech t JOIN staging_daily d ON d.symbol = t.symbol AND d.date = t.date`, source, fetchedAt)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	mergeStaging(db)
+	return int(n), nil
+}
+
+// 拉取之后仍缺的部分：某只股票在区间内一行都没有时整段保留；否则在它已有的最后一天之前按该市场已有的交易日
+// 找出缺的日期，最后一天之后 (数据源还没更新到的尾部) 只要还有工作日也整段保留
+func remainingGaps(db *sql.DB, market string, gaps []historyGap) []historyGap {
+	var out []historyGap
+	for _, g := range gaps {
+		var last sql.NullString
+		db.QueryRow("SELECT max(date) FROM stock_history WHERE symbol = ? AND date BETWEEN ? AND ?", g.Symbol, g.From, g.To).Scan(&last)
+		if !last.Valid {
+			out = append(out, g)
+			continue
+		}
+		rows, err := db.Query(`SELECT c.date, EXISTS (SELECT 1 FROM stock_history h WHERE h.symbol = ?1 AND h.date = c.date)
+			FROM (SELECT DISTINCT date FROM stock_history WHERE market = ?2 AND date BETWEEN ?3 AND ?4) c ORDER BY c.date`,
+			g.Symbol, market, g.From, last.String)
+		if err != nil {
+			log.Fatal(err)
+		}
+		open := false
+		for rows.Next() {
+			var date string
+			var present bool
+			if err := rows.Scan(&date, &present); err != nil {
+				log.Fatal(err)
+			}
+			switch {
+			case present:
+				open = false
+			case open:
+				out[len(out)-1].To = date
+				out[len(out)-1].Days++
+			default:
+				out = append(out, historyGap{Symbol: g.Symbol, From: date, To: date, Days: 1})
+				open = true
+			}
+		}
+		rows.Close()
+
+		t, _ := time.Parse(time.DateOnly, last.String)
+		end, _ := time.Parse(time.DateOnly, g.To)
+		tail := historyGap{Symbol: g.Symbol, From: t.AddDate(0, 0, 1).Format(time.DateOnly), To: g.To}
+		for d := t.AddDate(0, 0, 1); !d.After(end); d = d.AddDate(0, 0, 1) {
+			if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+				tail.Days++
+			}
+		}
+		if tail.Days > 0 {
+			out = append(out, tail)
+		}
+	}
+	return out
+}
+
+// 按链依次拉取，返回各数据源新增的行数与最终仍缺的部分。单个数据源出错只记警告，缺口交给下一个源
+func fillChain(ctx context.Context, db *sql.DB, config, market string, chain []string, gaps []historyGap) (map[string]int, []historyGap) {
+	createTables(db)
+	ensureProvenanceTable(db)
+	mustExec(db, "DELETE FROM staging_symbols;")
+	for _, g := range gaps {
+		if _, err := db.Exec("INSERT OR IGNORE INTO staging_symbols VALUES (?)", g.Symbol); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	filled := map[string]int{}
+	fetchedAt := time.Now().Format(time.DateTime)
+	for _, name := range chain {
+		if len(gaps) == 0 {
+			break
+		}
+		log.Printf(">>> [%s] 拉取 %d 段...", name, len(gaps))
+		if err := historySources[name](ctx, db, config, gaps); err != nil {
+			log.Printf("[WARN] %s 拉取失败，交给下一个数据源: %v", name, err)
+			mustExec(db, "DELETE FROM staging_tech;")
+			mustExec(db, "DELETE FROM staging_daily;")
+			continue
+		}
+		n, err := mergeFetched(db, name, fetchedAt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		filled[name] += n
+		gaps = remainingGaps(db, market, gaps)
+	}
+	mustExec(db, "DROP TABLE IF EXISTS staging_symbols;")
+	mustExec(db, "DROP TABLE IF EXISTS staging_tech;")
+	mustExec(db, "DROP TABLE IF EXISTS staging_daily;")
+	return filled, gaps
+}
+
+// 统一为库中的代码写法 (600000 -> 600000.SH，AAPL -> AAPL.US)，与 staging 表中一致
+func canonicalSymbol(s string) string {
+	if _, c, err := emSecID(s); err == nil {
+		return c
+	}
+	if _, c, err := yahooTicker(s); err == nil {
+		return c
+	}
+	return strings.ToUpper(strings.TrimSpace(s))
+}
+
+// 解析链：arg 不为空时优先 (逗号分隔)，否则取 sources.yaml 中 chains.<name>
+func loadChain(configPath, name, arg string) ([]string, error) {
+	chain := splitList(arg)
+	if len(chain) == 0 {
+		cfg, err := loadSourceConfig(configPath, "chains")
+		if err != nil {
+			return nil, err
+		}
+		chain = yamlStrings(cfg, name)
+	}
+	for _, s := range chain {
+		if _, ok := historySources[s]; !ok {
+			return nil, fmt.Errorf("未知数据源: %s", s)
+		}
+	}
+	return chain, nil
+}
+
+// chronos fetch [--chain eastmoney,baostock] [--market CN] [--symbols ...] [--from 2024-01-01] [--to 2024-12-31]
+func runFetch(args []string) {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件")
+	chainArg := fs.String("chain", "", "逗号分隔的数据源链，默认取 sources.yaml 中 chains.daily (港股/美股为 chains.daily_hk / daily_us)")
+	market := fs.String("market", MarketCN, "市场: CN / HK / US")
+	symbolsArg := fs.String("symbols", "", "代码列表文件或逗号分隔的代码 (默认库中该市场全部代码)")
+	from := fs.String("from", "", "起始日期 (YYYY-MM-DD)，默认每只股票从库中最后一天之后续拉")
+	to := fs.String("to", time.Now().Format(time.DateOnly), "结束日期 (YYYY-MM-DD，含)")
+	fs.Parse(args)
+
+	chainName := "daily"
+	if *market != MarketCN {
+		chainName += "_" + strings.ToLower(*market)
+	}
+	chain, err := loadChain(*config, chainName, *chainArg)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if len(chain) == 0 {
+		log.Fatalf("[ERROR] 没有配置数据源链 (sources.yaml 中 chains.%s，或 --chain)", chainName)
+	}
+
+	start := time.Now()
+	db := openOrCreateDB()
+	defer db.Close()
+	if err := setupSources(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	var symbols []string
+	if *symbolsArg != "" {
+		if symbols, err = parseSymbols(*symbolsArg); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		symbols = marketSymbols(db, *market)
+	}
+	var gaps []historyGap
+	for _, s := range symbols {
+		s = canonicalSymbol(s)
+		begin := *from
+		if begin == "" {
+			begin = "1990-12-19"
+			if next := nextFetchDate(db, "SELECT MAX(date) FROM stock_history WHERE symbol = ?", s, ""); next != "" {
+				begin = normDate(next).(string)
+			}
+		}
+		if begin <= *to {
+			gaps = append(gaps, historyGap{Symbol: s, From: begin, To: *to})
+		}
+	}
+	if len(gaps) == 0 {
+		log.Fatal("[ERROR] 没有要拉取的代码 (请指定 --symbols)")
+	}
+
+	filled, missing := fillChain(context.Background(), db, *config, *market, chain, gaps)
+	total := 0
+	for _, name := range chain {
+		fmt.Printf("%-10s %8d 行\n", name, filled[name])
+		total += filled[name]
+	}
+	if len(missing) > 0 {
+		log.Printf("[WARN] 仍有 %d 段没有任何数据源提供 (chronos backfill --dry-run 查看)", len(missing))
+	}
+	createViews(db)
+	logAPIUsage()
+	log.Printf(">>> ✅ 拉取完成: %d 行, 耗时: %s", total, time.Since(start))
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// ---------------------------------------------------------
+// 断点续传
+// ---------------------------------------------------------
+// importCSVFiles 默认把一次导入的全部文件放在一个事务里，几 GB 的单个文件导入到一半崩溃，
+// 下次只能从头读起。匹配的文件中有超过 import.big_file 的大文件时改为分段提交：每写入
+// import.checkpoint_rows 行 (默认 500 万) 提交一次，并在同一事务中把进度记进 import_checkpoints：
+//
+//	当前文件   已提交部分之后的字节位置与行数
+//	已完成的文件
+//
+// 再次导入同一目标表时，已完成的文件直接跳过，未完成的文件读过表头后跳到记录的位置继续，
+// 只需重读未提交的尾部。全部文件导入成功后删除该表的记录。文件的大小或修改时间变化时不续传，从头导入。
+// 分段提交后表头漂移 (drift.go) 只能回滚最后一段，修正布局后再次导入即从断点继续。
+//
+// 写入已有库的导入 (update files、vendor 等) 直接可以续传；全量导入 (import) 开始时会删除旧库，
+// 需要加 --resume 保留上次中断的库，staging 表全部导入完成后才清除这两张表的记录。
+
+var (
+	// 每段提交的行数，0 表示不分段 (sources.yaml 的 import.checkpoint_rows)
+	checkpointRows = 5000000
+	// 导入成功后保留完成状态 (全量导入在 staging 表全部完成后统一清除)
+	keepCheckpoints = false
+)
+
+func createCheckpointTable(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS import_checkpoints (
+		target      TEXT NOT NULL,      -- 目标表
+		file        TEXT NOT NULL,
+		size        INTEGER NOT NULL,
+		mtime       INTEGER NOT NULL,   -- 修改时间 (Unix 纳秒)，与 size 一起判断文件是否变化
+		byte_offset INTEGER NOT NULL,   -- 已提交部分之后的位置
+		rows        INTEGER NOT NULL,   -- 已提交的行数
+		done        INTEGER NOT NULL,
+		PRIMARY KEY (target, file)
+	) WITHOUT ROWID, STRICT;`)
+}
+
+type fileCheckpoint struct {
+	size, mtime, offset int64
+	rows                int
+	done                bool
+}
+
+func statCheckpoint(file string) (fileCheckpoint, error) {
+	st, err := os.Stat(file)
+	if err != nil {
+		return fileCheckpoint{}, err
+	}
+	return fileCheckpoint{size: st.Size(), mtime: st.ModTime().UnixNano()}, nil
+}
+
+// 一次 importCSVFiles 的进度。save 由调用方提供：在当前事务中执行 write 后提交并开始新事务。
+// 没有大文件、也没有上次的记录时不启用 (active 为 false)，各方法什么都不做，整个导入仍是一个事务
+type importCheckpoints struct {
+	target  string
+	active  bool
+	saved   map[string]fileCheckpoint // 上次中断时的记录
+	prior   map[string]int            // 本次沿用上次结果的文件已提交的行数
+	files   map[string]fileCheckpoint // 本次的进度 (未提交的部分)
+	dirty   []string
+	pending int
+	save    func(write func(tx *sql.Tx) error) error
+}
+
+// 读入 target 上次中断时的记录；不允许分段时返回 nil
+func newImportCheckpoints(db *sql.DB, target string) (*importCheckpoints, error) {
+	if checkpointRows <= 0 {
+		return nil, nil
+	}
+	createCheckpointTable(db)
+	ck := &importCheckpoints{target: target, saved: map[string]fileCheckpoint{}, prior: map[string]int{}, files: map[string]fileCheckpoint{}}
+	rows, err := db.Query("SELECT file, size, mtime, byte_offset, rows, done FROM import_checkpoints WHERE target = ?", target)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var file string
+		var c fileCheckpoint
+		if err := rows.Scan(&file, &c.size, &c.mtime, &c.offset, &c.rows, &c.done); err != nil {
+			return nil, err
+		}
+		ck.saved[file] = c
+	}
+	return ck, rows.Err()
+}
+
+// 按上次的记录筛选：返回仍需导入的文件与各文件开始的字节位置
+func (ck *importCheckpoints) resume(files []string) ([]string, map[string]int64) {
+	if ck == nil {
+		return files, nil
+	}
+	ck.active = keepCheckpoints || len(ck.saved) > 0
+	for _, f := range files {
+		if st, err := os.Stat(f); err == nil && st.Size() >= importOptions.bigFile {
+			ck.active = true
+			break
+		}
+	}
+	if !ck.active {
+		return files, nil
+	}
+	var todo []string
+	starts := map[string]int64{}
+	skipped := 0
+	for _, f := range files {
+		cur, err := statCheckpoint(f)
+		if err != nil {
+			todo = append(todo, f)
+			continue
+		}
+		saved, ok := ck.saved[filepath.Clean(f)]
+		switch {
+		case !ok:
+		case saved.size != cur.size || saved.mtime != cur.mtime:
+			log.Printf("[WARN] %s 在上次中断后发生了变化，从头导入 (之前已提交的 %d 行可能重复)", f, saved.rows)
+		case saved.done:
+			ck.prior[f] = saved.rows
+			skipped++
+			continue
+		default:
+			log.Printf(">>> %s 从第 %d 字节继续 (已提交 %d 行)", f, saved.offset, saved.rows)
+			ck.prior[f] = saved.rows
+			starts[f] = saved.offset
+			cur.offset, cur.rows = saved.offset, saved.rows
+		}
+		ck.files[filepath.Clean(f)] = cur
+		todo = append(todo, f)
+	}
+	if skipped > 0 {
+		log.Printf(">>> 跳过上次已完成的 %d 个文件", skipped)
+	}
+	return todo, starts
+}
+
+// 文件的 rows 行已写入 (尚未提交)，读到第 end 字节；累计满 checkpointRows 行时提交
+func (ck *importCheckpoints) progress(file string, end int64, rows int) error {
+	if ck == nil || !ck.active {
+		return nil
+	}
+	file = filepath.Clean(file)
+	c := ck.files[file]
+	c.offset, c.rows = end, c.rows+rows
+	ck.files[file] = c
+	ck.touch(file)
+	ck.pending += rows
+	if ck.pending < checkpointRows {
+		return nil
+	}
+	return ck.commit()
+}
+
+// 上次已提交的行数 (表头漂移检查按文件统计写入的行时计入)
+func (ck *importCheckpoints) previous(file string) int {
+	if ck == nil {
+		return 0
+	}
+	return ck.prior[file]
+}
+
+// 文件已全部写入
+func (ck *importCheckpoints) finish(file string) {
+	if ck == nil || !ck.active {
+		return
+	}
+	file = filepath.Clean(file)
+	c := ck.files[file]
+	c.done = true
+	ck.files[file] = c
+	ck.touch(file)
+}
+
+func (ck *importCheckpoints) touch(file string) {
+	if len(ck.dirty) == 0 || ck.dirty[len(ck.dirty)-1] != file {
+		ck.dirty = append(ck.dirty, file)
+	}
+}
+
+// 在 tx 中写入有变化的进度
+func (ck *importCheckpoints) write(tx *sql.Tx) error {
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO import_checkpoints VALUES (?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, f := range ck.dirty {
+		c := ck.files[f]
+		if _, err := stmt.Exec(ck.target, f, c.size, c.mtime, c.offset, c.rows, c.done); err != nil {
+			return err
+		}
+	}
+	ck.dirty = ck.dirty[:0]
+	return nil
+}
+
+// 把进度写进当前事务并提交
+func (ck *importCheckpoints) commit() error {
+	if err := ck.save(ck.write); err != nil {
+		return fmt.Errorf("保存导入进度: %v", err)
+	}
+	ck.pending = 0
+	return nil
+}
+
+// 导入成功：在最后的事务中删除该表的记录 (keepCheckpoints 时改为写入完成状态)
+func (ck *importCheckpoints) complete(tx *sql.Tx) error {
+	if ck == nil || !ck.active {
+		return nil
+	}
+	if keepCheckpoints {
+		return ck.write(tx)
+	}
+	_, err := tx.Exec("DELETE FROM import_checkpoints WHERE target = ?", ck.target)
+	return err
+}
+
+// 清除 staging 表的记录 (全量导入的 staging 表都已完成)。之后导入的附加数据集等目标的断点
+// 可能是上次中断时留下的，要留到 --resume 续传，各目标导入成功时由 complete 自行删除
+func clearStagingCheckpoints(db *sql.DB) {
+	createCheckpointTable(db)
+	mustExec(db, "DELETE FROM import_checkpoints WHERE target IN ('staging_tech', 'staging_daily');")
+}
+
+// 全量导入的库是否还在且有未完成的导入记录
+func resumableImport() bool {
+	if _, err := os.Stat(DBPath); err != nil {
+		return false
+	}
+	db, err := sql.Open(sqliteDriver, DBPath)
+	if err != nil {
+		return false
+	}
+	defer db.Close()
+	var n int
+	db.QueryRow("SELECT count(*) FROM import_checkpoints").Scan(&n)
+	return n > 0
+}
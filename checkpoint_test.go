@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+// staging 表合并完成后只清除这两张表的断点，之后才导入的目标 (上次中断留下的) 仍可续传
+func TestClearStagingCheckpoints(t *testing.T) {
+	db := openTestDB(t, "checkpoints.db")
+	createCheckpointTable(db)
+	for _, target := range []string{"staging_tech", "staging_daily", "index_daily"} {
+		mustExec(db, "INSERT INTO import_checkpoints VALUES (?, 'big.csv', 100, 1, 40, 3, 0)", target)
+	}
+	clearStagingCheckpoints(db)
+	if got, want := dumpTable(t, db, "SELECT target, byte_offset, rows FROM import_checkpoints"), "index_daily|40|3\n"; got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
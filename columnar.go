@@ -0,0 +1,105 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+)
+
+// ---------------------------------------------------------
+// 按列缓存的待写入行
+// ---------------------------------------------------------
+// stock_history 是以 (代码, 日期) 为主键的 WITHOUT ROWID 表，行按主键顺序存放在 B 树中。
+// 流式合并按文件顺序逐行写入，文件按日期切分 (每个文件含全市场一天) 时每一行都落在 B 树的不同位置，
+// 页面反复被读入、拆分、写回。这里先把行按列攒在内存中 (每列一个 []string 加空值标记，
+// 不为每个值单独装箱)，攒满 columnFlushRows 行后按主键排序再整块写入，相邻的行落在相邻的页上。
+//
+// 排序是稳定的：同一 (代码, 日期) 出现多次时仍按原顺序写入，INSERT OR REPLACE 的结果与逐行写入相同。
+
+// 每块的行数 (设置内存预算时按预算计算，见 memory.go)
+var columnFlushRows = 1 << 18
+
+type columnBuffer struct {
+	cols  [][]string
+	nulls [][]bool
+	other map[int]any // 不是 string / nil 的值 (按 行*列数+列 索引)，很少出现
+	rows  int
+	order []int32
+	row   []any
+	write func(row []any)
+}
+
+// 行的前两列为代码、日期；write 按主键顺序收到每一行 (切片会被复用)
+func newColumnBuffer(width int, write func(row []any)) *columnBuffer {
+	c := &columnBuffer{
+		cols:  make([][]string, width),
+		nulls: make([][]bool, width),
+		row:   make([]any, width),
+		write: write,
+	}
+	for k := range width {
+		c.cols[k] = make([]string, 0, columnFlushRows)
+		c.nulls[k] = make([]bool, 0, columnFlushRows)
+	}
+	return c
+}
+
+func (c *columnBuffer) add(row []any) {
+	for k := range c.cols {
+		var s string
+		null := false
+		switch v := row[k].(type) {
+		case string:
+			s = v
+		case nil:
+			null = true
+		default:
+			if c.other == nil {
+				c.other = map[int]any{}
+			}
+			c.other[c.rows*len(c.cols)+k] = v
+		}
+		c.cols[k] = append(c.cols[k], s)
+		c.nulls[k] = append(c.nulls[k], null)
+	}
+	c.rows++
+	if c.rows >= columnFlushRows {
+		c.flush()
+	}
+}
+
+// 按主键排序后写出缓存的行并清空
+func (c *columnBuffer) flush() {
+	if c.rows == 0 {
+		return
+	}
+	symbols, dates := c.cols[0], c.cols[1]
+	c.order = c.order[:0]
+	for i := range c.rows {
+		c.order = append(c.order, int32(i))
+	}
+	slices.SortStableFunc(c.order, func(a, b int32) int {
+		if r := cmp.Compare(symbols[a], symbols[b]); r != 0 {
+			return r
+		}
+		return cmp.Compare(dates[a], dates[b])
+	})
+	for _, i := range c.order {
+		for k := range c.cols {
+			switch {
+			case c.nulls[k][i]:
+				c.row[k] = nil
+			case c.other != nil && c.other[int(i)*len(c.cols)+k] != nil:
+				c.row[k] = c.other[int(i)*len(c.cols)+k]
+			default:
+				c.row[k] = c.cols[k][i]
+			}
+		}
+		c.write(c.row)
+	}
+	for k := range c.cols {
+		clear(c.cols[k]) // 不再引用已写出的字符串
+		c.cols[k], c.nulls[k] = c.cols[k][:0], c.nulls[k][:0]
+	}
+	c.other = nil
+	c.rows = 0
+}
@@ -0,0 +1,59 @@
+package main
+
+// 沪深港通个股资金流向：北向 (外资持有 A 股) 与南向 (内地资金持有港股) 分别是两套文件，
+// 共用一张表，用 direction 区分。持股比例为百分数 (占流通股)。
+// 常用作情绪因子，例如在 factors.yaml 里：
+//
+//	sql: SELECT symbol, date, hold_ratio - LAG(hold_ratio, 20) OVER (PARTITION BY symbol ORDER BY date) AS value
+//	     FROM connect_holdings WHERE direction = 'N'
+//
+// 索引：0:代码, 1:日期, 2:持股数量, 3:持股比例, 4:持股市值, 5:当日净买入额 (4 之后可缺省)
+func init() {
+	for _, src := range []struct {
+		name, pattern, direction string
+	}{
+		{"北向资金持股", PathNorthbound, "N"},
+		{"南向资金持股", PathSouthbound, "S"},
+	} {
+		direction := src.direction
+		registerDataset(dataset{
+			Name:    src.name,
+			Pattern: src.pattern,
+			Table:   "connect_holdings",
+			DDL: `CREATE TABLE IF NOT EXISTS connect_holdings (
+				symbol      TEXT NOT NULL,
+				date        TEXT NOT NULL,
+				direction   TEXT NOT NULL,      -- N 北向 / S 南向
+				hold_shares REAL,
+				hold_ratio  REAL,
+				hold_value  REAL,
+				net_buy     REAL,
+				PRIMARY KEY (symbol, date, direction)
+			) WITHOUT ROWID, STRICT;`,
+			MinCols: 4,
+			Mapper: func(record []string) []any {
+				date := normDate(record[1])
+				if date == nil {
+					return nil
+				}
+				symbol := normText(record[0])
+				if direction == "S" {
+					// 南向标的是港股，代码统一成 00700.HK 以便与港股日线关联
+					symbol = normHKSymbol(record[0])
+				}
+				if symbol == nil {
+					return nil
+				}
+				return []any{
+					symbol,
+					date,
+					direction,
+					normNum(record[2]),
+					normNum(record[3]),
+					normNum(col(record, 4)),
+					normNum(col(record, 5)),
+				}
+			},
+		})
+	}
+}
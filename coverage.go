@@ -0,0 +1,294 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 覆盖率热力图 (股票 × 月份)
+// ---------------------------------------------------------
+// 供应商历史的断档 (如小盘股 2005~2007 年整段缺失) 往往要等回测结果异常才发现。chronos coverage
+// 按月统计每只股票的行数占应有交易日的比例，写成矩阵 CSV 与 PNG 热力图，断档一眼可见：
+//
+//	chronos coverage --market CN --out coverage.csv --png coverage.png
+//
+// 应有交易日为所在市场的交易日 (tradingDates) 中落在该股票上市期间的天数。上市期间从 ipo_info 的上市日期、
+// stock_basic 的 list_date 与库中首个交易日三者中最早的算起 (供应商缺了上市初期的历史也算缺口)，
+// 到 stock_basic 的退市日期 (没有时为库中最后一个交易日) 为止；不在上市期间的格子为空。
+//
+// PNG 每行一只股票 (按上市日期排序，历史起点的 "悬崖" 连成斜线)，每列一个月：绿色为完整，黄色为部分缺失，
+// 红色为整月缺失，白色为不在上市期间；每年 1 月画一条灰色竖线。
+
+// 月份中按覆盖率打印的最差月份数
+const coverageWorstMonths = 10
+
+type coverageMatrix struct {
+	months  []string    // YYYY-MM
+	symbols []string    // 按上市日期、代码排序
+	starts  []string    // 各股票上市期间的起点
+	cells   [][]float64 // [股票][月份]，NaN 为不在上市期间
+}
+
+// 读取 代码 -> 日期 (表不存在时返回空，空的日期跳过)
+func loadSymbolDates(db *sql.DB, table, query string) (map[string]string, error) {
+	out := map[string]string{}
+	var exists int
+	db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&exists)
+	if exists == 0 {
+		return out, nil
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var symbol, date string
+		if err := rows.Scan(&symbol, &date); err != nil {
+			return nil, err
+		}
+		if date != "" {
+			out[symbol] = date
+		}
+	}
+	return out, rows.Err()
+}
+
+// 统计 market 的覆盖率矩阵；from / to 为月份 (YYYY-MM)，为空时不限
+func loadCoverageMatrix(db *sql.DB, market, from, to string) (*coverageMatrix, error) {
+	dates := tradingDates(db, market)
+	if len(dates) == 0 {
+		return nil, fmt.Errorf("%s 没有日线数据", market)
+	}
+	listed, err := loadSymbolDates(db, "ipo_info", "SELECT symbol, listing_date FROM ipo_info")
+	if err != nil {
+		return nil, err
+	}
+	basicList, err := loadSymbolDates(db, "stock_basic", "SELECT symbol, list_date FROM stock_basic WHERE list_date IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	basicDelist, err := loadSymbolDates(db, "stock_basic", "SELECT symbol, delist_date FROM stock_basic WHERE delist_date IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+
+	// 各股票各月的行数
+	type span struct{ start, end string }
+	spans := map[string]*span{}
+	counts := map[string]map[string]int{}
+	rows, err := db.Query("SELECT symbol, substr(date, 1, 7), count(*), min(date), max(date) FROM stock_history WHERE market = ? GROUP BY 1, 2", market)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var symbol, month, first, last string
+		var n int
+		if err := rows.Scan(&symbol, &month, &n, &first, &last); err != nil {
+			return nil, err
+		}
+		if counts[symbol] == nil {
+			counts[symbol] = map[string]int{}
+			spans[symbol] = &span{first, last}
+		}
+		counts[symbol][month] = n
+		s := spans[symbol]
+		s.start, s.end = min(s.start, first), max(s.end, last)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	m := &coverageMatrix{}
+	for _, d := range dates {
+		if month := d[:7]; (from == "" || month >= from) && (to == "" || month <= to) && (len(m.months) == 0 || m.months[len(m.months)-1] != month) {
+			m.months = append(m.months, month)
+		}
+	}
+	for symbol, s := range spans {
+		for _, d := range []string{listed[symbol], basicList[symbol]} {
+			if d != "" {
+				s.start = min(s.start, d)
+			}
+		}
+		if d := basicDelist[symbol]; d != "" {
+			s.end = d
+		}
+		m.symbols = append(m.symbols, symbol)
+	}
+	sort.Slice(m.symbols, func(i, j int) bool {
+		a, b := spans[m.symbols[i]], spans[m.symbols[j]]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		return m.symbols[i] < m.symbols[j]
+	})
+	// 区间 [lo, hi] 内的交易日数 (lo > hi 时为 0)
+	tradingDays := func(lo, hi string) int {
+		return max(sort.SearchStrings(dates, hi+"\x00")-sort.SearchStrings(dates, lo), 0)
+	}
+	for _, symbol := range m.symbols {
+		s := spans[symbol]
+		m.starts = append(m.starts, s.start)
+		row := make([]float64, len(m.months))
+		for k, month := range m.months {
+			expected := tradingDays(max(month+"-01", s.start), min(month+"-31", s.end))
+			if expected == 0 {
+				row[k] = math.NaN()
+				continue
+			}
+			row[k] = min(float64(counts[symbol][month])/float64(expected), 1)
+		}
+		m.cells = append(m.cells, row)
+	}
+	return m, nil
+}
+
+// 行为股票 (代码、上市期间起点)，列为月份，值为覆盖率 (0~1，不在上市期间为空)
+func (m *coverageMatrix) writeCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write(append([]string{"symbol", "start"}, m.months...))
+	for i, row := range m.cells {
+		record := []string{m.symbols[i], m.starts[i]}
+		for _, v := range row {
+			record = append(record, formatFloat(math.Round(v*1e4)/1e4))
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// 热力图的颜色：0 红、0.5 黄、1 绿，NaN 白
+func coverageColor(v float64) color.RGBA {
+	if math.IsNaN(v) {
+		return color.RGBA{255, 255, 255, 255}
+	}
+	red, yellow, green := [3]float64{215, 48, 39}, [3]float64{254, 224, 139}, [3]float64{26, 152, 80}
+	from, to, t := red, yellow, v*2
+	if v > 0.5 {
+		from, to, t = yellow, green, v*2-1
+	}
+	var c [3]uint8
+	for k := range c {
+		c[k] = uint8(math.Round(from[k] + (to[k]-from[k])*t))
+	}
+	return color.RGBA{c[0], c[1], c[2], 255}
+}
+
+// 每格 cellW × cellH 像素
+func (m *coverageMatrix) writePNG(path string, cellW, cellH int) error {
+	img := image.NewRGBA(image.Rect(0, 0, max(len(m.months)*cellW, 1), max(len(m.symbols)*cellH, 1)))
+	grid := color.RGBA{160, 160, 160, 255}
+	for i, row := range m.cells {
+		for k, v := range row {
+			c := coverageColor(v)
+			for y := i * cellH; y < (i+1)*cellH; y++ {
+				for x := k * cellW; x < (k+1)*cellW; x++ {
+					img.SetRGBA(x, y, c)
+				}
+			}
+		}
+	}
+	for k, month := range m.months {
+		if k > 0 && month[5:] == "01" {
+			for y := range img.Rect.Dy() {
+				img.SetRGBA(k*cellW, y, grid)
+			}
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+// 各月份的平均覆盖率 (只计在上市期间的股票)，从低到高的前 n 个
+func (m *coverageMatrix) worstMonths(n int) []string {
+	type monthAvg struct {
+		month string
+		avg   float64
+		count int
+	}
+	var avgs []monthAvg
+	for k, month := range m.months {
+		sum, count := 0.0, 0
+		for _, row := range m.cells {
+			if !math.IsNaN(row[k]) {
+				sum += row[k]
+				count++
+			}
+		}
+		if count > 0 {
+			avgs = append(avgs, monthAvg{month, sum / float64(count), count})
+		}
+	}
+	sort.SliceStable(avgs, func(i, j int) bool { return avgs[i].avg < avgs[j].avg })
+	var out []string
+	for _, a := range avgs[:min(n, len(avgs))] {
+		out = append(out, fmt.Sprintf("%s %.1f%% (%d 只)", a.month, 100*a.avg, a.count))
+	}
+	return out
+}
+
+// chronos coverage [--market CN] [--from 2000-01] [--to 2024-12] [--out coverage.csv] [--png coverage.png]
+func runCoverage(args []string) {
+	fs := flag.NewFlagSet("coverage", flag.ExitOnError)
+	market := fs.String("market", MarketCN, "市场: CN | HK | US")
+	from := fs.String("from", "", "起始月份 (YYYY-MM)")
+	to := fs.String("to", "", "截止月份 (YYYY-MM)")
+	out := fs.String("out", "coverage.csv", "输出的覆盖率矩阵 CSV (行为股票，列为月份)")
+	pngOut := fs.String("png", "coverage.png", "输出的 PNG 热力图，为空则不生成")
+	cellW := fs.Int("cell-width", 4, "热力图每个月的宽度 (像素)")
+	cellH := fs.Int("cell-height", 2, "热力图每只股票的高度 (像素)")
+	fs.Parse(args)
+	for _, m := range []string{*from, *to} {
+		if _, err := time.Parse("2006-01", m); m != "" && err != nil {
+			log.Fatalf("[ERROR] 月份格式错误 (需要 YYYY-MM): %s", m)
+		}
+	}
+	if *cellW < 1 || *cellH < 1 {
+		log.Fatal("[ERROR] --cell-width / --cell-height 至少为 1")
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+	m, err := loadCoverageMatrix(db, *market, *from, *to)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if err := m.writeCSV(*out); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf(">>> %s: %d 只股票 × %d 个月, 平均覆盖率最低的月份:", *market, len(m.symbols), len(m.months))
+	for _, s := range m.worstMonths(coverageWorstMonths) {
+		log.Printf("    %s", s)
+	}
+	log.Printf(">>> ✅ 覆盖率矩阵已写入 %s, 耗时: %s", *out, time.Since(start))
+	if *pngOut != "" {
+		if err := m.writePNG(*pngOut, *cellW, *cellH); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf(">>> ✅ 热力图已写入 %s", *pngOut)
+	}
+}
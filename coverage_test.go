@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// 上市期间从发行信息中的上市日期算起，缺了的月份为 0；上市之前的月份为空
+func TestCoverageMatrix(t *testing.T) {
+	db := openTestDB(t, "coverage.db")
+	createTables(db)
+	for _, r := range [][2]string{
+		{"A", "2024-01-02"}, {"A", "2024-01-03"}, {"A", "2024-02-01"}, {"A", "2024-03-01"},
+		{"C", "2024-03-01"}, // 上市之前的月份为空
+		{"B", "2024-02-01"}, {"B", "2024-02-02"}, {"B", "2024-03-01"},
+	} {
+		mustExec(db, "INSERT INTO stock_history (symbol, date, market) VALUES (?, ?, 'CN')", r[0], r[1])
+	}
+	// B 上市于 1 月 3 日，供应商的历史从 2 月开始
+	mustExec(db, "CREATE TABLE ipo_info (symbol TEXT PRIMARY KEY, listing_date TEXT NOT NULL)")
+	mustExec(db, "INSERT INTO ipo_info VALUES ('B', '2024-01-03')")
+
+	m, err := loadCoverageMatrix(db, MarketCN, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(m.months, []string{"2024-01", "2024-02", "2024-03"}) || !slices.Equal(m.symbols, []string{"A", "B", "C"}) {
+		t.Fatalf("months %v, symbols %v", m.months, m.symbols)
+	}
+	nan := math.NaN()
+	want := [][]float64{{1, 0.5, 1}, {0, 1, 1}, {nan, nan, 1}}
+	same := func(a, b float64) bool { return a == b || math.IsNaN(a) && math.IsNaN(b) }
+	if !slices.EqualFunc(m.cells, want, func(a, b []float64) bool { return slices.EqualFunc(a, b, same) }) {
+		t.Errorf("cells %v, want %v", m.cells, want)
+	}
+	if m.starts[1] != "2024-01-03" {
+		t.Errorf("B 的起点 %s", m.starts[1])
+	}
+
+	m, _ = loadCoverageMatrix(db, MarketCN, "2024-02", "2024-03")
+	if !slices.Equal(m.months, []string{"2024-02", "2024-03"}) {
+		t.Errorf("--from 2024-02: months %v", m.months)
+	}
+	if c := coverageColor(math.NaN()); c.R != 255 || c.G != 255 || c.B != 255 {
+		t.Errorf("NaN 的颜色 %v", c)
+	}
+}
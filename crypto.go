@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 数字货币 K 线：从交易所公开 REST 接口拉取，写入 crypto_bars。
+// 字段与 stock_minute 保持一致 (freq 为分钟数，日线 = 1440；ts 为 UTC 秒级时间戳，K 线结束时刻；tz 为时区名)，
+// 另加 exchange 与 asset_class (spot 现货 / perp 永续)。交易所 7x24 交易没有“本地时间”，tz 恒为 UTC。
+// 日线可用 export --crypto 按股票日线的格式导出 (见 Store.CryptoHistory)。
+// 请求走各交易所的限速器与响应缓存 (见 ratelimit.go、cache.go)。
+func ensureCryptoTables(db *sql.DB) {
+	migrateCryptoTimestamps(db)
+	mustExec(db, cryptoDDL)
+}
+
+const cryptoDDL = `CREATE TABLE IF NOT EXISTS crypto_bars (
+	exchange     TEXT NOT NULL,     -- binance / okx
+	asset_class  TEXT NOT NULL,     -- spot / perp
+	symbol       TEXT NOT NULL,     -- 交易所原始交易对，如 BTCUSDT、BTC-USDT
+	freq         INTEGER NOT NULL,  -- 分钟数
+	ts           INTEGER NOT NULL,  -- UTC 秒级时间戳，K 线结束时刻
+	tz           TEXT NOT NULL,     -- 恒为 UTC，本地时间 = local_time(ts, tz)
+	open         REAL,
+	high         REAL,
+	low          REAL,
+	close        REAL,
+	volume       REAL,              -- 基础货币数量
+	amount       REAL,              -- 计价货币成交额
+	PRIMARY KEY (exchange, asset_class, symbol, freq, ts)
+) WITHOUT ROWID, STRICT;`
+
+// 早期版本的 ts 为文本 "YYYY-MM-DD HH:MM:SS" (UTC，K 线开始时刻)，就地改为结束时刻的时间戳并补上 tz
+func migrateCryptoTimestamps(db *sql.DB) {
+	var typ string
+	if db.QueryRow("SELECT type FROM pragma_table_info('crypto_bars') WHERE name = 'ts'").Scan(&typ); typ != "TEXT" {
+		return
+	}
+	log.Println(">>> 正在把 crypto_bars.ts 转为 UTC 时间戳...")
+	mustExec(db, "BEGIN TRANSACTION;")
+	mustExec(db, "ALTER TABLE crypto_bars RENAME TO crypto_bars_text;")
+	mustExec(db, cryptoDDL)
+	mustExec(db, `INSERT INTO crypto_bars
+		SELECT exchange, asset_class, symbol, freq, CAST(strftime('%s', ts) AS INTEGER) + freq * 60, 'UTC',
+			open, high, low, close, volume, amount
+		FROM crypto_bars_text;`)
+	mustExec(db, "DROP TABLE crypto_bars_text;")
+	mustExec(db, "COMMIT;")
+}
+
+type kline struct {
+	Start                  time.Time
+	Open, High, Low, Close float64
+	Volume, Amount         float64
+}
+
+// 单次请求返回 [start, end) 内按时间升序的一批 K 线 (可能为空或少于全部)，
+// 以及下一页的起点；next 不晚于 start 时表示没有更多数据
+type klineFetcher interface {
+	Fetch(ctx context.Context, symbol string, freq int, start, end time.Time) (bars []kline, next time.Time, err error)
+}
+
+// chronos crypto --exchange binance --symbols BTCUSDT,ETHUSDT --interval 1m --from 2024-01-01 [--to ...] [--perp]
+// 不指定 --from 时从库中该交易对的最后一根 K 线之后续拉 (增量更新)
+func runCrypto(args []string) {
+	fs := flag.NewFlagSet("crypto", flag.ExitOnError)
+	exchange := fs.String("exchange", "binance", "交易所: binance | okx")
+	symbols := fs.String("symbols", "", "交易对，逗号分隔 (binance: BTCUSDT，okx: BTC-USDT)")
+	interval := fs.String("interval", "1d", "K 线周期: 1m 5m 15m 30m 1h 4h 1d")
+	from := fs.String("from", "", "起始日期 (YYYY-MM-DD)，默认从库中最后一根 K 线续拉")
+	to := fs.String("to", "", "结束日期 (YYYY-MM-DD，含)，默认到当前时刻")
+	perp := fs.Bool("perp", false, "拉取永续合约而不是现货")
+	fs.Parse(args)
+	if err := setupSources(DefaultSourcesConfig); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	syms := splitList(*symbols)
+	if len(syms) == 0 {
+		log.Fatal("[ERROR] 需要指定 --symbols")
+	}
+	freq, err := parseInterval(*interval)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	assetClass := "spot"
+	if *perp {
+		assetClass = "perp"
+	}
+	var fetcher klineFetcher
+	switch *exchange {
+	case "binance":
+		fetcher = binanceFetcher{perp: *perp}
+	case "okx":
+		fetcher = okxFetcher{}
+	default:
+		log.Fatalf("[ERROR] 未知交易所: %s", *exchange)
+	}
+
+	end := time.Now().UTC()
+	if *to != "" {
+		t, err := time.Parse(time.DateOnly, *to)
+		if err != nil {
+			log.Fatalf("[ERROR] 日期格式错误: %s", *to)
+		}
+		end = t.AddDate(0, 0, 1)
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+	ensureCryptoTables(db)
+
+	ctx := context.Background()
+	total := 0
+	for _, sym := range syms {
+		begin, err := cryptoStart(db, *exchange, assetClass, sym, freq, *from)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		n, err := fetchKlines(ctx, db, fetcher, *exchange, assetClass, sym, freq, begin, end)
+		if err != nil {
+			log.Fatalf("[ERROR] %s: %v", sym, err)
+		}
+		log.Printf(">>> %s %s %s: %d 根 K 线", *exchange, assetClass, sym, n)
+		total += n
+	}
+	log.Printf(">>> ✅ 数字货币 K 线拉取完成: %d 行, 耗时: %s", total, time.Since(start))
+}
+
+// 周期字符串 -> 分钟数
+func parseInterval(s string) (int, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("无法识别的周期: %s", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("无法识别的周期: %s", s)
+	}
+	switch s[len(s)-1] {
+	case 'm':
+		return n, nil
+	case 'h', 'H':
+		return n * 60, nil
+	case 'd', 'D':
+		return n * 1440, nil
+	}
+	return 0, fmt.Errorf("无法识别的周期: %s", s)
+}
+
+func cryptoStart(db *sql.DB, exchange, assetClass, symbol string, freq int, from string) (time.Time, error) {
+	if from != "" {
+		t, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("日期格式错误: %s", from)
+		}
+		return t, nil
+	}
+	// 最后一根 K 线的结束时刻即下一根的开始时刻
+	var last sql.NullInt64
+	db.QueryRow(`SELECT max(ts) FROM crypto_bars
+		WHERE exchange = ? AND asset_class = ? AND symbol = ? AND freq = ?`,
+		exchange, assetClass, symbol, freq).Scan(&last)
+	if !last.Valid {
+		return time.Time{}, fmt.Errorf("%s 库中没有历史数据，首次拉取需要指定 --from", symbol)
+	}
+	return time.Unix(last.Int64, 0).UTC(), nil
+}
+
+// 翻页拉取 [start, end) 并写库，已存在的 K 线 (例如上次拉取时尚未收盘的最后一根) 直接覆盖
+func fetchKlines(ctx context.Context, db *sql.DB, f klineFetcher, exchange, assetClass, symbol string, freq int, start, end time.Time) (int, error) {
+	n := 0
+	for start.Before(end) {
+		batch, next, err := f.Fetch(ctx, symbol, freq, start, end)
+		if err != nil {
+			return n, err
+		}
+		if !next.After(start) {
+			break
+		}
+		start = next
+		if len(batch) == 0 {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return n, err
+		}
+		stmt, err := tx.Prepare("INSERT OR REPLACE INTO crypto_bars VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		if err != nil {
+			tx.Rollback()
+			return n, err
+		}
+		for _, k := range batch {
+			if _, err := stmt.Exec(exchange, assetClass, symbol, freq, k.Start.Add(time.Duration(freq)*time.Minute).Unix(), "UTC",
+				k.Open, k.High, k.Low, k.Close, k.Volume, k.Amount); err != nil {
+				tx.Rollback()
+				return n, err
+			}
+		}
+		stmt.Close()
+		if err := tx.Commit(); err != nil {
+			return n, err
+		}
+		n += len(batch)
+	}
+	return n, nil
+}
+
+// 单只交易对的日线，按股票日线的格式给出 (不复权价与复权价相同，没有 PE)；日期为 K 线开始时刻所在的日期
+func (s *Store) CryptoHistory(ctx context.Context, exchange, assetClass, symbol string, r DateRange) ([]Bar, error) {
+	from, to := r.bounds()
+	return s.queryBars(ctx, cryptoHistorySQL, exchange, assetClass, symbol, from, to)
+}
+
+var cryptoHistorySQL = `SELECT symbol, date, close, close, open, high, low, NULL, volume FROM (
+		SELECT symbol, substr(local_time(ts - freq * 60, tz), 1, 10) AS date, ts, open, high, low, close, volume
+		FROM crypto_bars WHERE exchange = ? AND asset_class = ? AND symbol = ? AND freq = 1440
+	) WHERE date >= ? AND date <= ? ORDER BY ts`
+
+// 库中某交易所的全部交易对 (有日线的)
+func (s *Store) CryptoSymbols(ctx context.Context, exchange, assetClass string) ([]string, error) {
+	return s.queryStrings(ctx, `SELECT DISTINCT symbol FROM crypto_bars
+		WHERE exchange = ? AND asset_class = ? AND freq = 1440 ORDER BY symbol`, exchange, assetClass)
+}
+
+// 经限速器与响应缓存请求交易所接口，source 为交易所名
+func getCryptoJSON(ctx context.Context, source, u string, out any) error {
+	body, err := cachedFetch(ctx, source, u, func() ([]byte, error) {
+		if err := apiWait(ctx, source); err != nil {
+			return nil, err
+		}
+		return getBody(ctx, u)
+	})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		cacheDrop(source, u)
+		return err
+	}
+	return nil
+}
+
+var cryptoHTTP = &http.Client{Timeout: 30 * time.Second, Transport: sourceTransport}
+
+func getJSON(ctx context.Context, u string, out any) error {
+	body, err := getBody(ctx, u)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
+// GET 并读出完整响应体 (需要缓存原始响应时使用)
+func getBody(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	// 部分行情站点 (Yahoo、东方财富) 会拒绝没有 User-Agent 的请求
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; chronos)")
+	resp, err := cryptoHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, u)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// 交易所返回的数值多为字符串
+func jsonFloat(v any) float64 {
+	switch x := v.(type) {
+	case float64:
+		return x
+	case string:
+		f, _ := strconv.ParseFloat(x, 64)
+		return f
+	}
+	return 0
+}
+
+// Binance：GET /api/v3/klines (现货) 或 /fapi/v1/klines (U 本位永续)，每次最多 1000 根，升序
+type binanceFetcher struct {
+	perp bool
+}
+
+func (b binanceFetcher) Fetch(ctx context.Context, symbol string, freq int, start, end time.Time) ([]kline, time.Time, error) {
+	base := "https://api.binance.com/api/v3/klines"
+	if b.perp {
+		base = "https://fapi.binance.com/fapi/v1/klines"
+	}
+	q := url.Values{}
+	q.Set("symbol", strings.ToUpper(symbol))
+	q.Set("interval", binanceInterval(freq))
+	q.Set("startTime", strconv.FormatInt(start.UnixMilli(), 10))
+	q.Set("endTime", strconv.FormatInt(end.UnixMilli()-1, 10))
+	q.Set("limit", "1000")
+
+	var raw [][]any
+	if err := getCryptoJSON(ctx, "binance", base+"?"+q.Encode(), &raw); err != nil {
+		return nil, start, err
+	}
+	// [开盘时间, 开, 高, 低, 收, 成交量, 收盘时间, 成交额, ...]
+	out := make([]kline, 0, len(raw))
+	for _, r := range raw {
+		if len(r) < 8 {
+			continue
+		}
+		out = append(out, kline{
+			Start:  time.UnixMilli(int64(jsonFloat(r[0]))),
+			Open:   jsonFloat(r[1]),
+			High:   jsonFloat(r[2]),
+			Low:    jsonFloat(r[3]),
+			Close:  jsonFloat(r[4]),
+			Volume: jsonFloat(r[5]),
+			Amount: jsonFloat(r[7]),
+		})
+	}
+	// Binance 会自动跳到第一根有数据的 K 线，返回空即表示区间内已无数据
+	if len(out) == 0 {
+		return nil, start, nil
+	}
+	return out, out[len(out)-1].Start.Add(time.Duration(freq) * time.Minute), nil
+}
+
+func binanceInterval(freq int) string {
+	switch {
+	case freq%1440 == 0:
+		return fmt.Sprintf("%dd", freq/1440)
+	case freq%60 == 0:
+		return fmt.Sprintf("%dh", freq/60)
+	}
+	return fmt.Sprintf("%dm", freq)
+}
+
+// OKX：GET /api/v5/market/history-candles，每次最多 100 根，按时间降序，
+// after 参数表示“早于该时刻”，所以从 end 往回翻，再截掉 start 之前的部分。
+type okxFetcher struct{}
+
+func (okxFetcher) Fetch(ctx context.Context, symbol string, freq int, start, end time.Time) ([]kline, time.Time, error) {
+	bar := fmt.Sprintf("%dm", freq)
+	switch {
+	case freq%1440 == 0:
+		bar = fmt.Sprintf("%dDutc", freq/1440)
+	case freq%60 == 0:
+		bar = fmt.Sprintf("%dH", freq/60)
+	}
+	// 从 start 之后的 100 根处往回取，保证返回的是紧接 start 的一批
+	upper := start.Add(100 * time.Duration(freq) * time.Minute)
+	if upper.After(end) {
+		upper = end
+	}
+	q := url.Values{}
+	q.Set("instId", strings.ToUpper(symbol))
+	q.Set("bar", bar)
+	q.Set("after", strconv.FormatInt(upper.UnixMilli(), 10))
+	q.Set("limit", "100")
+
+	var resp struct {
+		Code string     `json:"code"`
+		Msg  string     `json:"msg"`
+		Data [][]string `json:"data"`
+	}
+	u := "https://www.okx.com/api/v5/market/history-candles?" + q.Encode()
+	if err := getCryptoJSON(ctx, "okx", u, &resp); err != nil {
+		return nil, start, err
+	}
+	if resp.Code != "0" {
+		cacheDrop("okx", u)
+		return nil, start, fmt.Errorf("OKX 错误 %s: %s", resp.Code, resp.Msg)
+	}
+	// [开盘时间, 开, 高, 低, 收, 成交量(张/币), 成交量(币), 成交额(计价货币), 是否收盘]
+	out := make([]kline, 0, len(resp.Data))
+	for k := len(resp.Data) - 1; k >= 0; k-- {
+		r := resp.Data[k]
+		if len(r) < 8 {
+			continue
+		}
+		ms, _ := strconv.ParseInt(r[0], 10, 64)
+		t := time.UnixMilli(ms)
+		if t.Before(start) {
+			continue
+		}
+		out = append(out, kline{
+			Start:  t,
+			Open:   jsonFloat(r[1]),
+			High:   jsonFloat(r[2]),
+			Low:    jsonFloat(r[3]),
+			Close:  jsonFloat(r[4]),
+			Volume: jsonFloat(r[6]),
+			Amount: jsonFloat(r[7]),
+		})
+	}
+	// 区间内无数据 (例如上线之前) 时直接跳到下一段
+	return out, upper, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// 按页返回固定 K 线的交易所
+type fixedKlines []kline
+
+func (f fixedKlines) Fetch(_ context.Context, _ string, freq int, start, end time.Time) ([]kline, time.Time, error) {
+	var out []kline
+	for _, k := range f {
+		if !k.Start.Before(start) && k.Start.Before(end) {
+			out = append(out, k)
+		}
+	}
+	if len(out) == 0 {
+		return nil, start, nil
+	}
+	return out, out[len(out)-1].Start.Add(time.Duration(freq) * time.Minute), nil
+}
+
+// ts 为 K 线结束时刻的 UTC 时间戳 (与 stock_minute 一致)；续拉从最后一根的结束时刻开始；日线可按股票日线格式读出
+func TestCryptoBars(t *testing.T) {
+	db := openTestDB(t, "crypto.db")
+	ensureCryptoTables(db)
+	day := func(s string) time.Time {
+		d, _ := time.Parse(time.DateOnly, s)
+		return d
+	}
+	f := fixedKlines{
+		{Start: day("2024-01-01"), Open: 100, High: 110, Low: 95, Close: 105, Volume: 10},
+		{Start: day("2024-01-02"), Open: 105, High: 120, Low: 100, Close: 118, Volume: 12},
+	}
+	n, err := fetchKlines(context.Background(), db, f, "binance", "spot", "BTCUSDT", 1440, day("2024-01-01"), day("2024-01-03"))
+	if err != nil || n != 2 {
+		t.Fatalf("fetchKlines = %d, %v", n, err)
+	}
+	if got, want := dumpTable(t, db, "SELECT ts, tz, local_time(ts, tz) FROM crypto_bars ORDER BY ts"),
+		"1704153600|UTC|2024-01-02 00:00:00\n1704240000|UTC|2024-01-03 00:00:00\n"; got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	next, err := cryptoStart(db, "binance", "spot", "BTCUSDT", 1440, "")
+	if err != nil || !next.Equal(day("2024-01-03")) {
+		t.Errorf("cryptoStart = %v, %v", next, err)
+	}
+
+	bars, err := newStore(db).CryptoHistory(context.Background(), "binance", "spot", "BTCUSDT", DateRange{From: "2024-01-02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(bars), "[{BTCUSDT 2024-01-02 118 118 105 120 100 NaN 12}]"; got != want {
+		t.Errorf("CryptoHistory = %s, want %s", got, want)
+	}
+}
+
+// 旧库中文本格式的 ts (K 线开始时刻) 转为结束时刻的时间戳
+func TestMigrateCryptoTimestamps(t *testing.T) {
+	db := openTestDB(t, "crypto.db")
+	mustExec(db, `CREATE TABLE crypto_bars (
+		exchange TEXT NOT NULL, asset_class TEXT NOT NULL, symbol TEXT NOT NULL, freq INTEGER NOT NULL, ts TEXT NOT NULL,
+		open REAL, high REAL, low REAL, close REAL, volume REAL, amount REAL,
+		PRIMARY KEY (exchange, asset_class, symbol, freq, ts)
+	) WITHOUT ROWID, STRICT;`)
+	mustExec(db, `INSERT INTO crypto_bars VALUES
+		('okx', 'perp', 'BTC-USDT', 1440, '2024-01-01 00:00:00', 1, 2, 0.5, 1.5, 10, 15),
+		('okx', 'perp', 'BTC-USDT', 5, '2024-01-01 23:55:00', 1, 2, 0.5, 1.5, 10, 15)`)
+	ensureCryptoTables(db)
+	ensureCryptoTables(db) // 已转换过的表不再处理
+
+	got := dumpTable(t, db, "SELECT freq, typeof(ts), local_time(ts, tz), close FROM crypto_bars ORDER BY freq")
+	if want := "5|integer|2024-01-02 00:00:00|1.5\n1440|integer|2024-01-02 00:00:00|1.5\n"; got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
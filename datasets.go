@@ -0,0 +1,184 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 附加数据集 (指数、参考数据等)
+// ---------------------------------------------------------
+// 与 stock_history 不同，这些数据只有一个来源，不需要 staging 表合并：
+// 映射函数里直接完成日期标准化与空值清洗，写入最终表。
+// 对应目录下没有文件时只打印提示，不影响主流程。
+
+type dataset struct {
+	Name    string // 日志中显示的名称
+	Pattern string // 文件 glob
+	Table   string
+	DDL     string // 为空表示写入已存在的表
+	MinCols int
+	Mapper  func(record []string) []any
+}
+
+// 按注册顺序导入
+var datasets []dataset
+
+func registerDataset(d dataset) {
+	datasets = append(datasets, d)
+}
+
+// 已存在的主键直接跳过 (重新扫描目录时旧文件的行都已在库中)，不算导入错误；
+// NOT NULL 等其他约束仍按 import.on_error 处理
+func importDatasets(db *sql.DB) {
+	for _, d := range datasets {
+		if d.DDL != "" {
+			mustExec(db, d.DDL)
+		}
+		log.Printf(">>> 正在导入%s...", d.Name)
+		importCSVUpsert(db, d.Pattern, d.Table, "ON CONFLICT DO NOTHING", d.MinCols, d.Mapper)
+	}
+}
+
+// 建立某个数据集的表 (API 拉取等不经过 importDatasets 的场景使用)
+func ensureDatasetTable(db *sql.DB, table string) {
+	for _, d := range datasets {
+		if d.Table == table && d.DDL != "" {
+			mustExec(db, d.DDL)
+		}
+	}
+}
+
+// 日期统一为 YYYY-MM-DD：支持 19910404、1991-04-04、1991/04/04 (后面可以跟空格或 T 再接时间)。
+// 空值、认不出的格式 (如 2024-1-2) 和不存在的日期 (如 20240132) 都返回 NULL，
+// 写入 NOT NULL 列时按导入错误处理，不会把非 ISO 的文本写进日期列打乱排序与关联
+func normDate(s string) any {
+	s = strings.TrimSpace(s)
+	var d string
+	switch {
+	case len(s) == 8 && isDigits(s):
+		d = s[:4] + "-" + s[4:6] + "-" + s[6:]
+	case len(s) >= 10 && (s[4] == '-' || s[4] == '/') && (s[7] == '-' || s[7] == '/') &&
+		isDigits(s[:4]) && isDigits(s[5:7]) && isDigits(s[8:10]) && (len(s) == 10 || s[10] == ' ' || s[10] == 'T'):
+		d = s[:4] + "-" + s[5:7] + "-" + s[8:10]
+	default:
+		return nil
+	}
+	if _, err := time.Parse(time.DateOnly, d); err != nil {
+		return nil
+	}
+	return d
+}
+
+// 数值清洗：去空格，空串、"--"、"NaN" 等转为 NULL (ParseFloat 认得的 "inf"、"NAN" 等非有限值同样)。
+// 逗号只认千分位 ("1,234.5")，"29,1" 这类小数逗号或错位的分组同样转为 NULL，不会变成另一个数
+func normNum(s string) any {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "", "--", "-", "NaN", "nan", "NULL", "null", "None":
+		return nil
+	}
+	if strings.IndexByte(s, ',') >= 0 {
+		if s = stripThousands(s); s == "" {
+			return nil
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return v
+}
+
+// 去掉千分位逗号：整数部分须为 1~3 位开头、之后每组 3 位，否则返回空串
+func stripThousands(s string) string {
+	sign := ""
+	if s[0] == '-' || s[0] == '+' {
+		sign, s = s[:1], s[1:]
+	}
+	whole, frac := s, ""
+	if k := strings.IndexByte(s, '.'); k >= 0 {
+		whole, frac = s[:k], s[k:]
+	}
+	if strings.IndexByte(frac, ',') >= 0 {
+		return ""
+	}
+	groups := strings.Split(whole, ",")
+	for k, g := range groups {
+		if !isDigits(g) || (k == 0 && len(g) > 3) || (k > 0 && len(g) != 3) {
+			return ""
+		}
+	}
+	return sign + strings.Join(groups, "") + frac
+}
+
+// 文本清洗：去空格，空串转为 NULL
+func normText(s string) any {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// 取第 k 列，越界时返回空串 (可选列)
+func col(record []string, k int) string {
+	if k < len(record) {
+		return record[k]
+	}
+	return ""
+}
+
+// 时间戳统一为 "YYYY-MM-DD HH:MM:SS" (交易所本地时间)：
+// 支持 2024-01-02 09:31[:00]、2024/01/02 09:31、20240102 0931[00]、202401020931[00]
+func normDateTime(s string) any {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	var datePart, timePart string
+	if k := strings.IndexAny(s, " T"); k >= 0 {
+		datePart, timePart = s[:k], strings.TrimSpace(s[k+1:])
+	} else if len(s) >= 12 && isDigits(s) {
+		datePart, timePart = s[:8], s[8:]
+	} else {
+		return nil
+	}
+	d, ok := normDate(datePart).(string)
+	if !ok || len(d) != 10 {
+		return nil
+	}
+
+	digits := strings.ReplaceAll(timePart, ":", "")
+	if k := strings.IndexByte(digits, '.'); k >= 0 {
+		digits = digits[:k] // 丢弃毫秒
+	}
+	if !isDigits(digits) {
+		return nil
+	}
+	switch len(digits) {
+	case 3, 5: // 931 / 93100 这类省略前导 0 的写法
+		digits = "0" + digits
+	}
+	switch len(digits) {
+	case 4:
+		digits += "00"
+	case 6:
+	default:
+		return nil
+	}
+	return d + " " + digits[:2] + ":" + digits[2:4] + ":" + digits[4:6]
+}
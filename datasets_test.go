@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 重新扫描同一目录 (update --sources datasets)：已有的主键跳过，不计入导入错误，新增的行照常写入
+func TestImportDatasetsTwice(t *testing.T) {
+	defer func(d []dataset) { datasets = d }(datasets)
+	var fund dataset
+	for _, d := range datasets {
+		if d.Table == "fund_nav" {
+			fund = d
+		}
+	}
+	dir := t.TempDir()
+	fund.Pattern = filepath.Join(dir, "*.csv")
+	datasets = []dataset{fund}
+	defer func(mode string) { importOptions.onError = mode }(importOptions.onError)
+	importOptions.onError = onErrorAbort // 有任何导入错误都会终止
+
+	db := openTestDB(t, "datasets.db")
+	os.WriteFile(filepath.Join(dir, "a.csv"), []byte("code,date,unit,accum\n510300,20240102,3.5,3.9\n510300,20240103,3.6,4.0\n"), 0o644)
+	importDatasets(db)
+	os.WriteFile(filepath.Join(dir, "b.csv"), []byte("code,date,unit,accum\n510300,20240104,3.7,4.1\n"), 0o644)
+	before := importErrs.count
+	importDatasets(db)
+	if n := importErrs.count - before; n != 0 {
+		t.Errorf("重复导入报告了 %d 处错误", n)
+	}
+	got := dumpTable(t, db, "SELECT fund_code, date, unit_nav FROM fund_nav ORDER BY date")
+	if want := "510300|2024-01-02|3.5\n510300|2024-01-03|3.6\n510300|2024-01-04|3.7\n"; got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
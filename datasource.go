@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"chronos/source"
+)
+
+// ---------------------------------------------------------
+// 第三方数据源适配器
+// ---------------------------------------------------------
+// 私有供应商的数据 (专有文件格式、内部接口) 可以在单独的 Go 模块中实现 source.DataSource 接口
+// (chronos/source 包)，在 init() 中调用 source.Register 注册，再在这里空白导入该模块一起编译：
+//
+//	import _ "example.com/acme/chronos-acme"
+//
+//	chronos source list
+//	chronos source run acme-ticks [--config sources.yaml]
+//
+// 执行时先 Discover 列出要读的单元 (文件路径、请求参数等)，逐个 Read 成行，按 Schema 写入目标表，
+// 每个单元一个事务。实现了 source.Configurable 的适配器会先收到 sources.yaml 中以其名称命名的配置节。
+// mock 数据源 (mock.go) 同时注册为一个适配器，可作为参考实现。
+
+// 读取一个单元并写入目标表，返回写入的行数；touched 不为 nil 时记下写入的代码 (symbol 列)
+func loadSourceItem(ctx context.Context, db *sql.DB, s source.DataSource, schema source.Schema, item string, touched map[string]bool) (int, error) {
+	it, err := s.Read(ctx, item)
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+
+	verb := "INSERT OR REPLACE"
+	if schema.Conflict != "" {
+		verb = "INSERT"
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	batch, err := newBatchInserter(tx, verb, schema.Table, schema.Columns, schema.Conflict, len(schema.Columns))
+	if err != nil {
+		return 0, err
+	}
+	defer batch.close()
+	symbolCol := slices.Index(schema.Columns, "symbol")
+	var symbols []string
+	n := 0
+	for {
+		row, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(row) != len(schema.Columns) {
+			return 0, fmt.Errorf("第 %d 行有 %d 个值, Schema 定义了 %d 列", n+1, len(row), len(schema.Columns))
+		}
+		if err := batch.add(row); err != nil {
+			return 0, err
+		}
+		if symbolCol >= 0 {
+			if symbol, ok := row[symbolCol].(string); ok {
+				symbols = append(symbols, symbol)
+			}
+		}
+		n++
+	}
+	if err := batch.flush(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	if touched != nil {
+		for _, symbol := range symbols {
+			touched[symbol] = true
+		}
+	}
+	return n, nil
+}
+
+func runSource(args []string) {
+	if len(args) == 0 {
+		log.Fatal("[ERROR] 用法: chronos source list | run <name>")
+	}
+	switch args[0] {
+	case "list":
+		var names []string
+		for _, d := range source.All() {
+			s := d.Schema()
+			names = append(names, fmt.Sprintf("%-16s -> %s (%s)", d.Name(), s.Table, strings.Join(s.Columns, ", ")))
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Println(n)
+		}
+	case "run":
+		runSourceRun(args[1:])
+	default:
+		log.Fatalf("[ERROR] 未知子命令: source %s", args[0])
+	}
+}
+
+// chronos source run <name> [--config sources.yaml]
+func runSourceRun(args []string) {
+	if len(args) == 0 {
+		log.Fatal("[ERROR] 需要指定数据源名称 (chronos source list 查看)")
+	}
+	s := source.Lookup(args[0])
+	if s == nil {
+		log.Fatalf("[ERROR] 未知数据源: %s", args[0])
+	}
+	fs := flag.NewFlagSet("source run", flag.ExitOnError)
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件")
+	fs.Parse(args[1:])
+
+	if c, ok := s.(source.Configurable); ok {
+		cfg, err := loadSourceConfig(*config, s.Name())
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		if err := c.Configure(cfg); err != nil {
+			log.Fatalf("[ERROR] %s: %v", s.Name(), err)
+		}
+	}
+	if err := setupSources(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if err := setupValidationRules(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	start := time.Now()
+	db := openOrCreateDB()
+	defer db.Close()
+	createTables(db)
+	schema := s.Schema()
+	ensureDatasetTable(db, schema.Table)
+
+	ctx := context.Background()
+	items, err := s.Discover(ctx)
+	if err != nil {
+		log.Fatalf("[ERROR] %s: %v", s.Name(), err)
+	}
+	log.Printf(">>> [%s] 共 %d 个单元 -> %s", s.Name(), len(items), schema.Table)
+	var touched map[string]bool // 写入日线时涉及的代码，结束后刷新统计与缓存
+	if schema.Table == "stock_history" {
+		touched = map[string]bool{}
+	}
+	total, failed := 0, 0
+	for _, item := range items {
+		n, err := loadSourceItem(ctx, db, s, schema, item, touched)
+		if err != nil {
+			log.Printf("[WARN] %s: %v", item, err)
+			failed++
+			continue
+		}
+		total += n
+		fmt.Printf(".")
+	}
+	fmt.Println()
+	if schema.Table == "stock_history" {
+		historyChanged(db, mapKeys(touched))
+		createViews(db)
+	}
+	log.Printf(">>> ✅ %s 导入完成: %d 行, %d 个单元失败, 耗时: %s", s.Name(), total, failed, time.Since(start))
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 远程文件批量下载
+// ---------------------------------------------------------
+// 供应商以 URL 提供的历史归档 (几百 GB 的 CSV / 压缩包) 用 download 拉到本地后再导入：
+//
+//	chronos download --urls archive.txt --out D:\archive --concurrency 4 --limit 20MB
+//
+// archive.txt 每行一个 URL，可在后面跟保存的文件名 (默认取 URL 路径的最后一段)，# 开头为注释。
+// 下载中的文件写成 <文件名>.part，完成后改名；中断 (Ctrl-C、断网、重启) 后重新执行同一命令，
+// 已完成的文件跳过，.part 文件用 Range 请求从断点续传 (服务器不支持时从头下载)。开始下载时把响应的
+// ETag (强校验) 或 Last-Modified 记在 <文件名>.part.validator 中，续传时以 If-Range 带上：远端文件
+// 已经变化时服务器返回完整内容，从头覆盖，不会把新文件的字节接在旧的前半段之后；没有记录的 .part 从头下载。
+// 失败按 1s、2s、4s... (最长 1 分钟) 退避重试；--limit 为所有并发合计的带宽上限。
+// 走 sources.yaml 的代理设置 (proxy.go)。下载完成后可用 chronos manifest verify 核对。
+
+type downloadJob struct {
+	URL  string
+	Dest string
+}
+
+func readDownloadList(listPath, outDir string) ([]downloadJob, error) {
+	f, err := os.Open(listPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var jobs []downloadJob
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		u, err := url.Parse(fields[0])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("%s 第 %d 行不是 http(s) 地址: %s", listPath, n, fields[0])
+		}
+		name := path.Base(u.Path)
+		if len(fields) > 1 {
+			name = fields[1]
+		}
+		if name == "" || name == "/" || name == "." {
+			return nil, fmt.Errorf("%s 第 %d 行无法确定文件名，请在 URL 后写明", listPath, n)
+		}
+		dest := filepath.Join(outDir, filepath.FromSlash(name))
+		if seen[dest] {
+			return nil, fmt.Errorf("%s 第 %d 行的文件名重复: %s", listPath, n, name)
+		}
+		seen[dest] = true
+		jobs = append(jobs, downloadJob{URL: fields[0], Dest: dest})
+	}
+	return jobs, scanner.Err()
+}
+
+// 解析字节数：支持 K/M/G/T 后缀 (1024 进制，可带 B 或 iB)，如 20MB、512k、1.5G
+func parseByteSize(s string) (int64, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	t = strings.TrimSuffix(strings.TrimSuffix(t, "B"), "I")
+	mult := 1.0
+	if t != "" {
+		if k := strings.IndexByte("KMGT", t[len(t)-1]); k >= 0 {
+			mult = float64(int64(1) << (10 * (k + 1)))
+			t = t[:len(t)-1]
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("无法解析大小: %q", s)
+	}
+	return int64(v * mult), nil
+}
+
+// 所有下载共享的带宽上限 (字节/秒)，0 表示不限
+type bandwidthLimiter struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time // 已预约的发送时间到此为止
+}
+
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	if l == nil || l.rate <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	delay := l.next.Sub(now) - 200*time.Millisecond // 允许 200ms 的突发
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limit   *bandwidthLimiter
+	counter *atomic.Int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > 32<<10 {
+		p = p[:32<<10]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.counter.Add(int64(n))
+		if werr := r.limit.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// 不再重试的错误 (404 等)
+type permanentError struct{ error }
+
+// 响应中可用于 If-Range 的校验值：强 ETag 优先 (弱 ETag 不能用于 If-Range)，否则 Last-Modified
+func rangeValidator(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return h.Get("Last-Modified")
+}
+
+// 解析 Content-Range: "bytes 100-199/1000" 或 "bytes */1000"，未知的部分为 -1
+func parseContentRange(s string) (start, total int64) {
+	start, total = -1, -1
+	spec, ok := strings.CutPrefix(strings.TrimSpace(s), "bytes ")
+	if !ok {
+		return
+	}
+	rng, size, ok := strings.Cut(spec, "/")
+	if !ok {
+		return
+	}
+	if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+		total = n
+	}
+	if from, _, ok := strings.Cut(rng, "-"); ok {
+		if n, err := strconv.ParseInt(from, 10, 64); err == nil {
+			start = n
+		}
+	}
+	return
+}
+
+// 丢弃 .part 与其校验值，下次从头下载
+func discardPart(part string) {
+	os.Remove(part)
+	os.Remove(part + ".validator")
+}
+
+// 下载一个文件，.part 存在且记有校验值时从断点继续
+func downloadOnce(ctx context.Context, client *http.Client, job downloadJob, limit *bandwidthLimiter, counter *atomic.Int64) error {
+	part := job.Dest + ".part"
+	var offset int64
+	var validator string
+	if st, err := os.Stat(part); err == nil {
+		offset = st.Size()
+		if b, err := os.ReadFile(part + ".validator"); err == nil {
+			validator = strings.TrimSpace(string(b))
+		}
+		if offset > 0 && validator == "" {
+			log.Printf("[WARN] %s: 没有记录远端文件的校验值，无法确认未变化，从头下载", filepath.Base(part))
+			offset = 0
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, job.URL, nil)
+	if err != nil {
+		return permanentError{err}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if start, _ := parseContentRange(resp.Header.Get("Content-Range")); start != offset {
+			discardPart(part)
+			return fmt.Errorf("续传的起点不符 (Content-Range: %q, 本地 %d 字节)，从头下载", resp.Header.Get("Content-Range"), offset)
+		}
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// If-Range 校验通过但起点已在文件末尾：.part 可能已是完整文件 (上次写完后没来得及改名)
+		if _, total := parseContentRange(resp.Header.Get("Content-Range")); total != offset {
+			discardPart(part)
+			return fmt.Errorf("本地 %d 字节与远端大小 %d 不符 (Content-Range: %q)，从头下载", offset, total, resp.Header.Get("Content-Range"))
+		}
+		os.Remove(part + ".validator")
+		return os.Rename(part, job.Dest)
+	case resp.StatusCode == http.StatusOK:
+		// 服务器不支持 Range，或 If-Range 不匹配 (远端文件已变化)：从头下载
+		flags |= os.O_TRUNC
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("HTTP %s", resp.Status)
+	default:
+		return permanentError{fmt.Errorf("HTTP %s", resp.Status)}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(job.Dest), 0o755); err != nil {
+		return permanentError{err}
+	}
+	if flags&os.O_TRUNC != 0 {
+		// 记下这次内容的校验值，中断后据此续传；服务器不提供时不留记录 (下次从头下载)
+		if v := rangeValidator(resp.Header); v != "" {
+			err = os.WriteFile(part+".validator", []byte(v), 0o644)
+		} else {
+			err = os.Remove(part + ".validator")
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return permanentError{err}
+		}
+	}
+	f, err := os.OpenFile(part, flags, 0o644)
+	if err != nil {
+		return permanentError{err}
+	}
+	_, err = io.Copy(f, &limitedReader{ctx: ctx, r: resp.Body, limit: limit, counter: counter})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if want := resp.ContentLength; want >= 0 {
+		if flags&os.O_APPEND != 0 {
+			want += offset
+		}
+		if st, err := os.Stat(part); err == nil && st.Size() < want {
+			return fmt.Errorf("连接提前断开 (已写入 %d / %d 字节)", st.Size(), want)
+		}
+	}
+	os.Remove(part + ".validator")
+	return os.Rename(part, job.Dest)
+}
+
+// 带退避重试的下载
+func downloadFile(ctx context.Context, client *http.Client, job downloadJob, retries int, limit *bandwidthLimiter, counter *atomic.Int64) error {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err := downloadOnce(ctx, client, job, limit, counter)
+		var perm permanentError
+		if err == nil || ctx.Err() != nil || errors.As(err, &perm) || attempt >= retries {
+			return err
+		}
+		log.Printf("[WARN] %s: %v，%s 后重试 (%d/%d)", filepath.Base(job.Dest), err, backoff, attempt+1, retries)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+func formatBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	k := 0
+	for n >= 1024 && k < len(units)-1 {
+		n /= 1024
+		k++
+	}
+	return fmt.Sprintf("%.1f%s", n, units[k])
+}
+
+// chronos download --urls list.txt --out dir [--concurrency 4] [--retries 5] [--limit 20MB]
+func runDownload(args []string) {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	listPath := fs.String("urls", "", "URL 列表文件 (每行: URL [文件名])")
+	outDir := fs.String("out", ".", "保存目录")
+	concurrency := fs.Int("concurrency", 4, "同时下载的文件数")
+	retries := fs.Int("retries", 5, "每个文件的最大重试次数")
+	limitArg := fs.String("limit", "0", "总带宽上限 (每秒，如 20MB)，0 表示不限")
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件 (代理设置)")
+	fs.Parse(args)
+	if *listPath == "" {
+		log.Fatal("[ERROR] 需要指定 --urls")
+	}
+	if *concurrency < 1 {
+		log.Fatal("[ERROR] --concurrency 至少为 1")
+	}
+	rate, err := parseByteSize(*limitArg)
+	if err != nil {
+		log.Fatalf("[ERROR] --limit: %v", err)
+	}
+	if err := setupSources(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	jobs, err := readDownloadList(*listPath, *outDir)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	var pending []downloadJob
+	for _, j := range jobs {
+		if _, err := os.Stat(j.Dest); err != nil {
+			pending = append(pending, j)
+		}
+	}
+	log.Printf(">>> 共 %d 个文件, 已完成 %d 个, 待下载 %d 个 (并发 %d)", len(jobs), len(jobs)-len(pending), len(pending), *concurrency)
+	if len(pending) == 0 {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client := &http.Client{Transport: sourceTransport} // 大文件不设整体超时，靠 ctx 与重试
+	limit := &bandwidthLimiter{rate: float64(rate)}
+	var received atomic.Int64
+	var done, failed atomic.Int32
+
+	start := time.Now()
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n := float64(received.Load())
+				log.Printf(">>> 已完成 %d/%d 个文件, 本次下载 %s (%s/s)", done.Load(), len(pending), formatBytes(n), formatBytes(n/time.Since(start).Seconds()))
+			}
+		}
+	}()
+
+	queue := make(chan downloadJob)
+	var wg sync.WaitGroup
+	for range *concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				if err := downloadFile(ctx, client, j, *retries, limit, &received); err != nil {
+					if ctx.Err() == nil {
+						log.Printf("[ERROR] %s: %v", j.URL, err)
+						failed.Add(1)
+					}
+					continue
+				}
+				done.Add(1)
+			}
+		}()
+	}
+feed:
+	for _, j := range pending {
+		select {
+		case queue <- j:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		log.Printf(">>> 已中断: 完成 %d 个文件，未完成的 .part 文件下次继续", done.Load())
+		return
+	}
+	log.Printf(">>> ✅ 下载结束: 完成 %d 个, 失败 %d 个, 共 %s, 耗时: %s", done.Load(), failed.Load(), formatBytes(float64(received.Load())), time.Since(start))
+	if failed.Load() > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 模拟供应商的文件服务器：ServeContent 按 ETag 处理 Range / If-Range，记录每个请求的 "Range|If-Range"
+type fakeArchive struct {
+	mu       sync.Mutex
+	data     []byte
+	etag     string
+	cut      int // 下一次完整响应只写出前 cut 字节就断开连接 (0 为不断开)
+	requests []string
+}
+
+func (a *fakeArchive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	a.requests = append(a.requests, r.Header.Get("Range")+"|"+r.Header.Get("If-Range"))
+	cut := 0
+	if r.Header.Get("Range") == "" {
+		cut, a.cut = a.cut, 0
+	}
+	a.mu.Unlock()
+	w.Header().Set("ETag", a.etag)
+	if cut > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(a.data)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(a.data[:cut])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	http.ServeContent(w, r, "archive.csv", time.Time{}, bytes.NewReader(a.data))
+}
+
+// 在临时目录中准备 .part (part 为 nil 时没有) 与校验值记录 (为空时没有)，返回下载任务
+func downloadFixture(t *testing.T, url string, part []byte, validator string) downloadJob {
+	dest := filepath.Join(t.TempDir(), "archive.csv")
+	if part != nil {
+		os.WriteFile(dest+".part", part, 0o644)
+	}
+	if validator != "" {
+		os.WriteFile(dest+".part.validator", []byte(validator), 0o644)
+	}
+	return downloadJob{URL: url, Dest: dest}
+}
+
+func checkDownloaded(t *testing.T, job downloadJob, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(job.Dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("内容 = %q, 期望 %q", got, want)
+	}
+	for _, leftover := range []string{job.Dest + ".part", job.Dest + ".part.validator"} {
+		if _, err := os.Stat(leftover); err == nil {
+			t.Errorf("%s 未清理", filepath.Base(leftover))
+		}
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	data := []byte("symbol,date,close\n600000.SH,20240102,10.01\n600000.SH,20240103,10.02\n")
+	ctx := context.Background()
+	var counter atomic.Int64
+
+	for _, tc := range []struct {
+		name      string
+		etag      string // 服务器当前的 ETag
+		part      []byte
+		validator string
+		requests  []string
+		failFirst bool // 第一次 downloadOnce 应返回错误，第二次完成
+	}{
+		// 206：校验值一致，从断点续传
+		{"partial", `"v1"`, data[:20], `"v1"`, []string{`bytes=20-|"v1"`}, false},
+		// 200：远端文件已变化，If-Range 不匹配，返回完整内容从头覆盖
+		{"changed", `"v2"`, []byte("old content of v1..."), `"v1"`, []string{`bytes=20-|"v1"`}, false},
+		// 旧的 .part 没有校验值记录：不发 Range，从头下载
+		{"no-validator", `"v1"`, data[:20], "", []string{"|"}, false},
+		// 416 且远端大小与 .part 相同：上次写完没来得及改名
+		{"complete", `"v1"`, data, `"v1"`, []string{"bytes=" + strconv.Itoa(len(data)) + `-|"v1"`}, false},
+		// 416 但 .part 比远端文件长：丢弃后重新下载
+		{"oversized", `"v1"`, append(append([]byte{}, data...), "extra"...), `"v1"`,
+			[]string{"bytes=" + strconv.Itoa(len(data)+5) + `-|"v1"`, "|"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := &fakeArchive{data: data, etag: tc.etag}
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+			job := downloadFixture(t, ts.URL+"/archive.csv", tc.part, tc.validator)
+
+			err := downloadOnce(ctx, ts.Client(), job, nil, &counter)
+			if tc.failFirst {
+				if err == nil {
+					t.Fatal("第一次下载应报错")
+				}
+				err = downloadOnce(ctx, ts.Client(), job, nil, &counter)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkDownloaded(t, job, data)
+			if got := srv.requests; !slices.Equal(got, tc.requests) {
+				t.Errorf("请求 = %q, 期望 %q", got, tc.requests)
+			}
+		})
+	}
+}
+
+// 连接中途断开：重试时带着首次记下的 ETag 从已写入的位置续传
+func TestDownloadRetryAfterMidStreamFailure(t *testing.T) {
+	data := bytes.Repeat([]byte("600000.SH,20240102,10.01\n"), 100)
+	srv := &fakeArchive{data: data, etag: `"v1"`, cut: 1000}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	job := downloadFixture(t, ts.URL+"/archive.csv", nil, "")
+
+	var counter atomic.Int64
+	if err := downloadFile(context.Background(), ts.Client(), job, 1, nil, &counter); err != nil {
+		t.Fatal(err)
+	}
+	checkDownloaded(t, job, data)
+	if want := []string{"|", `bytes=1000-|"v1"`}; !slices.Equal(srv.requests, want) {
+		t.Errorf("请求 = %q, 期望 %q", srv.requests, want)
+	}
+	if n := counter.Load(); n != int64(len(data)) {
+		t.Errorf("共接收 %d 字节, 期望 %d", n, len(data))
+	}
+}
+
+// 带宽上限：预约超出 200ms 突发额度的部分要等待
+func TestBandwidthLimiter(t *testing.T) {
+	l := &bandwidthLimiter{rate: 1 << 20}
+	start := time.Now()
+	for range 4 {
+		if err := l.wait(context.Background(), 1<<18); err != nil { // 每次 250ms 的额度
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 700*time.Millisecond || elapsed > 3*time.Second {
+		t.Errorf("4 × 256KB @ 1MB/s 耗时 %s, 期望约 800ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx, 1<<20); err == nil {
+		t.Error("ctx 取消后应立即返回错误")
+	}
+}
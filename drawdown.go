@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"math"
+)
+
+// 回撤序列中的一个点
+type DrawdownPoint struct {
+	Date     string
+	Value    float64 // 净值或后复权价
+	Peak     float64 // 截至当日的历史最高
+	Drawdown float64 // Value/Peak - 1，≤ 0
+	Duration int     // 距离上一次创新高的交易日数，处于新高时为 0
+}
+
+// 计算回撤 (水下曲线)，NaN 值沿用前一天的状态；第一个有效值之前还没有高点，持续天数保持 0
+func computeDrawdown(dates []string, values []float64) []DrawdownPoint {
+	out := make([]DrawdownPoint, len(values))
+	peak := math.NaN()
+	duration := 0
+	for k, v := range values {
+		switch {
+		case math.IsNaN(v):
+			if !math.IsNaN(peak) {
+				duration++
+			}
+		case math.IsNaN(peak) || v >= peak:
+			peak, duration = v, 0
+		default:
+			duration++
+		}
+		dd := math.NaN()
+		if !math.IsNaN(peak) && !math.IsNaN(v) && peak > 0 {
+			dd = v/peak - 1
+		}
+		out[k] = DrawdownPoint{Date: dates[k], Value: v, Peak: peak, Drawdown: dd, Duration: duration}
+	}
+	return out
+}
+
+// 最大回撤及其最长持续天数
+func maxDrawdown(points []DrawdownPoint) (depth float64, longest int) {
+	for _, p := range points {
+		if !math.IsNaN(p.Drawdown) && p.Drawdown < depth {
+			depth = p.Drawdown
+		}
+		if p.Duration > longest {
+			longest = p.Duration
+		}
+	}
+	return depth, longest
+}
+
+// 单只股票基于后复权收盘价的回撤序列
+func (s *Store) Drawdown(ctx context.Context, symbol string, r DateRange) ([]DrawdownPoint, error) {
+	bars, err := s.History(ctx, symbol, r)
+	if err != nil {
+		return nil, err
+	}
+	return barsDrawdown(bars), nil
+}
+
+func barsDrawdown(bars []Bar) []DrawdownPoint {
+	dates := make([]string, len(bars))
+	values := make([]float64, len(bars))
+	for k, b := range bars {
+		dates[k], values[k] = b.Date, b.CloseAdj
+	}
+	return computeDrawdown(dates, values)
+}
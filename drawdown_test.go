@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 上市前 (或数据开始前) 的 NaN 不计入水下天数，之后的停牌 NaN 照常计入
+func TestComputeDrawdownLeadingNaN(t *testing.T) {
+	nan := math.NaN()
+	dates := []string{"d1", "d2", "d3", "d4", "d5", "d6", "d7"}
+	points := computeDrawdown(dates, []float64{nan, nan, 10, 8, nan, 9, 12})
+
+	wantDur := []int{0, 0, 0, 1, 2, 3, 0}
+	for k, p := range points {
+		if p.Duration != wantDur[k] {
+			t.Errorf("%s: duration = %d, 期望 %d", p.Date, p.Duration, wantDur[k])
+		}
+	}
+	for _, k := range []int{0, 1} {
+		if !math.IsNaN(points[k].Peak) || !math.IsNaN(points[k].Drawdown) {
+			t.Errorf("%s: peak %v, drawdown %v, 期望 NaN", dates[k], points[k].Peak, points[k].Drawdown)
+		}
+	}
+	if dd := points[3].Drawdown; math.Abs(dd+0.2) > 1e-12 {
+		t.Errorf("d4: drawdown = %v, 期望 -0.2", dd)
+	}
+
+	depth, longest := maxDrawdown(points)
+	if math.Abs(depth+0.2) > 1e-12 || longest != 3 {
+		t.Errorf("maxDrawdown = %v, %d, 期望 -0.2, 3", depth, longest)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// 表头漂移检测
+// ---------------------------------------------------------
+// 同一个 glob 下的文件中途换了格式 (供应商加列、改列名) 时，以前新格式的文件会被逐个跳过或逐行丢弃，
+// 导入"成功"但新数据为零。现在导入前先读一遍所有表头，按指纹分组：只有一种格式时照旧；
+// 出现多种格式时打印各组的文件范围与列差异，每组分别选择映射 (profiles.go 的指纹布局)，
+// 任何一组无法映射 (没有匹配的布局、列数不足) 时直接报错退出，不写入任何数据。
+// 导入结束后，有数据行却一行都没写入的组同样报错并回滚。
+
+type schemaGroup struct {
+	Fingerprint string
+	Header      []string
+	Files       []string
+	Records     int // 读到的数据行
+	Short       int // 列数不足被丢弃的行
+	Rows        int // 写入的行
+}
+
+// 读取 CSV 表头 (分隔符同 importCSVFiles 自动识别)
+func readCSVHeader(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	_, _, headerLimit := fileBufferOptions(file)
+	br := bufio.NewReaderSize(f, headerLimit)
+	if _, err := skipBOM(br); err != nil {
+		return nil, err
+	}
+	line, _ := peekHeaderLine(br, headerLimit)
+	r := csv.NewReader(br)
+	r.Comma = sniffDelimiter(line)
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	return r.Read()
+}
+
+// 按表头指纹分组，组的顺序为首次出现的顺序 (glob 结果按文件名排序，通常即时间顺序)
+func groupCSVSchemas(files []string) ([]*schemaGroup, map[string]*schemaGroup) {
+	var groups []*schemaGroup
+	byFP, byFile := map[string]*schemaGroup{}, map[string]*schemaGroup{}
+	for _, file := range files {
+		header, err := readCSVHeader(file)
+		if err != nil {
+			continue
+		}
+		fp := headerFingerprint(header)
+		g := byFP[fp]
+		if g == nil {
+			g = &schemaGroup{Fingerprint: fp, Header: header}
+			byFP[fp] = g
+			groups = append(groups, g)
+		}
+		g.Files = append(g.Files, file)
+		byFile[file] = g
+	}
+	return groups, byFile
+}
+
+// 两个表头的差异：删除、新增、位置变化的列
+func headerDiff(from, to []string) string {
+	norm := func(h []string) map[string]int {
+		m := map[string]int{}
+		for k, s := range h {
+			m[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(s, "\ufeff")))] = k
+		}
+		return m
+	}
+	a, b := norm(from), norm(to)
+	var parts []string
+	for k, s := range from {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(s, "\ufeff")))
+		if j, ok := b[name]; !ok {
+			parts = append(parts, fmt.Sprintf("-%s(第%d列)", strings.TrimSpace(s), k))
+		} else if j != k {
+			parts = append(parts, fmt.Sprintf("~%s(第%d列->第%d列)", strings.TrimSpace(s), k, j))
+		}
+	}
+	for k, s := range to {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(s, "\ufeff")))
+		if _, ok := a[name]; !ok {
+			parts = append(parts, fmt.Sprintf("+%s(第%d列)", strings.TrimSpace(s), k))
+		}
+	}
+	if len(parts) == 0 {
+		return "列名相同"
+	}
+	return strings.Join(parts, " ")
+}
+
+func printSchemaDrift(pattern string, groups []*schemaGroup) {
+	log.Printf("[WARN] %s 下的文件有 %d 种表头格式:", pattern, len(groups))
+	for k, g := range groups {
+		span := filepath.Base(g.Files[0])
+		if len(g.Files) > 1 {
+			span += " ~ " + filepath.Base(g.Files[len(g.Files)-1])
+		}
+		log.Printf("[WARN]   格式 %d (指纹 %s, %d 列, %d 个文件: %s)", k+1, g.Fingerprint, len(g.Header), len(g.Files), span)
+		if k > 0 {
+			log.Printf("[WARN]     与格式 %d 相比: %s", k, headerDiff(groups[k-1].Header, g.Header))
+		}
+	}
+}
+
+// 每组分别生成映射；有任何一组无法映射时打印差异并退出
+func checkSchemaGroups(pattern string, groups []*schemaGroup, minCols int, newMapper func(header []string) rowMapper) {
+	var bad []string
+	for k, g := range groups {
+		switch {
+		case len(g.Header) < minCols:
+			bad = append(bad, fmt.Sprintf("格式 %d 只有 %d 列 (需要 %d)", k+1, len(g.Header), minCols))
+		case newMapper(g.Header) == nil:
+			bad = append(bad, fmt.Sprintf("格式 %d 没有匹配的列布局 (可用 chronos infer 生成后登记到 sources.yaml 的 profiles)", k+1))
+		}
+	}
+	if len(bad) > 0 {
+		log.Fatalf("[ERROR] %s 的表头格式发生变化，无法导入: %s", pattern, strings.Join(bad, "; "))
+	}
+}
+
+// 导入结束后检查：有数据行却一行都没写入的组
+func emptySchemaGroups(groups []*schemaGroup) []string {
+	var bad []string
+	for k, g := range groups {
+		if g.Records > 0 && g.Rows == 0 && g.Short > 0 {
+			bad = append(bad, fmt.Sprintf("格式 %d (%d 个文件) 的 %d 行全部因列数不足被丢弃", k+1, len(g.Files), g.Short))
+		}
+	}
+	return bad
+}
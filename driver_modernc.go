@@ -0,0 +1,79 @@
+//go:build !sqlite_cgo
+
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// ---------------------------------------------------------
+// SQLite 驱动: modernc.org/sqlite (默认)
+// ---------------------------------------------------------
+// 纯 Go 实现，不需要 C 编译器，交叉编译方便。批量导入更快的 cgo 驱动见 driver_cgo.go。
+
+const sqliteDriver = "sqlite"
+
+// 不做多行批量：绑定参数时对每个参数线性查找，多行 INSERT、拼 SQL 文本、JSON 参数都比逐行慢，
+// 逐行执行 (见 batch.go、BenchmarkBatchInserter)
+const insertBatchRows = 1
+
+var registerUDFsOnce sync.Once
+
+// 只读连接的 DSN：每个连接打开时设置 busy_timeout 与 query_only (见 readpool.go)
+func readOnlyDSN(path string, busyTimeout time.Duration) string {
+	q := url.Values{}
+	q.Set("mode", "ro")
+	q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	q.Add("_pragma", "query_only(1)")
+	return "file:" + filepath.ToSlash(path) + "?" + q.Encode()
+}
+
+// 注册只对之后新建的连接生效，必须在 sql.Open 之前调用
+func registerUDFs() {
+	registerUDFsOnce.Do(func() {
+		scalar := func(f func([]driver.Value) (driver.Value, error)) func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+			return func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) { return f(args) }
+		}
+		sqlite.MustRegisterDeterministicScalarFunction("log_return", 2, scalar(udfLogReturn))
+		sqlite.MustRegisterDeterministicScalarFunction("pct_change", 2, scalar(udfPctChange))
+		sqlite.MustRegisterDeterministicScalarFunction("winsorize", 3, scalar(udfWinsorize))
+		sqlite.MustRegisterDeterministicScalarFunction("local_time", 2, scalar(udfLocalTime))
+		sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, scalar(udfRegexp))
+		sqlite.MustRegisterFunction("quantile", &sqlite.FunctionImpl{
+			NArgs:         2,
+			Deterministic: true,
+			MakeAggregate: func(sqlite.FunctionContext) (sqlite.AggregateFunction, error) { return moderncAgg{&quantileAgg{}}, nil },
+		})
+		sqlite.MustRegisterFunction("ema", &sqlite.FunctionImpl{
+			NArgs:         2,
+			Deterministic: true,
+			MakeAggregate: func(sqlite.FunctionContext) (sqlite.AggregateFunction, error) { return moderncAgg{&emaAgg{}}, nil },
+		})
+	})
+}
+
+// windowAgg 适配 modernc 的 AggregateFunction / WindowFunction
+type moderncAgg struct{ windowAgg }
+
+func (a moderncAgg) Step(_ *sqlite.FunctionContext, args []driver.Value) error { return a.step(args) }
+
+func (a moderncAgg) WindowInverse(*sqlite.FunctionContext, []driver.Value) error { return a.inverse() }
+
+func (a moderncAgg) WindowValue(*sqlite.FunctionContext) (driver.Value, error) { return a.value(), nil }
+
+func (a moderncAgg) Final(*sqlite.FunctionContext) {}
+
+// 是否为违反约束 (NOT NULL、CHECK、STRICT 表的类型等) 的错误，见 importerr.go
+func isConstraintError(err error) bool {
+	var e *sqlite.Error
+	return errors.As(err, &e) && e.Code()&0xff == sqlite3.SQLITE_CONSTRAINT
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 东方财富 (AkShare 同源接口，无需 token)
+// ---------------------------------------------------------
+// 对应 AkShare 的 stock_zh_a_hist / stock_zh_index_daily_em：
+// 个股分别拉取不复权与后复权 K 线写入 staging 表，走与 CSV 导入相同的合并逻辑；
+// 该接口不提供市盈率，合并后 pe 为 NULL。指数日线直接写入 index_history。
+// 免费接口有频率限制，大批量拉取请降低并发、分批执行。
+
+const eastmoneyKlineURL = "https://push2his.eastmoney.com/api/qt/stock/kline/get"
+
+type emBar struct {
+	Date                   string // YYYY-MM-DD
+	Open, Close, High, Low float64
+	Volume, Amount         float64 // 成交量 (手)、成交额 (元)
+}
+
+// 东方财富证券 ID：沪市 1.xxxxxx，深市/北交所 0.xxxxxx。
+// 接受 600000.SH、sh600000 或纯 6 位代码 (按首位推断交易所)
+func emSecID(symbol string) (secid, canonical string, err error) {
+	s := strings.ToUpper(strings.TrimSpace(symbol))
+	code, exch := s, ""
+	switch {
+	case len(s) == 9 && s[6] == '.':
+		code, exch = s[:6], s[7:]
+	case len(s) == 8 && !isDigits(s[:2]):
+		code, exch = s[2:], s[:2]
+	case len(s) == 6:
+		switch s[0] {
+		case '6', '9':
+			exch = "SH"
+		case '4', '8':
+			exch = "BJ"
+		default:
+			exch = "SZ"
+		}
+	}
+	if exch == "SS" {
+		exch = "SH"
+	}
+	if len(code) != 6 || !isDigits(code) {
+		return "", "", fmt.Errorf("无法识别的 A 股代码: %s", symbol)
+	}
+	switch exch {
+	case "SH":
+		return "1." + code, code + ".SH", nil
+	case "SZ", "BJ":
+		return "0." + code, code + "." + exch, nil
+	}
+	return "", "", fmt.Errorf("无法识别的 A 股代码: %s", symbol)
+}
+
+// 拉取日 K 线；fqt: 0 不复权 / 1 前复权 / 2 后复权，beg/end 为 YYYYMMDD
+func emKlines(ctx context.Context, secid string, fqt int, beg, end string) ([]emBar, error) {
+	q := url.Values{
+		"secid":   {secid},
+		"fields1": {"f1,f2,f3,f4,f5,f6"},
+		"fields2": {"f51,f52,f53,f54,f55,f56,f57"},
+		"klt":     {"101"},
+		"fqt":     {fmt.Sprint(fqt)},
+		"beg":     {beg},
+		"end":     {end},
+	}
+	var resp struct {
+		Data *struct {
+			Klines []string `json:"klines"`
+		} `json:"data"`
+	}
+	u := eastmoneyKlineURL + "?" + q.Encode()
+	body, err := cachedFetch(ctx, "eastmoney", u, func() ([]byte, error) {
+		if err := apiWait(ctx, "eastmoney"); err != nil {
+			return nil, err
+		}
+		return getBody(ctx, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, nil // 代码不存在或区间内无数据
+	}
+	bars := make([]emBar, 0, len(resp.Data.Klines))
+	for _, line := range resp.Data.Klines {
+		f := strings.Split(line, ",")
+		if len(f) < 7 {
+			return nil, fmt.Errorf("K 线格式错误: %q", line)
+		}
+		bars = append(bars, emBar{
+			Date:   f[0],
+			Open:   jsonFloat(f[1]),
+			Close:  jsonFloat(f[2]),
+			High:   jsonFloat(f[3]),
+			Low:    jsonFloat(f[4]),
+			Volume: jsonFloat(f[5]),
+			Amount: jsonFloat(f[6]),
+		})
+	}
+	return bars, nil
+}
+
+// 个股日线写入 staging 表 (日期转为 YYYYMMDD 以复用 mergeStaging)，返回行数
+func stageEastmoneyStock(ctx context.Context, tx *sql.Tx, symbol, beg, end string) (int, error) {
+	secid, canonical, err := emSecID(symbol)
+	if err != nil {
+		return 0, err
+	}
+	raw, err := emKlines(ctx, secid, 0, beg, end)
+	if err != nil {
+		return 0, err
+	}
+	adj, err := emKlines(ctx, secid, 2, beg, end)
+	if err != nil {
+		return 0, err
+	}
+	rawByDate := make(map[string]emBar, len(raw))
+	for _, b := range raw {
+		rawByDate[b.Date] = b
+	}
+
+	n := 0
+	for _, a := range adj {
+		r, ok := rawByDate[a.Date]
+		if !ok {
+			continue
+		}
+		date := strings.ReplaceAll(a.Date, "-", "")
+		if _, err := tx.Exec("INSERT INTO staging_tech VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			canonical, date, r.Close, a.Close, a.Open, a.High, a.Low, r.Volume*100); err != nil {
+			return n, err
+		}
+		if _, err := tx.Exec("INSERT INTO staging_daily VALUES (?, ?, NULL)", canonical, date); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// 指数的证券 ID：没写交易所时 399xxx 为深证指数，其余 (000300 等) 为上证/中证指数，
+// 与个股 000xxx 在深市的规则相反
+func emIndexSecID(index string) (secid, canonical string, err error) {
+	s := strings.ToUpper(strings.TrimSpace(index))
+	if len(s) == 6 && isDigits(s) && !strings.HasPrefix(s, "399") {
+		s += ".SH"
+	}
+	return emSecID(s)
+}
+
+// 指数日线直接写入 index_history
+func saveEastmoneyIndex(ctx context.Context, db *sql.DB, secid, canonical, beg, end string) (int, error) {
+	bars, err := emKlines(ctx, secid, 0, beg, end)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, b := range bars {
+		if _, err := tx.Exec("INSERT OR REPLACE INTO index_history VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			canonical, b.Date, b.Open, b.High, b.Low, b.Close, b.Volume*100, b.Amount); err != nil {
+			return 0, err
+		}
+	}
+	return len(bars), tx.Commit()
+}
+
+// 某代码在表中的最后日期的次日 (YYYYMMDD)，没有数据时返回 fallback
+func nextFetchDate(db *sql.DB, query, symbol, fallback string) string {
+	var last sql.NullString
+	db.QueryRow(query, symbol).Scan(&last)
+	if !last.Valid {
+		return fallback
+	}
+	t, err := time.Parse(time.DateOnly, last.String)
+	if err != nil {
+		return fallback
+	}
+	return t.AddDate(0, 0, 1).Format("20060102")
+}
+
+// chronos eastmoney [--symbols 600000.SH,000001.SZ] [--index 000300.SH] [--from 20100101] [--to 20241231]
+func runEastmoney(args []string) {
+	fs := flag.NewFlagSet("eastmoney", flag.ExitOnError)
+	symbolsArg := fs.String("symbols", "", "股票代码列表文件或逗号分隔的代码 (默认库中全部 A 股)")
+	indexArg := fs.String("index", "", "同时拉取的指数代码，逗号分隔 (如 000300.SH,399006.SZ)")
+	from := fs.String("from", "", "起始日期 (YYYYMMDD 或 YYYY-MM-DD)，默认每只股票从库中最后一天之后续拉")
+	to := fs.String("to", time.Now().Format("20060102"), "结束日期 (含)")
+	batch := fs.Int("batch", 50, "每拉取多少只股票合并一次")
+	fs.Parse(args)
+
+	start := time.Now()
+	db := openOrCreateDB()
+	defer db.Close()
+	if err := setupSources(DefaultSourcesConfig); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	ensureDatasetTable(db, "index_history")
+	ctx := context.Background()
+	end := strings.ReplaceAll(*to, "-", "")
+	begin := func(query, symbol string) string {
+		if *from != "" {
+			return strings.ReplaceAll(*from, "-", "")
+		}
+		return nextFetchDate(db, query, symbol, "19900101")
+	}
+
+	var symbols []string
+	if *symbolsArg != "" {
+		var err error
+		if symbols, err = parseSymbols(*symbolsArg); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		symbols = marketSymbols(db, MarketCN)
+	}
+	if len(symbols) == 0 && *indexArg == "" {
+		log.Fatal("[ERROR] 没有要拉取的代码 (请指定 --symbols 或 --index)")
+	}
+
+	total := 0
+	for k := 0; k < len(symbols); k += *batch {
+		chunk := symbols[k:min(k+*batch, len(symbols))]
+		tx, err := db.Begin()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range chunk {
+			_, canonical, err := emSecID(s)
+			if err != nil {
+				log.Printf("[WARN] %v", err)
+				continue
+			}
+			n, err := stageEastmoneyStock(ctx, tx, s, begin("SELECT MAX(date) FROM stock_history WHERE symbol = ?", canonical), end)
+			if err != nil {
+				tx.Rollback()
+				log.Fatalf("[ERROR] %s: %v", s, err)
+			}
+			total += n
+		}
+		if err := tx.Commit(); err != nil {
+			log.Fatal(err)
+		}
+		mergeStaging(db)
+		log.Printf(">>> 已合并 %d/%d 只股票", k+len(chunk), len(symbols))
+	}
+
+	for _, idx := range splitList(*indexArg) {
+		secid, canonical, err := emIndexSecID(idx)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		n, err := saveEastmoneyIndex(ctx, db, secid, canonical, begin("SELECT MAX(date) FROM index_history WHERE index_code = ?", canonical), end)
+		if err != nil {
+			log.Fatalf("[ERROR] %s: %v", idx, err)
+		}
+		log.Printf(">>> 指数 %s: %d 行", idx, n)
+		total += n
+	}
+
+	mustExec(db, "DROP TABLE IF EXISTS staging_tech;")
+	mustExec(db, "DROP TABLE IF EXISTS staging_daily;")
+	createViews(db)
+	logAPIUsage()
+	log.Printf(">>> ✅ 东方财富拉取完成: %d 行, 耗时: %s", total, time.Since(start))
+}
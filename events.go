@@ -0,0 +1,91 @@
+package main
+
+// ---------------------------------------------------------
+// 事件数据：龙虎榜、大宗交易
+// ---------------------------------------------------------
+// 一只股票一天可能有多条记录，按营业部席位存储，便于做事件研究 (上榜后 N 日收益等)：
+//
+//	SELECT t.symbol, t.date, l.ret
+//	FROM (SELECT DISTINCT symbol, date FROM top_list WHERE seat LIKE '%机构专用%' AND side = 'B') t
+//	JOIN labels l ON l.symbol = t.symbol AND l.date = t.date AND l.horizon = 5
+
+// 龙虎榜 (按席位)
+// 索引：0:代码, 1:日期, 2:上榜原因, 3:营业部席位, 4:方向 (买/卖), 5:买入额, 6:卖出额
+func init() {
+	registerDataset(dataset{
+		Name:    "龙虎榜",
+		Pattern: PathTopList,
+		Table:   "top_list",
+		DDL: `CREATE TABLE IF NOT EXISTS top_list (
+			symbol       TEXT NOT NULL,
+			date         TEXT NOT NULL,
+			reason       TEXT NOT NULL,
+			seat         TEXT NOT NULL,      -- 营业部名称，机构席位为“机构专用”
+			side         TEXT NOT NULL,      -- B 买入前五 / S 卖出前五
+			buy_amount   REAL,
+			sell_amount  REAL,
+			PRIMARY KEY (symbol, date, reason, side, seat)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 5,
+		Mapper: func(record []string) []any {
+			date := normDate(record[1])
+			symbol, seat := normText(record[0]), normText(record[3])
+			side := normSide(record[4])
+			if date == nil || symbol == nil || seat == nil || side == nil {
+				return nil
+			}
+			reason := normText(record[2])
+			if reason == nil {
+				reason = ""
+			}
+			return []any{
+				symbol,
+				date,
+				reason,
+				seat,
+				side,
+				normNum(col(record, 5)),
+				normNum(col(record, 6)),
+			}
+		},
+	})
+
+	// 大宗交易 (逐笔)，同一天同价同席位的成交可能重复出现，用导入序号区分
+	// 索引：0:代码, 1:日期, 2:成交价, 3:成交量, 4:成交额, 5:买方营业部, 6:卖方营业部
+	seq := 0
+	registerDataset(dataset{
+		Name:    "大宗交易",
+		Pattern: PathBlockTrades,
+		Table:   "block_trades",
+		DDL: `CREATE TABLE IF NOT EXISTS block_trades (
+			symbol       TEXT NOT NULL,
+			date         TEXT NOT NULL,
+			seq          INTEGER NOT NULL,
+			price        REAL,
+			volume       REAL,
+			amount       REAL,
+			buyer_seat   TEXT,
+			seller_seat  TEXT,
+			PRIMARY KEY (symbol, date, seq)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 4,
+		Mapper: func(record []string) []any {
+			date := normDate(record[1])
+			symbol := normText(record[0])
+			if date == nil || symbol == nil {
+				return nil
+			}
+			seq++
+			return []any{
+				symbol,
+				date,
+				seq,
+				normNum(record[2]),
+				normNum(record[3]),
+				normNum(col(record, 4)),
+				normText(col(record, 5)),
+				normText(col(record, 6)),
+			}
+		},
+	})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 导出日线 (或重采样后的 K 线) 为 CSV；--crypto 时导出 crypto_bars 中该交易所的日线 (见 crypto.go)
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	symbolsArg := fs.String("symbols", "", "股票代码列表文件，或逗号分隔的代码 (默认全部)")
+	from := fs.String("from", "", "起始日期 (YYYY-MM-DD)")
+	to := fs.String("to", "", "截止日期 (YYYY-MM-DD)")
+	freqArg := fs.String("freq", "D", "频率: D/W/M/Q/Y，可加倍数如 5D、2W")
+	withDD := fs.Bool("drawdown", false, "附加回撤列 (历史高点、回撤幅度、回撤持续天数)")
+	currency := fs.String("currency", "", "把价格按当日汇率换算为该货币 (如 CNY，见 fx.go)，默认保留各市场原币")
+	crypto := fs.String("crypto", "", "导出数字货币日线而不是股票: binance | okx (--symbols 为交易对)")
+	perp := fs.Bool("perp", false, "与 --crypto 同用，导出永续合约而不是现货")
+	out := fs.String("out", "export.csv", "输出文件")
+	fs.Parse(args)
+	if *crypto != "" && *currency != "" {
+		log.Fatal("[ERROR] --currency 只适用于股票 (交易对的计价货币由交易对本身决定)")
+	}
+	assetClass := "spot"
+	if *perp {
+		assetClass = "perp"
+	}
+
+	freq, err := ParseFreq(*freqArg)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+	store := newStore(db)
+	ctx := context.Background()
+
+	symbols, err := parseSymbols(*symbolsArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(symbols) == 0 {
+		if *crypto != "" {
+			symbols, err = store.CryptoSymbols(ctx, *crypto, assetClass)
+		} else {
+			symbols, err = store.Symbols(ctx)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	header := []string{"symbol", "date", "close", "close_adj", "open_adj", "high_adj", "low_adj", "pe", "volume"}
+	if *withDD {
+		header = append(header, "peak_adj", "drawdown", "dd_duration")
+	}
+	w.Write(header)
+
+	rowCount, noRate := 0, 0
+	r := DateRange{From: *from, To: *to}
+	for _, sym := range symbols {
+		var bars []Bar
+		if *crypto != "" {
+			if bars, err = store.CryptoHistory(ctx, *crypto, assetClass, sym, r); err == nil {
+				bars, err = ResampleBars(bars, freq)
+			}
+		} else {
+			bars, err = store.Resample(ctx, sym, freq, r)
+		}
+		if err != nil {
+			log.Fatalf("导出 %s 失败: %v", sym, err)
+		}
+		if *currency != "" {
+			n, err := store.ConvertBars(ctx, bars, *currency)
+			if err != nil {
+				log.Fatalf("导出 %s 失败: %v", sym, err)
+			}
+			noRate += n
+		}
+		var dd []DrawdownPoint
+		if *withDD {
+			dd = barsDrawdown(bars)
+		}
+		for k, b := range bars {
+			record := []string{b.Symbol, b.Date, formatFloat(b.Close), formatFloat(b.CloseAdj),
+				formatFloat(b.OpenAdj), formatFloat(b.HighAdj), formatFloat(b.LowAdj), formatFloat(b.PE), formatFloat(b.Volume)}
+			if *withDD {
+				record = append(record, formatFloat(dd[k].Peak), formatFloat(dd[k].Drawdown), strconv.Itoa(dd[k].Duration))
+			}
+			w.Write(record)
+			rowCount++
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+	if noRate > 0 {
+		log.Printf("[WARN] %d 行没有可用的 %s 汇率，价格留空", noRate, strings.ToUpper(*currency))
+	}
+	log.Printf(">>> ✅ 导出完成: %d 只股票, %d 行 (频率 %s) -> %s, 耗时: %s", len(symbols), rowCount, freq, *out, time.Since(start))
+}
+
+// 逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// 股票列表参数：可以是文件路径 (每行一个代码，# 开头为注释)，也可以是逗号分隔的代码
+func parseSymbols(arg string) ([]string, error) {
+	if _, err := os.Stat(arg); err != nil {
+		return splitList(arg), nil
+	}
+	data, err := os.ReadFile(arg)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, splitList(line)...)
+	}
+	return out, nil
+}
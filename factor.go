@@ -0,0 +1,101 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 因子值统一存放在窄表中：一行 = (因子名, 代码, 日期, 数值)
+func ensureFactorTables(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS factor_values (
+		factor  TEXT NOT NULL,
+		symbol  TEXT NOT NULL,
+		date    TEXT NOT NULL,
+		value   REAL,
+		PRIMARY KEY (factor, symbol, date)
+	) WITHOUT ROWID, STRICT;`)
+}
+
+// stock_history 中可以直接当作因子使用的列
+var historyColumns = map[string]bool{
+	"close": true, "close_adj": true, "open_adj": true,
+	"high_adj": true, "low_adj": true, "pe": true, "volume": true,
+}
+
+// 视图中按 (symbol, date) 派生的列，同样可以直接当作因子使用：列名 -> 视图名
+var derivedColumns = map[string]string{}
+
+func registerDerivedColumn(name, view string) {
+	derivedColumns[name] = view
+}
+
+// 返回一个产出 (symbol, date, value) 的子查询：
+// 优先匹配 stock_history 的列名，其次是派生列和时点对齐的季度字段 (pit.go)，否则从 factor_values 中按因子名读取
+func factorSource(name string) string {
+	if historyColumns[name] {
+		return fmt.Sprintf("SELECT symbol, date, %s AS value FROM stock_history", name)
+	}
+	if view, ok := derivedColumns[name]; ok {
+		return fmt.Sprintf("SELECT symbol, date, %s AS value FROM %s", name, view)
+	}
+	if f, ok := pitFields[name]; ok {
+		return pitSource(f.Table, f.Expr)
+	}
+	return fmt.Sprintf("SELECT symbol, date, value FROM factor_values WHERE factor = %s", sqlQuote(name))
+}
+
+// 检查因子是否存在，不存在直接退出，避免后续算出一堆空结果
+func mustHaveFactor(db *sql.DB, name string) {
+	if _, ok := pitFields[name]; historyColumns[name] || derivedColumns[name] != "" || ok || registerFinField(db, name) {
+		return
+	}
+	ensureFactorTables(db)
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM (SELECT 1 FROM factor_values WHERE factor = ? LIMIT 1)", name).Scan(&n)
+	if n == 0 {
+		log.Fatalf("[ERROR] 因子不存在: %s (既不是 stock_history 列，也不在 factor_values 中)", name)
+	}
+}
+
+// 单引号转义，用于拼接进 SQL 的字面量
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// 批量写入因子值 (同名因子同一天的旧值会被覆盖)
+type factorWriter struct {
+	tx    *sql.Tx
+	stmt  *sql.Stmt
+	name  string
+	count int
+}
+
+func newFactorWriter(db *sql.DB, name string) *factorWriter {
+	ensureFactorTables(db)
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO factor_values VALUES (?, ?, ?, ?)")
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &factorWriter{tx: tx, stmt: stmt, name: name}
+}
+
+func (w *factorWriter) Write(symbol, date string, value float64) {
+	if _, err := w.stmt.Exec(w.name, symbol, date, value); err != nil {
+		log.Fatalf("写入因子失败 %s %s %s: %v", w.name, symbol, date, err)
+	}
+	w.count++
+}
+
+func (w *factorWriter) Close() int {
+	w.stmt.Close()
+	if err := w.tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	return w.count
+}
@@ -0,0 +1,88 @@
+// Package factor 是 chronos 的 Go 因子插件接口。
+//
+// SQL 写不出来的复杂因子 (例如用到分钟数据) 可以在自己的 Go 模块中实现 Factor，在 init() 中调用
+// Register 注册，再在 chronos 的构建中以空白导入引入该模块：
+//
+//	import _ "example.com/acme/chronos-factors"
+//
+// chronos factors build 在 SQL 因子之后统一调度已注册的因子，结果写入 factor_values，
+// 版本记录在 factor_registry 中。
+package factor
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// 日期区间 (闭区间，YYYY-MM-DD)，空字符串表示不限
+type DateRange struct {
+	From, To string
+}
+
+// 一根日线，缺失值为 NaN
+type Bar struct {
+	Symbol   string
+	Date     string
+	Close    float64 // 不复权收盘
+	CloseAdj float64
+	OpenAdj  float64
+	HighAdj  float64
+	LowAdj   float64
+	PE       float64
+	Volume   float64 // 股
+}
+
+// 计算因子时可用的查询 (chronos 的 Store)
+type Store interface {
+	// 全部股票代码
+	Symbols(ctx context.Context) ([]string, error)
+	// 一只股票区间内的日线，按日期排序
+	History(ctx context.Context, symbol string, r DateRange) ([]Bar, error)
+	// 某一交易日全部股票的日线截面，按代码排序
+	CrossSection(ctx context.Context, date string) ([]Bar, error)
+	// 底层连接，供需要自定义 SQL 的因子使用 (分钟线等)
+	DB() *sql.DB
+}
+
+type Value struct {
+	Symbol string
+	Date   string
+	Value  float64
+}
+
+type Factor interface {
+	Name() string
+	// 计算区间内的因子值，NaN 会被丢弃
+	Compute(ctx context.Context, store Store, r DateRange) ([]Value, error)
+}
+
+// 可选接口：实现逻辑变化时修改版本号，chronos 会据此重算
+type Versioned interface {
+	Factor
+	Version() string
+}
+
+var (
+	mu      sync.Mutex
+	factors []Factor
+)
+
+// 注册因子，名称重复时 panic (通常在 init() 中调用)
+func Register(f Factor) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, g := range factors {
+		if g.Name() == f.Name() {
+			panic("因子重复注册: " + f.Name())
+		}
+	}
+	factors = append(factors, f)
+}
+
+// 已注册的全部因子 (按注册顺序)
+func All() []Factor {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Factor(nil), factors...)
+}
@@ -0,0 +1,38 @@
+package factor_test
+
+import (
+	"context"
+	"testing"
+
+	"chronos/factor"
+)
+
+// 在 chronos 之外实现并注册的因子
+type lastClose struct{}
+
+func (lastClose) Name() string { return "test_last_close" }
+
+func (lastClose) Version() string { return "2" }
+
+func (lastClose) Compute(ctx context.Context, store factor.Store, r factor.DateRange) ([]factor.Value, error) {
+	return []factor.Value{{Symbol: "A", Date: r.To, Value: 1}}, nil
+}
+
+func TestRegister(t *testing.T) {
+	factor.Register(lastClose{})
+	var found factor.Factor
+	for _, f := range factor.All() {
+		if f.Name() == "test_last_close" {
+			found = f
+		}
+	}
+	if v, ok := found.(factor.Versioned); !ok || v.Version() != "2" {
+		t.Fatalf("注册的因子 = %v", found)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("重复注册应当 panic")
+		}
+	}()
+	factor.Register(lastClose{})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"chronos/factor"
+)
+
+// ---------------------------------------------------------
+// Go 因子插件
+// ---------------------------------------------------------
+// SQL 写不出来的复杂因子 (例如用到分钟数据) 可以在单独的 Go 模块中实现 factor.Factor 接口
+// (chronos/factor 包)，在 init() 中调用 factor.Register 注册，再在这里空白导入该模块一起编译。
+// `chronos factors build` 会在 SQL 因子之后统一调度它们，结果写入 factor_values，版本记录在
+// factor_registry 中。插件通过 factor.Store 查询，这里用 pluginStore 把 Store 适配过去。
+// 实现示例见 factor_plugin_test.go 中的 volatilityFactor。
+
+// 已注册的 Go 因子
+func goFactors() []factor.Factor {
+	return factor.All()
+}
+
+// Store 适配为 factor.Store (两边的 DateRange、Bar 字段相同，直接转换)
+type pluginStore struct {
+	*Store
+}
+
+var _ factor.Store = pluginStore{}
+
+func (p pluginStore) History(ctx context.Context, symbol string, r factor.DateRange) ([]factor.Bar, error) {
+	bars, err := p.Store.History(ctx, symbol, DateRange(r))
+	return pluginBars(bars), err
+}
+
+func (p pluginStore) CrossSection(ctx context.Context, date string) ([]factor.Bar, error) {
+	bars, err := p.Store.CrossSection(ctx, date)
+	return pluginBars(bars), err
+}
+
+func pluginBars(bars []Bar) []factor.Bar {
+	out := make([]factor.Bar, len(bars))
+	for k, b := range bars {
+		out[k] = factor.Bar(b)
+	}
+	return out
+}
+
+func goFactorHash(f factor.Factor) string {
+	if v, ok := f.(factor.Versioned); ok {
+		return "go:" + v.Version()
+	}
+	return "go"
+}
+
+// Go 因子的输入版本：行情版本加上本次计算的区间 (--from/--to)
+func goFactorInput(version string, r DateRange) string {
+	return version + "|" + r.From + "|" + r.To
+}
+
+// 行情未变且已物化的区间覆盖本次请求的区间时返回空字符串 (可以跳过)，否则返回重算原因
+func goFactorStale(input, version string, r DateRange) string {
+	parts := strings.Split(input, "|")
+	if len(parts) != 3 || parts[0] != version {
+		return "行情数据已更新"
+	}
+	built := DateRange{From: parts[1], To: parts[2]}
+	if built.From != "" && (r.From == "" || r.From < built.From) || built.To != "" && (r.To == "" || r.To > built.To) {
+		return fmt.Sprintf("计算区间扩大: [%s, %s] -> [%s, %s]", built.From, built.To, r.From, r.To)
+	}
+	return ""
+}
+
+// 调度所有 Go 因子，返回重算的个数
+func buildGoFactors(ctx context.Context, db *sql.DB, r DateRange, version string, rebuilt map[string]bool, full bool) (built, skipped int) {
+	store := pluginStore{newStore(db)}
+	for _, f := range goFactors() {
+		reason := "全量重算"
+		if !full {
+			var hash, inputVersion string
+			err := db.QueryRow("SELECT def_hash, input_version FROM factor_registry WHERE name = ?", f.Name()).Scan(&hash, &inputVersion)
+			switch {
+			case err == sql.ErrNoRows:
+				reason = "新因子"
+			case err != nil:
+				log.Fatal(err)
+			case hash != goFactorHash(f):
+				reason = "版本已变化"
+			default:
+				reason = goFactorStale(inputVersion, version, r)
+			}
+		}
+		if reason == "" {
+			skipped++
+			continue
+		}
+
+		t := time.Now()
+		log.Printf(">>> 正在计算 Go 因子 %s (%s)...", f.Name(), reason)
+		values, err := f.Compute(ctx, store, factor.DateRange(r))
+		if err != nil {
+			log.Fatalf("Go 因子 %s 计算失败: %v", f.Name(), err)
+		}
+		n := saveGoFactor(db, f, r, values, version)
+		rebuilt[f.Name()] = true
+		built++
+		log.Printf(">>> 因子 %s 完成: %d 行, 耗时: %s", f.Name(), n, time.Since(t))
+	}
+	return built, skipped
+}
+
+// 先删除区间内旧值再写入，保证重算后不残留过期数据
+func saveGoFactor(db *sql.DB, f factor.Factor, r DateRange, values []factor.Value, version string) int {
+	ensureFactorRegistry(db)
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	cond, args := r.where("date")
+	if _, err := tx.Exec("DELETE FROM factor_values WHERE factor = ? AND "+cond, append([]any{f.Name()}, args...)...); err != nil {
+		log.Fatal(err)
+	}
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO factor_values VALUES (?, ?, ?, ?)")
+	if err != nil {
+		log.Fatal(err)
+	}
+	n := 0
+	for _, v := range values {
+		if math.IsNaN(v.Value) || math.IsInf(v.Value, 0) {
+			continue
+		}
+		if _, err := stmt.Exec(f.Name(), v.Symbol, v.Date, v.Value); err != nil {
+			log.Fatalf("写入因子失败 %s %s %s: %v", f.Name(), v.Symbol, v.Date, err)
+		}
+		n++
+	}
+	stmt.Close()
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO factor_registry VALUES (?, ?, ?, ?, ?, ?)`,
+		f.Name(), fmt.Sprintf("Go 因子 (%T)", f), goFactorHash(f), goFactorInput(version, r), time.Now().Format(time.DateTime), n)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	return n
+}
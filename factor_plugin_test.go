@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"chronos/factor"
+)
+
+// ---------------------------------------------------------
+// Go 因子示例：N 日年化波动率
+// ---------------------------------------------------------
+// 实际使用时放在单独的模块里，在 init() 中调用 factor.Register 注册
+
+type volatilityFactor struct {
+	window int
+}
+
+func (f volatilityFactor) Name() string    { return fmt.Sprintf("vol_%d", f.window) }
+func (f volatilityFactor) Version() string { return "1" }
+
+func (f volatilityFactor) Compute(ctx context.Context, store factor.Store, r factor.DateRange) ([]factor.Value, error) {
+	symbols, err := store.Symbols(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []factor.Value
+	for _, sym := range symbols {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		bars, err := store.History(ctx, sym, factor.DateRange{To: r.To})
+		if err != nil {
+			return nil, err
+		}
+		rets := make([]float64, len(bars))
+		for k := 1; k < len(bars); k++ {
+			rets[k] = bars[k].CloseAdj/bars[k-1].CloseAdj - 1
+		}
+		for k := f.window; k < len(bars); k++ {
+			if r.From != "" && bars[k].Date < r.From {
+				continue
+			}
+			out = append(out, factor.Value{Symbol: sym, Date: bars[k].Date, Value: stddev(rets[k-f.window+1:k+1]) * math.Sqrt(252)})
+		}
+	}
+	return out, nil
+}
+
+var registerExampleFactor sync.Once
+
+// 区间扩大 (--from 提前或 --to 推后) 时不能因为版本未变而跳过
+func TestBuildGoFactorsRange(t *testing.T) {
+	registerExampleFactor.Do(func() { factor.Register(volatilityFactor{window: 2}) })
+	db := openTestDB(t, "gofactors.db")
+	createTables(db)
+	ensureFactorRegistry(db)
+	for k, close := range []float64{10, 11, 10.5, 12, 11.5, 12.5, 13} {
+		mustExec(db, "INSERT INTO stock_history (symbol, date, close_adj, market) VALUES ('000001', ?, ?, 'CN')",
+			fmt.Sprintf("2024-01-0%d", k+1), close)
+	}
+	version := historyVersion(db)
+	ctx := context.Background()
+
+	for _, c := range []struct {
+		r     DateRange
+		built int
+		rows  int
+	}{
+		{DateRange{From: "2024-01-05"}, 1, 3},
+		{DateRange{From: "2024-01-05"}, 0, 3}, // 同一区间，跳过
+		{DateRange{From: "2024-01-06"}, 0, 3}, // 已覆盖，跳过
+		{DateRange{From: "2024-01-04"}, 1, 4}, // 区间扩大
+		{DateRange{}, 1, 5},
+		{DateRange{To: "2024-01-05"}, 0, 5},
+	} {
+		built, _ := buildGoFactors(ctx, db, c.r, version, map[string]bool{}, false)
+		var rows int
+		db.QueryRow("SELECT COUNT(*) FROM factor_values WHERE factor = 'vol_2'").Scan(&rows)
+		if built != c.built || rows != c.rows {
+			t.Errorf("%+v: built = %d, rows = %d, want %d, %d", c.r, built, rows, c.built, c.rows)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 因子注册表：用户在 factors.yaml 中用 SQL 定义因子，例如
+//
+//	factors:
+//	  - name: pe_rank
+//	    description: PE 截面分位数
+//	    sql: |
+//	      SELECT symbol, date, PERCENT_RANK() OVER (PARTITION BY date ORDER BY pe) AS value
+//	      FROM stock_history WHERE pe IS NOT NULL
+//	  - name: mom_20
+//	    expr: close_adj / LAG(close_adj, 20) OVER (PARTITION BY symbol ORDER BY date) - 1
+//	  - name: pe_rank_chg
+//	    sql: |
+//	      SELECT symbol, date, value - LAG(value, 5) OVER (PARTITION BY symbol ORDER BY date) AS value
+//	      FROM {{pe_rank}}
+//
+// expr 是基于 stock_history 单行的表达式 (可用窗口函数)；sql 是完整查询，须产出 symbol, date, value 三列。
+// {{name}} 引用其他因子或 stock_history 的列，展开为 (symbol, date, value) 子查询，并自动成为依赖。
+//
+// 行情只追加了新日期时按增量构建：只计算上次物化日期之后的行，每只股票额外带上 lookback 行历史
+// 供 LAG / ROWS n PRECEDING 使用 (见 factorLookback)，无法推断回看长度时可在定义里写 lookback: 60。
+// 旧日期的行情被改写 (复权、修正、补数据) 或依赖被全量重算时，回退为全量重算。
+const DefaultFactorConfig = "factors.yaml"
+
+type factorDef struct {
+	Name        string
+	Description string
+	Expr        string
+	SQL         string
+	Depends     []string
+	Lookback    int // 增量构建时每只股票回看的行数，-1 表示从查询中推断
+}
+
+var factorRefPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+func runFactors(args []string) {
+	if len(args) == 0 {
+		log.Fatal("[ERROR] 用法: chronos factors build|list [参数]")
+	}
+	switch args[0] {
+	case "build":
+		runFactorsBuild(args[1:])
+	case "list":
+		runFactorsList(args[1:])
+	default:
+		log.Fatalf("[ERROR] 未知子命令: factors %s", args[0])
+	}
+}
+
+func loadFactorDefs(path string) ([]factorDef, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	root, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: 顶层必须是映射", path)
+	}
+
+	var defs []factorDef
+	seen := map[string]bool{}
+	for _, m := range yamlList(root, "factors") {
+		d := factorDef{
+			Name:        yamlString(m, "name"),
+			Description: yamlString(m, "description"),
+			Expr:        strings.TrimSpace(yamlString(m, "expr")),
+			SQL:         strings.TrimSpace(yamlString(m, "sql")),
+			Depends:     yamlStrings(m, "depends"),
+			Lookback:    -1,
+		}
+		if v := yamlString(m, "lookback"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s: 因子 %s 的 lookback 必须是非负整数: %s", path, d.Name, v)
+			}
+			d.Lookback = n
+		}
+		switch {
+		case d.Name == "":
+			return nil, fmt.Errorf("%s: 存在未命名的因子", path)
+		case seen[d.Name]:
+			return nil, fmt.Errorf("%s: 因子重复定义: %s", path, d.Name)
+		case historyColumns[d.Name]:
+			return nil, fmt.Errorf("%s: 因子名与 stock_history 列冲突: %s", path, d.Name)
+		case pitFields[d.Name] != (pitField{}) || derivedColumns[d.Name] != "":
+			return nil, fmt.Errorf("%s: 因子名与季度字段冲突: %s", path, d.Name)
+		case (d.Expr == "") == (d.SQL == ""):
+			return nil, fmt.Errorf("%s: 因子 %s 必须且只能指定 expr 或 sql 之一", path, d.Name)
+		}
+		seen[d.Name] = true
+		for _, ref := range factorRefPattern.FindAllStringSubmatch(d.Expr+d.SQL, -1) {
+			d.Depends = append(d.Depends, ref[1])
+		}
+		defs = append(defs, d)
+	}
+	return defs, nil
+}
+
+// 展开为最终执行的查询
+func (d factorDef) query() string {
+	q := d.SQL
+	if d.Expr != "" {
+		q = fmt.Sprintf("SELECT symbol, date, %s AS value FROM stock_history", d.Expr)
+	}
+	return factorRefPattern.ReplaceAllStringFunc(q, func(m string) string {
+		name := factorRefPattern.FindStringSubmatch(m)[1]
+		return "(" + factorSource(name) + ")"
+	})
+}
+
+// 定义指纹：查询文本或依赖变化都会触发重算
+func (d factorDef) hash() string {
+	deps := append([]string(nil), d.Depends...)
+	sort.Strings(deps)
+	sum := sha256.Sum256([]byte(d.query() + "\x00" + strings.Join(deps, ",")))
+	return hex.EncodeToString(sum[:8])
+}
+
+var (
+	windowCallPattern = regexp.MustCompile(`(?i)\b(\w+)\s*\(((?:[^()]|\([^()]*\))*)\)\s*over\s*\(([^()]*)\)`)
+	overPattern       = regexp.MustCompile(`(?i)\bover\b`)
+	partitionPattern  = regexp.MustCompile(`(?i)partition\s+by\s+(.*?)(?:\s+order\s+by\b|\s+rows\b|\s+range\b|\s+groups\b|$)`)
+	precedingPattern  = regexp.MustCompile(`(?i)\brows\s+(?:between\s+)?(\d+)\s+preceding(?:\s+and\s+current\s+row)?\s*$`)
+)
+
+// 增量构建时每只股票需要回看的行数。没有窗口函数或只在同一日期内开窗 (截面排名) 为 0；
+// LAG(x, n) 与 ROWS n PRECEDING 取 n；LEAD、累计窗口等依赖全部历史或未来数据的，返回 false 只能全量重算
+func (d factorDef) lookback() (int, bool) {
+	if d.Lookback >= 0 {
+		return d.Lookback, true
+	}
+	q := d.query()
+	calls := windowCallPattern.FindAllStringSubmatch(q, -1)
+	if len(calls) != len(overPattern.FindAllString(q, -1)) {
+		return 0, false // 命名窗口 (OVER w) 或嵌套太深，无法解析
+	}
+	n := 0
+	for _, c := range calls {
+		fn, args, spec := strings.ToLower(c[1]), c[2], strings.TrimSpace(c[3])
+		if m := partitionPattern.FindStringSubmatch(spec); m != nil && slices.ContainsFunc(strings.Split(m[1], ","), func(col string) bool {
+			return strings.EqualFold(strings.TrimSpace(col), "date")
+		}) {
+			continue
+		}
+		k := -1
+		if fn == "lag" {
+			k = 1
+			if parts := strings.Split(args, ","); len(parts) > 1 {
+				k, _ = strconv.Atoi(strings.TrimSpace(parts[1]))
+			}
+		} else if m := precedingPattern.FindStringSubmatch(spec); m != nil {
+			k, _ = strconv.Atoi(m[1])
+		}
+		if k <= 0 {
+			return 0, false
+		}
+		n = max(n, k)
+	}
+	return n, true
+}
+
+// 按依赖拓扑排序；依赖未在注册表中定义的，要求是 stock_history 列 (或已存在于 factor_values)
+func sortFactorDefs(defs []factorDef) ([]factorDef, error) {
+	byName := map[string]factorDef{}
+	for _, d := range defs {
+		byName[d.Name] = d
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var order []factorDef
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("因子存在循环依赖: %s", strings.Join(append(path, name), " -> "))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range byName[name].Depends {
+			if _, ok := byName[dep]; ok {
+				if err := visit(dep, append(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = done
+		order = append(order, byName[name])
+		return nil
+	}
+	for _, d := range defs {
+		if err := visit(d.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func ensureFactorRegistry(db *sql.DB) {
+	ensureFactorTables(db)
+	mustExec(db, `CREATE TABLE IF NOT EXISTS factor_registry (
+		name           TEXT PRIMARY KEY,
+		description    TEXT,
+		def_hash       TEXT NOT NULL,
+		input_version  TEXT NOT NULL,
+		built_at       TEXT NOT NULL,
+		row_count      INTEGER NOT NULL
+	) STRICT;`)
+	// SQL 因子的物化进度：last_date 之前的行情签名不变时，只需增量计算之后的日期
+	mustExec(db, `CREATE TABLE IF NOT EXISTS factor_progress (
+		name       TEXT PRIMARY KEY,
+		last_date  TEXT NOT NULL,
+		base_sig   TEXT NOT NULL
+	) STRICT;`)
+}
+
+// stock_history 的数据版本 (行数 + 最新日期)，重新导入后因子需要重算
+func historyVersion(db *sql.DB) string {
+	var n int
+	var maxDate sql.NullString
+	db.QueryRow("SELECT COUNT(*), MAX(date) FROM stock_history").Scan(&n, &maxDate)
+	return fmt.Sprintf("%d@%s", n, maxDate.String)
+}
+
+// through (含) 之前 stock_history 的签名：行数与各数值列之和，用来发现旧日期被改写 (如复权因子更新)
+func historySignature(db *sql.DB, through string) string {
+	var n int64
+	var sums [7]float64
+	err := db.QueryRow(`SELECT COUNT(*), TOTAL(close), TOTAL(close_adj), TOTAL(open_adj), TOTAL(high_adj),
+		TOTAL(low_adj), TOTAL(pe), TOTAL(volume) FROM stock_history WHERE date <= ?`, through).
+		Scan(&n, &sums[0], &sums[1], &sums[2], &sums[3], &sums[4], &sums[5], &sums[6])
+	if err != nil {
+		log.Fatal(err)
+	}
+	parts := []string{strconv.FormatInt(n, 10)}
+	for _, v := range sums {
+		parts = append(parts, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return strings.Join(parts, "/")
+}
+
+func runFactorsBuild(args []string) {
+	fs := flag.NewFlagSet("factors build", flag.ExitOnError)
+	config := fs.String("config", DefaultFactorConfig, "因子定义文件")
+	full := fs.Bool("full", false, "忽略已有结果，全部重算")
+	from := fs.String("from", "", "Go 因子的计算起始日期 (YYYY-MM-DD)")
+	to := fs.String("to", "", "Go 因子的计算截止日期 (YYYY-MM-DD)")
+	fs.Parse(args)
+
+	start := time.Now()
+	defs, err := loadFactorDefs(*config)
+	if os.IsNotExist(err) && len(goFactors()) > 0 {
+		log.Printf("[WARN] 未找到 %s，只构建 Go 因子", *config)
+	} else if err != nil {
+		log.Fatalf("[ERROR] 读取因子定义失败: %v", err)
+	}
+	order, err := sortFactorDefs(defs)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	db := openDB()
+	defer db.Close()
+	ensureFactorRegistry(db)
+	version := historyVersion(db)
+
+	defined := map[string]bool{}
+	for _, d := range defs {
+		defined[d.Name] = true
+	}
+	rebuilt := map[string]bool{}   // 本次有变化的因子 (含增量)
+	rewritten := map[string]bool{} // 本次全量重算的因子，旧日期的值可能变了
+	sigs := map[string]string{}    // last_date -> 行情签名，多个因子共用
+	signature := func(date string) string {
+		if _, ok := sigs[date]; !ok {
+			sigs[date] = historySignature(db, date)
+		}
+		return sigs[date]
+	}
+	var built, skipped int
+	for _, d := range order {
+		for _, dep := range d.Depends {
+			if !defined[dep] {
+				mustHaveFactor(db, dep)
+			}
+		}
+
+		reason, dataOnly := factorRebuildReason(db, d, version, rebuilt, *full)
+		if reason == "" {
+			skipped++
+			continue
+		}
+		since := ""
+		if dataOnly {
+			since = factorIncrementalFrom(db, d, rewritten, signature)
+		}
+
+		t := time.Now()
+		if since != "" {
+			log.Printf(">>> 正在增量构建因子 %s (%s, %s 之后)...", d.Name, reason, since)
+		} else {
+			log.Printf(">>> 正在构建因子 %s (%s)...", d.Name, reason)
+			rewritten[d.Name] = true
+		}
+		n := materializeFactor(db, d, version, since, signature)
+		rebuilt[d.Name] = true
+		built++
+		log.Printf(">>> 因子 %s 完成: %d 行, 耗时: %s", d.Name, n, time.Since(t))
+	}
+
+	// Go 因子可能依赖 SQL 因子，放在最后调度
+	goBuilt, goSkipped := buildGoFactors(context.Background(), db, DateRange{From: *from, To: *to}, version, rebuilt, *full)
+	built += goBuilt
+	skipped += goSkipped
+
+	log.Printf(">>> ✅ 因子构建完成: 重算 %d 个, 跳过 %d 个 (无变化), 耗时: %s", built, skipped, time.Since(start))
+}
+
+// 返回需要重算的原因，空字符串表示可以跳过；dataOnly 表示定义未变、只是输入数据有变化，可以尝试增量
+func factorRebuildReason(db *sql.DB, d factorDef, version string, rebuilt map[string]bool, full bool) (reason string, dataOnly bool) {
+	if full {
+		return "全量重算", false
+	}
+	var hash, inputVersion string
+	err := db.QueryRow("SELECT def_hash, input_version FROM factor_registry WHERE name = ?", d.Name).Scan(&hash, &inputVersion)
+	switch {
+	case err == sql.ErrNoRows:
+		return "新因子", false
+	case err != nil:
+		log.Fatal(err)
+	case hash != d.hash():
+		return "定义已修改", false
+	case inputVersion != version:
+		return "行情数据已更新", true
+	}
+	for _, dep := range d.Depends {
+		if rebuilt[dep] {
+			return "依赖 " + dep + " 已重算", true
+		}
+	}
+	return "", false
+}
+
+// 可以增量构建时返回上次物化到的日期，否则返回空字符串 (全量重算)
+func factorIncrementalFrom(db *sql.DB, d factorDef, rewritten map[string]bool, signature func(string) string) string {
+	for _, dep := range d.Depends {
+		if rewritten[dep] {
+			return ""
+		}
+	}
+	if _, ok := d.lookback(); !ok {
+		return ""
+	}
+	var lastDate, baseSig string
+	if err := db.QueryRow("SELECT last_date, base_sig FROM factor_progress WHERE name = ?", d.Name).Scan(&lastDate, &baseSig); err != nil {
+		return ""
+	}
+	if signature(lastDate) != baseSig {
+		return "" // 旧日期的行情有变化
+	}
+	return lastDate
+}
+
+// 物化因子，返回因子的总行数。since 非空时只重算该日期之后的行：
+// 用同名 CTE 遮蔽 stock_history，每只股票只保留 since 之前 lookback 行及之后的数据
+// (视图内部引用的仍是原表)，其余日期的结果原样保留
+func materializeFactor(db *sql.DB, d factorDef, version, since string, signature func(string) string) int64 {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`INSERT INTO factor_values
+		SELECT ?, symbol, date, value FROM (%s) WHERE value IS NOT NULL`, d.query())
+	args := []any{d.Name}
+	if since == "" {
+		if _, err := tx.Exec("DELETE FROM factor_values WHERE factor = ?", d.Name); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		lookback, _ := d.lookback()
+		if _, err := tx.Exec("DELETE FROM factor_values WHERE factor = ? AND date > ?", d.Name, since); err != nil {
+			log.Fatal(err)
+		}
+		if _, err := tx.Exec("DROP TABLE IF EXISTS temp.factor_window"); err != nil {
+			log.Fatal(err)
+		}
+		// after: 每只股票 since (含) 之前倒数第 lookback+1 行的日期，只取其后的行；没有这么多行则取全部
+		_, err := tx.Exec(`CREATE TEMP TABLE factor_window AS
+			SELECT symbol, COALESCE((SELECT s.date FROM main.stock_history s
+				WHERE s.symbol = d.symbol AND s.date <= ? ORDER BY s.date DESC LIMIT 1 OFFSET ?), '') AS after
+			FROM (SELECT DISTINCT symbol FROM main.stock_history) d`, since, lookback)
+		if err != nil {
+			log.Fatal(err)
+		}
+		query = `WITH stock_history AS (
+			SELECT h.* FROM main.stock_history h
+			INNER JOIN temp.factor_window w ON w.symbol = h.symbol AND h.date > w.after
+		) ` + query + ` AND date > ?`
+		args = append(args, since)
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		log.Fatalf("因子 %s 执行失败: %v | Query: %s", d.Name, err, query)
+	}
+	if since != "" {
+		if _, err := tx.Exec("DROP TABLE temp.factor_window"); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var n int64
+	var lastDate sql.NullString
+	if err := tx.QueryRow("SELECT COUNT(*), MAX(date) FROM factor_values WHERE factor = ?", d.Name).Scan(&n, &lastDate); err != nil {
+		log.Fatal(err)
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO factor_registry VALUES (?, ?, ?, ?, ?, ?)`,
+		d.Name, d.Description, d.hash(), version, time.Now().Format(time.DateTime), n)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := tx.Exec("DELETE FROM factor_progress WHERE name = ?", d.Name); err != nil {
+		log.Fatal(err)
+	}
+	if lastDate.Valid {
+		_, err = tx.Exec("INSERT INTO factor_progress VALUES (?, ?, ?)", d.Name, lastDate.String, signature(lastDate.String))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	return n
+}
+
+func runFactorsList(args []string) {
+	fs := flag.NewFlagSet("factors list", flag.ExitOnError)
+	fs.Parse(args)
+
+	db := openDB()
+	defer db.Close()
+	ensureFactorRegistry(db)
+
+	rows, err := db.Query("SELECT name, description, built_at, row_count FROM factor_registry ORDER BY name")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, built string
+		var desc sql.NullString
+		var n int64
+		if err := rows.Scan(&name, &desc, &built, &n); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%-24s %12d 行  %s  %s\n", name, n, built, desc.String)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFactorLookback(t *testing.T) {
+	cases := []struct {
+		def  factorDef
+		want int
+		ok   bool
+	}{
+		{factorDef{Expr: "close_adj * 2", Lookback: -1}, 0, true},
+		{factorDef{Expr: "close_adj / LAG(close_adj, 20) OVER (PARTITION BY symbol ORDER BY date) - 1", Lookback: -1}, 20, true},
+		{factorDef{SQL: "SELECT symbol, date, PERCENT_RANK() OVER (PARTITION BY date ORDER BY pe) AS value FROM stock_history", Lookback: -1}, 0, true},
+		{factorDef{Expr: "AVG(volume) OVER (PARTITION BY symbol ORDER BY date ROWS BETWEEN 9 PRECEDING AND CURRENT ROW)", Lookback: -1}, 9, true},
+		{factorDef{Expr: "LAG(close_adj) OVER (PARTITION BY symbol ORDER BY date)", Lookback: -1}, 1, true},
+		{factorDef{Expr: "SUM(close_adj) OVER (PARTITION BY symbol ORDER BY date)", Lookback: -1}, 0, false},
+		{factorDef{Expr: "LEAD(close_adj, 5) OVER (PARTITION BY symbol ORDER BY date)", Lookback: -1}, 0, false},
+		{factorDef{Expr: "SUM(close_adj) OVER (PARTITION BY symbol ORDER BY date)", Lookback: 60}, 60, true},
+	}
+	for _, c := range cases {
+		got, ok := c.def.lookback()
+		if got != c.want || ok != c.ok {
+			t.Errorf("%s: lookback = %d, %v, want %d, %v", c.def.query(), got, ok, c.want, c.ok)
+		}
+	}
+}
+
+// 追加新日期后增量构建的结果应与全量重算一致；旧日期被改写时必须回退为全量
+func TestMaterializeFactorIncremental(t *testing.T) {
+	db := openTestDB(t, "factors.db")
+	mustExec(db, "PRAGMA journal_mode = WAL;")
+	createTables(db)
+	ensureFactorRegistry(db)
+
+	day0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	insert := func(from, to int) {
+		for i := from; i < to; i++ {
+			date := day0.AddDate(0, 0, i).Format(time.DateOnly)
+			for j, symbol := range []string{"000001", "000002", "600000"} {
+				if symbol == "000002" && i >= 25 && i < 33 {
+					continue // 停牌跨过增量边界
+				}
+				mustExec(db, "INSERT INTO stock_history (symbol, date, close_adj, market) VALUES (?, ?, ?, 'CN')",
+					symbol, date, 10+float64(j)+float64(i*i%17)/10)
+			}
+		}
+	}
+	signature := func(date string) string { return historySignature(db, date) }
+	dump := func(name string) string {
+		return dumpTable(t, db, "SELECT symbol, date, value FROM factor_values WHERE factor = '"+name+"' ORDER BY symbol, date")
+	}
+
+	defs := []factorDef{
+		{Name: "mom_5", Expr: "close_adj / LAG(close_adj, 5) OVER (PARTITION BY symbol ORDER BY date) - 1", Lookback: -1},
+		{Name: "rank", Expr: "PERCENT_RANK() OVER (PARTITION BY date ORDER BY close_adj)", Lookback: -1},
+		{Name: "cum", Expr: "SUM(close_adj) OVER (PARTITION BY symbol ORDER BY date)", Lookback: -1},
+	}
+	insert(0, 30)
+	for _, d := range defs {
+		materializeFactor(db, d, historyVersion(db), "", signature)
+	}
+
+	insert(30, 40)
+	version := historyVersion(db)
+	for _, d := range defs {
+		reason, dataOnly := factorRebuildReason(db, d, version, nil, false)
+		if reason == "" || !dataOnly {
+			t.Fatalf("%s: reason = %q, %v", d.Name, reason, dataOnly)
+		}
+		since := factorIncrementalFrom(db, d, nil, signature)
+		if _, ok := d.lookback(); ok != (since != "") {
+			t.Fatalf("%s: since = %q", d.Name, since)
+		}
+		materializeFactor(db, d, version, since, signature)
+		incremental := dump(d.Name)
+		materializeFactor(db, d, version, "", signature)
+		if full := dump(d.Name); incremental != full {
+			t.Errorf("%s: 增量结果与全量不一致\n增量:\n%s\n全量:\n%s", d.Name, incremental, full)
+		}
+	}
+
+	// 旧日期复权价被改写
+	mustExec(db, "UPDATE stock_history SET close_adj = close_adj * 1.1 WHERE date = ?", day0.AddDate(0, 0, 3).Format(time.DateOnly))
+	if since := factorIncrementalFrom(db, defs[0], nil, signature); since != "" {
+		t.Errorf("旧日期改写后仍按增量构建: since = %q", since)
+	}
+	// 依赖被全量重算
+	dep := factorDef{Name: "mom_5_chg", SQL: "SELECT symbol, date, value FROM {{mom_5}}", Depends: []string{"mom_5"}, Lookback: -1}
+	if since := factorIncrementalFrom(db, dep, map[string]bool{"mom_5": true}, signature); since != "" {
+		t.Errorf("依赖全量重算后仍按增量构建: since = %q", since)
+	}
+}
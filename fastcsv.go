@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// ---------------------------------------------------------
+// 快速 CSV 解析
+// ---------------------------------------------------------
+// 供应商文件绝大多数没有引号字段，encoding/csv 逐字符处理引号的开销占了导入 CPU 的大头。
+// 快速路径按行读取、按分隔符字节切分 (字段直接指向读缓冲区，见 rowmap.go)，遇到含引号的行才交给 encoding/csv
+// 解析该条记录 (跨行的引号字段会先拼成完整记录)，结果与 encoding/csv (LazyQuotes) 相同。
+// sources.yaml 中 import.csv 选择解析方式：
+//
+//	auto    快速路径，含引号的记录回退到 encoding/csv (默认)
+//	fast    只按分隔符切分，引号按普通字符处理 (确定没有引号字段时最快)
+//	strict  始终使用 encoding/csv
+
+const (
+	csvAuto   = "auto"
+	csvFast   = "fast"
+	csvStrict = "strict"
+)
+
+// Excel 另存的 "CSV UTF-8" 以 BOM 开头，不去掉的话会粘在第一列的列名上 (按列名映射时找不到该列)，
+// 没有表头的文件则粘在第一行数据的代码上。各处读取 CSV 时先用 skipBOM 跳过 (GBK 文件没有 BOM)
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// 跳过输入开头的 UTF-8 BOM，返回跳过的字节数。
+// UTF-16 (Excel 的 "Unicode 文本"，FF FE / FE FF 开头) 不支持，返回错误
+func skipBOM(br *bufio.Reader) (int, error) {
+	head, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		br.Discard(len(utf8BOM))
+		return len(utf8BOM), nil
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}), bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return 0, errors.New("UTF-16 编码 (Excel 的 Unicode 文本) 不支持，请另存为 CSV UTF-8 或 GBK")
+	}
+	return 0, nil
+}
+
+// Read 返回独立的字符串 (用于表头)；ReadFields 返回的字段在下次读取前有效。
+// Offset 为从创建起已读取的字节数 (即最后一条记录之后的位置，用于断点续传，见 checkpoint.go)；
+// Line 为最后读取 (或出错) 的记录开始的行号，从创建起计 (第一行为 1)
+type recordReader interface {
+	Read() ([]string, error)
+	ReadFields() ([][]byte, error)
+	Offset() int64
+	Line() int
+}
+
+// br 必须是 *bufio.Reader：encoding/csv 直接在它上面按行读取，两种解析器可以在同一个流上接力 (如先读表头)
+func newRecordReader(br *bufio.Reader, comma rune, mode string) recordReader {
+	if mode == csvStrict || comma >= utf8.RuneSelf {
+		r := csv.NewReader(br)
+		r.Comma = comma
+		r.LazyQuotes = true
+		r.FieldsPerRecord = -1 // 列数由调用方检查 (minCols)，与快速路径相同
+		return &strictCSVReader{Reader: r}
+	}
+	return &fastCSVReader{br: br, comma: byte(comma), quotes: mode != csvFast}
+}
+
+type strictCSVReader struct {
+	*csv.Reader
+	buf    []byte
+	fields [][]byte
+	line   int
+}
+
+func (r *strictCSVReader) Offset() int64 { return r.InputOffset() }
+
+func (r *strictCSVReader) Line() int { return r.line }
+
+func (r *strictCSVReader) ReadFields() ([][]byte, error) {
+	record, err := r.Read()
+	if err != nil {
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			r.line = pe.StartLine
+		}
+		return nil, err
+	}
+	r.line, _ = r.FieldPos(0)
+	r.buf, r.fields = copyFields(record, r.buf, r.fields)
+	return r.fields, nil
+}
+
+// 把 record 复制到复用的 buf 中，返回各字段的切片
+func copyFields(record []string, buf []byte, fields [][]byte) ([]byte, [][]byte) {
+	buf, fields = buf[:0], fields[:0]
+	for _, s := range record {
+		buf = append(buf, s...)
+	}
+	off := 0
+	for _, s := range record {
+		fields = append(fields, buf[off:off+len(s):off+len(s)])
+		off += len(s)
+	}
+	return buf, fields
+}
+
+type fastCSVReader struct {
+	br     *bufio.Reader
+	comma  byte
+	quotes bool
+	long   []byte // 超过缓冲区的长行
+	buf    []byte // 含引号的记录解析后的内容
+	fields [][]byte
+	off    int64
+	lines  int // 已读取的行数
+	line   int
+}
+
+func (r *fastCSVReader) Offset() int64 { return r.off }
+
+func (r *fastCSVReader) Line() int { return r.line }
+
+// 读一行 (含换行符)。返回的切片在下次读取前有效
+func (r *fastCSVReader) readLine() ([]byte, error) {
+	line, err := r.br.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		r.long = append(r.long[:0], line...)
+		for err == bufio.ErrBufferFull {
+			line, err = r.br.ReadSlice('\n')
+			r.long = append(r.long, line...)
+		}
+		line = r.long
+	}
+	r.off += int64(len(line))
+	if len(line) > 0 {
+		r.lines++
+	}
+	if len(line) > 0 && err == io.EOF {
+		err = nil // 最后一行没有换行符
+	}
+	return line, err
+}
+
+func trimEOL(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+	return bytes.TrimSuffix(line, []byte{'\r'})
+}
+
+func (r *fastCSVReader) Read() ([]string, error) {
+	fields, err := r.ReadFields()
+	if err != nil {
+		return nil, err
+	}
+	return fieldStrings(fields), nil
+}
+
+func (r *fastCSVReader) ReadFields() ([][]byte, error) {
+	for {
+		r.line = r.lines + 1
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		trimmed := trimEOL(line)
+		if len(trimmed) == 0 {
+			continue
+		}
+		if r.quotes && bytes.IndexByte(trimmed, '"') >= 0 {
+			record, err := r.readQuoted(line)
+			if err != nil {
+				return nil, err
+			}
+			r.buf, r.fields = copyFields(record, r.buf, r.fields)
+			return r.fields, nil
+		}
+		r.fields = splitFieldBytes(trimmed, r.comma, r.fields[:0])
+		return r.fields, nil
+	}
+}
+
+// 含引号的记录：引号字段跨行时继续读到字段结束，再把原始的几行交给 encoding/csv 解析
+func (r *fastCSVReader) readQuoted(line []byte) ([]string, error) {
+	buf := append([]byte(nil), line...)
+	for open := openQuote(trimEOL(line), r.comma, false); open; {
+		next, err := r.readLine()
+		if err != nil {
+			break
+		}
+		buf = append(buf, next...)
+		open = openQuote(trimEOL(next), r.comma, true)
+	}
+	cr := csv.NewReader(bytes.NewReader(buf))
+	cr.Comma = rune(r.comma)
+	cr.LazyQuotes = true
+	cr.FieldsPerRecord = -1
+	return cr.Read()
+}
+
+// 行尾是否仍在引号字段内 (规则同 encoding/csv 的 LazyQuotes：只有字段开头的引号开启引号字段，
+// 引号字段内 "" 为转义，后面紧跟分隔符或行尾的引号结束字段，其余引号按字面处理)
+func openQuote(line []byte, comma byte, inQuote bool) bool {
+	atStart := !inQuote
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inQuote && c == '"':
+			if i+1 < len(line) && line[i+1] == '"' {
+				i++
+				continue
+			}
+			if i+1 == len(line) || line[i+1] == comma {
+				inQuote = false
+			}
+		case !inQuote && atStart && c == '"':
+			inQuote = true
+		}
+		atStart = !inQuote && c == comma
+	}
+	return inQuote
+}
+
+// 按分隔符切分，追加到复用的 dst (各字段共用 line 的底层存储)
+func splitFieldBytes(line []byte, comma byte, dst [][]byte) [][]byte {
+	for {
+		k := bytes.IndexByte(line, comma)
+		if k < 0 {
+			return append(dst, line)
+		}
+		dst = append(dst, line[:k:k])
+		line = line[k+1:]
+	}
+}
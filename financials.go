@@ -0,0 +1,175 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 财务报表 (利润表 / 资产负债表 / 现金流量表)。
+// 各家供应商的列名差异很大，因此字段映射放在 financials.yaml 中配置：
+//
+//	statements:
+//	  - name: income                     # 写入 fin_income
+//	    path: C:\baidunetdiskdownload\利润表\*.csv
+//	    symbol: 股票代码
+//	    report_period: 报告期
+//	    announce_date: 公告日期
+//	    fields:                          # 标准字段名: 文件列名 (多个候选用 | 分隔)
+//	      revenue: 营业总收入|营业收入
+//	      net_profit: 归属于母公司所有者的净利润
+//	  - name: balance
+//	    ...
+//
+// 每张表以 (symbol, report_period, announce_date) 为主键，同一报告期的更正公告会作为新行保留，
+// 便于时点对齐时按公告日选择当时可见的版本。公告日期缺失时按法定披露截止日填充 (见 pit.go)。
+const DefaultFinancialsConfig = "financials.yaml"
+
+type statementDef struct {
+	Name        string
+	Path        string
+	SymbolCol   string
+	PeriodCol   string
+	AnnounceCol string
+	Fields      []string          // 标准字段名 (排序后，决定表的列顺序)
+	Sources     map[string]string // 标准字段名 -> 文件列名候选 (| 分隔)
+}
+
+var identPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+func loadStatementDefs(path string) ([]statementDef, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	root, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: 顶层必须是映射", path)
+	}
+
+	var defs []statementDef
+	for _, m := range yamlList(root, "statements") {
+		d := statementDef{
+			Name:        yamlString(m, "name"),
+			Path:        yamlString(m, "path"),
+			SymbolCol:   yamlString(m, "symbol"),
+			PeriodCol:   yamlString(m, "report_period"),
+			AnnounceCol: yamlString(m, "announce_date"),
+			Sources:     map[string]string{},
+		}
+		if !identPattern.MatchString(d.Name) || d.Path == "" || d.SymbolCol == "" || d.PeriodCol == "" {
+			return nil, fmt.Errorf("%s: 报表 %q 缺少 name/path/symbol/report_period，或 name 不是合法标识符", path, d.Name)
+		}
+		fields, _ := m["fields"].(map[string]any)
+		for field, v := range fields {
+			src, _ := v.(string)
+			if !identPattern.MatchString(field) || src == "" {
+				return nil, fmt.Errorf("%s: 报表 %s 的字段 %q 非法", path, d.Name, field)
+			}
+			d.Fields = append(d.Fields, field)
+			d.Sources[field] = src
+		}
+		if len(d.Fields) == 0 {
+			return nil, fmt.Errorf("%s: 报表 %s 没有配置 fields", path, d.Name)
+		}
+		sort.Strings(d.Fields)
+		defs = append(defs, d)
+	}
+	return defs, nil
+}
+
+func (d statementDef) table() string {
+	return "fin_" + d.Name
+}
+
+func (d statementDef) ddl() string {
+	var cols strings.Builder
+	for _, f := range d.Fields {
+		fmt.Fprintf(&cols, "\t\t%s REAL,\n", f)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		symbol         TEXT NOT NULL,
+		report_period  TEXT NOT NULL,
+		announce_date  TEXT NOT NULL,
+		avail_date     TEXT NOT NULL,
+%s		PRIMARY KEY (symbol, report_period, announce_date)
+	) WITHOUT ROWID, STRICT;`, d.table(), cols.String())
+}
+
+// 按表头定位各列，必需列缺失时返回 nil (跳过该文件)
+func (d statementDef) mapper(header []string) func([]string) []any {
+	index := map[string]int{}
+	for k, h := range header {
+		index[strings.TrimSpace(h)] = k
+	}
+	find := func(candidates string) int {
+		for _, c := range strings.Split(candidates, "|") {
+			if k, ok := index[strings.TrimSpace(c)]; ok {
+				return k
+			}
+		}
+		return -1
+	}
+	symCol, periodCol, annCol := find(d.SymbolCol), find(d.PeriodCol), -1
+	if d.AnnounceCol != "" {
+		annCol = find(d.AnnounceCol)
+	}
+	if symCol < 0 || periodCol < 0 {
+		return nil
+	}
+	fieldCols := make([]int, len(d.Fields))
+	for k, f := range d.Fields {
+		fieldCols[k] = find(d.Sources[f])
+	}
+
+	return func(record []string) []any {
+		symbol := normText(col(record, symCol))
+		period := normDate(col(record, periodCol))
+		if symbol == nil || period == nil {
+			return nil
+		}
+		var announce any
+		if annCol >= 0 {
+			announce = normDate(col(record, annCol))
+		}
+		avail := availDate(period, announce)
+		if announce == nil {
+			announce = avail
+		}
+		out := []any{symbol, period, announce, avail}
+		for _, k := range fieldCols {
+			if k < 0 {
+				out = append(out, nil)
+			} else {
+				out = append(out, normNum(col(record, k)))
+			}
+		}
+		return out
+	}
+}
+
+// 按配置导入全部报表；配置文件不存在时跳过
+func importFinancials(db *sql.DB, configPath string) {
+	defs, err := loadStatementDefs(configPath)
+	if os.IsNotExist(err) {
+		log.Printf(">>> 未找到 %s，跳过财务报表导入", configPath)
+		return
+	}
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	for _, d := range defs {
+		mustExec(db, d.ddl())
+		log.Printf(">>> 正在导入财务报表 %s (%d 个字段)...", d.table(), len(d.Fields))
+		// 同一文件集里重复出现的 (代码, 报告期, 公告日) 只保留第一条
+		importCSVFiles(db, d.Path, d.table(), "ON CONFLICT DO NOTHING", 2, recordMappers(d.mapper))
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 分析师盈利预测 / 目标价。每份研报是一个快照，按 (代码, 机构, 预测年度, 发布日期) 保留全部历史，
+// 不覆盖旧预测，这样才能在任意历史日期还原“当时”的一致预期并计算预期修正。
+// 同一机构同一天对同一年度发布多次时只保留最后一条。
+//
+// 一致预期见视图 v_consensus (每个机构取当时最新、且不超过 180 天的一份预测再求平均)，以下字段可直接作为因子名使用：
+//   - consensus_eps           当年 EPS 一致预期
+//   - consensus_target_price  一致目标价
+//   - analyst_count           参与一致预期的机构数
+//   - eps_revision_1m / _3m   当年 EPS 一致预期相对 30 / 90 天前的变化率
+//
+// 索引：0:代码, 1:机构, 2:分析师, 3:发布日期, 4:预测年度 (2024、2024E、2024-12-31 均可), 5:预测EPS, 6:目标价, 7:评级
+func init() {
+	registerDataset(dataset{
+		Name:    "盈利预测",
+		Pattern: PathForecasts,
+		Table:   "analyst_forecasts",
+		DDL: `CREATE TABLE IF NOT EXISTS analyst_forecasts (
+			symbol         TEXT NOT NULL,
+			institution    TEXT NOT NULL,
+			analyst        TEXT,
+			forecast_date  TEXT NOT NULL,
+			fiscal_year    INTEGER NOT NULL,
+			eps            REAL,
+			target_price   REAL,
+			rating         TEXT,
+			PRIMARY KEY (symbol, institution, fiscal_year, forecast_date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 6,
+		Mapper: func(record []string) []any {
+			symbol := normText(record[0])
+			institution := normText(record[1])
+			date := normDate(record[3])
+			year := normFiscalYear(record[4])
+			if symbol == nil || institution == nil || date == nil || year == nil {
+				return nil
+			}
+			return []any{
+				symbol,
+				institution,
+				normText(record[2]),
+				date,
+				year,
+				normNum(record[5]),
+				normNum(col(record, 6)),
+				normText(col(record, 7)),
+			}
+		},
+	})
+
+	for _, name := range []string{"consensus_eps", "consensus_target_price", "analyst_count", "eps_revision_1m", "eps_revision_3m"} {
+		registerDerivedColumn(name, "v_consensus")
+	}
+}
+
+// 预测年度取前 4 位数字：2024、2024E、2024A、2024-12-31 -> 2024
+func normFiscalYear(s string) any {
+	s = strings.TrimSpace(s)
+	if len(s) < 4 || !isDigits(s[:4]) {
+		return nil
+	}
+	y, _ := strconv.Atoi(s[:4])
+	return y
+}
+
+// 截至 asOf (SQL 日期表达式) 的一致预期子查询：h.date 所在年度的预测，
+// 每个机构取 asOf 当天及之前最新的一份，且发布不超过 180 天，再对 expr 做 agg 聚合
+func consensusSQL(agg, expr, asOf string) string {
+	return fmt.Sprintf(`(SELECT %[1]s(%[2]s) FROM analyst_forecasts f
+			WHERE f.symbol = h.symbol AND f.fiscal_year = CAST(substr(h.date, 1, 4) AS INTEGER)
+				AND f.forecast_date <= %[3]s AND f.forecast_date > date(%[3]s, '-180 days')
+				AND f.forecast_date = (SELECT max(g.forecast_date) FROM analyst_forecasts g
+					WHERE g.symbol = f.symbol AND g.institution = f.institution
+						AND g.fiscal_year = f.fiscal_year AND g.forecast_date <= %[3]s))`, agg, expr, asOf)
+}
+
+// 一致预期视图；EPS 可能为负，变化率的分母取绝对值
+var consensusViewSQL = fmt.Sprintf(`CREATE VIEW v_consensus AS
+	SELECT symbol, date, consensus_eps, consensus_target_price, analyst_count,
+		(consensus_eps - eps_1m) / abs(nullif(eps_1m, 0)) AS eps_revision_1m,
+		(consensus_eps - eps_3m) / abs(nullif(eps_3m, 0)) AS eps_revision_3m
+	FROM (
+		SELECT h.symbol, h.date,
+			%s AS consensus_eps,
+			%s AS consensus_target_price,
+			%s AS analyst_count,
+			%s AS eps_1m,
+			%s AS eps_3m
+		FROM stock_history h
+	);`,
+	consensusSQL("avg", "f.eps", "h.date"),
+	consensusSQL("avg", "f.target_price", "h.date"),
+	consensusSQL("count", "DISTINCT f.institution", "h.date"),
+	consensusSQL("avg", "f.eps", "date(h.date, '-30 days')"),
+	consensusSQL("avg", "f.eps", "date(h.date, '-90 days')"))
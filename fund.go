@@ -0,0 +1,35 @@
+package main
+
+// 开放式基金 / ETF 净值。单位净值用于申赎价格，累计净值含历史分红，
+// 计算收益率时优先用复权净值 (供应商提供时)，否则用累计净值近似。
+//
+// 索引：0:基金代码, 1:净值日期, 2:单位净值, 3:累计净值, 4:复权净值 (可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "基金净值",
+		Pattern: PathFundNAV,
+		Table:   "fund_nav",
+		DDL: `CREATE TABLE IF NOT EXISTS fund_nav (
+			fund_code  TEXT NOT NULL,
+			date       TEXT NOT NULL,
+			unit_nav   REAL,
+			accum_nav  REAL,
+			adj_nav    REAL,
+			PRIMARY KEY (fund_code, date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 4,
+		Mapper: func(record []string) []any {
+			date := normDate(record[1])
+			if date == nil {
+				return nil
+			}
+			return []any{
+				normText(record[0]),
+				date,
+				normNum(record[2]),
+				normNum(record[3]),
+				normNum(col(record, 4)),
+			}
+		},
+	})
+}
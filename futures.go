@@ -0,0 +1,416 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 期货日线：按合约存储，品种代码从合约代码中提取 (RB2405.SHF -> RB，SR405 -> SR)。
+//
+// 索引：0:合约代码, 1:日期, 2:开盘, 3:最高, 4:最低, 5:收盘, 6:结算价, 7:成交量, 8:成交额, 9:持仓量 (6 之后可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "期货日线",
+		Pattern: PathFuturesDaily,
+		Table:   "futures_daily",
+		DDL: `CREATE TABLE IF NOT EXISTS futures_daily (
+			contract       TEXT NOT NULL,
+			product        TEXT NOT NULL,
+			date           TEXT NOT NULL,
+			open           REAL,
+			high           REAL,
+			low            REAL,
+			close          REAL,
+			settle         REAL,
+			volume         REAL,
+			amount         REAL,
+			open_interest  REAL,
+			PRIMARY KEY (contract, date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 6,
+		Mapper: func(record []string) []any {
+			contract := strings.ToUpper(strings.TrimSpace(record[0]))
+			product := futuresProduct(contract)
+			date := normDate(record[1])
+			if product == "" || date == nil {
+				return nil
+			}
+			return []any{
+				contract,
+				product,
+				date,
+				normNum(record[2]),
+				normNum(record[3]),
+				normNum(record[4]),
+				normNum(record[5]),
+				normNum(col(record, 6)),
+				normNum(col(record, 7)),
+				normNum(col(record, 8)),
+				normNum(col(record, 9)),
+			}
+		},
+	})
+}
+
+// 合约代码开头的字母部分即品种代码
+func futuresProduct(contract string) string {
+	k := strings.IndexFunc(contract, func(r rune) bool { return !unicode.IsLetter(r) })
+	if k <= 0 {
+		return ""
+	}
+	return contract[:k]
+}
+
+// 合约交割月 (year*12 + month-1)。四位数字为 YYMM；郑商所三位数字为 YMM，
+// 年份取交易日期当年或之后最近的一个以 Y 结尾的年份。
+func futuresDelivery(contract, date string) (int, bool) {
+	rest := contract[len(futuresProduct(contract)):]
+	if k := strings.IndexByte(rest, '.'); k >= 0 {
+		rest = rest[:k]
+	}
+	if !isDigits(rest) || len(date) < 4 {
+		return 0, false
+	}
+	tradeYear, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(rest)
+	var year, month int
+	switch len(rest) {
+	case 4:
+		year, month = 2000+n/100, n%100
+	case 3:
+		year, month = tradeYear-tradeYear%10+n/100, n%100
+		if year < tradeYear {
+			year += 10
+		}
+	default:
+		return 0, false
+	}
+	if month < 1 || month > 12 {
+		return 0, false
+	}
+	return year*12 + month - 1, true
+}
+
+// 连续合约规则
+type rollRule struct {
+	Name   string // volume | calendar
+	Offset int    // calendar：在交割月前 Offset 个月的月初换月
+	Adjust string // ratio | diff | none
+}
+
+var defaultRollRules = []rollRule{
+	{Name: "volume", Adjust: "ratio"},
+	{Name: "calendar", Offset: 1, Adjust: "ratio"},
+}
+
+// 主力连续：
+//
+//	chronos futures [--product RB] [--rule volume|calendar] [--offset 1] [--adjust ratio|diff|none]
+//
+// volume   ：成交量 (同量比持仓量) 最大的合约成为主力，次日生效，只向更远月合约换月；
+// calendar ：交割月前 offset 个月的月初换到下一个合约。
+// 复权为后复权 (back-adjusted)：最新一段保持原价，换月日之前的价格按换月前一日新旧合约收盘价
+// 的比值 (ratio) 或差值 (diff) 调整，adj 列记录该日使用的乘数或加数。
+// 规则、复权方式与提前月数 (volume 规则记为 0) 共同确定一条序列，不同参数生成的序列并存。
+func runFutures(args []string) {
+	fs := flag.NewFlagSet("futures", flag.ExitOnError)
+	product := fs.String("product", "", "品种代码 (默认全部)")
+	rule := fs.String("rule", "volume", "换月规则: volume | calendar")
+	offset := fs.Int("offset", 1, "calendar 规则下提前换月的月数")
+	adjust := fs.String("adjust", "ratio", "复权方式: ratio | diff | none")
+	fs.Parse(args)
+
+	r := rollRule{Name: *rule, Offset: *offset, Adjust: *adjust}
+	if err := r.validate(); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	db := openDB()
+	defer db.Close()
+	buildFuturesContinuous(db, strings.ToUpper(*product), r)
+}
+
+func (r rollRule) validate() error {
+	if r.Name != "volume" && r.Name != "calendar" {
+		return fmt.Errorf("未知换月规则: %s", r.Name)
+	}
+	if r.Adjust != "ratio" && r.Adjust != "diff" && r.Adjust != "none" {
+		return fmt.Errorf("未知复权方式: %s", r.Adjust)
+	}
+	if r.Offset < 0 {
+		return fmt.Errorf("offset 不能为负: %d", r.Offset)
+	}
+	return nil
+}
+
+// futures_continuous.roll_offset：只有 calendar 规则用到 Offset
+func (r rollRule) rollOffset() int {
+	if r.Name == "calendar" {
+		return r.Offset
+	}
+	return 0
+}
+
+func ensureFuturesContinuous(db *sql.DB) {
+	// 早期的表只以规则名区分序列；连续合约可随时重新生成，直接重建
+	var n int
+	db.QueryRow("SELECT count(*) FROM pragma_table_info('futures_continuous') WHERE name = 'adjust'").Scan(&n)
+	if n == 0 {
+		mustExec(db, "DROP TABLE IF EXISTS futures_continuous;")
+	}
+	mustExec(db, `CREATE TABLE IF NOT EXISTS futures_continuous (
+		product        TEXT NOT NULL,
+		rule           TEXT NOT NULL,   -- volume / calendar
+		adjust         TEXT NOT NULL,   -- ratio / diff / none
+		roll_offset    INTEGER NOT NULL, -- calendar 规则提前换月的月数，volume 规则为 0
+		date           TEXT NOT NULL,
+		contract       TEXT NOT NULL,   -- 当日映射的实际合约
+		open           REAL,
+		high           REAL,
+		low            REAL,
+		close          REAL,
+		settle         REAL,
+		volume         REAL,
+		open_interest  REAL,
+		adj            REAL,            -- 已应用的复权乘数 (ratio) 或加数 (diff)
+		PRIMARY KEY (product, rule, adjust, roll_offset, date)
+	) WITHOUT ROWID, STRICT;`)
+}
+
+type futuresBar struct {
+	contract                     string
+	delivery                     int
+	open, high, low, close       float64
+	settle, volume, openInterest float64
+}
+
+// 生成连续合约写入 futures_continuous，product 为空时处理全部品种
+func buildFuturesContinuous(db *sql.DB, product string, r rollRule) {
+	start := time.Now()
+	ensureFuturesContinuous(db)
+
+	var products []string
+	if product != "" {
+		products = []string{product}
+	} else {
+		rows, err := db.Query("SELECT DISTINCT product FROM futures_daily ORDER BY product")
+		if err != nil {
+			log.Fatal(err)
+		}
+		for rows.Next() {
+			var p string
+			rows.Scan(&p)
+			products = append(products, p)
+		}
+		rows.Close()
+	}
+
+	log.Printf(">>> 正在生成期货连续合约 (%s 换月, %s 复权): %d 个品种", r.Name, r.Adjust, len(products))
+	total := 0
+	for _, p := range products {
+		dates, bars := loadFuturesBars(db, p)
+		active := rollSchedule(dates, bars, r)
+		adj := backAdjust(dates, bars, active, r.Adjust)
+		total += saveContinuous(db, p, r, dates, bars, active, adj)
+	}
+	log.Printf(">>> ✅ 连续合约生成完成: %d 行, 耗时: %s", total, time.Since(start))
+}
+
+// 按日期加载某品种全部合约：dates 升序，bars[k] 为第 k 天各合约的行情
+func loadFuturesBars(db *sql.DB, product string) ([]string, []map[string]futuresBar) {
+	rows, err := db.Query(`SELECT date, contract, open, high, low, close, settle, volume, open_interest
+		FROM futures_daily WHERE product = ? ORDER BY date, contract`, product)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var dates []string
+	var bars []map[string]futuresBar
+	for rows.Next() {
+		var date string
+		var b futuresBar
+		var o, h, l, c, s, v, oi sql.NullFloat64
+		if err := rows.Scan(&date, &b.contract, &o, &h, &l, &c, &s, &v, &oi); err != nil {
+			log.Fatal(err)
+		}
+		d, ok := futuresDelivery(b.contract, date)
+		if !ok || !c.Valid {
+			continue
+		}
+		b.delivery = d
+		b.open, b.high, b.low, b.close = nullToNaN(o), nullToNaN(h), nullToNaN(l), c.Float64
+		b.settle, b.volume, b.openInterest = nullToNaN(s), nullToNaN(v), nullToNaN(oi)
+		if len(dates) == 0 || dates[len(dates)-1] != date {
+			dates = append(dates, date)
+			bars = append(bars, map[string]futuresBar{})
+		}
+		bars[len(bars)-1][b.contract] = b
+	}
+	return dates, bars
+}
+
+// 每个交易日映射到的合约 (当日无可用合约时为空串)
+func rollSchedule(dates []string, bars []map[string]futuresBar, r rollRule) []string {
+	active := make([]string, len(dates))
+	cur, next := "", ""
+	for k, date := range dates {
+		day := bars[k]
+		switch r.Name {
+		case "calendar":
+			// 取换月日之后仍未到期、交割月最近的合约
+			y, _ := strconv.Atoi(date[:4])
+			m, _ := strconv.Atoi(date[5:7])
+			cutoff := y*12 + m - 1 + r.Offset
+			best := ""
+			for c, b := range day {
+				if b.delivery > cutoff && (best == "" || b.delivery < day[best].delivery) {
+					best = c
+				}
+			}
+			cur = best
+		default:
+			// 前一日选出的新主力今天生效；当前合约已无行情 (到期) 时立即切换
+			if next != "" {
+				if _, ok := day[next]; ok {
+					cur = next
+				}
+			}
+			lead := dominantContract(day)
+			if _, ok := day[cur]; !ok {
+				cur = lead
+			}
+			next = ""
+			if lead != "" && day[lead].delivery > day[cur].delivery {
+				next = lead
+			}
+		}
+		active[k] = cur
+	}
+	return active
+}
+
+// 成交量最大的合约，成交量相同时比持仓量
+func dominantContract(day map[string]futuresBar) string {
+	best := ""
+	for c, b := range day {
+		if best == "" {
+			best = c
+			continue
+		}
+		bb := day[best]
+		v, bv := zeroIfNaN(b.volume), zeroIfNaN(bb.volume)
+		if v > bv || (v == bv && (zeroIfNaN(b.openInterest) > zeroIfNaN(bb.openInterest) ||
+			(zeroIfNaN(b.openInterest) == zeroIfNaN(bb.openInterest) && c < best))) {
+			best = c
+		}
+	}
+	return best
+}
+
+func zeroIfNaN(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
+
+// 后复权因子：从最新一天往前累计每次换月的价差。
+// 换月前一日新旧合约都有收盘价时才调整，否则视为无缝衔接。
+func backAdjust(dates []string, bars []map[string]futuresBar, active []string, method string) []float64 {
+	adj := make([]float64, len(dates))
+	factor := 1.0
+	if method == "diff" {
+		factor = 0
+	}
+	for k := len(dates) - 1; k >= 0; k-- {
+		adj[k] = factor
+		if k == 0 || method == "none" {
+			continue
+		}
+		from, to := active[k-1], active[k]
+		if from == "" || to == "" || from == to {
+			continue
+		}
+		oldBar, ok1 := bars[k-1][from]
+		newBar, ok2 := bars[k-1][to]
+		if !ok1 || !ok2 || oldBar.close == 0 {
+			continue
+		}
+		switch method {
+		case "ratio":
+			factor *= newBar.close / oldBar.close
+		case "diff":
+			factor += newBar.close - oldBar.close
+		}
+	}
+	if method == "none" {
+		for k := range adj {
+			adj[k] = 1
+		}
+	}
+	return adj
+}
+
+func saveContinuous(db *sql.DB, product string, r rollRule, dates []string, bars []map[string]futuresBar, active []string, adj []float64) int {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := tx.Exec("DELETE FROM futures_continuous WHERE product = ? AND rule = ? AND adjust = ? AND roll_offset = ?",
+		product, r.Name, r.Adjust, r.rollOffset()); err != nil {
+		log.Fatal(err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO futures_continuous VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// diff 复权时 adj 为加数，其余为乘数
+	isDiff := r.Adjust == "diff"
+	price := func(v, a float64) any {
+		if math.IsNaN(v) {
+			return nil
+		}
+		if isDiff {
+			return v + a
+		}
+		return v * a
+	}
+	n := 0
+	for k, date := range dates {
+		c := active[k]
+		if c == "" {
+			continue
+		}
+		b := bars[k][c]
+		a := adj[k]
+		if _, err := stmt.Exec(product, r.Name, r.Adjust, r.rollOffset(), date, c,
+			price(b.open, a), price(b.high, a), price(b.low, a), price(b.close, a), price(b.settle, a),
+			nanToNull(b.volume), nanToNull(b.openInterest), a); err != nil {
+			log.Fatal(err)
+		}
+		n++
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	return n
+}
+
+func nanToNull(v float64) any {
+	if math.IsNaN(v) {
+		return nil
+	}
+	return v
+}
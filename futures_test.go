@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestFuturesDelivery(t *testing.T) {
+	for _, tc := range []struct {
+		contract, date string
+		year, month    int
+		ok             bool
+	}{
+		{"RB2405.SHF", "2024-01-02", 2024, 5, true},
+		{"SR405", "2024-01-02", 2024, 5, true},
+		{"SR405.CZC", "2024-01-02", 2024, 5, true},
+		{"SR912", "2019-03-01", 2019, 12, true},
+		{"SR001", "2019-12-30", 2020, 1, true}, // 跨年：0 指 2020
+		{"SR501", "2024-03-01", 2025, 1, true}, // 当年的 4 已过，5 指 2025
+		{"CF003", "2029-06-01", 2030, 3, true}, // 跨十年：0 指 2030
+		{"CF909", "2029-09-15", 2029, 9, true}, // 交割月当月
+		{"SR413", "2024-01-02", 0, 0, false},
+		{"SR40", "2024-01-02", 0, 0, false},
+	} {
+		d, ok := futuresDelivery(tc.contract, tc.date)
+		if ok != tc.ok || (ok && d != tc.year*12+tc.month-1) {
+			t.Errorf("futuresDelivery(%s, %s) = %d-%02d, %v; want %d-%02d, %v",
+				tc.contract, tc.date, d/12, d%12+1, ok, tc.year, tc.month, tc.ok)
+		}
+	}
+}
+
+// 两个合约四个交易日：第 2 天 RB2410 成交量超过 RB2405，第 3 天起换为 RB2410
+func futuresFixture() ([]string, []map[string]futuresBar) {
+	dates := []string{"2024-03-28", "2024-03-29", "2024-04-01", "2024-04-02"}
+	bar := func(contract string, close_, volume float64) futuresBar {
+		d, _ := futuresDelivery(contract, "2024-03-28")
+		return futuresBar{contract: contract, delivery: d, open: close_, high: close_, low: close_, close: close_,
+			settle: close_, volume: volume, openInterest: math.NaN()}
+	}
+	bars := []map[string]futuresBar{
+		{"RB2405": bar("RB2405", 100, 500), "RB2410": bar("RB2410", 104, 100)},
+		{"RB2405": bar("RB2405", 100, 200), "RB2410": bar("RB2410", 110, 900)},
+		{"RB2405": bar("RB2405", 101, 100), "RB2410": bar("RB2410", 111, 800)},
+		{"RB2405": bar("RB2405", 102, 900), "RB2410": bar("RB2410", 112, 800)}, // 不回到近月
+	}
+	return dates, bars
+}
+
+func TestRollSchedule(t *testing.T) {
+	dates, bars := futuresFixture()
+	got := fmt.Sprint(
+		rollSchedule(dates, bars, rollRule{Name: "volume"}),
+		rollSchedule(dates, bars, rollRule{Name: "calendar", Offset: 1}), // 4 月起距 5 月不足 1 个月
+		rollSchedule(dates, bars, rollRule{Name: "calendar", Offset: 2}), // 3 月起距 5 月不足 2 个月
+	)
+	want := "[RB2405 RB2405 RB2410 RB2410] [RB2405 RB2405 RB2410 RB2410] [RB2410 RB2410 RB2410 RB2410]"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// 当前合约没有行情 (已到期) 时立即换到主力
+	delete(bars[2], "RB2405")
+	bars[1]["RB2410"] = futuresBar{contract: "RB2410", delivery: bars[1]["RB2410"].delivery, close: 110, volume: 1}
+	if got := fmt.Sprint(rollSchedule(dates, bars, rollRule{Name: "volume"})); got != "[RB2405 RB2405 RB2410 RB2410]" {
+		t.Errorf("到期换月: got %s", got)
+	}
+}
+
+// 换月前一日 (第 2 天) 新旧合约收盘 110 / 100：之前的价格乘 1.1 或加 10，最新一段保持原价
+func TestBackAdjust(t *testing.T) {
+	dates, bars := futuresFixture()
+	active := []string{"RB2405", "RB2405", "RB2410", "RB2410"}
+	got := fmt.Sprintf("%.4g %.4g %.4g", backAdjust(dates, bars, active, "ratio"), backAdjust(dates, bars, active, "diff"),
+		backAdjust(dates, bars, active, "none"))
+	if want := "[1.1 1.1 1 1] [10 10 0 0] [1 1 1 1]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// 同一规则不同复权方式、不同提前月数的序列并存，不互相覆盖
+func TestFuturesContinuousKeys(t *testing.T) {
+	db := openTestDB(t, "futures.db")
+	ensureDatasetTable(db, "futures_daily")
+	dates, bars := futuresFixture()
+	for k, date := range dates {
+		for _, b := range bars[k] {
+			mustExec(db, "INSERT INTO futures_daily (contract, product, date, close, volume) VALUES (?, 'RB', ?, ?, ?)",
+				b.contract, date, b.close, b.volume)
+		}
+	}
+	for _, r := range []rollRule{
+		{Name: "volume", Adjust: "ratio"}, {Name: "volume", Adjust: "diff"}, {Name: "volume", Offset: 3, Adjust: "diff"},
+		{Name: "calendar", Offset: 1, Adjust: "ratio"}, {Name: "calendar", Offset: 2, Adjust: "ratio"},
+	} {
+		buildFuturesContinuous(db, "RB", r)
+	}
+	got := dumpTable(t, db, `SELECT rule, adjust, roll_offset, count(*), round(min(close), 4) FROM futures_continuous
+		GROUP BY rule, adjust, roll_offset ORDER BY rule, adjust, roll_offset`)
+	want := "calendar|ratio|1|4|110\ncalendar|ratio|2|4|104\nvolume|diff|0|4|110\nvolume|ratio|0|4|110\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
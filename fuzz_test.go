@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 供应商文件里的怪行 (嵌入引号、混用分隔符、截断的行) 不能让导入崩溃，也不能被悄悄映射成错误的值。
+// 种子覆盖已知的格式；长时间运行：go test -run X -fuzz FuzzNormDate -fuzztime 1m
+
+func FuzzSniffDelimiter(f *testing.F) {
+	for _, s := range []string{"a,b,c", "a\tb\tc", "a;b;c", "a|b|c", "a,b\tc\td", "", "\"a,b\"\tc", "代码;名称,日期"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		comma := sniffDelimiter(line)
+		if !slices.Contains([]rune{',', '\t', ';', '|'}, comma) {
+			t.Fatalf("%q: 分隔符 %q", line, comma)
+		}
+		// 选中的分隔符在引号字段以外出现的次数不少于其他候选；没有引号时即原始计数
+		counts := map[byte]int{}
+		scanCSVQuotes(line, func(c byte) { counts[c]++ })
+		for _, c := range []byte{',', '\t', ';', '|'} {
+			if counts[c] > counts[byte(comma)] {
+				t.Fatalf("%q: 选了 %q，但 %q 更多", line, comma, c)
+			}
+			if !strings.Contains(line, `"`) && counts[c] != strings.Count(line, string(c)) {
+				t.Fatalf("%q: 没有引号时 %q 计数 %d", line, c, counts[c])
+			}
+		}
+	})
+}
+
+func FuzzNormNum(f *testing.F) {
+	for _, s := range []string{"1.5", " 1,234.5 ", "--", "-", "NaN", "NAN", "inf", "-Infinity", "1e308", "1e309", "29,1", "-1,234,567.25", "1,2345", "0x1p-2", "", "１２", "12abc", "\u00a01\u00a0"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		v := normNum(s)
+		if v != nil {
+			x, ok := v.(float64)
+			if !ok || math.IsNaN(x) || math.IsInf(x, 0) {
+				t.Fatalf("%q -> %v (%T)", s, v, v)
+			}
+		}
+		// 字节版与字符串版结果相同
+		if b := normNumBytes([]byte(s)); b != v {
+			t.Fatalf("%q: normNumBytes %v, normNum %v", s, b, v)
+		}
+	})
+}
+
+func FuzzNormDate(f *testing.F) {
+	for _, s := range []string{"19910404", "1991-04-04", "1991/04/04", "1991-04-04 09:30:00", " 20240102 ", "", "2024-1-2", "abcd/ef/gh",
+		"2024年01月02日", "1991-04-0", "20240132", "2023-02-29", "2024/02/29", "2024-13-01", "00000000", "1991-04-04x"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		// 输出只能是 NULL 或真实存在的 YYYY-MM-DD 日期，绝不能把别的文本写进日期列
+		v := normDate(s)
+		if v == nil {
+			return
+		}
+		d, ok := v.(string)
+		if !ok || !isISODate(d) {
+			t.Fatalf("%q -> %#v", s, v)
+		}
+		// 且就是输入开头的那个日期
+		if digits := strings.ReplaceAll(d, "-", ""); !strings.HasPrefix(strings.NewReplacer("-", "", "/", "").Replace(strings.TrimSpace(s)), digits) {
+			t.Fatalf("%q -> %q", s, d)
+		}
+	})
+}
+
+// 合法的 YYYY-MM-DD 日历日期 (20240132、2023-02-29 这种不存在的日期不算)
+func isISODate(s string) bool {
+	if len(s) != 10 || s[4] != '-' || s[7] != '-' {
+		return false
+	}
+	_, err := time.Parse(time.DateOnly, s)
+	return err == nil
+}
+
+// 快速路径 (auto) 与 encoding/csv (strict) 对同一输入给出相同的记录
+func FuzzRecordReader(f *testing.F) {
+	for _, s := range []string{
+		"a,b,c\n1,2,3\n",
+		"a,\"b,c\",d\r\n\"x\"\"y\",2,3\n",
+		"a,\"multi\nline\",c\n1,2,3",
+		"a,b\"c,d\n",
+		"\"unterminated,1,2\n3,4\n",
+		"a\tb\n1\t\"2\t3\"\n",
+		"\n\n1,2\n\r\n3,4",
+		"a,b,\n,,\n",
+	} {
+		f.Add(s, byte(','))
+	}
+	f.Add("a\tb\n1\t2\n", byte('\t'))
+	f.Fuzz(func(t *testing.T, data string, sep byte) {
+		if !slices.Contains([]byte{',', '\t', ';', '|'}, sep) {
+			return
+		}
+		strict := readAllRecords(t, data, rune(sep), csvStrict)
+		auto := readAllRecords(t, data, rune(sep), csvAuto)
+		if strict == nil {
+			return // encoding/csv 报错的输入 (如 LazyQuotes 也无法接受的)，不比较
+		}
+		if !slices.EqualFunc(strict, auto, slices.Equal[[]string]) {
+			t.Fatalf("%q:\nstrict %q\nauto   %q", data, strict, auto)
+		}
+	})
+}
+
+func readAllRecords(t *testing.T, data string, comma rune, mode string) [][]string {
+	r := newRecordReader(bufio.NewReader(strings.NewReader(data)), comma, mode)
+	var out [][]string
+	for {
+		fields, err := r.ReadFields()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			return nil
+		}
+		out = append(out, fieldStrings(fields))
+	}
+}
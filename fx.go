@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// 汇率：每个货币对一条日序列，rate 表示 1 单位基础货币折合多少计价货币 (USDCNY = 7.1)。
+// 中间价常按 100 外币报价 (如 100 港元)，可在第 3 列给出报价单位，导入时换算为 1 单位。
+//
+// 索引：0:货币对 (USDCNY、USD/CNY、USD.CNY 均可), 1:日期, 2:汇率, 3:报价单位 (可缺省，默认 1)
+func init() {
+	registerDataset(dataset{
+		Name:    "汇率",
+		Pattern: PathFXRates,
+		Table:   "fx_rates",
+		DDL: `CREATE TABLE IF NOT EXISTS fx_rates (
+			pair  TEXT NOT NULL,
+			date  TEXT NOT NULL,
+			rate  REAL NOT NULL,
+			PRIMARY KEY (pair, date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 3,
+		Mapper: func(record []string) []any {
+			pair := normPair(record[0])
+			date := normDate(record[1])
+			rate, ok := normNum(record[2]).(float64)
+			if pair == "" || date == nil || !ok {
+				return nil
+			}
+			if unit, ok := normNum(col(record, 3)).(float64); ok && unit > 0 {
+				rate /= unit
+			}
+			return []any{pair, date, rate}
+		},
+	})
+}
+
+// 货币对统一为 6 位大写 (USDCNY)，无法识别返回空串
+func normPair(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.NewReplacer("/", "", ".", "", "-", "", "_", "").Replace(s)
+	if len(s) != 6 {
+		return ""
+	}
+	return s
+}
+
+var errNoFXRate = errors.New("没有可用汇率")
+
+var fxLookupSQL = `SELECT rate FROM fx_rates WHERE pair = ? AND date <= ? ORDER BY date DESC LIMIT 1`
+
+// from -> to 在指定日期的汇率 (当天或之前最近一个)。
+// 依次尝试直接报价、反向报价、经人民币交叉 (from/CNY ÷ to/CNY)。
+func (s *Store) FXRate(ctx context.Context, from, to, date string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return 1, nil
+	}
+	lookup := func(pair string) (float64, bool, error) {
+		var rate float64
+		err := s.queryRow(ctx, fxLookupSQL, pair, date).Scan(&rate)
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return rate, err == nil && rate != 0, err
+	}
+
+	if r, ok, err := lookup(from + to); err != nil || ok {
+		return r, err
+	}
+	if r, ok, err := lookup(to + from); err != nil || ok {
+		return 1 / r, err
+	}
+	if from != "CNY" && to != "CNY" {
+		a, err := s.FXRate(ctx, from, "CNY", date)
+		if err != nil {
+			return 0, err
+		}
+		b, err := s.FXRate(ctx, to, "CNY", date)
+		if err != nil {
+			return 0, err
+		}
+		return a / b, nil
+	}
+	return 0, fmt.Errorf("%w: %s%s @ %s", errNoFXRate, from, to, date)
+}
+
+// 把 bars 的价格 (不复权收盘与各复权价) 按各自日期的汇率从该股票市场的货币换算为 currency，原地修改。
+// PE、成交量与货币无关，不动。没有可用汇率的日期价格记为 NaN，返回这样的行数。
+func (s *Store) ConvertBars(ctx context.Context, bars []Bar, currency string) (int, error) {
+	type key struct{ from, date string }
+	rates := map[key]float64{}
+	missing := 0
+	for k := range bars {
+		b := &bars[k]
+		kk := key{marketCurrency(marketOf(b.Symbol)), b.Date}
+		rate, ok := rates[kk]
+		if !ok {
+			var err error
+			rate, err = s.FXRate(ctx, kk.from, currency, b.Date)
+			if errors.Is(err, errNoFXRate) {
+				rate = math.NaN()
+			} else if err != nil {
+				return 0, err
+			}
+			rates[kk] = rate
+		}
+		if math.IsNaN(rate) {
+			missing++
+		}
+		b.Close *= rate
+		b.CloseAdj *= rate
+		b.OpenAdj *= rate
+		b.HighAdj *= rate
+		b.LowAdj *= rate
+	}
+	return missing, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestFXRate(t *testing.T) {
+	db := openTestDB(t, "fx.db")
+	ensureDatasetTable(db, "fx_rates")
+	for _, r := range []struct {
+		pair, date string
+		rate       float64
+	}{
+		{"USDCNY", "2024-01-02", 7.1}, {"USDCNY", "2024-01-05", 7.2},
+		{"HKDCNY", "2024-01-02", 0.91},
+		{"CNYJPY", "2024-01-02", 20},
+	} {
+		mustExec(db, "INSERT INTO fx_rates VALUES (?, ?, ?)", r.pair, r.date, r.rate)
+	}
+	s := newStore(db)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		from, to, date string
+		want           float64
+	}{
+		{"USD", "CNY", "2024-01-02", 7.1},             // 直接报价
+		{"usd", "CNY", "2024-01-04", 7.1},             // 节假日沿用前值
+		{"USD", "CNY", "2024-01-08", 7.2},             // 最近一个在更早的日期
+		{"CNY", "USD", "2024-01-05", 1 / 7.2},         // 反向报价
+		{"HKD", "USD", "2024-01-03", 0.91 / 7.1},      // 经人民币交叉
+		{"JPY", "HKD", "2024-01-02", 1.0 / 20 / 0.91}, // 交叉的一边为反向报价
+		{"EUR", "EUR", "2024-01-02", 1},
+	} {
+		got, err := s.FXRate(ctx, tc.from, tc.to, tc.date)
+		if err != nil || math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("FXRate(%s, %s, %s) = %v, %v; want %v", tc.from, tc.to, tc.date, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range [][3]string{
+		{"USD", "CNY", "2024-01-01"}, // 第一个汇率之前
+		{"EUR", "CNY", "2024-01-02"},
+		{"EUR", "USD", "2024-01-02"},
+	} {
+		if _, err := s.FXRate(ctx, tc[0], tc[1], tc[2]); !errors.Is(err, errNoFXRate) {
+			t.Errorf("FXRate(%v) error = %v, want errNoFXRate", tc, err)
+		}
+	}
+}
+
+// 各股票按所在市场的货币换算，PE 与成交量不变；没有汇率的行价格为 NaN
+func TestConvertBars(t *testing.T) {
+	db := openTestDB(t, "fx.db")
+	ensureDatasetTable(db, "fx_rates")
+	mustExec(db, "INSERT INTO fx_rates VALUES ('USDCNY', '2024-01-02', 7), ('HKDCNY', '2024-01-02', 0.9)")
+	bars := []Bar{
+		{Symbol: "AAPL.US", Date: "2024-01-02", Close: 10, CloseAdj: 5, OpenAdj: 4, HighAdj: 6, LowAdj: 3, PE: 30, Volume: 100},
+		{Symbol: "00700.HK", Date: "2024-01-03", Close: 10, CloseAdj: 10, PE: 20, Volume: 100},
+		{Symbol: "600000.SH", Date: "2024-01-02", Close: 10, CloseAdj: 10},
+		{Symbol: "AAPL.US", Date: "2023-12-29", Close: 10, CloseAdj: 10},
+	}
+	missing, err := newStore(db).ConvertBars(context.Background(), bars, "CNY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ""
+	for _, b := range bars {
+		got += fmt.Sprintf("%s %g %g %g %g %g %g %g\n", b.Symbol, b.Close, b.CloseAdj, b.OpenAdj, b.HighAdj, b.LowAdj, b.PE, b.Volume)
+	}
+	want := "AAPL.US 70 35 28 42 21 30 100\n00700.HK 9 9 0 0 0 20 100\n600000.SH 10 10 0 0 0 0 0\nAAPL.US NaN NaN NaN NaN NaN 0 0\n"
+	if got != want || missing != 1 {
+		t.Errorf("missing %d, got:\n%s\nwant:\n%s", missing, got, want)
+	}
+}
@@ -2,7 +2,12 @@ module chronos
 
 go 1.24.0
 
-require github.com/marcboeker/go-duckdb v1.8.5
+require (
+	github.com/marcboeker/go-duckdb v1.8.5
+	github.com/mattn/go-sqlite3 v1.14.52
+	golang.org/x/text v0.30.0
+	modernc.org/sqlite v1.44.3
+)
 
 require (
 	github.com/apache/arrow-go/v18 v18.1.0 // indirect
@@ -27,5 +32,4 @@ require (
 	modernc.org/libc v1.67.6 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
-	modernc.org/sqlite v1.44.3 // indirect
 )
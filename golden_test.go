@@ -0,0 +1,164 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testdata/golden 下每个目录是一组供应商文件 (tech/*.csv 技术因子、daily/*.csv 每日指标)，
+// stock_history.golden 为导入后 stock_history 的全部内容。三种合并方式的结果必须与之完全相同。
+// 修改了清洗规则后用 go test -run Golden -update 重新生成，检查 diff 后提交。
+var updateGolden = flag.Bool("update", false, "重新生成 testdata 中的 golden 文件")
+
+func TestGoldenStockHistory(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	cases, _ := filepath.Glob("testdata/golden/*")
+	if len(cases) == 0 {
+		t.Fatal("没有 golden 数据")
+	}
+	for _, dir := range cases {
+		for _, method := range []string{"sql", "sort", "streaming"} {
+			t.Run(filepath.Base(dir)+"/"+method, func(t *testing.T) {
+				got := importGolden(t, dir, method)
+				golden := filepath.Join(dir, "stock_history.golden")
+				if *updateGolden && method == "sql" {
+					if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != string(want) {
+					t.Errorf("stock_history 与 %s 不同:\n--- got\n%s--- want\n%s", golden, got, want)
+				}
+			})
+		}
+	}
+}
+
+// 在临时库中导入 dir 的两组文件，返回 stock_history 的文本形式
+func importGolden(t *testing.T, dir, method string) string {
+	db := openTestDB(t, "golden.db")
+	createTables(db)
+	ensureMarketTables(db)
+
+	techMapper := profileMapper(nil, "staging_tech", mapTechFactors, 19)
+	dailyMapper := profileMapper(nil, "staging_daily", mapDailyMetrics, 15)
+	merge, streaming := method, method == "streaming"
+	if streaming {
+		merge = "sql"
+	}
+	loadStockHistory(db, filepath.Join(dir, "tech", "*.csv"), filepath.Join(dir, "daily", "*.csv"),
+		techMapper, dailyMapper, merge, streaming)
+	return dumpTable(t, db, "SELECT * FROM stock_history ORDER BY symbol, date")
+}
+
+// 临时目录中的新库 (cgo 驱动要先注册才能打开)，测试结束时关闭
+func openTestDB(t testing.TB, name string) *sql.DB {
+	registerUDFs()
+	db, err := sql.Open(sqliteDriver, filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// 每行一条记录，列之间用 | 分隔，NULL 写作 NULL
+func dumpTable(t *testing.T, db *sql.DB, query string) string {
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	cols, _ := rows.Columns()
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for k := range vals {
+		ptrs[k] = &vals[k]
+	}
+	var b strings.Builder
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range vals {
+			if k > 0 {
+				b.WriteByte('|')
+			}
+			switch v := v.(type) {
+			case nil:
+				b.WriteString("NULL")
+			case float64:
+				b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+			case []byte:
+				b.Write(v)
+			default:
+				fmt.Fprint(&b, v)
+			}
+		}
+		b.WriteByte('\n')
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+// readCSV 与文件导入走同一条解析路径：两种分隔符、引号字段、CRLF、列数不足的行
+func TestReadCSV(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	mapper := func([]string) rowMapper {
+		return func(a *rowArena, f [][]byte) []any {
+			return []any{a.intern(f[0]), a.text(f[1]), normNumBytes(f[2])}
+		}
+	}
+	for _, tc := range []struct {
+		name, in string
+		want     string
+		short    int
+		comma    rune
+	}{
+		{"comma", "code,date,v\nA,20240102,1.5\nB,20240102,--\n", "A|20240102|1.5\nB|20240102|NULL\n", 0, ','},
+		{"tab", "code\tdate\tv\r\nA\t20240102\t2\r\n\r\nA\t20240103\t\r\n", "A|20240102|2\nA|20240103|NULL\n", 0, '\t'},
+		{"quoted", "code,date,v\n\"A,1\",\"2024\n0102\",\"1,234.5\"\n", "A,1|2024\n0102|1234.5\n", 0, ','},
+		{"short", "code,date,v\nA,20240102\nB,20240102,3\n", "B|20240102|3\n", 1, ','},
+		{"bom", "\ufeffcode;date;v\nA;20240102;1\n", "A|20240102|1\n", 0, ';'},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, pf := readCSV(strings.NewReader(tc.in), 3, mapper)
+			var b strings.Builder
+			for _, r := range rows {
+				for k, v := range r {
+					if k > 0 {
+						b.WriteByte('|')
+					}
+					if v == nil {
+						b.WriteString("NULL")
+					} else {
+						fmt.Fprint(&b, v)
+					}
+				}
+				b.WriteByte('\n')
+			}
+			if b.String() != tc.want || pf.short != tc.short || pf.comma != tc.comma {
+				t.Errorf("got %q (short %d, comma %q), want %q (short %d, comma %q)",
+					b.String(), pf.short, pf.comma, tc.want, tc.short, tc.comma)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------
+// gRPC 推送
+// ---------------------------------------------------------
+// 最小的 gRPC 服务端 (明文 HTTP/2，prior knowledge)，只实现一个服务端流式方法，
+// 与 WebSocket 共用同一个监听地址。客户端按下面的定义生成桩代码，用 insecure 凭据连接：
+//
+//	syntax = "proto3";
+//	package chronos;
+//	service Replay { rpc Stream(StreamRequest) returns (stream Bar); }
+//	message StreamRequest {}
+//	message Bar {
+//	  string type = 1;  string symbol = 2;  int32 freq = 3;  int64 ts = 4;  string time = 5;
+//	  optional double open = 6;  optional double high = 7;  optional double low = 8;
+//	  optional double close = 9;  optional double close_raw = 10;  optional double volume = 11;
+//	}
+//
+// 字段含义同 replayBar。回放结束时发送 type=end 的消息并以 grpc-status 0 结束流；
+// 订阅者处理不过来时以 RESOURCE_EXHAUSTED (8) 结束，不拖慢其他订阅者。
+
+const grpcStreamPath = "/chronos.Replay/Stream"
+
+type grpcClient struct {
+	send   chan []byte
+	done   chan struct{} // handler 返回 (流已结束) 时关闭
+	status int           // 队列关闭后返回给订阅者的 grpc-status
+	once   sync.Once
+}
+
+// 关闭发送队列，handler 写完剩余消息后以 status 结束流
+func (c *grpcClient) close(status int) {
+	c.once.Do(func() {
+		c.status = status
+		close(c.send)
+	})
+}
+
+type grpcHub struct {
+	mu      sync.Mutex
+	clients map[*grpcClient]bool
+	joined  chan struct{} // 有订阅者连入时通知 (不阻塞)
+}
+
+func newGRPCHub() *grpcHub {
+	return &grpcHub{clients: map[*grpcClient]bool{}, joined: make(chan struct{}, 1)}
+}
+
+func (h *grpcHub) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+func (h *grpcHub) remove(c *grpcClient, status int) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+	c.close(status)
+}
+
+// 向所有订阅者发送一条已编码的 protobuf 消息
+func (h *grpcHub) broadcast(msg []byte) {
+	h.mu.Lock()
+	var slow []*grpcClient
+	for c := range h.clients {
+		select {
+		case c.send <- msg:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	h.mu.Unlock()
+	for _, c := range slow {
+		log.Printf("[WARN] gRPC 订阅者处理过慢，已断开")
+		h.remove(c, 8) // RESOURCE_EXHAUSTED
+	}
+}
+
+func (h *grpcHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost ||
+		!strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "需要 gRPC (HTTP/2) 请求", http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.URL.Path != grpcStreamPath {
+		grpcFinish(w, 12, "未知方法: "+r.URL.Path) // UNIMPLEMENTED
+		return
+	}
+	io.Copy(io.Discard, r.Body) // StreamRequest 没有字段
+
+	c := &grpcClient{send: make(chan []byte, 4096), done: make(chan struct{})}
+	defer close(c.done)
+	h.mu.Lock()
+	h.clients[c] = true
+	h.mu.Unlock()
+	select {
+	case h.joined <- struct{}{}:
+	default:
+	}
+	log.Printf(">>> gRPC 订阅者接入: %s (共 %d 个)", r.RemoteAddr, h.count())
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			h.remove(c, 1) // CANCELLED
+			return
+		case msg, ok := <-c.send:
+			if !ok {
+				grpcFinish(w, c.status, "")
+				return
+			}
+			if _, err := w.Write(grpcFrame(msg)); err != nil {
+				h.remove(c, 1) // CANCELLED
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// 等各订阅者的发送队列写完 (最多 timeout) 后正常结束所有流
+func (h *grpcHub) closeAll(timeout time.Duration) {
+	h.mu.Lock()
+	clients := h.clients
+	h.clients = map[*grpcClient]bool{}
+	h.mu.Unlock()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for c := range clients {
+		c.close(0)
+		select {
+		case <-c.done:
+		case <-deadline.C:
+			return
+		}
+	}
+}
+
+// 以 trailer 返回 gRPC 状态；未写过响应头时作为 Trailers-Only 响应
+func grpcFinish(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", msg)
+	}
+}
+
+// 长度前缀消息：1 字节压缩标志 (0) + 4 字节大端长度 + 内容
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// replayBar 的 protobuf 编码 (字段号见文件头的 Bar 定义)
+func (b replayBar) protobuf() []byte {
+	var out []byte
+	str := func(field int, s string) {
+		if s != "" {
+			out = binary.AppendUvarint(out, uint64(field)<<3|2)
+			out = binary.AppendUvarint(out, uint64(len(s)))
+			out = append(out, s...)
+		}
+	}
+	num := func(field int, v int64) {
+		if v != 0 {
+			out = binary.AppendUvarint(out, uint64(field)<<3)
+			out = binary.AppendUvarint(out, uint64(v))
+		}
+	}
+	dbl := func(field int, v *float64) {
+		if v != nil {
+			out = binary.AppendUvarint(out, uint64(field)<<3|1)
+			out = binary.LittleEndian.AppendUint64(out, math.Float64bits(*v))
+		}
+	}
+	str(1, b.Type)
+	str(2, b.Symbol)
+	num(3, int64(b.Freq))
+	num(4, b.TS)
+	str(5, b.Time)
+	dbl(6, b.Open)
+	dbl(7, b.High)
+	dbl(8, b.Low)
+	dbl(9, b.Close)
+	dbl(10, b.Raw)
+	dbl(11, b.Volume)
+	return out
+}
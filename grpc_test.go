@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 测试用的 protobuf 解码：只取 varint / fixed64 / 字符串字段，按字段号返回
+func decodeProtobuf(t *testing.T, b []byte) map[int]any {
+	out := map[int]any{}
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		switch field := int(key >> 3); key & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			out[field], b = int64(v), b[n:]
+		case 1:
+			out[field], b = math.Float64frombits(binary.LittleEndian.Uint64(b)), b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			out[field], b = string(b[n:n+int(l)]), b[n+int(l):]
+		default:
+			t.Fatalf("未知 wire type %d", key&7)
+		}
+	}
+	return out
+}
+
+// 明文 HTTP/2 上的服务端流：收到一根 K 线、结束消息，最后 grpc-status 0
+func TestGRPCHubReplay(t *testing.T) {
+	hub := newGRPCHub()
+	srv := httptest.NewUnstartedServer(hub)
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	proto := new(http.Protocols)
+	proto.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: proto}, Timeout: 5 * time.Second}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+grpcStreamPath, bytes.NewReader(grpcFrame(nil)))
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.Header.Get("Content-Type") != "application/grpc" {
+		t.Fatalf("响应: %s %s", resp.Proto, resp.Header.Get("Content-Type"))
+	}
+
+	select {
+	case <-hub.joined:
+	case <-time.After(5 * time.Second):
+		t.Fatal("订阅者未登记")
+	}
+	cl := 10.08
+	hub.broadcast(replayBar{Type: "bar", Symbol: "600000.SH", TS: 1710486000, Close: &cl}.protobuf())
+	hub.broadcast(replayBar{Type: "end"}.protobuf())
+	go hub.closeAll(5 * time.Second)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []map[int]any
+	for len(body) >= 5 {
+		n := int(binary.BigEndian.Uint32(body[1:5]))
+		if body[0] != 0 {
+			t.Fatal("消息不应压缩")
+		}
+		msgs = append(msgs, decodeProtobuf(t, body[5:5+n]))
+		body = body[5+n:]
+	}
+	if len(body) != 0 || len(msgs) != 2 {
+		t.Fatalf("收到 %d 条消息, 剩余 %d 字节", len(msgs), len(body))
+	}
+	if m := msgs[0]; m[1] != "bar" || m[2] != "600000.SH" || m[4] != int64(1710486000) || m[9] != cl || m[6] != nil {
+		t.Errorf("K 线消息: %v", m)
+	}
+	if m := msgs[1]; m[1] != "end" || len(m) != 1 {
+		t.Errorf("结束消息: %v", m)
+	}
+	if s := resp.Trailer.Get("Grpc-Status"); s != "0" {
+		t.Errorf("grpc-status = %q", s)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 日线统计缓存
+// ---------------------------------------------------------
+// 代码列表、各股票的起止日期与行数、各列的缺失率，每次现算都要扫一遍上亿行的 stock_history。
+// history_stats 按代码缓存这些统计：全量导入结束时整表重建，之后每次写入日线都经 historyChanged
+// 只刷新本次涉及的代码：合并 staging (mergeStaging / --merge sort / --streaming)、直接导入
+// (vendor / tdx / metastock 经 importCSVFiles、importRecordFiles，source run)、yahoo (saveHistoryRows)
+// 与 verify --quarantine。还没有统计表的库在 update 结束时建立。读取方 (Store.SymbolStats、chronos summary)
+// 直接查这张小表：
+//
+//	chronos summary              # 各市场汇总
+//	chronos summary --symbols    # 逐只股票
+//	chronos summary --refresh    # 先整表重建
+
+// 统计缺失数的列
+var historyStatColumns = []string{"close", "close_adj", "open_adj", "high_adj", "low_adj", "pe", "volume"}
+
+func ensureHistoryStatsTable(db *sql.DB) {
+	cols := ""
+	for _, c := range historyStatColumns {
+		cols += fmt.Sprintf("\n\t\tnull_%s INTEGER NOT NULL,", c)
+	}
+	mustExec(db, `CREATE TABLE IF NOT EXISTS history_stats (
+		symbol     TEXT NOT NULL PRIMARY KEY,
+		market     TEXT NOT NULL,
+		first_date TEXT NOT NULL,
+		last_date  TEXT NOT NULL,
+		rows       INTEGER NOT NULL,`+cols+`
+		updated_at TEXT NOT NULL       -- 统计刷新时间 (本地时间)
+	) WITHOUT ROWID, STRICT;`)
+}
+
+// 统计 stock_history 的 SELECT 列表 (与 history_stats 的列顺序相同，updated_at 为 ?1)
+func historyStatsSelect() string {
+	var nulls []string
+	for _, c := range historyStatColumns {
+		nulls = append(nulls, "count(*) - count("+c+")")
+	}
+	return "symbol, max(market), min(date), max(date), count(*), " + strings.Join(nulls, ", ") + ", ?1"
+}
+
+// 重建 symbols 的统计 (nil 为整表重建)，返回刷新的代码数
+func refreshHistoryStats(db *sql.DB, symbols []string) (int, error) {
+	ensureHistoryStatsTable(db)
+	now := time.Now().Format(time.DateTime)
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if symbols == nil {
+		if _, err := tx.Exec("DELETE FROM history_stats"); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec("INSERT INTO history_stats SELECT "+historyStatsSelect()+" FROM stock_history GROUP BY symbol", now); err != nil {
+			return 0, err
+		}
+		var n int
+		tx.QueryRow("SELECT count(*) FROM history_stats").Scan(&n)
+		return n, tx.Commit()
+	}
+
+	// 单只股票按主键范围读取；没有行时 (已被删除) 只删除统计
+	del, err := tx.Prepare("DELETE FROM history_stats WHERE symbol = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer del.Close()
+	ins, err := tx.Prepare("INSERT INTO history_stats SELECT " + historyStatsSelect() + " FROM stock_history WHERE symbol = ?2 HAVING count(*) > 0")
+	if err != nil {
+		return 0, err
+	}
+	defer ins.Close()
+	for _, s := range symbols {
+		if _, err := del.Exec(s); err != nil {
+			return 0, err
+		}
+		if _, err := ins.Exec(now, s); err != nil {
+			return 0, err
+		}
+	}
+	return len(symbols), tx.Commit()
+}
+
+// 库中是否已有统计表 (没有时合并后不做增量刷新，由全量导入或 update 统一建立)
+func hasHistoryStats(db *sql.DB) bool {
+	var n int
+	db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'history_stats'").Scan(&n)
+	return n > 0
+}
+
+// staging_tech 中的代码 (合并前取出，合并后据此刷新统计)；没有统计表时不必刷新，返回空
+func stagedSymbols(db *sql.DB) []string {
+	if !hasHistoryStats(db) {
+		return []string{}
+	}
+	rows, err := db.Query("SELECT DISTINCT symbol FROM staging_tech WHERE symbol IS NOT NULL")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+	symbols := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			log.Fatal(err)
+		}
+		symbols = append(symbols, s)
+	}
+	return symbols
+}
+
+func mapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 合并之后刷新统计；统计只是缓存，失败时提示后继续
+func mustRefreshHistoryStats(db *sql.DB, symbols []string) {
+	start := time.Now()
+	n, err := refreshHistoryStats(db, symbols)
+	if err != nil {
+		log.Printf("[WARN] 刷新 history_stats 失败: %v", err)
+		return
+	}
+	if symbols == nil || time.Since(start) > time.Second {
+		log.Printf(">>> 已刷新 %d 只股票的日线统计, 耗时: %s", n, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// 一只股票的日线统计
+type SymbolStats struct {
+	Symbol    string
+	Market    string
+	FirstDate string
+	LastDate  string
+	Rows      int
+	Nulls     map[string]int // 列名 -> 缺失行数 (列见 historyStatColumns)
+	UpdatedAt string
+}
+
+// 缺失率 (0~1)
+func (s SymbolStats) NullRate(col string) float64 {
+	if s.Rows == 0 {
+		return 0
+	}
+	return float64(s.Nulls[col]) / float64(s.Rows)
+}
+
+// 全部股票的日线统计 (读缓存表，按代码排序)；没有缓存时返回空
+func (s *Store) SymbolStats(ctx context.Context) ([]SymbolStats, error) {
+	var exists int
+	if err := s.queryRow(ctx, "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'history_stats'").Scan(&exists); err != nil || exists == 0 {
+		return nil, err
+	}
+	cols := "symbol, market, first_date, last_date, rows, updated_at"
+	for _, c := range historyStatColumns {
+		cols += ", null_" + c
+	}
+	rows, err := s.query(ctx, "SELECT "+cols+" FROM history_stats ORDER BY symbol")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SymbolStats
+	nulls := make([]int, len(historyStatColumns))
+	for rows.Next() {
+		var st SymbolStats
+		dst := []any{&st.Symbol, &st.Market, &st.FirstDate, &st.LastDate, &st.Rows, &st.UpdatedAt}
+		for k := range nulls {
+			dst = append(dst, &nulls[k])
+		}
+		if err := rows.Scan(dst...); err != nil {
+			return nil, err
+		}
+		st.Nulls = make(map[string]int, len(nulls))
+		for k, c := range historyStatColumns {
+			st.Nulls[c] = nulls[k]
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
+
+// chronos summary [--symbols] [--refresh]
+func runSummary(args []string) {
+	fs := flag.NewFlagSet("summary", flag.ExitOnError)
+	perSymbol := fs.Bool("symbols", false, "逐只股票列出")
+	refresh := fs.Bool("refresh", false, "先整表重建统计")
+	fs.Parse(args)
+
+	db := openDB()
+	defer db.Close()
+	if *refresh {
+		mustRefreshHistoryStats(db, nil)
+	}
+	stats, err := newStore(db).SymbolStats(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(stats) == 0 {
+		log.Fatal("[ERROR] 还没有日线统计，请先执行 chronos summary --refresh (或重新导入)")
+	}
+
+	header := fmt.Sprintf("%-12s %-6s %-10s %-10s %10s", "代码", "市场", "起始", "最后", "行数")
+	for _, c := range historyStatColumns {
+		header += fmt.Sprintf(" %9s", c)
+	}
+	fmt.Println(header + "   (缺失率)")
+	line := func(name, market, first, last string, rows int, nulls map[string]int) {
+		fmt.Printf("%-12s %-6s %-10s %-10s %10d", name, market, first, last, rows)
+		for _, c := range historyStatColumns {
+			fmt.Printf(" %8.2f%%", 100*float64(nulls[c])/float64(max(rows, 1)))
+		}
+		fmt.Println()
+	}
+	if *perSymbol {
+		for _, s := range stats {
+			line(s.Symbol, s.Market, s.FirstDate, s.LastDate, s.Rows, s.Nulls)
+		}
+		return
+	}
+
+	type total struct {
+		symbols, rows int
+		first, last   string
+		nulls         map[string]int
+	}
+	byMarket := map[string]*total{}
+	updated := ""
+	for _, s := range stats {
+		t := byMarket[s.Market]
+		if t == nil {
+			t = &total{first: s.FirstDate, nulls: map[string]int{}}
+			byMarket[s.Market] = t
+		}
+		t.symbols++
+		t.rows += s.Rows
+		t.first, t.last = min(t.first, s.FirstDate), max(t.last, s.LastDate)
+		for c, n := range s.Nulls {
+			t.nulls[c] += n
+		}
+		updated = max(updated, s.UpdatedAt)
+	}
+	markets := make([]string, 0, len(byMarket))
+	for m := range byMarket {
+		markets = append(markets, m)
+	}
+	sort.Strings(markets)
+	for _, m := range markets {
+		t := byMarket[m]
+		line(fmt.Sprintf("%d 只", t.symbols), m, t.first, t.last, t.rows, t.nulls)
+	}
+	fmt.Printf("\n统计刷新于 %s\n", updated)
+}
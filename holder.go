@@ -0,0 +1,49 @@
+package main
+
+// 股东户数与持股集中度 (季度)。股东户数下降、前十大集中度上升常被视为筹码集中。
+// 以下字段可直接作为因子名使用 (按公告日时点对齐到日频，见 pit.go)：
+// holder_count、holder_avg_shares、top10_ratio、top10_float_ratio
+//
+// 索引：0:代码, 1:报告期 (截止日期), 2:公告日期, 3:股东户数, 4:户均持股, 5:前十大股东持股比例, 6:前十大流通股东持股比例
+func init() {
+	registerDataset(dataset{
+		Name:    "股东户数",
+		Pattern: PathHolderStats,
+		Table:   "holder_stats",
+		DDL: `CREATE TABLE IF NOT EXISTS holder_stats (
+			symbol             TEXT NOT NULL,
+			report_period      TEXT NOT NULL,
+			announce_date      TEXT,
+			avail_date         TEXT NOT NULL,   -- 可用日期：公告日，缺失时为法定披露截止日
+			holder_count       REAL,
+			avg_shares         REAL,
+			top10_ratio        REAL,            -- 百分数
+			top10_float_ratio  REAL,            -- 百分数
+			PRIMARY KEY (symbol, report_period)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 4,
+		Mapper: func(record []string) []any {
+			symbol := normText(record[0])
+			period := normDate(record[1])
+			if symbol == nil || period == nil {
+				return nil
+			}
+			announce := normDate(record[2])
+			return []any{
+				symbol,
+				period,
+				announce,
+				availDate(period, announce),
+				normNum(record[3]),
+				normNum(col(record, 4)),
+				normNum(col(record, 5)),
+				normNum(col(record, 6)),
+			}
+		},
+	})
+
+	registerPITField("holder_count", "holder_stats", "holder_count")
+	registerPITField("holder_avg_shares", "holder_stats", "avg_shares")
+	registerPITField("top10_ratio", "holder_stats", "top10_ratio")
+	registerPITField("top10_float_ratio", "holder_stats", "top10_float_ratio")
+}
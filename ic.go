@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// IC 分析：逐日计算因子值与远期收益的截面相关系数
+//
+//	IC     = Pearson(因子, 远期收益)
+//	RankIC = Spearman(因子, 远期收益)
+//
+// 汇总输出均值、ICIR (均值/标准差) 与 t 统计量，并把每日序列写成 CSV
+func runIC(args []string) {
+	fs := flag.NewFlagSet("ic", flag.ExitOnError)
+	factor := fs.String("factor", "pe", "因子名 (stock_history 列名或 factor_values 中的因子名)")
+	universe := fs.String("universe", "", "股票池：指数代码 (按当日成分股过滤，如 000300.SH) 或 tag:<概念>")
+	minListing := fs.Int("min-listing-days", 0, "剔除上市不足 N 个自然日的新股 (需导入新股发行信息)")
+	minQuality := fs.Float64("min-quality", 0, "剔除数据质量评分 (0~100，见 quality.go) 低于该值的股票")
+	horizon := fs.Int("horizon", 20, "远期收益的持有天数 (交易日)")
+	minCount := fs.Int("min-count", 30, "截面样本少于该数量的交易日不计算 IC")
+	market := fs.String("market", MarketCN, "市场: CN | HK | US (决定交易日历和股票范围)")
+	out := fs.String("out", "", "每日 IC 序列输出 CSV (默认 ic_<factor>_<horizon>.csv)")
+	fs.Parse(args)
+
+	if *horizon <= 0 {
+		log.Fatalf("[ERROR] horizon 必须为正数: %d", *horizon)
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("ic_%s_%d.csv", *factor, *horizon)
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+	mustHaveFactor(db, *factor)
+
+	// 远期收益按市场交易日历计算 (与 quantile、labels 同口径)，停牌股票也比较同一持有窗口
+	periods := calendarPeriods(tradingDates(db, *market), *horizon, 1)
+	if len(periods) == 0 {
+		log.Fatalf("[ERROR] 交易日不足 %d 天，无法计算远期收益", *horizon+1)
+	}
+
+	log.Printf(">>> 正在计算 IC: 因子 %s, 持有期 %d 天", *factor, *horizon)
+	query := fmt.Sprintf(`
+	SELECT f.date, f.value, r.value
+	FROM (%s) f
+	INNER JOIN (%s) r ON r.symbol = f.symbol AND r.date = f.date
+	WHERE f.value IS NOT NULL AND r.value IS NOT NULL AND %s AND %s AND %s AND %s = %s
+	ORDER BY f.date`, factorSource(*factor), calendarReturnSource(periods), universeCond(*universe, "f"), listingCond(*minListing, "f"),
+		qualityCond(*minQuality, "f"), marketSQL("f.symbol"), sqlQuote(*market))
+
+	rows, err := db.Query(query)
+	if err != nil {
+		log.Fatalf("SQL Error: %v | Query: %s", err, query)
+	}
+	defer rows.Close()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"date", "count", "ic", "rank_ic"})
+
+	var ics, rankICs []float64
+	var curDate string
+	var xs, ys []float64
+	flush := func() {
+		if curDate == "" || len(xs) < *minCount {
+			return
+		}
+		ic, ric := pearson(xs, ys), spearman(xs, ys)
+		ics = append(ics, ic)
+		rankICs = append(rankICs, ric)
+		w.Write([]string{curDate, strconv.Itoa(len(xs)), formatFloat(ic), formatFloat(ric)})
+	}
+	for rows.Next() {
+		var date string
+		var x, y float64
+		if err := rows.Scan(&date, &x, &y); err != nil {
+			log.Fatal(err)
+		}
+		if date != curDate {
+			flush()
+			curDate, xs, ys = date, xs[:0], ys[:0]
+		}
+		xs = append(xs, x)
+		ys = append(ys, y)
+	}
+	flush()
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf(">>> ✅ IC 计算完成, 耗时: %s, 序列已写入 %s", time.Since(start), *out)
+	printICSummary("IC", ics)
+	printICSummary("RankIC", rankICs)
+}
+
+func printICSummary(label string, series []float64) {
+	s := dropNaN(series)
+	if len(s) == 0 {
+		log.Printf(">>> %s: 无有效交易日", label)
+		return
+	}
+	m, sd := mean(s), stddev(s)
+	icir := m / sd
+	tstat := icir * math.Sqrt(float64(len(s)))
+	var pos int
+	for _, v := range s {
+		if v > 0 {
+			pos++
+		}
+	}
+	log.Printf(">>> %-6s 均值: %.4f | 标准差: %.4f | IR: %.4f | t: %.2f | 正比例: %.1f%% | 天数: %d",
+		label, m, sd, icir, tstat, 100*float64(pos)/float64(len(s)), len(s))
+}
+
+// NaN 写成空字符串，方便 pandas/Excel 读取
+func formatFloat(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
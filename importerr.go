@@ -0,0 +1,148 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// 导入中的错误
+// ---------------------------------------------------------
+// 打不开的文件、读到一半的 I/O 错误、违反约束的行，以前要么被忽略，要么只算作 "列数不足"，
+// 磁盘写满时得到的是一个悄悄变小的数据集。现在每个错误都带上文件名、行号与该行的值 (importError)，
+// 按 sources.yaml 中的 import.on_error 处理：
+//
+//	import:
+//	  on_error: skip     # 跳过出错的行 (或文件)，逐条打印并在结束时汇总 (默认)
+//	                     # abort: 遇到第一个错误即终止
+//	  max_errors: 1000   # skip 时累计出错超过该数即终止，0 为不限
+//
+// 只有数据本身的问题 (解析失败、违反约束、列数与表不符) 按设置跳过；其余写入错误 (磁盘已满、I/O 错误、
+// 事务无法开始或提交) 之后的行也会失败，总是直接终止，事务回滚。
+
+const (
+	onErrorSkip  = "skip"
+	onErrorAbort = "abort"
+)
+
+// 逐条打印的错误数，之后只计数
+const importErrorSamples = 20
+
+// 出错的位置。续传 (见 checkpoint.go) 时行号从续传位置起算，from 为续传的字节位置。
+// row 为该行在文件内映射出的第几行数据 (从 1 开始，续传时接着上次已提交的行数)，不随续传与并发解析变化
+type rowPos struct {
+	file string
+	line int
+	from int64
+	row  int
+}
+
+func (p rowPos) String() string {
+	switch {
+	case p.file == "":
+		return ""
+	case p.line == 0:
+		return p.file
+	case p.from > 0:
+		return fmt.Sprintf("%s 第 %d 字节之后第 %d 行", p.file, p.from, p.line)
+	}
+	return fmt.Sprintf("%s 第 %d 行", p.file, p.line)
+}
+
+// 一个出错的行 (或文件，此时 values 为空)
+type importError struct {
+	at     rowPos
+	values string
+	err    error
+}
+
+func (e *importError) Error() string {
+	var b strings.Builder
+	if s := e.at.String(); s != "" {
+		b.WriteString(s + ": ")
+	}
+	b.WriteString(e.err.Error())
+	if e.values != "" {
+		b.WriteString(" (值: " + e.values + ")")
+	}
+	return b.String()
+}
+
+func (e *importError) Unwrap() error { return e.err }
+
+// 行的值，NULL 写作 NULL，每个值最多 64 字节
+func formatRowValues(row []any) string {
+	parts := make([]string, len(row))
+	for k, v := range row {
+		s := "NULL"
+		if v != nil {
+			s = fmt.Sprint(v)
+		}
+		if len(s) > 64 {
+			s = strings.ToValidUTF8(s[:64], "") + "…"
+		}
+		parts[k] = s
+	}
+	return strings.Join(parts, " | ")
+}
+
+// 列数与表不符 (见 batchInserter.add)
+var errRowWidth = errors.New("列数与表不符")
+
+// 数据本身的问题，按 import.on_error 处理
+func isRowError(err error) bool {
+	return isConstraintError(err) || errors.Is(err, errRowWidth)
+}
+
+// 本次运行累计的错误
+type errorTally struct {
+	count int
+}
+
+var importErrs errorTally
+
+// 记录一个数据错误：abort 时终止，否则打印 (前 importErrorSamples 条) 后继续
+func (t *errorTally) add(e *importError) {
+	t.count++
+	if importOptions.onError == onErrorAbort {
+		fmt.Println()
+		log.Fatalf("[ERROR] %v (%s)", e, cmp.Or(strictReason(), "import.on_error: abort"))
+	}
+	switch {
+	case t.count <= importErrorSamples:
+		log.Printf("[ERROR] %v，已跳过", e)
+	case t.count == importErrorSamples+1:
+		log.Printf("[WARN] 出错已超过 %d 处，之后的错误只计数", importErrorSamples)
+	}
+	if m := importOptions.maxErrors; m > 0 && t.count > m {
+		fmt.Println()
+		log.Fatalf("[ERROR] 出错超过 %d 处 (import.max_errors)，已终止", m)
+	}
+}
+
+// 处理写入 table 时的错误 (batchInserter 返回的 batchErrors 或单行的 importError)：
+// 数据错误按 add 处理，其余错误直接终止。返回跳过的行数
+func (t *errorTally) write(table string, err error) int {
+	if err == nil {
+		return 0
+	}
+	var errs batchErrors
+	if !errors.As(err, &errs) {
+		var e *importError
+		if !errors.As(err, &e) {
+			e = &importError{err: err}
+		}
+		errs = batchErrors{e}
+	}
+	for _, e := range errs {
+		if !isRowError(e.err) {
+			fmt.Println()
+			log.Fatalf("[ERROR] 写入 %s 失败，已终止: %v", table, e)
+		}
+		t.add(e)
+	}
+	return len(errs)
+}
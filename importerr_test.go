@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 违反约束的行被跳过，错误中有文件、行号 (跨行记录按开始的行) 与该行的值；两种解析方式的行号相同
+func TestImportRowErrors(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.csv"), []byte("code,v\nA,1\nB,\n\"C\nD\",3\nE,x\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "b.csv"), []byte("code,v\r\n\r\nF,\r\n"), 0o644)
+	defer func(mode string) { importOptions.csv = mode }(importOptions.csv)
+
+	for _, mode := range []string{csvAuto, csvStrict} {
+		t.Run(mode, func(t *testing.T) {
+			importOptions.csv = mode
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			db := openTestDB(t, "x.db")
+			mustExec(db, "CREATE TABLE t (code TEXT NOT NULL, v REAL NOT NULL) STRICT")
+			before := importErrs.count
+			importCSVFiles(db, filepath.Join(dir, "*.csv"), "t", "", 2, func([]string) rowMapper {
+				return func(a *rowArena, f [][]byte) []any { return []any{a.text(f[0]), normNumBytes(f[1])} }
+			})
+			if got := dumpTable(t, db, "SELECT * FROM t ORDER BY code"); got != "A|1\nC\nD|3\n" {
+				t.Errorf("t = %q", got)
+			}
+			if n := importErrs.count - before; n != 3 {
+				t.Errorf("%d 处错误, want 3", n)
+			}
+			for _, want := range []string{"a.csv 第 3 行", "(值: B | NULL)", "a.csv 第 6 行", "(值: E | NULL)", "b.csv 第 3 行"} {
+				if !strings.Contains(logs.String(), want) {
+					t.Errorf("日志中没有 %q:\n%s", want, logs.String())
+				}
+			}
+		})
+	}
+}
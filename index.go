@@ -0,0 +1,41 @@
+package main
+
+// 基准指数日线 (沪深300、中证500、上证指数等)，用于超额收益、Beta 等计算。
+// 常用代码：000300.SH 沪深300，000905.SH 中证500，000001.SH 上证指数
+//
+// 索引：0:指数代码, 1:日期, 2:收盘, 3:开盘, 4:最高, 5:最低, 9:成交量, 10:成交额 (后两列可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "指数日线",
+		Pattern: PathIndexDaily,
+		Table:   "index_history",
+		DDL: `CREATE TABLE IF NOT EXISTS index_history (
+			index_code  TEXT NOT NULL,
+			date        TEXT NOT NULL,
+			open        REAL,
+			high        REAL,
+			low         REAL,
+			close       REAL,
+			volume      REAL,
+			amount      REAL,
+			PRIMARY KEY (index_code, date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 6,
+		Mapper: func(record []string) []any {
+			date := normDate(record[1])
+			if date == nil {
+				return nil
+			}
+			return []any{
+				normText(record[0]),
+				date,
+				normNum(record[3]),
+				normNum(record[4]),
+				normNum(record[5]),
+				normNum(record[2]),
+				normNum(col(record, 9)),
+				normNum(col(record, 10)),
+			}
+		},
+	})
+}
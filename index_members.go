@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// 指数历史成分股：区间 [in_date, out_date)，out_date 为空表示仍在指数中。
+// 按日期查询成分股可以避免用“今天的沪深300”回测历史带来的幸存者偏差。
+//
+// 索引：0:指数代码, 1:股票代码, 2:纳入日期, 3:剔除日期, 4:权重 (可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "指数成分",
+		Pattern: PathIndexMembers,
+		Table:   "index_members",
+		DDL: `CREATE TABLE IF NOT EXISTS index_members (
+			index_code  TEXT NOT NULL,
+			symbol      TEXT NOT NULL,
+			in_date     TEXT NOT NULL,
+			out_date    TEXT,
+			weight      REAL,
+			PRIMARY KEY (index_code, symbol, in_date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 3,
+		Mapper: func(record []string) []any {
+			inDate := normDate(record[2])
+			if inDate == nil {
+				return nil
+			}
+			return []any{
+				normText(record[0]),
+				normText(record[1]),
+				inDate,
+				normDate(col(record, 3)),
+				normNum(col(record, 4)),
+			}
+		},
+	})
+}
+
+// 股票池过滤条件：alias 表的 symbol 在 alias.date 当天属于该指数；indexCode 为空时不过滤，
+// 写作 tag:<概念> 时按概念板块过滤 (见 tags.go)
+func universeCond(indexCode, alias string) string {
+	if indexCode == "" {
+		return "1=1"
+	}
+	if tag, ok := strings.CutPrefix(indexCode, tagUniversePrefix); ok {
+		return tagCond(tag, alias)
+	}
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM index_members m
+		WHERE m.index_code = %s AND m.symbol = %[2]s.symbol
+		AND m.in_date <= %[2]s.date AND (m.out_date IS NULL OR m.out_date > %[2]s.date))`, sqlQuote(indexCode), alias)
+}
+
+// 把 "2018-06" 这样的月份解析为该月最后一天，完整日期原样返回
+func asOfDate(s string) (string, error) {
+	if t, err := time.Parse("2006-01", s); err == nil {
+		return t.AddDate(0, 1, -1).Format(time.DateOnly), nil
+	}
+	if _, err := time.Parse(time.DateOnly, s); err != nil {
+		return "", fmt.Errorf("日期格式错误 (需要 YYYY-MM-DD 或 YYYY-MM): %s", s)
+	}
+	return s, nil
+}
+
+type IndexMember struct {
+	Symbol string
+	Weight float64 // 未提供时为 NaN
+}
+
+var indexMembersSQL = `SELECT symbol, weight FROM index_members
+	WHERE index_code = ?1 AND in_date <= ?2 AND (out_date IS NULL OR out_date > ?2)
+	ORDER BY symbol`
+
+// 某指数在指定日期的成分股 (时点查询)
+func (s *Store) IndexMembers(ctx context.Context, indexCode, date string) ([]IndexMember, error) {
+	rows, err := s.query(ctx, indexMembersSQL, indexCode, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []IndexMember
+	for rows.Next() {
+		var m IndexMember
+		var w sql.NullFloat64
+		if err := rows.Scan(&m.Symbol, &w); err != nil {
+			return nil, err
+		}
+		m.Weight = nullToNaN(w)
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// chronos members --index 000300.SH --date 2018-06
+func runMembers(args []string) {
+	fs := flag.NewFlagSet("members", flag.ExitOnError)
+	index := fs.String("index", "000300.SH", "指数代码")
+	date := fs.String("date", time.Now().Format(time.DateOnly), "查询日期 (YYYY-MM-DD 或 YYYY-MM)")
+	fs.Parse(args)
+
+	asOf, err := asOfDate(*date)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	db := openDB()
+	defer db.Close()
+
+	members, err := newStore(db).IndexMembers(context.Background(), *index, asOf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, m := range members {
+		fmt.Printf("%s\t%s\n", m.Symbol, formatFloat(m.Weight))
+	}
+	log.Printf(">>> %s 截至 %s 共 %d 只成分股", *index, asOf, len(members))
+}
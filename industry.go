@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 行业分类表：同一只股票在不同时间段可能属于不同行业
+// out_date 为空表示至今有效，区间为 [in_date, out_date)。
+// 同时保存多套分类标准 (申万 SW / 中信 CITIC) 的一至三级行业，使用时按 industryScheme 选取其中一套。
+const industryDDL = `CREATE TABLE IF NOT EXISTS industry_class (
+		symbol    TEXT NOT NULL,
+		standard  TEXT NOT NULL,      -- SW / CITIC
+		level     INTEGER NOT NULL,   -- 1~3
+		code      TEXT,
+		industry  TEXT NOT NULL,
+		in_date   TEXT NOT NULL,
+		out_date  TEXT,
+		PRIMARY KEY (symbol, standard, level, in_date)
+	) WITHOUT ROWID, STRICT;`
+
+func ensureIndustryTables(db *sql.DB) {
+	mustExec(db, industryDDL)
+}
+
+// 索引：0:代码, 1:分类标准 (申万/中信/SW/CITIC), 2:级别 (1~3 或 一级/二级/三级), 3:行业代码, 4:行业名称, 5:纳入日期, 6:剔除日期 (可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "行业分类",
+		Pattern: PathIndustry,
+		Table:   "industry_class",
+		DDL:     industryDDL,
+		MinCols: 6,
+		Mapper: func(record []string) []any {
+			symbol := normText(record[0])
+			standard := normIndustryStandard(record[1])
+			level := normIndustryLevel(record[2])
+			name := normText(record[4])
+			inDate := normDate(record[5])
+			if symbol == nil || standard == "" || level == 0 || name == nil || inDate == nil {
+				return nil
+			}
+			return []any{symbol, standard, level, normText(record[3]), name, inDate, normDate(col(record, 6))}
+		},
+	})
+}
+
+func normIndustryStandard(s string) string {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "SW", "申万", "申万行业", "SWS":
+		return "SW"
+	case "CITIC", "中信", "中信行业", "CS":
+		return "CITIC"
+	}
+	return ""
+}
+
+func normIndustryLevel(s string) int {
+	switch strings.TrimSpace(s) {
+	case "1", "一级", "L1":
+		return 1
+	case "2", "二级", "L2":
+		return 2
+	case "3", "三级", "L3":
+		return 3
+	}
+	return 0
+}
+
+// 分类口径：标准 + 级别，命令行写作 SW1、CITIC2 这样的形式
+type industryScheme struct {
+	Standard string
+	Level    int
+}
+
+const DefaultIndustryScheme = "SW1"
+
+func parseIndustryScheme(s string) (industryScheme, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) >= 2 {
+		level, err := strconv.Atoi(s[len(s)-1:])
+		std := normIndustryStandard(s[:len(s)-1])
+		if err == nil && level >= 1 && level <= 3 && std != "" {
+			return industryScheme{std, level}, nil
+		}
+	}
+	return industryScheme{}, fmt.Errorf("行业分类口径格式错误 (需要 SW1~SW3 或 CITIC1~CITIC3): %s", s)
+}
+
+func (s industryScheme) String() string {
+	return s.Standard + strconv.Itoa(s.Level)
+}
+
+// 按日期关联行业的 JOIN 条件 (h 为带 symbol/date 的行，i 为 industry_class)
+func industryJoinCond(s industryScheme) string {
+	return fmt.Sprintf(`i.symbol = h.symbol AND i.standard = %s AND i.level = %d
+		AND h.date >= i.in_date AND (i.out_date IS NULL OR h.date < i.out_date)`, sqlQuote(s.Standard), s.Level)
+}
+
+// 某一天各行业的成分股：行业 -> 代码列表
+func industryMembers(db *sql.DB, date string, s industryScheme) (map[string][]string, error) {
+	ensureIndustryTables(db)
+	rows, err := db.Query(`SELECT industry, symbol FROM industry_class
+		WHERE standard = ? AND level = ? AND in_date <= ? AND (out_date IS NULL OR out_date > ?)
+		ORDER BY industry, symbol`, s.Standard, s.Level, date, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string][]string{}
+	for rows.Next() {
+		var ind, sym string
+		if err := rows.Scan(&ind, &sym); err != nil {
+			return nil, err
+		}
+		out[ind] = append(out[ind], sym)
+	}
+	return out, rows.Err()
+}
+
+type Industry struct {
+	Standard string
+	Level    int
+	Code     string
+	Name     string
+}
+
+var industryOfSQL = `SELECT standard, level, coalesce(code, ''), industry FROM industry_class
+	WHERE symbol = ?1 AND in_date <= ?2 AND (out_date IS NULL OR out_date > ?2)
+	ORDER BY standard, level`
+
+// 股票在指定日期所属的行业 (全部分类标准与级别，按标准、级别排序)
+func (s *Store) IndustryOf(ctx context.Context, symbol, date string) ([]Industry, error) {
+	rows, err := s.query(ctx, industryOfSQL, symbol, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Industry
+	for rows.Next() {
+		var ind Industry
+		if err := rows.Scan(&ind.Standard, &ind.Level, &ind.Code, &ind.Name); err != nil {
+			return nil, err
+		}
+		out = append(out, ind)
+	}
+	return out, rows.Err()
+}
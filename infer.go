@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ---------------------------------------------------------
+// 列布局推断
+// ---------------------------------------------------------
+// 新供应商的文件先用 infer 看一眼：抽样若干行，推断每列的类型 (日期、代码、整数、小数、文本) 和用途
+// (代码、日期、开高低收、复权价、成交量、比率)，逐项确认后输出可直接粘贴到 sources.yaml 的 profiles 片段：
+//
+//	chronos infer D:\newvendor\600000.csv
+//	chronos infer --table staging_tech --yes sample.csv > profile.yaml
+//
+// 交互时直接回车接受推断，输入列号改选，输入 - 表示没有该列；--yes 或标准输入不是终端时全部接受推断。
+
+// 各目标表需要确定的字段 (stock_history 使用 vendor 的字段名，见 profiles.go)
+var inferTargets = map[string][]string{
+	"stock_history": {"symbol", "date", "open", "high", "low", "close", "adj_close", "adj_factor", "volume", "pe"},
+	"staging_tech":  stagingColumns["staging_tech"],
+	"staging_daily": stagingColumns["staging_daily"],
+}
+
+// 字段 -> 列名关键词 (小写)；adj 表示复权列，复权与不复权的字段互斥
+var inferKeywords = map[string][]string{
+	"symbol":     {"ts_code", "symbol", "ticker", "code", "代码"},
+	"date":       {"trade_date", "date", "日期", "时间"},
+	"open":       {"open", "开盘"},
+	"high":       {"high", "最高"},
+	"low":        {"low", "最低"},
+	"close":      {"close", "收盘"},
+	"adj_factor": {"adj_factor", "factor", "复权因子"},
+	"volume":     {"vol", "成交量"},
+	"pe":         {"pe_ttm", "pe", "市盈率"},
+}
+
+var (
+	inferSymbolPattern = regexp.MustCompile(`^(?i)(\d{6}\.(SH|SZ|BJ|SS)|(SH|SZ|BJ)\d{6}|\d{5}(\.HK)?|[A-Z]{1,5}(\.US)?|\d{6})$`)
+	inferAdjMarkers    = []string{"hfq", "adj", "复权", "后复权"}
+	inferSkipMarkers   = []string{"pre", "前收", "昨收", "qfq", "前复权"}
+	inferNullMarkers   = []string{"", "--", "-", "NaN", "nan", "NULL", "null", "None"} // 同 normNum
+)
+
+type inferColumn struct {
+	Index       int
+	Header      string
+	Kind        string // date / symbol / int / float / text / empty
+	Sample      string
+	Min, Max    float64
+	Ratio       bool // 数值都在 [-1, 1] 内或列名像比率 (%、pct、涨跌幅、换手)
+	lower       string
+	adj, ignore bool
+}
+
+func inferKind(values []string) (kind string, lo, hi float64) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	counts := map[string]int{}
+	n := 0
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if containsString(inferNullMarkers, v) {
+			continue
+		}
+		n++
+		if d, ok := normDate(v).(string); ok && len(d) == 10 {
+			if _, err := time.Parse(time.DateOnly, d); err == nil && (len(v) == 8 || strings.ContainsAny(v, "-/")) {
+				counts["date"]++
+				continue
+			}
+		}
+		if f, err := strconv.ParseFloat(strings.ReplaceAll(v, ",", ""), 64); err == nil {
+			lo, hi = math.Min(lo, f), math.Max(hi, f)
+			if strings.ContainsAny(v, ".eE") {
+				counts["float"]++
+			} else {
+				counts["int"]++
+			}
+			if inferSymbolPattern.MatchString(v) && len(v) >= 5 && v[0] == '0' {
+				counts["symbol"]++ // 000001 这类代码同时也是整数
+			}
+			continue
+		}
+		if inferSymbolPattern.MatchString(v) {
+			counts["symbol"]++
+			continue
+		}
+		counts["text"]++
+	}
+	switch {
+	case n == 0:
+		return "empty", 0, 0
+	case counts["date"] == n:
+		return "date", 0, 0
+	case counts["symbol"] == n:
+		return "symbol", 0, 0
+	case counts["int"]+counts["float"] == n && counts["float"] == 0:
+		return "int", lo, hi
+	case counts["int"]+counts["float"] == n:
+		return "float", lo, hi
+	}
+	return "text", 0, 0
+}
+
+func containsAny(s string, words []string) bool {
+	for _, w := range words {
+		if strings.Contains(s, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// 列与字段的匹配分数，0 表示不可能
+func inferScore(c inferColumn, field string) int {
+	numeric := c.Kind == "int" || c.Kind == "float"
+	base, adj := strings.TrimSuffix(strings.TrimSuffix(field, "_adj"), "_raw"), strings.HasSuffix(field, "_adj") || field == "adj_close"
+	if field == "adj_close" {
+		base = "close"
+	}
+	switch {
+	case field == "symbol":
+		if c.Kind != "symbol" && c.Kind != "text" && c.Kind != "int" {
+			return 0
+		}
+	case field == "date":
+		if c.Kind != "date" {
+			return 0
+		}
+		if containsAny(c.lower, inferKeywords["date"]) {
+			return 3
+		}
+		return 2
+	case !numeric || c.ignore:
+		return 0
+	}
+	score := 0
+	if containsAny(c.lower, inferKeywords[base]) {
+		score = 2
+	}
+	switch {
+	case field == "symbol":
+		if c.Kind == "symbol" {
+			score++
+		}
+		return score
+	case field == "adj_factor" || field == "volume" || field == "pe":
+		if c.adj && field != "adj_factor" {
+			return 0
+		}
+		return score
+	case adj != c.adj:
+		return 0
+	}
+	return score
+}
+
+// 每个字段选分数最高的列 (每列只用一次)，返回 字段 -> 列号，-1 表示没有
+func inferAssign(cols []inferColumn, fields []string) map[string]int {
+	type cand struct {
+		field string
+		col   int
+		score int
+	}
+	var cands []cand
+	for _, f := range fields {
+		for _, c := range cols {
+			if s := inferScore(c, f); s >= 2 {
+				cands = append(cands, cand{f, c.Index, s})
+			}
+		}
+	}
+	sort.SliceStable(cands, func(a, b int) bool { return cands[a].score > cands[b].score })
+	out, used := map[string]int{}, map[int]bool{}
+	for _, f := range fields {
+		out[f] = -1
+	}
+	for _, c := range cands {
+		if out[c.field] < 0 && !used[c.col] {
+			out[c.field], used[c.col] = c.col, true
+		}
+	}
+	return out
+}
+
+// chronos infer [--table stock_history] [--rows 200] [--name my-vendor] [--yes] file.csv
+func runInfer(args []string) {
+	fs := flag.NewFlagSet("infer", flag.ExitOnError)
+	table := fs.String("table", "stock_history", "目标表: stock_history / staging_tech / staging_daily")
+	sampleRows := fs.Int("rows", 200, "抽样行数")
+	name := fs.String("name", "", "布局名称，默认取文件名")
+	yes := fs.Bool("yes", false, "不询问，全部接受推断")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("[ERROR] 用法: chronos infer [选项] file.csv")
+	}
+	path := fs.Arg(0)
+	fields, ok := inferTargets[*table]
+	if !ok {
+		log.Fatalf("[ERROR] 未知目标表: %s", *table)
+	}
+	if *name == "" {
+		*name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	_, _, headerLimit := fileBufferOptions(path)
+	br := bufio.NewReaderSize(f, headerLimit)
+	if _, err := skipBOM(br); err != nil {
+		log.Fatalf("[ERROR] %s: %v", path, err)
+	}
+	line, _ := peekHeaderLine(br, headerLimit)
+	r := csv.NewReader(br)
+	r.Comma = sniffDelimiter(line)
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		log.Fatalf("[ERROR] 读取表头失败: %v", err)
+	}
+	values := make([][]string, len(header))
+	n := 0
+	for ; n < *sampleRows; n++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+		for k := range header {
+			if k < len(rec) {
+				values[k] = append(values[k], rec[k])
+			}
+		}
+	}
+
+	cols := make([]inferColumn, len(header))
+	for k, h := range header {
+		c := inferColumn{Index: k, Header: strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))}
+		c.lower = strings.ToLower(c.Header)
+		c.adj = containsAny(c.lower, inferAdjMarkers) && !strings.Contains(c.lower, "factor") && !strings.Contains(c.lower, "因子")
+		c.ignore = containsAny(c.lower, inferSkipMarkers)
+		c.Kind, c.Min, c.Max = inferKind(values[k])
+		for _, v := range values[k] {
+			if strings.TrimSpace(v) != "" {
+				c.Sample = strings.TrimSpace(v)
+				break
+			}
+		}
+		c.Ratio = (c.Kind == "float" && c.Min >= -1 && c.Max <= 1) || containsAny(c.lower, []string{"%", "pct", "ratio", "涨跌幅", "换手"})
+		cols[k] = c
+	}
+
+	encoding := "UTF-8"
+	if !utf8.ValidString(line) {
+		encoding = "GBK"
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d 列, 抽样 %d 行, 分隔符 %q, 编码 %s, 表头指纹 %s\n\n", path, len(header), n, r.Comma, encoding, headerFingerprint(header))
+	fmt.Fprintf(os.Stderr, "%4s  %-20s %-7s %-24s %s\n", "列", "表头", "类型", "示例", "范围")
+	for _, c := range cols {
+		rng := ""
+		if c.Kind == "int" || c.Kind == "float" {
+			rng = fmt.Sprintf("%g ~ %g", c.Min, c.Max)
+			if c.Ratio {
+				rng += " (比率)"
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%4d  %-20s %-7s %-24s %s\n", c.Index, truncate(c.Header, 20), c.Kind, truncate(c.Sample, 24), rng)
+	}
+
+	assign := inferAssign(cols, fields)
+	stat, _ := os.Stdin.Stat()
+	interactive := !*yes && stat != nil && stat.Mode()&os.ModeCharDevice != 0
+	if interactive {
+		fmt.Fprintln(os.Stderr, "\n确认各字段所在列 (回车接受，输入列号改选，- 表示没有):")
+		in := bufio.NewReader(os.Stdin)
+		for _, field := range fields {
+			for {
+				guess := "-"
+				if k := assign[field]; k >= 0 {
+					guess = fmt.Sprintf("%d %s", k, cols[k].Header)
+				}
+				fmt.Fprintf(os.Stderr, "  %-10s [%s]: ", field, guess)
+				answer, err := in.ReadString('\n')
+				answer = strings.TrimSpace(answer)
+				if err != nil || answer == "" {
+					break
+				}
+				if answer == "-" {
+					assign[field] = -1
+					break
+				}
+				if k, err := strconv.Atoi(answer); err == nil && k >= 0 && k < len(cols) {
+					assign[field] = k
+					break
+				}
+				fmt.Fprintln(os.Stderr, "  请输入 0 ~", len(cols)-1, "之间的列号或 -")
+			}
+		}
+	}
+	for _, field := range []string{"symbol", "date"} {
+		if assign[field] < 0 {
+			log.Printf("[WARN] 没有确定 %s 列，生成的布局无法使用", field)
+		}
+	}
+
+	// 输出 sources.yaml 片段
+	fmt.Printf("profiles:\n  %s:\n", *name)
+	fmt.Printf("    # 表头: %s\n", strings.Join(header, ","))
+	fmt.Printf("    fingerprint: %s\n    table: %s\n    encoding: %s\n    columns:\n", headerFingerprint(header), *table, encoding)
+	for _, field := range fields {
+		if k := assign[field]; k >= 0 {
+			fmt.Printf("      %s: %d\n", field, k)
+		}
+	}
+	if k := assign["volume"]; *table == "stock_history" && k >= 0 && strings.Contains(cols[k].Header, "手") {
+		fmt.Println("    volume_unit: 100")
+	}
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// 分钟线 → 日线：日线供应商文件通常滞后几天，而分钟线当天就有。
+// 对每只股票在其最后一个日线日期之后、有分钟线的交易日，聚合出一根日 K 线补进 stock_history：
+// 开盘取首根、最高/最低取极值、收盘取末根、成交量求和；同一天有多种周期时取最细的一种。
+//
+// 分钟线是不复权价格，复权价按该股最后一个日线交易日的复权因子 (close_adj / close) 换算，
+// 即假设补齐的这几天里没有除权除息；没有任何日线记录的新股因子取 1 (后复权以上市价为基准)。
+// PE 需要财务数据，补齐的日期保留 NULL。
+//
+// 补齐的行在 history_provenance 中记为 source = 'minute'。这些行不算"库中已有"：update files 的两种过滤
+// 都放行它们，在线数据源的链 (见 chain.go) 也照常补，供应商日线写入时顺带删掉来源记录 (见 clearMinuteProvenance)。
+// 再次聚合时以最后一个非分钟线来源的日线为准，之后的日期重新聚合。
+const minuteSource = "minute"
+
+// 非分钟线聚合而来的日线行
+const notMinuteRowSQL = `NOT EXISTS (SELECT 1 FROM history_provenance p
+	WHERE p.symbol = h.symbol AND p.date = h.date AND p.source = '` + minuteSource + `')`
+
+var minuteToDailySQL = `
+WITH last_daily AS (
+	SELECT h.symbol, max(h.date) AS date FROM stock_history h WHERE ` + notMinuteRowSQL + ` GROUP BY h.symbol
+),
+adj AS (
+	SELECT h.symbol, h.date, h.close_adj / NULLIF(h.close, 0) AS factor
+	FROM stock_history h
+	INNER JOIN last_daily l ON l.symbol = h.symbol AND l.date = h.date
+),
+days AS (
+	SELECT symbol, freq, substr(local_time(ts, tz), 1, 10) AS date,
+		min(ts) AS first_ts, max(ts) AS last_ts,
+		max(high) AS high, min(low) AS low, sum(volume) AS volume
+	FROM stock_minute
+	GROUP BY symbol, freq, substr(local_time(ts, tz), 1, 10)
+),
+finest AS (
+	SELECT *, ROW_NUMBER() OVER (PARTITION BY symbol, date ORDER BY freq) AS rn
+	FROM days
+)
+SELECT d.symbol, d.date,
+	c.close,
+	c.close * coalesce(a.factor, 1),
+	o.open * coalesce(a.factor, 1),
+	d.high * coalesce(a.factor, 1),
+	d.low * coalesce(a.factor, 1),
+	NULL,
+	d.volume,
+	` + marketSQL("d.symbol") + `
+FROM finest d
+INNER JOIN stock_minute o ON o.symbol = d.symbol AND o.freq = d.freq AND o.ts = d.first_ts
+INNER JOIN stock_minute c ON c.symbol = d.symbol AND c.freq = d.freq AND c.ts = d.last_ts
+LEFT JOIN adj a ON a.symbol = d.symbol
+WHERE d.rn = 1
+	AND (a.date IS NULL OR d.date > a.date)
+	AND c.close IS NOT NULL`
+
+func aggregateMinuteToDaily(db *sql.DB) {
+	log.Println(">>> 正在用分钟线补齐缺失的日线...")
+	n, err := minuteToDaily(db)
+	if err != nil {
+		log.Fatalf("SQL Error: %v", err)
+	}
+	log.Printf(">>> 分钟线聚合补齐日线: %d 行", n)
+}
+
+// 聚合并写入 stock_history，同时记录来源；返回写入的行数
+func minuteToDaily(db *sql.DB) (int, error) {
+	ensureProvenanceTable(db)
+	// 临时表只在当前连接可见，整个过程放在同一个事务 (连接) 里
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, q := range []string{
+		"DROP TABLE IF EXISTS temp.minute_daily",
+		"CREATE TEMP TABLE minute_daily AS " + minuteToDailySQL,
+		`INSERT OR REPLACE INTO stock_history (symbol, date, close, close_adj, open_adj, high_adj, low_adj, pe, volume, market)
+			SELECT * FROM temp.minute_daily`,
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return 0, err
+		}
+	}
+	res, err := tx.Exec(`INSERT OR REPLACE INTO history_provenance SELECT symbol, date, ?, ? FROM temp.minute_daily`,
+		minuteSource, time.Now().Format(time.DateTime))
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	if _, err := tx.Exec("DROP TABLE temp.minute_daily"); err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// 分钟线聚合而来的 (代码, 日期)，日期去掉分隔符 (YYYYMMDD)，与 staging 表一致
+func loadMinuteKeys(db *sql.DB) (map[stagingKey]bool, error) {
+	ensureProvenanceTable(db)
+	rows, err := db.Query("SELECT symbol, replace(date, '-', '') FROM history_provenance WHERE source = ?", minuteSource)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := map[stagingKey]bool{}
+	for rows.Next() {
+		var k stagingKey
+		if err := rows.Scan(&k.symbol, &k.date); err != nil {
+			return nil, err
+		}
+		keys[k] = true
+	}
+	return keys, rows.Err()
+}
+
+// staging_tech 中的行即将覆盖 stock_history，其中原本由分钟线聚合而来的行删掉来源记录
+func clearMinuteProvenance(tx execer) error {
+	_, err := tx.Exec(`DELETE FROM history_provenance WHERE source = ? AND EXISTS (SELECT 1 FROM staging_tech t
+		WHERE t.symbol = history_provenance.symbol AND t.date = replace(history_provenance.date, '-', ''))`, minuteSource)
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+// 分钟线补齐的行记为 minute 来源；供应商日线合并后覆盖它们并删掉来源记录，再次聚合不会把它们写回
+func TestMinuteDailyReplacedByVendor(t *testing.T) {
+	db := openTestDB(t, "intraday.db")
+	createTables(db)
+	mustExec(db, minuteDDL)
+	mustExec(db, `INSERT INTO stock_history (symbol, date, close, close_adj, market) VALUES ('600000.SH', '2024-01-02', 10, 20, 'CN')`)
+	for _, b := range []struct {
+		ts                      string
+		open, high, low, close_ float64
+	}{
+		{"2024-01-03 09:31:00", 10.0, 10.2, 9.9, 10.1},
+		{"2024-01-03 15:00:00", 10.1, 10.5, 10.0, 10.4},
+	} {
+		ts, _ := localToEpoch(b.ts, "Asia/Shanghai")
+		mustExec(db, `INSERT INTO stock_minute VALUES ('600000.SH', 1, ?, 'Asia/Shanghai', ?, ?, ?, ?, 100, NULL)`,
+			ts, b.open, b.high, b.low, b.close_)
+	}
+
+	if n, err := minuteToDaily(db); err != nil || n != 1 {
+		t.Fatalf("minuteToDaily = %d, %v", n, err)
+	}
+	const query = `SELECT h.date, h.close, h.close_adj, h.pe, p.source FROM stock_history h
+		LEFT JOIN history_provenance p ON p.symbol = h.symbol AND p.date = h.date ORDER BY h.date`
+	if got, want := dumpTable(t, db, query), "2024-01-02|10|20|NULL|NULL\n2024-01-03|10.4|20.8|NULL|minute\n"; got != want {
+		t.Errorf("聚合后:\n%s\nwant:\n%s", got, want)
+	}
+	keys, err := loadMinuteKeys(db)
+	if err != nil || !keys[stagingKey{"600000.SH", "20240103"}] {
+		t.Errorf("loadMinuteKeys = %v, %v", keys, err)
+	}
+
+	// 供应商日线到达 (staging 日期为 YYYYMMDD)
+	mustExec(db, `INSERT INTO staging_tech VALUES ('600000.SH', '20240103', '10.4', '20.9', '20.1', '21.0', '20.0', NULL)`)
+	mustExec(db, `INSERT INTO staging_daily VALUES ('600000.SH', '20240103', '8.5')`)
+	mergeStaging(db)
+	if _, err := minuteToDaily(db); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dumpTable(t, db, query), "2024-01-02|10|20|NULL|NULL\n2024-01-03|10.4|20.9|8.5|NULL\n"; got != want {
+		t.Errorf("供应商日线合并后:\n%s\nwant:\n%s", got, want)
+	}
+}
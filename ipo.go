@@ -0,0 +1,55 @@
+package main
+
+import "fmt"
+
+// 新股发行信息。上市初期 (尤其是 A 股连续涨停阶段) 的价格不反映正常定价，
+// 因子研究通常剔除上市不足 N 天的股票：ic / quantile 的 --min-listing-days 即按此过滤。
+// 派生字段 days_since_listing (上市以来的自然日数，见视图 v_listing_days) 可直接作为因子名使用；
+// 没有发行信息的股票以 stock_history 中的首个交易日近似上市日。
+//
+// 索引：0:代码, 1:上市日期, 2:发行价, 3:申购日期, 4:发行数量 (万股), 5:发行市盈率, 6:网上中签率 (%) (2 之后可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "新股发行",
+		Pattern: PathIPO,
+		Table:   "ipo_info",
+		DDL: `CREATE TABLE IF NOT EXISTS ipo_info (
+			symbol        TEXT NOT NULL PRIMARY KEY,
+			listing_date  TEXT NOT NULL,
+			issue_price   REAL,
+			issue_date    TEXT,             -- 申购日
+			issue_shares  REAL,             -- 万股
+			issue_pe      REAL,
+			lot_rate      REAL              -- 网上中签率，百分数
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 2,
+		Mapper: func(record []string) []any {
+			symbol := normText(record[0])
+			listing := normDate(record[1])
+			if symbol == nil || listing == nil {
+				return nil
+			}
+			return []any{
+				symbol,
+				listing,
+				normNum(col(record, 2)),
+				normDate(col(record, 3)),
+				normNum(col(record, 4)),
+				normNum(col(record, 5)),
+				normNum(col(record, 6)),
+			}
+		},
+	})
+
+	registerDerivedColumn("days_since_listing", "v_listing_days")
+}
+
+// 过滤条件：alias 表的 symbol 在 alias.date 当天已上市满 days 个自然日；days <= 0 时不过滤。
+// 只对有发行信息的股票生效
+func listingCond(days int, alias string) string {
+	if days <= 0 {
+		return "1=1"
+	}
+	return fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM ipo_info i
+		WHERE i.symbol = %[1]s.symbol AND julianday(%[1]s.date) - julianday(i.listing_date) < %[2]d)`, alias, days)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 已有主键的内存集合
+// ---------------------------------------------------------
+// update files 默认只导入库中 A 股最后一天之后的行，某只股票中间缺的日子 (停牌后补发、上次导入中断)
+// 不会再被补上。--filter keys 改为启动时把 stock_history 的全部 (代码, 日期) 读进内存，解析时就跳过
+// 已有的行，只有真正新增的行才写进 staging，合并时几乎没有冲突需要处理。
+//
+// 每个代码一张按天编号的位图 (从该代码最早的日期开始，每天 1 位)，30 年的日线约 1.4KB，
+// 全市场一万只股票十几 MB。与布隆过滤器不同，位图是精确的，不会把新行误判为已有而漏掉。
+
+// 从 1970-01-01 起的天数；日期可为 YYYYMMDD 或 YYYY-MM-DD (其他分隔符同)，无法识别时返回 false
+func dayNumber(s string) (int, bool) {
+	var digits [8]int
+	n := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		if n == len(digits) {
+			return 0, false
+		}
+		digits[n] = int(c - '0')
+		n++
+	}
+	if n != len(digits) {
+		return 0, false
+	}
+	y := digits[0]*1000 + digits[1]*100 + digits[2]*10 + digits[3]
+	m := digits[4]*10 + digits[5]
+	d := digits[6]*10 + digits[7]
+	if m < 1 || m > 12 || d < 1 || d > 31 {
+		return 0, false
+	}
+	return int(time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC).Unix() / 86400), true
+}
+
+// 一个代码的日期位图：第 i 位表示 base + i 这一天 (base 按 64 对齐)
+type dayBitmap struct {
+	base  int
+	words []uint64
+}
+
+func (b *dayBitmap) set(day int) {
+	start := day &^ 63
+	if len(b.words) == 0 {
+		b.base = start
+	}
+	if start < b.base {
+		// 早于现有范围：在前面补齐
+		grow := (b.base - start) / 64
+		b.words = append(make([]uint64, grow, grow+len(b.words)), b.words...)
+		b.base = start
+	}
+	i := day - b.base
+	for i/64 >= len(b.words) {
+		b.words = append(b.words, 0)
+	}
+	b.words[i/64] |= 1 << (i % 64)
+}
+
+func (b *dayBitmap) has(day int) bool {
+	i := day - b.base
+	if i < 0 || i/64 >= len(b.words) {
+		return false
+	}
+	return b.words[i/64]&(1<<(i%64)) != 0
+}
+
+// stock_history 中已有的 (代码, 日期)；加载完成后只读，可在多个解析 goroutine 中同时查询
+type historyKeySet struct {
+	symbols map[string]*dayBitmap
+	rows    int
+}
+
+func loadHistoryKeys(db *sql.DB) (*historyKeySet, error) {
+	// 按主键顺序读出，每个代码的位图只会向后增长
+	rows, err := db.Query("SELECT symbol, date FROM stock_history ORDER BY symbol, date")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ks := &historyKeySet{symbols: map[string]*dayBitmap{}}
+	var cur *dayBitmap
+	var curSymbol string
+	for rows.Next() {
+		var symbol, date string
+		if err := rows.Scan(&symbol, &date); err != nil {
+			return nil, err
+		}
+		day, ok := dayNumber(date)
+		if !ok {
+			continue
+		}
+		if cur == nil || symbol != curSymbol {
+			cur = &dayBitmap{}
+			curSymbol = symbol
+			ks.symbols[symbol] = cur
+		}
+		cur.set(day)
+		ks.rows++
+	}
+	return ks, rows.Err()
+}
+
+func (ks *historyKeySet) has(symbol, date string) bool {
+	b := ks.symbols[symbol]
+	if b == nil {
+		return false
+	}
+	day, ok := dayNumber(date)
+	return ok && b.has(day)
+}
+
+// 位图占用的内存 (不含 map 本身)
+func (ks *historyKeySet) size() int {
+	n := 0
+	for symbol, b := range ks.symbols {
+		n += len(symbol) + len(b.words)*8
+	}
+	return n
+}
+
+// 按 stock_history 当前内容建立集合并打印规模
+func mustLoadHistoryKeys(db *sql.DB) *historyKeySet {
+	start := time.Now()
+	ks, err := loadHistoryKeys(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf(">>> 已载入 %d 个代码的 %d 个 (代码, 日期)，位图 %s，耗时 %s",
+		len(ks.symbols), ks.rows, formatBytes(float64(ks.size())), time.Since(start).Round(time.Millisecond))
+	return ks
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 生成机器学习用的远期收益标签，写入 labels 表。
+//
+// 持有期按“市场交易日”计数，而不是按个股自己的行数：
+//   - 目标日个股停牌：使用目标日当天或之前最后一个收盘价 (停牌期间价格冻结，这才是真实持仓市值)
+//   - 目标日之后个股再无任何数据 (退市或至今停牌)：标签为 NULL，避免用未知的退市价格制造偏差
+//   - 目标日超出日历末尾：标签为 NULL
+func runLabels(args []string) {
+	fs := flag.NewFlagSet("labels", flag.ExitOnError)
+	horizonsArg := fs.String("horizons", "1,5,10,20", "持有期列表 (交易日)，逗号分隔")
+	fs.Parse(args)
+
+	horizons, err := parseIntList(*horizonsArg)
+	if err != nil {
+		log.Fatalf("[ERROR] horizons 格式错误: %v", err)
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+
+	mustExec(db, `CREATE TABLE IF NOT EXISTS labels (
+		symbol     TEXT NOT NULL,
+		date       TEXT NOT NULL,
+		horizon    INTEGER NOT NULL,
+		ret        REAL,
+		exit_date  TEXT,
+		PRIMARY KEY (symbol, date, horizon)
+	) WITHOUT ROWID, STRICT;`)
+
+	// 临时日历表只在创建它的连接上可见，整个过程必须跑在同一个事务 (同一连接) 上，不能经过连接池
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	// 各市场日历 (带序号)，供所有持有期共用；港股、美股按各自交易日计算持有期
+	mustExec(tx, "DROP TABLE IF EXISTS temp.label_calendar;")
+	mustExec(tx, `CREATE TEMP TABLE label_calendar AS
+		SELECT market, date, ROW_NUMBER() OVER (PARTITION BY market ORDER BY date) AS n
+		FROM (SELECT DISTINCT market, date FROM stock_history);`)
+	mustExec(tx, "CREATE UNIQUE INDEX temp.idx_label_cal_n ON label_calendar(market, n);")
+	mustExec(tx, "CREATE UNIQUE INDEX temp.idx_label_cal_date ON label_calendar(market, date);")
+
+	for _, h := range horizons {
+		log.Printf(">>> 正在生成 %d 日远期收益标签...", h)
+		query := fmt.Sprintf(`
+		INSERT OR REPLACE INTO labels
+		SELECT symbol, date, %[1]d,
+			CASE WHEN exit_close IS NOT NULL AND listed_after THEN exit_close / close_adj - 1 END,
+			CASE WHEN exit_close IS NOT NULL AND listed_after THEN exit_date END
+		FROM (
+			SELECT h.symbol, h.date, h.close_adj,
+				(SELECT x.close_adj FROM stock_history x
+					WHERE x.symbol = h.symbol AND x.date <= t.date ORDER BY x.date DESC LIMIT 1) AS exit_close,
+				(SELECT x.date FROM stock_history x
+					WHERE x.symbol = h.symbol AND x.date <= t.date ORDER BY x.date DESC LIMIT 1) AS exit_date,
+				EXISTS (SELECT 1 FROM stock_history x
+					WHERE x.symbol = h.symbol AND x.date >= t.date) AS listed_after
+			FROM stock_history h
+			INNER JOIN label_calendar c ON c.market = h.market AND c.date = h.date
+			LEFT JOIN label_calendar t ON t.market = c.market AND t.n = c.n + %[1]d
+		);`, h)
+		// 注意：LEFT JOIN 未命中时 t.date 为 NULL，子查询结果为 NULL，标签自然为空
+		mustExec(tx, fmt.Sprintf("DELETE FROM labels WHERE horizon = %d;", h))
+		mustExec(tx, query)
+	}
+	mustExec(tx, "DROP TABLE temp.label_calendar;")
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+
+	var total, nulls int
+	db.QueryRow("SELECT COUNT(*), COUNT(*) - COUNT(ret) FROM labels").Scan(&total, &nulls)
+	log.Printf(">>> ✅ 标签生成完成: %d 行 (其中 NULL %d 行), 耗时: %s", total, nulls, time.Since(start))
+}
+
+// 解析 "1,5,10" 形式的正整数列表
+func parseIntList(s string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("必须为正数: %d", n)
+		}
+		out = append(out, n)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("列表为空")
+	}
+	return out, nil
+}
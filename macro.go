@@ -0,0 +1,109 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 宏观经济序列 (CPI、PMI、SHIBOR、M2 ...)
+// ---------------------------------------------------------
+// 所有序列共用一张长表 macro_series(series_id, date, value)，date 为统计期末
+// (月度 2024-01 记为 2024-01-31)。序列的频率、单位、发布滞后放在 macro_meta 中：
+// 常用序列内置在 macroProfiles，其他序列按相邻观测的平均间隔推断频率，发布滞后记为 0。
+//
+// 与日频数据对齐时必须按发布日而不是统计期：1 月 CPI 2 月中旬才公布。
+// 视图 v_macro_daily 给出每个交易日各序列当时已发布的最新值：
+//
+//	SELECT h.symbol, h.date, h.close_adj, m.value AS cpi
+//	FROM stock_history h JOIN v_macro_daily m ON m.date = h.date AND m.series_id = 'CPI_YOY'
+
+type macroProfile struct {
+	SeriesID   string
+	Name       string
+	Freq       string // D / W / M / Q
+	Unit       string
+	ReleaseLag int // 统计期末到发布日的自然日数 (保守估计)
+}
+
+var macroProfiles = []macroProfile{
+	{"CPI_YOY", "CPI 当月同比", "M", "%", 15},
+	{"PPI_YOY", "PPI 当月同比", "M", "%", 15},
+	{"PMI", "制造业 PMI", "M", "指数", 1},
+	{"M2_YOY", "M2 同比", "M", "%", 15},
+	{"M1_YOY", "M1 同比", "M", "%", 15},
+	{"TSF", "社会融资规模增量", "M", "亿元", 15},
+	{"GDP_YOY", "GDP 当季同比", "Q", "%", 20},
+	{"LPR_1Y", "1 年期 LPR", "M", "%", 0},
+	{"SHIBOR_ON", "SHIBOR 隔夜", "D", "%", 0},
+	{"SHIBOR_1W", "SHIBOR 1 周", "D", "%", 0},
+	{"SHIBOR_3M", "SHIBOR 3 个月", "D", "%", 0},
+}
+
+// 索引：0:序列代码, 1:日期 (YYYY-MM 视为月末), 2:数值
+func init() {
+	registerDataset(dataset{
+		Name:    "宏观序列",
+		Pattern: PathMacro,
+		Table:   "macro_series",
+		DDL: `CREATE TABLE IF NOT EXISTS macro_series (
+			series_id  TEXT NOT NULL,
+			date       TEXT NOT NULL,   -- 统计期末
+			value      REAL,
+			PRIMARY KEY (series_id, date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 3,
+		Mapper: func(record []string) []any {
+			id := normText(strings.ToUpper(record[0]))
+			date := normPeriodEnd(record[1])
+			if id == nil || date == nil {
+				return nil
+			}
+			return []any{id, date, normNum(record[2])}
+		},
+	})
+}
+
+// 统计期末日期：月度写法 2024-01 / 202401 / 2024/01 转为当月最后一天，其余同 normDate
+func normPeriodEnd(s string) any {
+	s = strings.TrimSpace(s)
+	if len(s) == 6 && isDigits(s) {
+		s = s[:4] + "-" + s[4:]
+	}
+	if len(s) == 7 && (s[4] == '-' || s[4] == '/') {
+		if t, err := time.Parse("2006-01", s[:4]+"-"+s[5:]); err == nil {
+			return t.AddDate(0, 1, -1).Format(time.DateOnly)
+		}
+		return nil
+	}
+	return normDate(s)
+}
+
+// 写入序列元数据：内置序列用 macroProfiles，库中其余序列按平均观测间隔推断频率
+func ensureMacroMeta(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS macro_meta (
+		series_id    TEXT NOT NULL PRIMARY KEY,
+		name         TEXT,
+		freq         TEXT NOT NULL,      -- D / W / M / Q
+		unit         TEXT,
+		release_lag  INTEGER NOT NULL    -- 统计期末到发布日的自然日数
+	) WITHOUT ROWID, STRICT;`)
+	for _, p := range macroProfiles {
+		if _, err := db.Exec("INSERT OR REPLACE INTO macro_meta VALUES (?, ?, ?, ?, ?)",
+			p.SeriesID, p.Name, p.Freq, p.Unit, p.ReleaseLag); err != nil {
+			log.Fatal(err)
+		}
+	}
+	mustExec(db, `INSERT OR IGNORE INTO macro_meta (series_id, freq, release_lag)
+	SELECT series_id,
+		CASE
+			WHEN COUNT(*) < 2 THEN 'M'
+			WHEN (julianday(MAX(date)) - julianday(MIN(date))) / (COUNT(*) - 1) < 4 THEN 'D'
+			WHEN (julianday(MAX(date)) - julianday(MIN(date))) / (COUNT(*) - 1) < 15 THEN 'W'
+			WHEN (julianday(MAX(date)) - julianday(MIN(date))) / (COUNT(*) - 1) < 60 THEN 'M'
+			ELSE 'Q' END,
+		0
+	FROM macro_series GROUP BY series_id;`)
+}
@@ -1,34 +1,177 @@
 package main
 
 import (
-	"bufio"
 	"database/sql"
-	"encoding/csv"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
-
-	_ "modernc.org/sqlite"
 )
 
 const (
 	DBPath = "stock_data.db"
 	// 请确保路径没有多余空格
-	PathTechFactors  = "C:\\baidunetdiskdownload\\技术因子_复权数据\\*.csv"
-	PathDailyMetrics = "C:\\baidunetdiskdownload\\每日指标\\*.csv"
+	PathTechFactors      = "C:\\baidunetdiskdownload\\技术因子_复权数据\\*.csv"
+	PathDailyMetrics     = "C:\\baidunetdiskdownload\\每日指标\\*.csv"
+	PathIndexDaily       = "C:\\baidunetdiskdownload\\指数日线\\*.csv"
+	PathIndexMembers     = "C:\\baidunetdiskdownload\\指数成分\\*.csv"
+	PathMinute1          = "C:\\baidunetdiskdownload\\分钟线\\1分钟\\*.csv"
+	PathMinute5          = "C:\\baidunetdiskdownload\\分钟线\\5分钟\\*.csv"
+	PathTicks            = "C:\\baidunetdiskdownload\\逐笔成交\\*.csv"
+	PathFuturesDaily     = "C:\\baidunetdiskdownload\\期货日线\\*.csv"
+	PathOptionContracts  = "C:\\baidunetdiskdownload\\期权合约\\*.csv"
+	PathOptionDaily      = "C:\\baidunetdiskdownload\\期权日线\\*.csv"
+	PathFundNAV          = "C:\\baidunetdiskdownload\\基金净值\\*.csv"
+	PathConvertibleBonds = "C:\\baidunetdiskdownload\\可转债日线\\*.csv"
+	PathFXRates          = "C:\\baidunetdiskdownload\\汇率\\*.csv"
+	PathHKDaily          = "C:\\baidunetdiskdownload\\港股日线\\*.csv"
+	PathUSDaily          = "C:\\baidunetdiskdownload\\美股日线\\*.csv"
+	PathNorthbound       = "C:\\baidunetdiskdownload\\北向资金\\*.csv"
+	PathSouthbound       = "C:\\baidunetdiskdownload\\南向资金\\*.csv"
+	PathMargin           = "C:\\baidunetdiskdownload\\融资融券\\*.csv"
+	PathTopList          = "C:\\baidunetdiskdownload\\龙虎榜\\*.csv"
+	PathBlockTrades      = "C:\\baidunetdiskdownload\\大宗交易\\*.csv"
+	PathHolderStats      = "C:\\baidunetdiskdownload\\股东户数\\*.csv"
+	PathAnnouncements    = "C:\\baidunetdiskdownload\\披露日历\\*.csv"
+	PathForecasts        = "C:\\baidunetdiskdownload\\盈利预测\\*.csv"
+	PathIndustry         = "C:\\baidunetdiskdownload\\行业分类\\*.csv"
+	PathConceptTags      = "C:\\baidunetdiskdownload\\概念板块\\*.csv"
+	PathMacro            = "C:\\baidunetdiskdownload\\宏观数据\\*.csv"
+	PathNews             = "C:\\baidunetdiskdownload\\公告新闻\\*.csv"
+	PathIPO              = "C:\\baidunetdiskdownload\\新股发行\\*.csv"
+	PathShareStructure   = "C:\\baidunetdiskdownload\\股本变动\\*.csv"
 )
 
+// 子命令表：不带参数（或 import）时执行完整导入流程
+var commands = map[string]func(args []string){
+	"import":      runImport,
+	"neutralize":  runNeutralize,
+	"ic":          runIC,
+	"quantile":    runQuantile,
+	"labels":      runLabels,
+	"factors":     runFactors,
+	"export":      runExport,
+	"query":       runQuery,
+	"views":       runViews,
+	"corr":        runCorr,
+	"risk":        runRisk,
+	"pairs":       runPairs,
+	"seasonality": runSeasonality,
+	"members":     runMembers,
+	"futures":     runFutures,
+	"pit":         runPIT,
+	"crypto":      runCrypto,
+	"tags":        runTags,
+	"news":        runNews,
+	"tushare":     runTushare,
+	"eastmoney":   runEastmoney,
+	"yahoo":       runYahoo,
+	"quotes":      runQuotes,
+	"baostock":    runBaostock,
+	"vendor":      runVendor,
+	"tdx":         runTdx,
+	"metastock":   runMetastock,
+	"update":      runUpdate,
+	"backfill":    runBackfill,
+	"fetch":       runFetch,
+	"mock":        runMock,
+	"replay":      runReplay,
+	"record":      runRecord,
+	"reconcile":   runReconcile,
+	"source":      runSource,
+	"infer":       runInfer,
+	"manifest":    runManifest,
+	"download":    runDownload,
+	"bench":       runBench,
+	"vacuum":      runVacuum,
+	"summary":     runSummary,
+	"report":      runReport,
+	"coverage":    runCoverage,
+	"verify":      runVerify,
+}
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	// 子命令之前可以有全局参数 (性能剖析见 profile.go，临时目录见 tempdir.go)
+	flag.Parse()
+	setupTempDir()
+	stop := startProfiling()
+	defer stop()
+
+	args := flag.Args()
+	if len(args) < 1 {
+		runImport(nil)
+		return
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		log.Fatalf("[ERROR] 未知子命令: %s", args[0])
+	}
+	cmd(args[1:])
+}
+
+// 全量导入：删除旧库，从 CSV 重建 stock_history
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	tickBucket := fs.Duration("tick-bucket", 0, "逐笔成交降采样周期 (如 3s、1m)，0 表示保留原始逐笔")
+	financials := fs.String("financials", DefaultFinancialsConfig, "财务报表字段映射配置")
+	sentiment := fs.String("sentiment", "", "导入后用该打分器为公告新闻打分 (如 keywords)，为空则不打分")
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件 (profiles 节登记自定义表头布局)")
+	streaming := fs.Bool("streaming", false, "在内存中关联技术因子与每日指标后直接写入 stock_history，不经过 staging 表 (见 stream.go)")
+	merge := fs.String("merge", "sql", "staging 合并方式: sql (SQLite 关联) 或 sort (外部排序归并，带进度，见 sortmerge.go)")
+	maxMemory := fs.String("max-memory", "", "内存预算 (如 4GB)，覆盖 sources.yaml 的 import.max_memory (见 memory.go)")
+	skip := fs.String("skip", "", "跳过的收尾步骤 (逗号分隔: vacuum, drop-staging, staging-index, report)，覆盖 sources.yaml 的 import.skip")
+	resume := fs.Bool("resume", false, "上次导入在写入 staging 表时中断：保留原库从断点继续 (见 checkpoint.go)")
+	strict := fs.Bool("strict", false, "任何数据问题 (解析失败、被拒绝的行、重复冲突) 都终止，覆盖 sources.yaml 的 import.strict (见 strict.go)")
+	fs.Parse(args)
+	checkMergeMethod(*merge)
+
+	profiles, err := loadHeaderProfiles(*config)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if err := setupImportOptions(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if err := setMaxMemory(*maxMemory); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if *skip != "" {
+		if err := setSkipSteps(splitList(*skip)); err != nil {
+			log.Fatalf("[ERROR] --skip: %v", err)
+		}
+	}
+	if *strict {
+		setStrict()
+	}
+
+	var scorer SentimentScorer
+	if *sentiment != "" {
+		var ok bool
+		if scorer, ok = sentimentScorers[*sentiment]; !ok {
+			log.Fatalf("[ERROR] 未知打分器: %s", *sentiment)
+		}
+	}
+
 	startTotal := time.Now()
 	log.Println(">>> 启动全自动量化数据清洗程序 (v2.1 - 智能分隔符版)...")
 
-	os.Remove(DBPath)
-	db, err := sql.Open("sqlite", DBPath)
+	fresh := true
+	if *resume && resumableImport() {
+		log.Println(">>> 保留上次中断的数据库，从断点继续导入 staging 表")
+		fresh = false
+	} else {
+		if *resume {
+			log.Println("[WARN] 没有可续传的导入记录，从头导入")
+		}
+		os.Remove(DBPath)
+	}
+	registerUDFs() // 分钟线聚合日线需要 local_time
+	db, err := sql.Open(sqliteDriver, DBPath)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -38,106 +181,117 @@ func main() {
 	mustExec(db, "PRAGMA journal_mode = WAL;")
 	mustExec(db, "PRAGMA synchronous = OFF;")
 	mustExec(db, "PRAGMA temp_store = MEMORY;")
+	applyTempDir(db)
+	applyMemoryBudget(db)
+	if fresh {
+		applyAutoVacuum(db)
+	}
 
 	createTables(db)
-
-	// ---------------------------------------------------------
-	// 1. 导入技术因子 (提取复权价)
-	// ---------------------------------------------------------
-	// 索引：0:代码, 1:日期, 2:收盘(原), 12:开(后), 14:收(后), 16:高(后), 18:低(后)
-	importCSV(db, PathTechFactors, "staging_tech", 19, func(record []string) []any {
-		if len(record) < 19 {
-			return nil
-		}
-		return []any{
-			record[0],  // symbol
-			record[1],  // date
-			record[2],  // close_raw
-			record[14], // close_adj
-			record[12], // open_adj
-			record[16], // high_adj
-			record[18], // low_adj
-		}
+	ensureMarketTables(db)
+
+	techMapper := profileMapper(profiles, "staging_tech", mapTechFactors, 19)
+	dailyMapper := profileMapper(profiles, "staging_daily", mapDailyMetrics, 15)
+	// 两张 staging 表都导入、合并完成前保留各文件的完成状态，中断后可用 --resume 继续
+	keepCheckpoints = !*streaming
+	runStage("日线 (技术因子 + 每日指标)", func() {
+		loadStockHistory(db, PathTechFactors, PathDailyMetrics, techMapper, dailyMapper, *merge, *streaming)
 	})
+	if keepCheckpoints {
+		keepCheckpoints = false
+		clearStagingCheckpoints(db)
+	}
 
-	// ---------------------------------------------------------
-	// 2. 导入每日指标 (提取 PE)
-	// ---------------------------------------------------------
-	// 索引：0:代码, 1:日期, 14:市盈率
-	// 注意：如果导入仍为0，程序会打印第一行的解析情况帮助调试
-	importCSV(db, PathDailyMetrics, "staging_daily", 15, func(record []string) []any {
-		if len(record) < 15 {
-			return nil
+	// 附加数据集 (指数日线等)
+	runStage("附加数据集", func() {
+		importDatasets(db)
+		ensureMacroMeta(db)
+	})
+	if scorer != nil {
+		n, err := scoreNews(db, scorer, false)
+		if err != nil {
+			log.Fatal(err)
 		}
-		return []any{
-			record[0],  // symbol
-			record[1],  // date
-			record[14], // pe
+		log.Printf(">>> 已用 %s 为 %d 条公告新闻打分", scorer.Name(), n)
+	}
+	runStage("逐笔成交", func() { importTicks(db, *tickBucket) })
+	runStage("财务报表", func() { importFinancials(db, *financials) })
+	runStage("分钟线聚合日线", func() { aggregateMinuteToDaily(db) })
+	runStage("期货连续合约", func() {
+		for _, r := range defaultRollRules {
+			buildFuturesContinuous(db, "", r)
 		}
 	})
 
-	// ---------------------------------------------------------
-	// 3. 建立索引 & 合并数据
-	// ---------------------------------------------------------
-	log.Println(">>> 正在优化临时索引...")
-	mustExec(db, "CREATE INDEX idx_st_tech_sd ON staging_tech(symbol, date);")
-	mustExec(db, "CREATE INDEX idx_st_daily_sd ON staging_daily(symbol, date);")
-
-	log.Println(">>> 正在执行最终合并与数据清洗...")
-	eltQuery := `
-	INSERT INTO stock_history 
-	SELECT 
-		t.symbol,
-		-- 日期格式化: 19910404 -> 1991-04-04
-		substr(t.date, 1, 4) || '-' || substr(t.date, 5, 2) || '-' || substr(t.date, 7, 2),
-		
-		CAST(t.close_raw AS REAL),
-		CAST(t.close_adj AS REAL),
-		CAST(t.open_adj AS REAL),
-		CAST(t.high_adj AS REAL),
-		CAST(t.low_adj AS REAL),
-
-		-- 清洗 PE: 去除空格，空字符串转 NULL
-		CAST(NULLIF(trim(d.pe), '') AS REAL)
-
-	FROM staging_tech t
-	INNER JOIN staging_daily d 
-		ON t.symbol = d.symbol 
-		AND t.date = d.date;
-	`
-	mustExec(db, "BEGIN TRANSACTION;")
-	mustExec(db, eltQuery)
-	mustExec(db, "COMMIT;")
-
 	// ---------------------------------------------------------
 	// 4. 收尾
 	// ---------------------------------------------------------
-	log.Println(">>> 正在清理临时空间...")
-	mustExec(db, "DROP TABLE staging_tech;")
-	mustExec(db, "DROP TABLE staging_daily;")
-	mustExec(db, "VACUUM;")
+	if !skipStep("drop-staging") {
+		log.Println(">>> 正在清理临时空间...")
+		mustExec(db, "DROP TABLE staging_tech;")
+		mustExec(db, "DROP TABLE staging_daily;")
+	}
+	createViews(db)
+	createPITView(db)
+	runStage("统计与校验", func() { historyChanged(db, nil) })
+	if !skipStep("vacuum") {
+		runStage("VACUUM", func() { mustExec(db, "VACUUM;") })
+	}
 
 	log.Printf(">>> ✅ 任务全部完成! 耗时: %s", time.Since(startTotal))
 
 	// 最终自检
 	checkCount(db)
+	writeRunReport(db)
 }
 
 // ---------------------------------------------------------
 // 辅助函数
 // ---------------------------------------------------------
 
+// 技术因子文件 -> staging_tech (全量导入与 update 共用)。数值列在这里经 normNumBytes 清洗，
+// "abc"、"--" 等写为 NULL，合并时的 CAST 不会把它们变成 0
+// 索引：0:代码, 1:日期, 2:收盘(原), 12:开(后), 14:收(后), 16:高(后), 18:低(后)
+func mapTechFactors(a *rowArena, f [][]byte) []any {
+	if len(f) < 19 {
+		return nil
+	}
+	row := a.row(8)
+	row[0] = a.intern(f[0])      // symbol
+	row[1] = a.intern(f[1])      // date
+	row[2] = normNumBytes(f[2])  // close_raw
+	row[3] = normNumBytes(f[14]) // close_adj
+	row[4] = normNumBytes(f[12]) // open_adj
+	row[5] = normNumBytes(f[16]) // high_adj
+	row[6] = normNumBytes(f[18]) // low_adj
+	// row[7] volume: 技术因子文件不含成交量
+	return row
+}
+
+// 每日指标文件 -> staging_daily
+// 索引：0:代码, 1:日期, 14:市盈率
+func mapDailyMetrics(a *rowArena, f [][]byte) []any {
+	if len(f) < 15 {
+		return nil
+	}
+	row := a.row(3)
+	row[0] = a.intern(f[0])      // symbol
+	row[1] = a.intern(f[1])      // date
+	row[2] = normNumBytes(f[14]) // pe
+	return row
+}
+
 func createTables(db *sql.DB) {
-	mustExec(db, `CREATE TABLE staging_tech (
+	mustExec(db, `CREATE TABLE IF NOT EXISTS staging_tech (
 		symbol TEXT, date TEXT, close_raw TEXT, 
-		close_adj TEXT, open_adj TEXT, high_adj TEXT, low_adj TEXT
+		close_adj TEXT, open_adj TEXT, high_adj TEXT, low_adj TEXT, volume TEXT
 	);`)
 
-	mustExec(db, `CREATE TABLE staging_daily (
+	mustExec(db, `CREATE TABLE IF NOT EXISTS staging_daily (
 		symbol TEXT, date TEXT, pe TEXT
 	);`)
 
-	mustExec(db, `CREATE TABLE stock_history (
+	mustExec(db, `CREATE TABLE IF NOT EXISTS stock_history (
 		symbol      TEXT NOT NULL,
 		date        TEXT NOT NULL,
 		close       REAL, 
@@ -146,100 +300,360 @@ func createTables(db *sql.DB) {
 		high_adj    REAL, 
 		low_adj     REAL, 
 		pe          REAL, 
+		volume      REAL,               -- 股；网盘日线文件不含成交量，仅分钟线聚合或 API 拉取的日期有值
+		market      TEXT NOT NULL,      -- CN / HK / US，由代码推断 (见 market.go)
 		PRIMARY KEY (symbol, date)
 	) WITHOUT ROWID, STRICT;`)
 }
 
+// staging 行清洗为 stock_history 的各列 (mergeStaging 与流式合并共用)。
+// ref 给出 staging_tech 各列与每日指标 pe 在语句中的写法 (列引用或参数)
+func stagingHistoryColumns(ref func(col string) string) string {
+	return `
+		` + ref("symbol") + `,
+		-- 日期格式化: 19910404 -> 1991-04-04
+		substr(` + ref("date") + `, 1, 4) || '-' || substr(` + ref("date") + `, 5, 2) || '-' || substr(` + ref("date") + `, 7, 2),
+		
+		CAST(` + ref("close_raw") + ` AS REAL),
+		CAST(` + ref("close_adj") + ` AS REAL),
+		CAST(` + ref("open_adj") + ` AS REAL),
+		CAST(` + ref("high_adj") + ` AS REAL),
+		CAST(` + ref("low_adj") + ` AS REAL),
+
+		-- 清洗 PE: 去除空格，空字符串转 NULL
+		CAST(NULLIF(trim(` + ref("pe") + `), '') AS REAL),
+
+		CAST(NULLIF(trim(` + ref("volume") + `), '') AS REAL),
+
+		` + marketSQL(ref("symbol"))
+}
+
+// 导入技术因子与每日指标两组文件并合并进 stock_history (import 与 update files 共用)。
+// merge 为 staging 的合并方式 (见 sortmerge.go)；streaming 时不经过 staging 表 (见 stream.go)
+func loadStockHistory(db *sql.DB, techPattern, dailyPattern string, techMapper, dailyMapper func(header []string) rowMapper, merge string, streaming bool) {
+	if streaming {
+		streamMergeStaging(db, techPattern, dailyPattern, techMapper, dailyMapper)
+		return
+	}
+
+	// ---------------------------------------------------------
+	// 1. 导入技术因子 (提取复权价)
+	// ---------------------------------------------------------
+	importCSVFiles(db, techPattern, "staging_tech", "", 2, techMapper)
+
+	// ---------------------------------------------------------
+	// 2. 导入每日指标 (提取 PE)
+	// ---------------------------------------------------------
+	// 注意：如果导入仍为0，程序会打印第一行的解析情况帮助调试
+	importCSVFiles(db, dailyPattern, "staging_daily", "", 2, dailyMapper)
+
+	// ---------------------------------------------------------
+	// 3. 建立索引 & 合并数据
+	// ---------------------------------------------------------
+	runStage("合并 staging ("+merge+")", func() { mergeStagingBy(db, merge) })
+}
+
+// 把 staging 表合并进 stock_history (日期为 YYYYMMDD)，完成后清空 staging 表。
+// 全量导入和 API 拉取 (tushare.go) 共用；已存在的 (symbol, date) 以新数据为准
+func mergeStaging(db *sql.DB) {
+	if !skipStep("staging-index") {
+		log.Println(">>> 正在优化临时索引...")
+		mustExec(db, "CREATE INDEX IF NOT EXISTS idx_st_tech_sd ON staging_tech(symbol, date);")
+		mustExec(db, "CREATE INDEX IF NOT EXISTS idx_st_daily_sd ON staging_daily(symbol, date);")
+	}
+
+	stale := stagedSymbols(db)
+	log.Println(">>> 正在执行最终合并与数据清洗...")
+	eltQuery := `
+	INSERT OR REPLACE INTO stock_history 
+	SELECT ` + stagingHistoryColumns(func(col string) string {
+		if col == "pe" {
+			return "d.pe"
+		}
+		return "t." + col
+	}) + `
+	FROM staging_tech t
+	INNER JOIN staging_daily d 
+		ON t.symbol = d.symbol 
+		AND t.date = d.date;
+	`
+	ensureProvenanceTable(db)
+	mustExec(db, "BEGIN TRANSACTION;")
+	if err := clearMinuteProvenance(db); err != nil {
+		log.Fatal(err)
+	}
+	mustExec(db, eltQuery)
+	mustExec(db, "DELETE FROM staging_tech;")
+	mustExec(db, "DELETE FROM staging_daily;")
+	mustExec(db, "COMMIT;")
+	historyChanged(db, stale)
+}
+
 // 智能 CSV 导入器 (自动识别逗号或Tab)
 func importCSV(db *sql.DB, pattern string, tableName string, minCols int, mapper func([]string) []any) {
+	importCSVUpsert(db, pattern, tableName, "", minCols, mapper)
+}
+
+// 同 importCSV，conflict 为追加在 INSERT 语句后的 ON CONFLICT 子句 (用于导入时聚合)
+func importCSVUpsert(db *sql.DB, pattern string, tableName string, conflict string, minCols int, mapper func([]string) []any) {
+	importCSVFiles(db, pattern, tableName, conflict, minCols, func([]string) rowMapper { return recordMapper(mapper) })
+}
+
+// 通用导入：每个文件读完表头后调用 newMapper 生成该文件的映射函数 (按列名映射时使用)，
+// 返回 nil 表示跳过该文件。按字符串编写的映射用 recordMapper / recordMappers 转换
+func importCSVFiles(db *sql.DB, pattern string, tableName string, conflict string, minCols int, newMapper func(header []string) rowMapper) {
+	importCSVFilesFill(db, pattern, tableName, conflict, minCols, newMapper, nil)
+}
+
+// 同 importCSVFiles，写入前由 fill 按行的位置补全映射结果 (在导入 goroutine 中按文件顺序调用，如逐笔成交的行序号)
+func importCSVFilesFill(db *sql.DB, pattern string, tableName string, conflict string, minCols int, newMapper func(header []string) rowMapper, fill func(row []any, at rowPos)) {
+	ck, err := newImportCheckpoints(db, tableName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	start := time.Now()
+	errsBefore, failed := importErrs.count, 0
+	var check *rowValidator
+	var touched map[string]bool // 直接写入日线时涉及的代码，提交后刷新统计与缓存 (stock_history 的规则在写入后检查)
+	if tableName == "stock_history" {
+		touched = map[string]bool{}
+	} else if hasValidationRules(tableName) {
+		check = newRowValidator(tableName, tableColumns(db, tableName))
+	}
+	var batch *batchInserter
+	if ck != nil {
+		// 分段提交 (见 checkpoint.go)：写完缓冲的行，连同进度一起提交
+		ck.save = func(write func(tx *sql.Tx) error) error {
+			if batch != nil {
+				failed += importErrs.write(tableName, batch.close())
+				batch = nil
+			}
+			if err := write(tx); err != nil {
+				return err
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			tx, err = db.Begin()
+			return err
+		}
+	}
+	rowCount, bad, found := scanCSVFilesFrom(pattern, minCols, newMapper, ck, func(args []any, at rowPos) {
+		if batch == nil {
+			var err error
+			batch, err = newBatchInserter(tx, "INSERT", tableName, nil, conflict, len(args))
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if fill != nil {
+			fill(args, at)
+		}
+		if !check.check(args, at) {
+			return // 校验规则丢弃的行 (见 validate.go)
+		}
+		if symbol, ok := args[0].(string); ok && touched != nil {
+			touched[symbol] = true
+		}
+		failed += importErrs.write(tableName, batch.addAt(args, at)) // 违反约束等按 import.on_error 处理
+	})
+	if batch != nil {
+		failed += importErrs.write(tableName, batch.close())
+	}
+	if !found {
+		tx.Rollback()
+		return
+	}
+	if len(bad) > 0 {
+		tx.Rollback()
+		fmt.Println()
+		log.Fatalf("[ERROR] %s 的表头格式发生变化，已回滚: %s", pattern, strings.Join(bad, "; "))
+	}
+	if err := ck.complete(tx); err != nil {
+		log.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("\n>>> %s 导入完成: %d 行\n", tableName, rowCount-failed)
+	recordSource(tableName, pattern, rowCount-failed, failed, time.Since(start))
+	check.finish(db)
+	if touched != nil {
+		historyChanged(db, mapKeys(touched))
+	}
+	if n := importErrs.count - errsBefore; n > 0 {
+		log.Printf("[WARN] %s: %d 处出错已跳过 (见上面的 [ERROR])", tableName, n)
+	}
+}
+
+// 读取 pattern 匹配的全部文件，按文件顺序把映射好的行连同其位置 (文件与行号) 交给 emit。
+// 解析与读取错误按 import.on_error 处理 (见 importerr.go)。
+// bad 为表头格式变化后一行都没导入的组 (见 drift.go)，调用方应回滚；没有文件时 found 为 false
+func scanCSVFiles(pattern string, minCols int, newMapper func(header []string) rowMapper, emit func(row []any, at rowPos)) (rows int, bad []string, found bool) {
+	return scanCSVFilesFrom(pattern, minCols, newMapper, nil, emit)
+}
+
+// 同 scanCSVFiles，ck 不为 nil 时跳过上次已完成的部分并在每块之后记录进度 (见 checkpoint.go)
+func scanCSVFilesFrom(pattern string, minCols int, newMapper func(header []string) rowMapper, ck *importCheckpoints, emit func(row []any, at rowPos)) (rows int, bad []string, found bool) {
 	files, _ := filepath.Glob(pattern)
 	if len(files) == 0 {
 		log.Printf("[ERROR] 未找到文件: %s", pattern)
-		return
+		return 0, nil, false
 	}
-
-	tx, _ := db.Begin()
-	var stmt *sql.Stmt
+	verifyImportManifest(pattern)
+	groups, groupOf := groupCSVSchemas(files)
+	drift := len(groups) > 1
+	if drift {
+		printSchemaDrift(pattern, groups)
+		checkSchemaGroups(pattern, groups, minCols, newMapper)
+	}
+	files, starts := ck.resume(files)
 
 	rowCount := 0
 	filesCount := 0
 
-	for _, file := range files {
-		f, err := os.Open(file)
-		if err != nil {
-			continue
+	// 解析在后台并行进行 (pipeline.go)，这里按文件顺序消费
+	for _, pf := range parseCSVFilesFrom(files, starts, minCols, newMapper) {
+		fileRows := 0
+		for chunk := range pf.chunks {
+			for _, e := range chunk.errs {
+				importErrs.add(e)
+			}
+			for k, args := range chunk.rows {
+				emit(args, rowPos{pf.file, chunk.lines[k], pf.start, ck.previous(pf.file) + fileRows + k + 1})
+			}
+			fileRows += len(chunk.rows)
+			if err := ck.progress(pf.file, chunk.end, len(chunk.rows)); err != nil {
+				log.Fatal(err)
+			}
 		}
-
-		// --- 智能探测分隔符 ---
-		// 先读取第一行文本，看看哪个分隔符多
-		scanner := bufio.NewScanner(f)
-		var comma rune = ',' // 默认逗号
-		if scanner.Scan() {
-			line := scanner.Text()
-			// 如果包含制表符，且比逗号多，或者是包含制表符且没有逗号
-			if strings.Count(line, "\t") > strings.Count(line, ",") {
-				comma = '\t'
+		// 读取出错的文件不记为完成，下次从最后提交的位置重试
+		if pf.err != nil {
+			importErrs.add(pf.err)
+		} else if !pf.skipped || pf.unknown {
+			ck.finish(pf.file)
+		}
+		if pf.skipped {
+			if pf.unknown {
+				log.Printf("[WARN] 跳过文件 (表头不匹配): %s", pf.file)
 			}
+			continue
 		}
-		f.Seek(0, 0) // 探测完必须回到文件开头
-
-		r := csv.NewReader(f)
-		r.Comma = comma // 设置检测到的分隔符
-		r.LazyQuotes = true
+		// 调试日志：如果总是跳过，打印第一条失败的原因
+		if pf.debug != nil && rowCount == 0 && filesCount == 0 {
+			log.Printf("[DEBUG] 首行解析失败! 检测分隔符: '%c', 解析后列数: %d (需要: %d), 内容: %v",
+				pf.comma, len(pf.debug), minCols, pf.debug)
+		}
+		if g := groupOf[pf.file]; g != nil {
+			g.Records += pf.records
+			g.Short += pf.short
+			g.Rows += fileRows
+		}
+		rowCount += fileRows
+		fmt.Printf(".")
+		filesCount++
+	}
+	if drift {
+		for file, g := range groupOf {
+			g.Rows += ck.previous(file)
+		}
+		bad = emptySchemaGroups(groups)
+	}
+	return rowCount, bad, true
+}
 
-		// 跳过 Header
-		_, err = r.Read()
-		if err != nil {
-			f.Close()
-			continue
+// 按表头行中出现次数最多的分隔符判断 (逗号、制表符、分号、竖线)，默认逗号。
+// 引号字段内的字符不计 ("成交额(元,万)" 这样的列名不会让分号分隔的文件被认成逗号)
+func sniffDelimiter(line string) rune {
+	counts := map[byte]int{}
+	scanCSVQuotes(line, func(c byte) {
+		if isDelimiterCandidate(c) {
+			counts[c]++
+		}
+	})
+	comma := byte(',')
+	for _, c := range []byte{'\t', ';', '|'} {
+		if counts[c] > counts[comma] {
+			comma = c
 		}
+	}
+	return rune(comma)
+}
 
-		for {
-			record, err := r.Read()
-			if err == io.EOF {
-				break
-			}
+func isDelimiterCandidate(c byte) bool {
+	return c == ',' || c == '\t' || c == ';' || c == '|'
+}
 
-			// 调试日志：如果总是跳过，打印第一条失败的原因
-			if len(record) < minCols {
-				if rowCount == 0 && filesCount == 0 {
-					log.Printf("[DEBUG] 首行解析失败! 检测分隔符: '%c', 解析后列数: %d (需要: %d), 内容: %v",
-						comma, len(record), minCols, record)
+// 逐字节扫描 s，对引号字段以外的字节调用 visit，返回扫描结束时是否仍在引号字段内。
+// 分隔符未知，任一候选分隔符之后 (或行首) 的引号开启引号字段，规则同 openQuote (fastcsv.go)
+func scanCSVQuotes(s string, visit func(c byte)) bool {
+	inQuote, atStart := false, true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inQuote {
+			if c == '"' {
+				if i+1 < len(s) && s[i+1] == '"' {
+					i++
+					continue
 				}
-				continue
-			}
-
-			args := mapper(record)
-			if args == nil {
-				continue
-			}
-
-			if stmt == nil {
-				placeholders := strings.Repeat("?,", len(args))
-				placeholders = placeholders[:len(placeholders)-1]
-				query := fmt.Sprintf("INSERT INTO %s VALUES (%s)", tableName, placeholders)
-				stmt, err = tx.Prepare(query)
-				if err != nil {
-					log.Fatal(err)
+				if i+1 == len(s) || isDelimiterCandidate(s[i+1]) || s[i+1] == '\r' || s[i+1] == '\n' {
+					inQuote = false
 				}
 			}
-
-			stmt.Exec(args...)
-			rowCount++
+			continue
 		}
-		f.Close()
-		fmt.Printf(".")
-		filesCount++
+		if c == '"' && atStart {
+			inQuote, atStart = true, false
+			continue
+		}
+		visit(c)
+		atStart = isDelimiterCandidate(c) || c == '\n'
+	}
+	return inQuote
+}
+
+// 打开已构建的数据库 (供分析类子命令使用，不会删除已有数据)
+func openDB() *sql.DB {
+	if _, err := os.Stat(DBPath); err != nil {
+		log.Fatalf("[ERROR] 数据库不存在: %s (请先执行导入)", DBPath)
+	}
+	registerUDFs()
+	db, err := sql.Open(sqliteDriver, DBPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mustExec(db, "PRAGMA journal_mode = WAL;")
+	mustExec(db, "PRAGMA temp_store = MEMORY;")
+	applyTempDir(db)
+	return db
+}
+
+// 打开数据库，不存在时新建 (供 API 拉取类子命令从零建库)
+func openOrCreateDB() *sql.DB {
+	_, err := os.Stat(DBPath)
+	fresh := os.IsNotExist(err)
+	registerUDFs()
+	db, err := sql.Open(sqliteDriver, DBPath)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if stmt != nil {
-		stmt.Close()
+	mustExec(db, "PRAGMA journal_mode = WAL;")
+	mustExec(db, "PRAGMA temp_store = MEMORY;")
+	applyTempDir(db)
+	if fresh {
+		applyAutoVacuum(db)
 	}
-	tx.Commit()
-	fmt.Printf("\n>>> %s 导入完成: %d 行\n", tableName, rowCount)
+	createTables(db)
+	ensureMarketTables(db)
+	return db
 }
 
-func mustExec(db *sql.DB, query string) {
-	if _, err := db.Exec(query); err != nil {
+func mustExec(db execer, query string, args ...any) {
+	if _, err := db.Exec(query, args...); err != nil {
 		log.Fatalf("SQL Error: %v | Query: %s", err, query)
 	}
 }
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// 文件清单与校验
+// ---------------------------------------------------------
+// 目录中有清单文件时 (供应商提供，或 chronos manifest generate 生成)，导入该目录前先核对：
+// 清单中的文件必须都存在且校验和一致，否则列出缺失与损坏的文件并退出，不再等合并后才发现行数不够。
+// 清单格式与 sha256sum / md5sum 的输出相同 (按校验和长度区分算法)：
+//
+//	9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08  600000.SH.csv
+//
+//	chronos manifest generate --path "D:\data\技术因子_复权数据\*.csv"
+//	chronos manifest verify --path "D:\data\技术因子_复权数据\*.csv"
+//
+// 不在清单中的文件只警告。
+
+// 按顺序查找的清单文件名
+var manifestNames = []string{"MANIFEST.sha256", "MANIFEST.md5", "SHA256SUMS", "MD5SUMS"}
+
+type manifestEntry struct {
+	File string
+	Sum  string
+}
+
+func findManifest(dir string) string {
+	for _, name := range manifestNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func readManifest(path string) ([]manifestEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []manifestEntry
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, file, ok := strings.Cut(line, " ")
+		file = strings.TrimPrefix(strings.TrimSpace(file), "*") // sha256sum -b 的二进制标记
+		if !ok || file == "" || (len(sum) != 32 && len(sum) != 64) {
+			return nil, fmt.Errorf("%s 第 %d 行格式错误: %s", path, n, line)
+		}
+		entries = append(entries, manifestEntry{File: file, Sum: strings.ToLower(sum)})
+	}
+	return entries, scanner.Err()
+}
+
+// 按 sum 的长度选择算法 (32 位 md5，64 位 sha256)
+func fileChecksum(path string, sumLen int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var h hash.Hash = sha256.New()
+	if sumLen == 32 {
+		h = md5.New()
+	}
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 核对清单，返回缺失、损坏的文件，以及目录中匹配 pattern 但不在清单中的文件
+func verifyManifest(manifest, pattern string) (missing, corrupt, extra []string, err error) {
+	entries, err := readManifest(manifest)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	dir := filepath.Dir(manifest)
+	listed := map[string]bool{}
+	for _, e := range entries {
+		path := filepath.Join(dir, filepath.FromSlash(e.File))
+		listed[filepath.Clean(path)] = true
+		sum, err := fileChecksum(path, len(e.Sum))
+		switch {
+		case os.IsNotExist(err):
+			missing = append(missing, e.File)
+		case err != nil:
+			return nil, nil, nil, err
+		case sum != e.Sum:
+			corrupt = append(corrupt, e.File)
+		}
+	}
+	files, _ := filepath.Glob(pattern)
+	for _, f := range files {
+		if !listed[filepath.Clean(f)] {
+			extra = append(extra, filepath.Base(f))
+		}
+	}
+	return missing, corrupt, extra, nil
+}
+
+// 已核对过的清单 (同一次运行中同一目录只核对一次)
+var verifiedManifests = map[string]bool{}
+
+// 导入前核对 pattern 所在目录的清单；没有清单时直接返回，有缺失或损坏时退出
+func verifyImportManifest(pattern string) {
+	manifest := findManifest(filepath.Dir(pattern))
+	if manifest == "" || verifiedManifests[manifest] {
+		return
+	}
+	verifiedManifests[manifest] = true
+	missing, corrupt, extra, err := verifyManifest(manifest, pattern)
+	if err != nil {
+		log.Fatalf("[ERROR] 核对清单失败: %v", err)
+	}
+	if len(extra) > 0 {
+		log.Printf("[WARN] %d 个文件不在清单 %s 中: %s", len(extra), manifest, listSample(extra, 10))
+	}
+	if len(missing) > 0 || len(corrupt) > 0 {
+		log.Fatalf("[ERROR] 清单 %s 核对失败: 缺失 %d 个文件 %s; 校验和不一致 %d 个文件 %s",
+			manifest, len(missing), listSample(missing, 20), len(corrupt), listSample(corrupt, 20))
+	}
+	log.Printf(">>> 清单核对通过: %s", manifest)
+}
+
+// 最多列出 n 项
+func listSample(items []string, n int) string {
+	if len(items) <= n {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s 等", strings.Join(items[:n], ", "))
+}
+
+// chronos manifest generate|verify --path <glob>
+func runManifest(args []string) {
+	if len(args) == 0 || (args[0] != "generate" && args[0] != "verify") {
+		log.Fatal("[ERROR] 用法: chronos manifest generate|verify --path <glob>")
+	}
+	fs := flag.NewFlagSet("manifest "+args[0], flag.ExitOnError)
+	path := fs.String("path", "", "数据文件 glob (清单位于其所在目录)")
+	fs.Parse(args[1:])
+	if *path == "" {
+		log.Fatal("[ERROR] 需要指定 --path")
+	}
+
+	if args[0] == "verify" {
+		manifest := findManifest(filepath.Dir(*path))
+		if manifest == "" {
+			log.Fatalf("[ERROR] %s 中没有清单文件 (%s)", filepath.Dir(*path), strings.Join(manifestNames, " / "))
+		}
+		verifyImportManifest(*path)
+		return
+	}
+
+	files, _ := filepath.Glob(*path)
+	if len(files) == 0 {
+		log.Fatalf("[ERROR] 未找到文件: %s", *path)
+	}
+	sort.Strings(files)
+	out := filepath.Join(filepath.Dir(*path), manifestNames[0])
+	var b strings.Builder
+	for _, f := range files {
+		sum, err := fileChecksum(f, 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(&b, "%s  %s\n", sum, filepath.Base(f))
+	}
+	if err := os.WriteFile(out, []byte(b.String()), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf(">>> ✅ 已生成清单 %s (%d 个文件)", out, len(files))
+}
@@ -0,0 +1,42 @@
+package main
+
+// 融资融券明细 (按股票、按日)。与 stock_history 同样以 (symbol, date) 为主键，可直接 JOIN：
+//
+//	SELECT h.date, h.close, m.margin_balance, m.short_volume
+//	FROM stock_history h JOIN margin_trading m USING (symbol, date)
+//
+// 索引：0:代码, 1:日期, 2:融资余额, 3:融资买入额, 4:融资偿还额, 5:融券余量, 6:融券卖出量,
+// 7:融券偿还量, 8:融券余额, 9:融资融券余额 (3 之后可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "融资融券",
+		Pattern: PathMargin,
+		Table:   "margin_trading",
+		DDL: `CREATE TABLE IF NOT EXISTS margin_trading (
+			symbol          TEXT NOT NULL,
+			date            TEXT NOT NULL,
+			margin_balance  REAL,       -- 融资余额 (元)
+			margin_buy      REAL,       -- 融资买入额 (元)
+			margin_repay    REAL,       -- 融资偿还额 (元)
+			short_volume    REAL,       -- 融券余量 (股)
+			short_sell      REAL,       -- 融券卖出量 (股)
+			short_repay     REAL,       -- 融券偿还量 (股)
+			short_balance   REAL,       -- 融券余额 (元)
+			total_balance   REAL,       -- 融资融券余额 (元)
+			PRIMARY KEY (symbol, date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 3,
+		Mapper: func(record []string) []any {
+			date := normDate(record[1])
+			symbol := normText(record[0])
+			if date == nil || symbol == nil {
+				return nil
+			}
+			out := []any{symbol, date}
+			for k := 2; k <= 9; k++ {
+				out = append(out, normNum(col(record, k)))
+			}
+			return out
+		},
+	})
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// ---------------------------------------------------------
+// 多市场支持 (A 股 / 港股 / 美股)
+// ---------------------------------------------------------
+// 所有市场共用 stock_history，代码带市场后缀保证唯一：600000.SH、00700.HK、AAPL.US。
+// market 列在入库时由代码推断，交易日历、收益标签等按市场分别计算，
+// 避免港股、美股的交易日混进 A 股日历 (反之亦然)。
+
+const (
+	MarketCN = "CN"
+	MarketHK = "HK"
+	MarketUS = "US"
+)
+
+type marketInfo struct {
+	Market   string
+	Timezone string // IANA 时区名
+	Currency string
+}
+
+// 市场参考数据，同时写入 markets 表供 SQL 使用
+var marketInfos = []marketInfo{
+	{MarketCN, "Asia/Shanghai", "CNY"},
+	{MarketHK, "Asia/Hong_Kong", "HKD"},
+	{MarketUS, "America/New_York", "USD"},
+}
+
+func ensureMarketTables(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS markets (
+		market    TEXT NOT NULL PRIMARY KEY,
+		timezone  TEXT NOT NULL,    -- IANA 时区名
+		currency  TEXT NOT NULL
+	) WITHOUT ROWID, STRICT;`)
+	for _, m := range marketInfos {
+		if _, err := db.Exec("INSERT OR REPLACE INTO markets VALUES (?, ?, ?)", m.Market, m.Timezone, m.Currency); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// 市场所在时区，未知市场按 A 股处理
+func marketTimezone(market string) string {
+	for _, m := range marketInfos {
+		if m.Market == market {
+			return m.Timezone
+		}
+	}
+	return marketInfos[0].Timezone
+}
+
+// 市场的计价货币，未知市场按 A 股处理
+func marketCurrency(market string) string {
+	for _, m := range marketInfos {
+		if m.Market == market {
+			return m.Currency
+		}
+	}
+	return marketInfos[0].Currency
+}
+
+// 由代码推断所属市场 (与 marketSQL 规则一致)：
+// .HK / 5 位纯数字为港股，.US / 其他含字母的代码为美股，其余 (含 sh600000 这类前缀写法) 为 A 股
+func marketOf(symbol string) string {
+	s := strings.ToUpper(strings.TrimSpace(symbol))
+	switch {
+	case strings.HasSuffix(s, ".HK"):
+		return MarketHK
+	case strings.HasSuffix(s, ".US"):
+		return MarketUS
+	case strings.HasSuffix(s, ".SH"), strings.HasSuffix(s, ".SZ"), strings.HasSuffix(s, ".BJ"), strings.HasSuffix(s, ".SS"):
+		return MarketCN
+	case len(s) == 5 && isDigits(s):
+		return MarketHK
+	case len(s) == 8 && (strings.HasPrefix(s, "SH") || strings.HasPrefix(s, "SZ") || strings.HasPrefix(s, "BJ")) && isDigits(s[2:]):
+		return MarketCN
+	case strings.IndexFunc(s, unicode.IsLetter) >= 0:
+		return MarketUS
+	}
+	return MarketCN
+}
+
+// marketOf 的 SQL 版本，col 为代码列表达式
+func marketSQL(col string) string {
+	return fmt.Sprintf(`CASE
+		WHEN upper(%[1]s) LIKE '%%.HK' THEN 'HK'
+		WHEN upper(%[1]s) LIKE '%%.US' THEN 'US'
+		WHEN upper(%[1]s) LIKE '%%.SH' OR upper(%[1]s) LIKE '%%.SZ'
+			OR upper(%[1]s) LIKE '%%.BJ' OR upper(%[1]s) LIKE '%%.SS' THEN 'CN'
+		WHEN %[1]s GLOB '[0-9][0-9][0-9][0-9][0-9]' THEN 'HK'
+		WHEN upper(%[1]s) GLOB 'S[HZ][0-9][0-9][0-9][0-9][0-9][0-9]'
+			OR upper(%[1]s) GLOB 'BJ[0-9][0-9][0-9][0-9][0-9][0-9]' THEN 'CN'
+		WHEN %[1]s GLOB '*[A-Za-z]*' THEN 'US'
+		ELSE 'CN' END`, col)
+}
+
+// 库中属于给定市场的全部代码 (API 增量拉取时默认的代码范围)
+func marketSymbols(db *sql.DB, markets ...string) []string {
+	var symbols []string
+	for _, m := range markets {
+		rows, err := db.Query("SELECT DISTINCT symbol FROM stock_history WHERE market = ? ORDER BY symbol", m)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for rows.Next() {
+			var s string
+			if err := rows.Scan(&s); err != nil {
+				log.Fatal(err)
+			}
+			symbols = append(symbols, s)
+		}
+		rows.Close()
+	}
+	return symbols
+}
+
+// 一组代码共同的市场，跨市场时返回空串 (使用各市场交易日的并集)
+func symbolsMarket(symbols []string) string {
+	market := ""
+	for k, s := range symbols {
+		m := marketOf(s)
+		if k > 0 && m != market {
+			return ""
+		}
+		market = m
+	}
+	return market
+}
+
+// 港股代码统一为 5 位 + .HK：700、0700.HK、HK0700 -> 00700.HK
+func normHKSymbol(s string) any {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "HK"), ".HK")
+	if !isDigits(s) || len(s) > 5 {
+		return nil
+	}
+	return strings.Repeat("0", 5-len(s)) + s + ".HK"
+}
+
+// 美股代码统一为大写 + .US：aapl、AAPL.O、BRK.B -> AAPL.US、BRK-B.US
+func normUSSymbol(s string) any {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, ".US")
+	for _, suffix := range []string{".O", ".N", ".A", ".OQ"} {
+		s = strings.TrimSuffix(s, suffix)
+	}
+	s = strings.ReplaceAll(s, ".", "-")
+	if s == "" {
+		return nil
+	}
+	return s + ".US"
+}
+
+// 港股、美股日线：供应商通常给出原始 OHLC 和复权收盘价 (Yahoo 风格的 Adj Close，前复权)，
+// 按 复权收盘 / 原始收盘 的比例换算复权开高低。A 股为后复权，两者价格水平不可直接比较，但收益率一致。
+//
+// 索引：0:代码, 1:日期, 2:开盘, 3:最高, 4:最低, 5:收盘, 6:复权收盘 (缺省时视为无复权), 7:成交量
+func init() {
+	for _, src := range []struct {
+		name, pattern string
+		norm          func(string) any
+	}{
+		{"港股日线", PathHKDaily, normHKSymbol},
+		{"美股日线", PathUSDaily, normUSSymbol},
+	} {
+		norm := src.norm
+		registerDataset(dataset{
+			Name:    src.name,
+			Pattern: src.pattern,
+			Table:   "stock_history", // 表已由 createTables 建好
+			MinCols: 6,
+			Mapper: func(record []string) []any {
+				symbol := norm(record[0])
+				date := normDate(record[1])
+				if symbol == nil || date == nil {
+					return nil
+				}
+				return adjustedHistoryRow(symbol.(string), date, normNum(record[2]), normNum(record[3]), normNum(record[4]),
+					normNum(record[5]), normNum(col(record, 6)), normNum(col(record, 7)))
+			},
+		})
+	}
+}
+
+// 由原始 OHLC 与复权收盘价生成 stock_history 的一行 (复权开高低按 复权收盘 / 原始收盘 换算)；
+// 原始收盘价缺失或为 0 时返回 nil，adjClose 为 nil 时视为无复权
+func adjustedHistoryRow(symbol string, date, open, high, low, closeRaw, adjClose, volume any) []any {
+	c, ok := closeRaw.(float64)
+	if !ok || c == 0 {
+		return nil
+	}
+	ratio := 1.0
+	if adj, ok := adjClose.(float64); ok {
+		ratio = adj / c
+	}
+	scale := func(v any) any {
+		if x, ok := v.(float64); ok {
+			return x * ratio
+		}
+		return nil
+	}
+	return []any{
+		symbol,
+		date,
+		c,
+		c * ratio,
+		scale(open),
+		scale(high),
+		scale(low),
+		nil, // pe
+		volume,
+		marketOf(symbol),
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// ---------------------------------------------------------
+// 内存预算
+// ---------------------------------------------------------
+// 默认各处缓冲按固定大小分配 (解析每个文件缓冲 4 块、排序段 100 万行、SQLite 临时表放在内存中)，
+// 在 8GB 的笔记本上可能被耗尽，在 256GB 的服务器上又用不满。--max-memory (或 sources.yaml 的
+// import.max_memory) 给出整个进程的预算后按比例分配：
+//
+//	解析缓冲     1/8   (每个文件缓冲的块数，必要时减少解析 goroutine)
+//	排序段       1/4   (--merge sort 每段的行数，超出后写入 import.temp_dir)
+//	写入缓存     1/16  (--streaming 按列缓存、排序后成块写入的行数，见 columnar.go)
+//	SQLite       1/4   (页缓存与 soft_heap_limit，临时表改为写文件)
+//
+// 并把 Go 的堆上限 (debug.SetMemoryLimit) 设为预算的 3/4，接近上限时更积极地回收。
+// 流式合并 (--streaming) 的 PE 索引无法溢出到磁盘，超过预算的一半时退出并提示改用 --merge sort。
+
+// 按内存中一行 (切片 + 字符串) 估算的字节数
+const estimatedRowBytes = 256
+
+// 覆盖 sources.yaml 中的 import.max_memory (s 为空时不变)
+func setMaxMemory(s string) error {
+	if s == "" {
+		return nil
+	}
+	n, err := parseByteSize(s)
+	if err != nil {
+		return fmt.Errorf("--max-memory: %v", err)
+	}
+	importOptions.maxMemory = n
+	return nil
+}
+
+// 按预算调整缓冲大小与 SQLite 设置；未设置预算时不做任何改动
+func applyMemoryBudget(db *sql.DB) {
+	budget := importOptions.maxMemory
+	if budget <= 0 {
+		return
+	}
+	if budget < 256<<20 {
+		log.Fatalf("[ERROR] 内存预算至少 256MB: %s", formatBytes(float64(budget)))
+	}
+
+	perChunk := int64(importChunkRows * estimatedRowBytes)
+	parse := budget / 8
+	importParseWorkers = int(min(int64(importParseWorkers), max(parse/perChunk, 1)))
+	importFileChunks = int(min(4, max(parse/(perChunk*int64(importParseWorkers)), 1)))
+	sortRunRows = int(max(budget/4/estimatedRowBytes, 10000))
+	columnFlushRows = int(min(max(budget/16/estimatedRowBytes, 10000), 1<<22))
+
+	mustExec(db, fmt.Sprintf("PRAGMA cache_size = -%d;", budget/8>>10))
+	mustExec(db, fmt.Sprintf("PRAGMA soft_heap_limit = %d;", budget/4))
+	mustExec(db, "PRAGMA temp_store = FILE;")
+	debug.SetMemoryLimit(budget / 4 * 3)
+
+	log.Printf(">>> 内存预算 %s: 解析 %d 路 × %d 块, 排序段 %d 行, 写入缓存 %d 行, SQLite 缓存 %s",
+		formatBytes(float64(budget)), importParseWorkers, importFileChunks, sortRunRows, columnFlushRows, formatBytes(float64(budget/8)))
+}
+
+// 流式合并的 PE 索引是否已超过预算的一半 (每项按 estimatedRowBytes / 2 估算)
+func streamIndexOverBudget(entries int) bool {
+	return importOptions.maxMemory > 0 && int64(entries)*estimatedRowBytes/2 > importOptions.maxMemory/2
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// MetaStock / ASCII 历史数据
+// ---------------------------------------------------------
+// 老牌行情软件的两种常见存档，统一导入 stock_history (不复权，已存在的 (symbol, date) 保留原数据)：
+//
+//  1. MetaStock 二进制目录：MASTER 索引 + F1.DAT、F2.DAT...
+//     MASTER 每条 53 字节：0:文件号, 3:记录长度, 4:字段数, 7:名称(16), 33:周期('D'), 36:代码(14)
+//     F#.DAT 首条记录为文件头，之后每条为 字段数 × 4 字节的 MBF (Microsoft Binary Format) 浮点数：
+//     5 字段 = 日期,高,低,收,量；6 字段 = 日期,开,高,低,收,量；7 字段再加持仓量；8 字段在日期后多一个时间。
+//     日期为 YYYMMDD，年份从 1900 起 (1240102 = 2024-01-02)。
+//     只支持 MASTER 索引的前 255 个品种，EMASTER/XMASTER 扩展索引不读取。
+//  2. ASCII 导出 (MetaStock ASCII、Stooq、AmiBroker 等)，按表头识别列：
+//     <TICKER>,<PER>,<DTYYYYMMDD>,<TIME>,<OPEN>,<HIGH>,<LOW>,<CLOSE>,<VOL>
+//     尖括号可有可无，也认 Symbol/Date/Volume 等写法。没有代码列时用 --symbol 指定。
+//
+// 只导入日线，<PER> 不是 D 的分钟数据会被跳过 (分钟线请转成分钟线 CSV 后走 import)。
+// 6 位数字代码按 A 股规则补交易所后缀，其余代码原样大写。
+
+const metastockMasterSize = 53
+
+// MBF 单精度转 IEEE：MBF 指数偏移 128 且尾数为 0.1xxx，IEEE 为 127 与 1.xxx，相差 2
+func mbfToFloat(b []byte) float64 {
+	exp := b[3]
+	if exp <= 2 {
+		return 0
+	}
+	bits := uint32(b[2]&0x80)<<24 | uint32(exp-2)<<23 | uint32(b[2]&0x7f)<<16 | uint32(b[1])<<8 | uint32(b[0])
+	return float64(math.Float32frombits(bits))
+}
+
+func metastockSymbol(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if _, canonical, err := emSecID(s); err == nil {
+		return canonical
+	}
+	return s
+}
+
+type metastockEntry struct {
+	file    string
+	symbol  string
+	fields  int
+	recSize int
+}
+
+func readMetastockMaster(dir string) ([]metastockEntry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "MASTER"))
+	if err != nil {
+		return nil, err
+	}
+	var out []metastockEntry
+	for off := metastockMasterSize; off+metastockMasterSize <= len(data); off += metastockMasterSize {
+		r := data[off : off+metastockMasterSize]
+		if r[33] != 'D' {
+			continue // 只导入日线
+		}
+		out = append(out, metastockEntry{
+			file:    filepath.Join(dir, fmt.Sprintf("F%d.DAT", r[0])),
+			symbol:  metastockSymbol(strings.TrimRight(string(r[36:50]), " \x00")),
+			fields:  int(r[4]),
+			recSize: int(r[3]),
+		})
+	}
+	return out, nil
+}
+
+// 读取 F#.DAT，返回记录：0:代码, 1:日期, 2:开盘, 3:最高, 4:最低, 5:收盘, 6:成交量
+func readMetastockData(e metastockEntry) ([][]string, error) {
+	if e.fields < 5 || e.fields > 8 || e.recSize != e.fields*4 {
+		return nil, fmt.Errorf("%s: 不支持的记录格式 (%d 字段, %d 字节)", e.file, e.fields, e.recSize)
+	}
+	data, err := os.ReadFile(e.file)
+	if err != nil {
+		return nil, err
+	}
+	// 各字段在记录中的位置，-1 表示没有
+	open, high := -1, 1
+	if e.fields >= 6 {
+		open, high = 1, 2
+	}
+	if e.fields == 8 {
+		open, high = 2, 3 // 第 1 个字段是时间
+	}
+	num := func(r []byte, k int) string {
+		if k < 0 {
+			return ""
+		}
+		return strconv.FormatFloat(mbfToFloat(r[k*4:]), 'f', -1, 32)
+	}
+
+	var records [][]string
+	for off := e.recSize; off+e.recSize <= len(data); off += e.recSize {
+		r := data[off : off+e.recSize]
+		date := int(mbfToFloat(r))
+		records = append(records, []string{
+			e.symbol,
+			strconv.Itoa(date + 19000000),
+			num(r, open),
+			num(r, high),
+			num(r, high+1),
+			num(r, high+2),
+			num(r, high+3),
+		})
+	}
+	return records, nil
+}
+
+func mapMetastockBar(record []string) []any {
+	date := normDate(record[1])
+	if record[0] == "" || date == nil {
+		return nil
+	}
+	return adjustedHistoryRow(record[0], date, normNum(record[2]), normNum(record[3]), normNum(record[4]),
+		normNum(record[5]), nil, normNum(record[6]))
+}
+
+// ASCII 导出按表头定位各列；缺少日期或收盘列时返回 nil (跳过该文件)
+func newASCIIBarMapper(symbol string) func(header []string) func([]string) []any {
+	return func(header []string) func([]string) []any {
+		idx := map[string]int{}
+		for k, h := range header {
+			h = strings.ToUpper(strings.Trim(strings.TrimSpace(h), "<>\ufeff"))
+			switch h {
+			case "TICKER", "SYMBOL", "CODE":
+				idx["symbol"] = k
+			case "PER", "PERIOD":
+				idx["per"] = k
+			case "DTYYYYMMDD", "DATE":
+				idx["date"] = k
+			case "OPEN", "HIGH", "LOW", "CLOSE":
+				idx[strings.ToLower(h)] = k
+			case "VOL", "VOLUME":
+				idx["volume"] = k
+			}
+		}
+		_, hasDate := idx["date"]
+		_, hasClose := idx["close"]
+		_, hasSymbol := idx["symbol"]
+		if !hasDate || !hasClose || (!hasSymbol && symbol == "") {
+			return nil
+		}
+		field := func(record []string, name string) string {
+			if k, ok := idx[name]; ok {
+				return col(record, k)
+			}
+			return ""
+		}
+		return func(record []string) []any {
+			if per := strings.ToUpper(strings.TrimSpace(field(record, "per"))); per != "" && per != "D" {
+				return nil
+			}
+			s := symbol
+			if s == "" {
+				s = metastockSymbol(field(record, "symbol"))
+			}
+			return mapMetastockBar([]string{s, field(record, "date"), field(record, "open"), field(record, "high"),
+				field(record, "low"), field(record, "close"), field(record, "volume")})
+		}
+	}
+}
+
+// chronos metastock --path D:\metastock\data       (含 MASTER 的目录)
+// chronos metastock --path "D:\ascii\*.txt" [--symbol 600000.SH]
+func runMetastock(args []string) {
+	fs := flag.NewFlagSet("metastock", flag.ExitOnError)
+	path := fs.String("path", "", "MetaStock 数据目录 (含 MASTER)，或 ASCII 文件 glob")
+	symbol := fs.String("symbol", "", "ASCII 文件没有代码列时使用的代码")
+	fs.Parse(args)
+	if err := setupValidationRules(DefaultSourcesConfig); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if *path == "" {
+		log.Fatal("[ERROR] 需要指定 --path")
+	}
+
+	start := time.Now()
+	db := openOrCreateDB()
+	defer db.Close()
+	const conflict = "ON CONFLICT (symbol, date) DO NOTHING"
+
+	if _, err := os.Stat(filepath.Join(*path, "MASTER")); err == nil {
+		entries, err := readMetastockMaster(*path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf(">>> 正在导入 MetaStock 目录 %s: %d 个品种...", *path, len(entries))
+		byFile := make(map[string]metastockEntry, len(entries))
+		files := make([]string, 0, len(entries))
+		for _, e := range entries {
+			byFile[e.file] = e
+			files = append(files, e.file)
+		}
+		read := func(file string) ([][]string, error) { return readMetastockData(byFile[file]) }
+		if _, err := importRecordFiles(db, files, "stock_history", conflict, read, mapMetastockBar); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Printf(">>> 正在导入 ASCII 行情 %s...", *path)
+		importCSVFiles(db, *path, "stock_history", conflict, 2, recordMappers(newASCIIBarMapper(metastockSymbol(*symbol))))
+	}
+	createViews(db)
+	log.Printf(">>> ✅ 导入完成, 耗时: %s", time.Since(start))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 分钟线：1 分钟和 5 分钟共用一张表，用 freq 列区分。
+// 主键 (symbol, freq, ts) 即聚簇顺序 (WITHOUT ROWID)，按股票取一段时间的分钟线是连续读，
+// 不再额外建二级索引——分钟表动辄数十亿行，多一个索引就多一倍体积和写入时间。
+//
+// 索引：0:代码, 1:时间戳 (或日期), 2:开盘, 3:最高, 4:最低, 5:收盘, 6:成交量, 7:成交额
+// 如果第 1、2 列分别是日期和时间 (如 "20240102","09:31")，会自动合并，其余列顺延一位。
+// 文件中的时间按交易所本地时间解释 (见 tz.go)。
+const minuteDDL = `CREATE TABLE IF NOT EXISTS stock_minute (
+	symbol  TEXT NOT NULL,
+	freq    INTEGER NOT NULL,   -- 分钟数：1 / 5
+	ts      INTEGER NOT NULL,   -- UTC 秒级时间戳，K 线结束时刻
+	tz      TEXT NOT NULL,      -- 交易所时区 (如 Asia/Shanghai)，本地时间 = local_time(ts, tz)
+	open    REAL,
+	high    REAL,
+	low     REAL,
+	close   REAL,
+	volume  REAL,
+	amount  REAL,
+	PRIMARY KEY (symbol, freq, ts)
+) WITHOUT ROWID, STRICT;`
+
+func init() {
+	for _, src := range []struct {
+		freq    int
+		pattern string
+	}{{1, PathMinute1}, {5, PathMinute5}} {
+		freq := src.freq
+		registerDataset(dataset{
+			Name:    fmt.Sprintf("%d 分钟线", freq),
+			Pattern: src.pattern,
+			Table:   "stock_minute",
+			DDL:     minuteDDL,
+			MinCols: 6,
+			Mapper: func(record []string) []any {
+				return mapMinuteRecord(record, freq)
+			},
+		})
+	}
+}
+
+func mapMinuteRecord(record []string, freq int) []any {
+	ts := normDateTime(record[1])
+	fields := record[2:]
+	if ts == nil && len(record) > 2 {
+		// 日期、时间分两列
+		ts = normDateTime(record[1] + " " + record[2])
+		fields = record[3:]
+	}
+	if ts == nil || len(fields) < 4 {
+		return nil
+	}
+	symbol := strings.TrimSpace(record[0])
+	tz := symbolTimezone(symbol)
+	epoch, ok := localToEpoch(ts.(string), tz)
+	if symbol == "" || !ok {
+		return nil
+	}
+	return []any{
+		symbol,
+		freq,
+		epoch,
+		tz,
+		normNum(fields[0]),
+		normNum(fields[1]),
+		normNum(fields[2]),
+		normNum(fields[3]),
+		normNum(col(fields, 4)),
+		normNum(col(fields, 5)),
+	}
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// 只读映射整个文件，返回的 unmap 释放映射
+func mmapFile(f *os.File, size int) ([]byte, func() error, error) {
+	h, err := syscall.CreateFileMapping(syscall.Handle(f.Fd()), nil, syscall.PAGE_READONLY, 0, 0, nil)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	addr, err := syscall.MapViewOfFile(h, syscall.FILE_MAP_READ, 0, 0, uintptr(size))
+	syscall.CloseHandle(h) // 视图保留对映射对象的引用
+	if err != nil {
+		return nil, nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+	data := unsafe.Slice((*byte)(unsafe.Add(nil, addr)), size)
+	return data, func() error { return syscall.UnmapViewOfFile(addr) }, nil
+}
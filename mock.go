@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"chronos/source"
+)
+
+// ---------------------------------------------------------
+// 模拟数据源
+// ---------------------------------------------------------
+// 按种子生成可复现的假股票与日线，用来在没有真实数据的机器上跑通导入、映射、合并与回补流程：
+//
+//	chronos mock --out D:\mock --count 50 --from 2020-01-01             # 生成 import 布局的 CSV
+//	chronos mock --out D:\mock --format wind-daily                       # 生成终端导出格式 (见 vendor.go)
+//	chronos fetch --chain mock --symbols 600000,000001 --from 2024-01-01 # 作为在线数据源
+//
+// 同一种子、同一代码在任意日期区间生成的数值都相同 (序列固定从 mockEpoch 起算)，分段拉取与一次拉取结果一致。
+// 序列包含分红除权 (复权因子跳变)、偶发停牌 (当天无数据) 与缺失的市盈率，便于检查复权与缺口处理。
+// 作为数据源时种子取 sources.yaml 中 mock.seed，默认 1。
+
+var mockEpoch = time.Date(2000, 1, 3, 0, 0, 0, 0, time.UTC)
+
+type mockBar struct {
+	Date                   time.Time
+	Open, High, Low, Close float64 // 不复权
+	Factor                 float64 // 后复权因子，首日为 1
+	Volume                 float64 // 股
+	PE                     float64 // NaN 表示缺失
+}
+
+func mockRand(seed int64, key string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+}
+
+// 生成 n 个不重复的 A 股代码 (沪市主板、深市主板、创业板混合)
+func mockSymbols(seed int64, n int) []string {
+	r := mockRand(seed, "symbols")
+	seen := map[string]bool{}
+	var out []string
+	for len(out) < n {
+		var s string
+		switch r.Intn(3) {
+		case 0:
+			s = fmt.Sprintf("%06d.SH", 600000+r.Intn(4000))
+		case 1:
+			s = fmt.Sprintf("%06d.SZ", 1+r.Intn(3000))
+		default:
+			s = fmt.Sprintf("%06d.SZ", 300001+r.Intn(1000))
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// symbol 在 [from, to] 内的日线 (只有工作日；上市日同样由种子决定，早于上市日的区间没有数据)
+func mockSeries(seed int64, symbol string, from, to time.Time) []mockBar {
+	r := mockRand(seed, symbol)
+	listed := mockEpoch.AddDate(0, 0, r.Intn(365*20))
+	price := 5 + r.Float64()*45
+	factor := 1.0
+	pe := 10 + r.Float64()*40
+	vol := math.Pow(10, 5+r.Float64()*2)
+
+	var out []mockBar
+	for d := mockEpoch; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			continue
+		}
+		// 不论是否输出都消耗相同数量的随机数，保证任意区间的结果一致
+		ret, gap, rng, volJitter, peJitter := r.NormFloat64()*0.02, r.NormFloat64()*0.005, r.Float64(), r.Float64(), r.NormFloat64()*0.01
+		suspended, dividend, peMissing := r.Float64() < 0.01, r.Float64() < 0.004, r.Float64() < 0.02
+		if d.Before(listed) {
+			continue
+		}
+		if dividend {
+			// 每股派息约 1%~3%，原始价下跳，复权因子同比上调，复权价连续
+			cut := 0.01 + rng*0.02
+			price *= 1 - cut
+			factor /= 1 - cut
+		}
+		open := price * (1 + gap)
+		price = math.Max(0.5, price*(1+ret))
+		pe = math.Max(1, pe*(1+ret+peJitter))
+		if suspended || d.Before(from) {
+			continue
+		}
+		high := math.Max(open, price) * (1 + rng*0.02)
+		low := math.Min(open, price) * (1 - rng*0.02)
+		b := mockBar{Date: d, Open: open, High: high, Low: low, Close: price, Factor: factor,
+			Volume: math.Round(vol*(0.5+volJitter)/100) * 100, PE: pe}
+		if peMissing {
+			b.PE = math.NaN()
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// stock_history 的一行 (价格保留两位小数，与生成的文件一致)
+func (b mockBar) historyRow(symbol string) []any {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	var pe any
+	if !math.IsNaN(b.PE) {
+		pe = round(b.PE)
+	}
+	return []any{symbol, b.Date.Format(time.DateOnly), round(b.Close), round(b.Close * b.Factor), round(b.Open * b.Factor),
+		round(b.High * b.Factor), round(b.Low * b.Factor), pe, b.Volume, marketOf(symbol)}
+}
+
+func mockRound(v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', 2, 64)
+}
+
+func mockSeed(config string) (int64, error) {
+	cfg, err := loadSourceConfig(config, "mock")
+	if err != nil {
+		return 0, err
+	}
+	s := yamlString(cfg, "seed")
+	if s == "" {
+		return 1, nil
+	}
+	seed, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("mock.seed 必须是整数: %q", s)
+	}
+	return seed, nil
+}
+
+// 作为数据源链 (chain.go) 中的一环：缺口范围内的模拟日线写入 staging 表
+func stageMock(ctx context.Context, db *sql.DB, config string, gaps []historyGap) error {
+	seed, err := mockSeed(config)
+	if err != nil {
+		return err
+	}
+	return stageGaps(db, gaps, func(tx *sql.Tx, g historyGap) error {
+		from, _ := time.Parse(time.DateOnly, g.From)
+		to, _ := time.Parse(time.DateOnly, g.To)
+		for _, b := range mockSeries(seed, g.Symbol, from, to) {
+			r := b.historyRow(g.Symbol)
+			date := b.Date.Format("20060102")
+			if _, err := tx.Exec("INSERT INTO staging_tech VALUES (?, ?, ?, ?, ?, ?, ?, ?)", g.Symbol, date, r[2], r[3], r[4], r[5], r[6], r[8]); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("INSERT INTO staging_daily VALUES (?, ?, ?)", g.Symbol, date, r[7]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// import 布局文件的表头 (内置表头指纹见 profiles.go)
+func mockImportHeaders() (tech, daily []string) {
+	header := func(n int, names map[int]string) []string {
+		h := make([]string, n)
+		for k := range h {
+			h[k] = fmt.Sprintf("col%d", k)
+		}
+		for k, name := range names {
+			h[k] = name
+		}
+		return h
+	}
+	return header(19, map[int]string{0: "ts_code", 1: "trade_date", 2: "close", 12: "open_hfq", 14: "close_hfq", 16: "high_hfq", 18: "low_hfq"}),
+		header(15, map[int]string{0: "ts_code", 1: "trade_date", 14: "pe_ttm"})
+}
+
+// 按 import 的布局写一只股票的技术因子与每日指标文件 (列号见 mapTechFactors / mapDailyMetrics，其余列留空)
+func writeMockImportFiles(dir, symbol string, bars []mockBar) error {
+	tech := make([][]string, 0, len(bars)+1)
+	daily := make([][]string, 0, len(bars)+1)
+	techHeader, dailyHeader := mockImportHeaders()
+	tech = append(tech, techHeader)
+	daily = append(daily, dailyHeader)
+	for _, b := range bars {
+		date := b.Date.Format("20060102")
+		t := make([]string, 19)
+		t[0], t[1], t[2] = symbol, date, mockRound(b.Close)
+		t[12], t[14], t[16], t[18] = mockRound(b.Open*b.Factor), mockRound(b.Close*b.Factor), mockRound(b.High*b.Factor), mockRound(b.Low*b.Factor)
+		tech = append(tech, t)
+		d := make([]string, 15)
+		d[0], d[1], d[14] = symbol, date, mockRound(b.PE)
+		daily = append(daily, d)
+	}
+	if err := writeCSVFile(filepath.Join(dir, "技术因子_复权数据", symbol+".csv"), tech); err != nil {
+		return err
+	}
+	return writeCSVFile(filepath.Join(dir, "每日指标", symbol+".csv"), daily)
+}
+
+// 按终端导出格式生成一行 (列布局取自 vendorProfile，未用到的列留空)
+func mockVendorRecord(p vendorProfile, symbol string, b mockBar) []string {
+	rec := make([]string, p.minCols())
+	set := func(k int, v string) {
+		if k >= 0 {
+			rec[k] = v
+		}
+	}
+	c := p.Columns
+	set(c.Symbol, symbol)
+	set(c.Date, b.Date.Format(time.DateOnly))
+	set(c.Open, mockRound(b.Open))
+	set(c.High, mockRound(b.High))
+	set(c.Low, mockRound(b.Low))
+	set(c.Close, mockRound(b.Close))
+	set(c.AdjClose, mockRound(b.Close*b.Factor))
+	set(c.AdjFactor, strconv.FormatFloat(b.Factor, 'f', 6, 64))
+	set(c.Volume, strconv.FormatFloat(b.Volume/p.VolumeUnit, 'f', -1, 64))
+	set(c.PE, mockRound(b.PE))
+	if math.IsNaN(b.PE) && len(p.NullMarkers) > 0 {
+		set(c.PE, p.NullMarkers[0])
+	}
+	return rec
+}
+
+func writeCSVFile(path string, records [][]string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.WriteAll(records)
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// chronos mock --out D:\mock [--format import|wind-daily|...] [--count 20 | --symbols 600000,...] [--seed 1] [--from 2020-01-01] [--to 2020-12-31]
+func runMock(args []string) {
+	fs := flag.NewFlagSet("mock", flag.ExitOnError)
+	out := fs.String("out", "", "输出目录")
+	format := fs.String("format", "import", "文件格式: import (技术因子 + 每日指标目录) 或终端导出格式名 (见 chronos vendor --list)")
+	count := fs.Int("count", 20, "随机生成的股票数量")
+	symbolsArg := fs.String("symbols", "", "逗号分隔的代码 (指定时忽略 --count)")
+	seed := fs.Int64("seed", 1, "随机种子，相同种子生成相同数据")
+	from := fs.String("from", "2020-01-01", "起始日期 (YYYY-MM-DD)")
+	to := fs.String("to", "2020-12-31", "结束日期 (YYYY-MM-DD，含)")
+	fs.Parse(args)
+
+	if *out == "" {
+		log.Fatal("[ERROR] 请用 --out 指定输出目录")
+	}
+	start, err := time.Parse(time.DateOnly, *from)
+	if err != nil {
+		log.Fatalf("[ERROR] --from: %v", err)
+	}
+	end, err := time.Parse(time.DateOnly, *to)
+	if err != nil {
+		log.Fatalf("[ERROR] --to: %v", err)
+	}
+	if *count < 1 || *count > 1000 {
+		log.Fatal("[ERROR] --count 须在 1~1000 之间")
+	}
+	symbols := mockSymbols(*seed, *count)
+	if *symbolsArg != "" {
+		symbols = nil
+		for _, s := range splitList(*symbolsArg) {
+			symbols = append(symbols, canonicalSymbol(s))
+		}
+	}
+
+	var profile vendorProfile
+	if *format != "import" {
+		var ok bool
+		if profile, ok = findVendorProfile(*format); !ok {
+			log.Fatalf("[ERROR] 未知格式: %s", *format)
+		}
+	}
+	vendorRows := [][]string{strings.Split(profile.Header, ",")}
+	bars := 0
+	for _, s := range symbols {
+		series := mockSeries(*seed, s, start, end)
+		bars += len(series)
+		if *format == "import" {
+			if err := writeMockImportFiles(*out, s, series); err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+		for _, b := range series {
+			vendorRows = append(vendorRows, mockVendorRecord(profile, s, b))
+		}
+	}
+	if *format != "import" {
+		if err := writeCSVFile(filepath.Join(*out, *format+".csv"), vendorRows); err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Printf(">>> ✅ 已生成 %d 只股票、%d 根日线 (种子 %d) -> %s", len(symbols), bars, *seed, *out)
+}
+
+// 作为数据源适配器 (source.DataSource，见 datasource.go)：chronos source run mock，配置 (sources.yaml)：
+//
+//	mock:
+//	  seed: 1
+//	  count: 20
+//	  from: 2020-01-01
+//	  to: 2020-12-31
+
+type mockDataSource struct {
+	seed     int64
+	count    int
+	from, to time.Time
+}
+
+func (*mockDataSource) Name() string { return "mock" }
+
+func (m *mockDataSource) Configure(cfg map[string]any) error {
+	m.seed, m.count = 1, 20
+	m.from = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.to = time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+	var err error
+	if s := yamlString(cfg, "seed"); s != "" {
+		if m.seed, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return fmt.Errorf("seed 必须是整数: %q", s)
+		}
+	}
+	if s := yamlString(cfg, "count"); s != "" {
+		if m.count, err = strconv.Atoi(s); err != nil || m.count < 1 || m.count > 1000 {
+			return fmt.Errorf("count 须在 1~1000 之间: %q", s)
+		}
+	}
+	for key, t := range map[string]*time.Time{"from": &m.from, "to": &m.to} {
+		if s := yamlString(cfg, key); s != "" {
+			if *t, err = time.Parse(time.DateOnly, s); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockDataSource) Discover(ctx context.Context) ([]string, error) {
+	return mockSymbols(m.seed, m.count), nil
+}
+
+func (m *mockDataSource) Read(ctx context.Context, symbol string) (source.RowIterator, error) {
+	var rows [][]any
+	for _, b := range mockSeries(m.seed, symbol, m.from, m.to) {
+		rows = append(rows, b.historyRow(symbol))
+	}
+	return &source.SliceRows{Rows: rows}, nil
+}
+
+func (*mockDataSource) Schema() source.Schema {
+	return source.Schema{
+		Table:   "stock_history",
+		Columns: []string{"symbol", "date", "close", "close_adj", "open_adj", "high_adj", "low_adj", "pe", "volume", "market"},
+	}
+}
+
+func init() {
+	source.Register(&mockDataSource{})
+}
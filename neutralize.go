@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// 行业中性化：每个交易日对因子做截面回归
+//
+//	value = Σ 行业哑变量 + β·ln(市值) + ε
+//
+// 残差 ε 即中性化后的因子值，写入 factor_values (原始因子保持不变)
+func runNeutralize(args []string) {
+	fs := flag.NewFlagSet("neutralize", flag.ExitOnError)
+	factor := fs.String("factor", "pe", "待中性化的因子 (stock_history 列名或 factor_values 中的因子名)")
+	size := fs.String("size", "", "可选：市值因子名 (如 market_cap)，取对数后一并回归剔除")
+	out := fs.String("out", "", "输出因子名 (默认 <factor>_neutral)")
+	classification := fs.String("classification", DefaultIndustryScheme, "行业分类口径：SW1~SW3 或 CITIC1~CITIC3")
+	fs.Parse(args)
+
+	scheme, err := parseIndustryScheme(*classification)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	if *out == "" {
+		*out = *factor + "_neutral"
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+	ensureIndustryTables(db)
+	mustHaveFactor(db, *factor)
+
+	sizeCol := "NULL"
+	sizeJoin := ""
+	if *size != "" {
+		mustHaveFactor(db, *size)
+		sizeCol = "s.value"
+		sizeJoin = fmt.Sprintf("INNER JOIN (%s) s ON s.symbol = h.symbol AND s.date = h.date", factorSource(*size))
+	}
+
+	log.Printf(">>> 正在中性化因子 %s (市值: %q, 行业: %s) -> %s", *factor, *size, scheme, *out)
+	query := fmt.Sprintf(`
+	SELECT h.date, h.symbol, h.value, i.industry, %s
+	FROM (%s) h
+	INNER JOIN industry_class i ON %s
+	%s
+	WHERE h.value IS NOT NULL
+	ORDER BY h.date`, sizeCol, factorSource(*factor), industryJoinCond(scheme), sizeJoin)
+
+	rows, err := db.Query(query)
+	if err != nil {
+		log.Fatalf("SQL Error: %v | Query: %s", err, query)
+	}
+
+	// 查询已按日期排序：日期一变就回归并写出上一个截面，内存中只保留一个交易日。
+	// WAL 模式下读查询持有自己的快照，写事务在另一个连接上进行，两者互不阻塞
+	type obs struct {
+		symbol, industry string
+		value, logSize   float64
+	}
+	w := newFactorWriter(db, *out)
+	var days int
+	var curDate string
+	var cross []obs
+	flush := func() {
+		if len(cross) == 0 {
+			return
+		}
+		values := make([]float64, len(cross))
+		sizes := make([]float64, len(cross))
+		industries := make([]string, len(cross))
+		for k, o := range cross {
+			values[k], sizes[k], industries[k] = o.value, o.logSize, o.industry
+		}
+		resid := neutralizeCross(values, industries, sizes, *size != "")
+		for k, o := range cross {
+			if !math.IsNaN(resid[k]) {
+				w.Write(o.symbol, curDate, resid[k])
+			}
+		}
+		days++
+	}
+	for rows.Next() {
+		var date string
+		var o obs
+		var sz *float64
+		if err := rows.Scan(&date, &o.symbol, &o.value, &o.industry, &sz); err != nil {
+			log.Fatal(err)
+		}
+		if *size != "" {
+			if sz == nil || *sz <= 0 {
+				continue
+			}
+			o.logSize = math.Log(*sz)
+		}
+		if date != curDate {
+			flush()
+			curDate, cross = date, cross[:0]
+		}
+		cross = append(cross, o)
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatal(err)
+	}
+	flush()
+	rows.Close()
+	n := w.Close()
+
+	log.Printf(">>> ✅ 中性化完成: %d 个交易日, %d 行, 耗时: %s", days, n, time.Since(start))
+}
+
+// 单个截面的中性化。
+// 只有行业哑变量时，残差就是减去行业均值；
+// 加入 ln(市值) 时按 Frisch-Waugh 定理：先对因子和市值都做行业内去均值，
+// 再用单变量回归求 β，结果与完整 OLS 一致，且无需解大矩阵。
+// 因子值 (或用到市值时的市值) 为 NaN 的股票不参与回归，残差为 NaN。
+func neutralizeCross(values []float64, industries []string, sizes []float64, withSize bool) []float64 {
+	valid := make([]bool, len(values))
+	for k := range values {
+		valid[k] = !math.IsNaN(values[k]) && (!withSize || !math.IsNaN(sizes[k]))
+	}
+	v := demeanByGroup(values, industries, valid)
+	if !withSize {
+		return v
+	}
+	s := demeanByGroup(sizes, industries, valid)
+
+	var sxy, sxx float64
+	for k := range v {
+		if valid[k] {
+			sxy += v[k] * s[k]
+			sxx += s[k] * s[k]
+		}
+	}
+	beta := 0.0
+	if sxx > 0 {
+		beta = sxy / sxx
+	}
+	for k := range v {
+		v[k] -= beta * s[k]
+	}
+	return v
+}
+
+// 组内去均值，只用 valid 的样本；其余记为 NaN
+func demeanByGroup(x []float64, groups []string, valid []bool) []float64 {
+	sum := map[string]float64{}
+	cnt := map[string]int{}
+	for k, g := range groups {
+		if valid[k] {
+			sum[g] += x[k]
+			cnt[g]++
+		}
+	}
+	out := make([]float64, len(x))
+	for k, g := range groups {
+		out[k] = math.NaN()
+		if valid[k] {
+			out[k] = x[k] - sum[g]/float64(cnt[g])
+		}
+	}
+	return out
+}
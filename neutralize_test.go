@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 残差与每个行业哑变量、ln(市值) 都正交；NaN 的股票不参与回归，残差为 NaN
+func TestNeutralizeCross(t *testing.T) {
+	values := []float64{1.2, 3.4, 0.5, math.NaN(), 2.2, 5.1, 4.0, 0.7, 1.9}
+	industries := []string{"银行", "银行", "银行", "银行", "医药", "医药", "医药", "电子", "电子"}
+	sizes := []float64{23.1, 24.0, 22.5, 25.0, 21.7, 23.3, 22.9, 20.4, math.NaN()}
+
+	for _, withSize := range []bool{false, true} {
+		resid := neutralizeCross(values, industries, sizes, withSize)
+		skip := map[int]bool{3: true}
+		if withSize {
+			skip[8] = true
+		}
+		for k := range resid {
+			if math.IsNaN(resid[k]) != skip[k] {
+				t.Errorf("withSize=%v: resid[%d] = %v", withSize, k, resid[k])
+			}
+		}
+		dot := map[string]float64{}
+		var dotSize float64
+		for k, r := range resid {
+			if skip[k] {
+				continue
+			}
+			dot[industries[k]] += r
+			dotSize += r * sizes[k]
+		}
+		for ind, d := range dot {
+			if math.Abs(d) > 1e-12 {
+				t.Errorf("withSize=%v: 残差与 %s 哑变量不正交: %v", withSize, ind, d)
+			}
+		}
+		if withSize && math.Abs(dotSize) > 1e-12 {
+			t.Errorf("残差与 ln(市值) 不正交: %v", dotSize)
+		}
+	}
+
+	// 只有行业时残差即减去行业均值 (不含 NaN)
+	resid := neutralizeCross(values, industries, sizes, false)
+	if want := 1.2 - (1.2+3.4+0.5)/3; math.Abs(resid[0]-want) > 1e-12 {
+		t.Errorf("resid[0] = %v, want %v", resid[0], want)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 公告 / 新闻标题
+// ---------------------------------------------------------
+// 以 (symbol, datetime, title) 为主键：同一时刻可能有多条公告，标题相同视为重复。
+// datetime 为交易所当地时间 YYYY-MM-DD HH:MM:SS，只有日期时记为 00:00:00。
+// 用于事件研究时注意：收盘后发布的公告只能影响下一个交易日。
+//
+// sentiment 由可插拔的打分器计算 (chronos news score)，取值 [-1, 1]，未打分为 NULL；
+// scorer 记录打分器名称，换打分器后可用 --rescore 全部重算。
+//
+// 索引：0:代码, 1:发布时间, 2:标题, 3:来源, 4:类别, 5:链接 (3 之后可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "公告新闻",
+		Pattern: PathNews,
+		Table:   "news",
+		DDL: `CREATE TABLE IF NOT EXISTS news (
+			symbol     TEXT NOT NULL,
+			datetime   TEXT NOT NULL,
+			title      TEXT NOT NULL,
+			source     TEXT,
+			category   TEXT,
+			url        TEXT,
+			sentiment  REAL,
+			scorer     TEXT,
+			PRIMARY KEY (symbol, datetime, title)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 3,
+		Mapper: func(record []string) []any {
+			symbol := normText(record[0])
+			title := normText(record[2])
+			dt := normDateTime(record[1])
+			if dt == nil {
+				if d, ok := normDate(record[1]).(string); ok && len(d) == 10 {
+					dt = d + " 00:00:00"
+				}
+			}
+			if symbol == nil || title == nil || dt == nil {
+				return nil
+			}
+			return []any{symbol, dt, title, normText(col(record, 3)), normText(col(record, 4)), normText(col(record, 5)), nil, nil}
+		},
+	})
+}
+
+// 情感打分器：返回 [-1, 1] 的分数，ok=false 表示无法判断 (保持 NULL)。
+// 自定义打分器 (词典、调用外部模型等) 在 init() 中调用 RegisterSentimentScorer 注册。
+type SentimentScorer interface {
+	Name() string
+	Score(title string) (score float64, ok bool)
+}
+
+var sentimentScorers = map[string]SentimentScorer{}
+
+func RegisterSentimentScorer(s SentimentScorer) {
+	if _, dup := sentimentScorers[s.Name()]; dup {
+		panic("打分器重复注册: " + s.Name())
+	}
+	sentimentScorers[s.Name()] = s
+}
+
+// 对未打分 (rescore 时为全部) 的标题打分，返回更新的行数
+func scoreNews(db *sql.DB, scorer SentimentScorer, rescore bool) (int, error) {
+	cond := "WHERE scorer IS NULL"
+	if rescore {
+		cond = ""
+	}
+	rows, err := db.Query("SELECT symbol, datetime, title FROM news " + cond)
+	if err != nil {
+		return 0, err
+	}
+	// 先整体读出再写入，避免读写同一连接时互相阻塞
+	type key struct{ symbol, datetime, title string }
+	var keys []key
+	for rows.Next() {
+		var k key
+		if err := rows.Scan(&k.symbol, &k.datetime, &k.title); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare("UPDATE news SET sentiment = ?, scorer = ? WHERE symbol = ? AND datetime = ? AND title = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for _, k := range keys {
+		var v any
+		if s, ok := scorer.Score(k.title); ok {
+			v = max(-1, min(1, s))
+		}
+		if _, err := stmt.Exec(v, scorer.Name(), k.symbol, k.datetime, k.title); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), tx.Commit()
+}
+
+// chronos news score [--scorer keywords] [--rescore]
+func runNews(args []string) {
+	if len(args) == 0 || args[0] != "score" {
+		log.Fatal("[ERROR] 用法: chronos news score [参数]")
+	}
+	fs := flag.NewFlagSet("news score", flag.ExitOnError)
+	name := fs.String("scorer", "keywords", "打分器名称")
+	rescore := fs.Bool("rescore", false, "重算全部标题 (默认只处理未打分的)")
+	fs.Parse(args[1:])
+
+	scorer, ok := sentimentScorers[*name]
+	if !ok {
+		names := make([]string, 0, len(sentimentScorers))
+		for n := range sentimentScorers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		log.Fatalf("[ERROR] 未知打分器: %s (可选: %s)", *name, strings.Join(names, ", "))
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+	n, err := scoreNews(db, scorer, *rescore)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf(">>> ✅ 已用 %s 为 %d 条标题打分, 耗时: %s", scorer.Name(), n, time.Since(start))
+}
+
+// ---------------------------------------------------------
+// 内置示例：关键词计数
+// ---------------------------------------------------------
+// (正面词数 - 负面词数) / 命中词数，没有命中任何关键词时不打分。
+// 否定前缀 (“不”“未”“终止”等) 不做处理，只适合作为基线。
+
+func init() {
+	RegisterSentimentScorer(keywordScorer{
+		positive: []string{"增长", "预增", "扭亏", "中标", "回购", "增持", "签订", "获批", "上调", "超预期", "分红", "突破"},
+		negative: []string{"下降", "预减", "亏损", "减持", "处罚", "立案", "诉讼", "违规", "下调", "质押", "退市", "风险提示", "问询"},
+	})
+}
+
+type keywordScorer struct {
+	positive, negative []string
+}
+
+func (keywordScorer) Name() string { return "keywords" }
+
+func (s keywordScorer) Score(title string) (float64, bool) {
+	pos, neg := 0, 0
+	for _, w := range s.positive {
+		pos += strings.Count(title, w)
+	}
+	for _, w := range s.negative {
+		neg += strings.Count(title, w)
+	}
+	if pos+neg == 0 {
+		return 0, false
+	}
+	return float64(pos-neg) / float64(pos+neg), true
+}
@@ -0,0 +1,108 @@
+package main
+
+import "strings"
+
+// 期权：合约要素与日行情分两张表，按合约代码关联。
+// 波动率曲面研究常用查询：
+//
+//	SELECT c.underlying, c.expiry, c.type, c.strike, d.settle, d.iv
+//	FROM option_daily d JOIN option_contracts c USING (contract)
+//	WHERE d.date = '2024-01-02' AND c.underlying = '510050.SH'
+//
+// 合约要素 索引：0:合约代码, 1:标的代码, 2:类型 (认购/认沽、C/P), 3:行权价, 4:到期日, 5:合约乘数 (可缺省)
+// 日行情   索引：0:合约代码, 1:日期, 2:开盘, 3:最高, 4:最低, 5:收盘, 6:结算价, 7:成交量, 8:持仓量, 9:隐含波动率 (7 之后可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "期权合约要素",
+		Pattern: PathOptionContracts,
+		Table:   "option_contracts",
+		DDL: `CREATE TABLE IF NOT EXISTS option_contracts (
+			contract    TEXT NOT NULL PRIMARY KEY,
+			underlying  TEXT NOT NULL,
+			type        TEXT NOT NULL,      -- C 认购 / P 认沽
+			strike      REAL NOT NULL,
+			expiry      TEXT NOT NULL,
+			multiplier  REAL
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 5,
+		Mapper: func(record []string) []any {
+			typ := normOptionType(record[2])
+			strike := normNum(record[3])
+			expiry := normDate(record[4])
+			if typ == nil || strike == nil || expiry == nil {
+				return nil
+			}
+			return []any{
+				normText(record[0]),
+				normText(record[1]),
+				typ,
+				strike,
+				expiry,
+				normNum(col(record, 5)),
+			}
+		},
+	})
+
+	registerDataset(dataset{
+		Name:    "期权日线",
+		Pattern: PathOptionDaily,
+		Table:   "option_daily",
+		DDL: `CREATE TABLE IF NOT EXISTS option_daily (
+			contract       TEXT NOT NULL,
+			date           TEXT NOT NULL,
+			open           REAL,
+			high           REAL,
+			low            REAL,
+			close          REAL,
+			settle         REAL,
+			volume         REAL,
+			open_interest  REAL,
+			iv             REAL,            -- 供应商提供的隐含波动率 (小数，0.25 = 25%)，没有则为 NULL
+			PRIMARY KEY (contract, date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 6,
+		Mapper: func(record []string) []any {
+			date := normDate(record[1])
+			if date == nil {
+				return nil
+			}
+			return []any{
+				normText(record[0]),
+				date,
+				normNum(record[2]),
+				normNum(record[3]),
+				normNum(record[4]),
+				normNum(record[5]),
+				normNum(col(record, 6)),
+				normNum(col(record, 7)),
+				normNum(col(record, 8)),
+				normIV(col(record, 9)),
+			}
+		},
+	})
+}
+
+// 期权类型统一为 C / P，无法识别返回 NULL
+func normOptionType(s string) any {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "C", "CALL", "认购", "看涨":
+		return "C"
+	case "P", "PUT", "认沽", "看跌":
+		return "P"
+	}
+	return nil
+}
+
+// 隐含波动率统一为小数：带 % 或大于 5 (即 500%) 的视为百分数
+func normIV(s string) any {
+	s = strings.TrimSpace(s)
+	pct := strings.HasSuffix(s, "%")
+	v, ok := normNum(strings.TrimSuffix(s, "%")).(float64)
+	if !ok {
+		return nil
+	}
+	if pct || v > 5 {
+		v /= 100
+	}
+	return v
+}
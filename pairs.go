@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Engle-Granger 两变量协整检验的 MacKinnon 临界值 (带常数项)
+const (
+	egCritical1  = -3.90
+	egCritical5  = -3.34
+	egCritical10 = -3.04
+)
+
+// 配对筛选：在同一行业 (或用户给定列表) 内两两组合，计算
+//   - 窗口内日收益相关系数及滚动相关均值
+//   - Engle-Granger 协整：ln(A) 对 ln(B) 做 OLS，残差做 ADF 检验
+//   - 残差均值回复半衰期
+//
+// 按 ADF t 值从小到大 (协整越显著越靠前) 输出候选列表。
+func runPairs(args []string) {
+	fs := flag.NewFlagSet("pairs", flag.ExitOnError)
+	symbolsArg := fs.String("symbols", "", "股票代码列表文件或逗号分隔的代码 (不指定则按行业分组)")
+	industry := fs.String("industry", "", "只扫描该行业内的股票")
+	classification := fs.String("classification", DefaultIndustryScheme, "按行业分组时的分类口径：SW1~SW3 或 CITIC1~CITIC3")
+	window := fs.Int("window", 250, "回看窗口 (交易日)")
+	roll := fs.Int("roll", 60, "滚动相关的窗口 (交易日)")
+	lags := fs.Int("lags", 1, "ADF 检验的差分滞后阶数")
+	to := fs.String("to", "", "截止日期 (YYYY-MM-DD，默认最新)")
+	minPeriods := fs.Int("min-periods", 120, "共同有效样本的最少天数")
+	top := fs.Int("top", 100, "输出前 N 对 (0 表示全部)")
+	out := fs.String("out", "pairs.csv", "输出 CSV 文件")
+	fs.Parse(args)
+
+	// 负的 lags 会让 ADF 回归越界；样本太少时回归没有自由度
+	if *lags < 0 || *roll < 2 || *minPeriods <= *lags+2 {
+		log.Fatalf("[ERROR] 参数非法: lags=%d (需 >= 0) roll=%d (需 >= 2) min-periods=%d (需 > lags+2)", *lags, *roll, *minPeriods)
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+	ctx := context.Background()
+	store := newStore(db)
+
+	// 行业分类只覆盖 A 股，按行业分组时使用 A 股日历
+	var listed []string
+	market := MarketCN
+	if *symbolsArg != "" {
+		var err error
+		if listed, err = parseSymbols(*symbolsArg); err != nil {
+			log.Fatal(err)
+		}
+		market = symbolsMarket(listed)
+	}
+	dates := lastTradingDates(tradingDates(db, market), *to, *window)
+	if len(dates) < *minPeriods {
+		log.Fatalf("[ERROR] 截止 %q 的交易日不足 %d 天", *to, *minPeriods)
+	}
+	asOf := dates[len(dates)-1]
+
+	// 候选分组：同一组内两两配对
+	groups := map[string][]string{}
+	if listed != nil {
+		groups["(list)"] = listed
+	} else {
+		scheme, err := parseIndustryScheme(*classification)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		members, err := industryMembers(db, asOf, scheme)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *industry != "" {
+			groups[*industry] = members[*industry]
+		} else {
+			groups = members
+		}
+	}
+	if len(groups) == 0 {
+		log.Fatal("[ERROR] 没有候选股票 (请指定 --symbols，或先导入行业分类)")
+	}
+
+	var results []pairResult
+	for group, symbols := range groups {
+		if len(symbols) < 2 {
+			continue
+		}
+		prices, err := priceMatrix(ctx, store, symbols, dates)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i := 0; i < len(symbols); i++ {
+			for j := i + 1; j < len(symbols); j++ {
+				r, ok := screenPair(prices[i], prices[j], *roll, *lags, *minPeriods)
+				if !ok {
+					continue
+				}
+				r.A, r.B, r.Group = symbols[i], symbols[j], group
+				results = append(results, r)
+			}
+		}
+	}
+
+	sort.Slice(results, func(a, b int) bool { return results[a].ADF < results[b].ADF })
+	if *top > 0 && len(results) > *top {
+		results = results[:*top]
+	}
+	if err := writePairsCSV(*out, results); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf(">>> ✅ 配对筛选完成 (截至 %s): 输出 %d 对 -> %s, 耗时: %s", asOf, len(results), *out, time.Since(start))
+}
+
+type pairResult struct {
+	A, B, Group  string
+	N            int
+	Corr         float64
+	RollCorrMean float64
+	Beta         float64
+	ADF          float64
+	HalfLife     float64
+}
+
+func screenPair(pa, pb []float64, roll, lags, minPeriods int) (pairResult, bool) {
+	// 只保留双方都有价格的日期
+	var la, lb []float64
+	for k := range pa {
+		if !math.IsNaN(pa[k]) && !math.IsNaN(pb[k]) && pa[k] > 0 && pb[k] > 0 {
+			la = append(la, math.Log(pa[k]))
+			lb = append(lb, math.Log(pb[k]))
+		}
+	}
+	n := len(la)
+	if n < minPeriods {
+		return pairResult{}, false
+	}
+
+	ra, rb := diff(la), diff(lb)
+	res := pairResult{N: n, Corr: pearson(ra, rb), RollCorrMean: math.NaN()}
+	if roll > 1 && len(ra) >= roll {
+		var cs []float64
+		for k := roll; k <= len(ra); k++ {
+			cs = append(cs, pearson(ra[k-roll:k], rb[k-roll:k]))
+		}
+		res.RollCorrMean = mean(dropNaN(cs))
+	}
+
+	// 协整回归 ln(A) = α + β·ln(B) + e
+	X := make([][]float64, n)
+	for k := range X {
+		X[k] = []float64{1, lb[k]}
+	}
+	beta, _, ok := ols(X, la)
+	if !ok {
+		return pairResult{}, false
+	}
+	res.Beta = beta[1]
+	resid := make([]float64, n)
+	for k := range resid {
+		resid[k] = la[k] - beta[0] - beta[1]*lb[k]
+	}
+
+	var gamma float64
+	res.ADF, gamma, ok = adfStat(resid, lags)
+	if !ok {
+		return pairResult{}, false
+	}
+	res.HalfLife = math.NaN()
+	if gamma < 0 {
+		res.HalfLife = -math.Ln2 / math.Log(1+gamma)
+	}
+	return res, true
+}
+
+func diff(x []float64) []float64 {
+	out := make([]float64, len(x)-1)
+	for k := 1; k < len(x); k++ {
+		out[k-1] = x[k] - x[k-1]
+	}
+	return out
+}
+
+// ADF 检验 (残差已去均值，不带常数项)：
+//
+//	Δe_t = γ·e_{t-1} + Σ φ_i·Δe_{t-i} + u_t
+//
+// 返回 γ 的 t 统计量和 γ 本身
+func adfStat(e []float64, lags int) (tstat, gamma float64, ok bool) {
+	if lags < 0 {
+		return 0, 0, false
+	}
+	de := diff(e)
+	var X [][]float64
+	var y []float64
+	for t := lags; t < len(de); t++ {
+		row := []float64{e[t]}
+		for i := 1; i <= lags; i++ {
+			row = append(row, de[t-i])
+		}
+		X = append(X, row)
+		y = append(y, de[t])
+	}
+	beta, se, ok := ols(X, y)
+	if !ok || se[0] == 0 {
+		return 0, 0, false
+	}
+	return beta[0] / se[0], beta[0], true
+}
+
+func writePairsCSV(path string, results []pairResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"symbol_a", "symbol_b", "group", "n", "corr", "roll_corr_mean", "beta", "adf_t", "signif", "half_life"})
+	for _, r := range results {
+		signif := ""
+		switch {
+		case r.ADF < egCritical1:
+			signif = "1%"
+		case r.ADF < egCritical5:
+			signif = "5%"
+		case r.ADF < egCritical10:
+			signif = "10%"
+		}
+		w.Write([]string{r.A, r.B, r.Group, strconv.Itoa(r.N), formatFloat(r.Corr), formatFloat(r.RollCorrMean),
+			formatFloat(r.Beta), formatFloat(r.ADF), signif, formatFloat(r.HalfLife)})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// e = [2, -1, 1, 0]，lags = 0：Δe = [-3, 2, -1] 对 e_{t-1} = [2, -1, 1] 回归，
+// γ = -9/6 = -1.5，残差 [0, 0.5, 0.5]，σ² = 0.5/2，se = sqrt(0.25/6)，t = -1.5·sqrt(24)
+func TestADFStat(t *testing.T) {
+	tstat, gamma, ok := adfStat([]float64{2, -1, 1, 0}, 0)
+	if !ok || math.Abs(gamma+1.5) > 1e-12 || math.Abs(tstat+1.5*math.Sqrt(24)) > 1e-12 {
+		t.Errorf("adfStat = %v, %v, %v; want %v, -1.5", tstat, gamma, ok, -1.5*math.Sqrt(24))
+	}
+	if _, _, ok := adfStat([]float64{2, -1, 1, 0}, -1); ok {
+		t.Error("负的 lags 应返回 ok=false")
+	}
+	if _, _, ok := adfStat([]float64{2, -1, 1}, 1); ok {
+		t.Error("样本不足时应返回 ok=false")
+	}
+}
+
+// 协整的一对 (ln A = 0.5 + 1.2·ln B + AR(1) 噪声) 在 5% 水平显著；两条独立随机游走不显著
+func TestScreenPair(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	const n = 250
+	walk := func() []float64 {
+		p := make([]float64, n)
+		lp := math.Log(20.0)
+		for k := range p {
+			lp += 0.02 * rng.NormFloat64()
+			p[k] = math.Exp(lp)
+		}
+		return p
+	}
+
+	b := walk()
+	a := make([]float64, n)
+	noise := 0.0
+	for k := range a {
+		noise = 0.5*noise + 0.01*rng.NormFloat64()
+		a[k] = math.Exp(0.5 + 1.2*math.Log(b[k]) + noise)
+	}
+	b[10] = math.NaN() // 缺失的日期两边一起去掉
+	r, ok := screenPair(a, b, 60, 1, 120)
+	if !ok {
+		t.Fatal("协整的一对没有结果")
+	}
+	if r.N != n-1 || r.ADF >= egCritical5 || math.Abs(r.Beta-1.2) > 0.05 || !(r.HalfLife > 0 && r.HalfLife < 5) {
+		t.Errorf("协整的一对: %+v", r)
+	}
+
+	r, ok = screenPair(walk(), walk(), 60, 1, 120)
+	if !ok {
+		t.Fatal("独立随机游走没有结果")
+	}
+	if r.ADF < egCritical10 {
+		t.Errorf("独立随机游走被判为协整: ADF = %v", r.ADF)
+	}
+
+	if _, ok := screenPair(a[:100], b[:100], 60, 1, 120); ok {
+		t.Error("样本少于 min-periods 时应返回 ok=false")
+	}
+}
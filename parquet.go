@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+)
+
+// 最小 Parquet 写出：单行组、每列一个未压缩的 PLAIN 数据页 (v1)，不引入额外依赖。
+// 第一列 symbol 为 REQUIRED UTF8，其余每个标签一列 OPTIONAL DOUBLE，NaN 写为 null。
+// 元数据按 parquet.thrift 的 compact protocol 手工编码，只写读取端必需的字段。
+
+const (
+	pqTypeDouble    = 5
+	pqTypeByteArray = 6
+
+	pqRequired = 0
+	pqOptional = 1
+
+	pqEncPlain = 0
+	pqEncRLE   = 3
+
+	pqConvertedUTF8 = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// 带行列标签的方阵 Parquet，列与 writeMatrixCSV 一致
+func writeMatrixParquet(path string, labels []string, m [][]float64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if _, err := w.Write(encodeMatrixParquet(labels, m)); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+type pqColumn struct {
+	name   string
+	typ    int32
+	rep    int32
+	offset int64
+	size   int64
+}
+
+func encodeMatrixParquet(labels []string, m [][]float64) []byte {
+	var out bytes.Buffer
+	out.Write(parquetMagic)
+	rows := len(m)
+
+	cols := []pqColumn{{name: "symbol", typ: pqTypeByteArray, rep: pqRequired}}
+	var body bytes.Buffer
+	for _, s := range labels {
+		binary.Write(&body, binary.LittleEndian, uint32(len(s)))
+		body.WriteString(s)
+	}
+	cols[0].offset, cols[0].size = writeDataPage(&out, rows, body.Bytes())
+
+	for j, name := range labels {
+		col := make([]float64, rows)
+		for i := range m {
+			col[i] = m[i][j]
+		}
+		c := pqColumn{name: name, typ: pqTypeDouble, rep: pqOptional}
+		c.offset, c.size = writeDataPage(&out, rows, optionalDoublePage(col))
+		cols = append(cols, c)
+	}
+
+	meta := fileMetaData(cols, rows)
+	out.Write(meta)
+	binary.Write(&out, binary.LittleEndian, uint32(len(meta)))
+	out.Write(parquetMagic)
+	return out.Bytes()
+}
+
+// 定义级别 (RLE/bit-packed 混合编码, 位宽 1, 带 4 字节长度前缀) + 非空值
+func optionalDoublePage(col []float64) []byte {
+	groups := (len(col) + 7) / 8
+	levels := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	packed := make([]byte, groups)
+	var values bytes.Buffer
+	for i, v := range col {
+		if math.IsNaN(v) {
+			continue
+		}
+		packed[i/8] |= 1 << (i % 8)
+		binary.Write(&values, binary.LittleEndian, math.Float64bits(v))
+	}
+	levels = append(levels, packed...)
+
+	var page bytes.Buffer
+	binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+	page.Write(levels)
+	page.Write(values.Bytes())
+	return page.Bytes()
+}
+
+// 写页头和页体，返回列块起始偏移与总字节数
+func writeDataPage(out *bytes.Buffer, numValues int, body []byte) (int64, int64) {
+	var t thriftCompact
+	t.i32(1, 0) // type = DATA_PAGE
+	t.i32(2, int32(len(body)))
+	t.i32(3, int32(len(body)))
+	t.structBegin(5) // data_page_header
+	t.i32(1, int32(numValues))
+	t.i32(2, pqEncPlain)
+	t.i32(3, pqEncRLE)
+	t.i32(4, pqEncRLE)
+	t.structEnd()
+	t.stop()
+
+	offset := int64(out.Len())
+	out.Write(t.buf.Bytes())
+	out.Write(body)
+	return offset, int64(out.Len()) - offset
+}
+
+func fileMetaData(cols []pqColumn, rows int) []byte {
+	var t thriftCompact
+	t.i32(1, 1) // version
+
+	t.listBegin(2, thriftStruct, len(cols)+1)
+	t.elemBegin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(cols)))
+	t.elemEnd()
+	for _, c := range cols {
+		t.elemBegin()
+		t.i32(1, c.typ)
+		t.i32(3, c.rep)
+		t.binary(4, c.name)
+		if c.typ == pqTypeByteArray {
+			t.i32(6, pqConvertedUTF8)
+		}
+		t.elemEnd()
+	}
+
+	t.i64(3, int64(rows))
+
+	var total int64
+	for _, c := range cols {
+		total += c.size
+	}
+	t.listBegin(4, thriftStruct, 1)
+	t.elemBegin()
+	t.listBegin(1, thriftStruct, len(cols))
+	for _, c := range cols {
+		t.elemBegin()
+		t.i64(2, c.offset)
+		t.structBegin(3) // meta_data
+		t.i32(1, c.typ)
+		t.listBegin(2, thriftI32, 2)
+		t.rawVarint(zigzag(pqEncPlain))
+		t.rawVarint(zigzag(pqEncRLE))
+		t.listBegin(3, thriftBinary, 1)
+		t.rawBinary(c.name)
+		t.i32(4, 0) // codec = UNCOMPRESSED
+		t.i64(5, int64(rows))
+		t.i64(6, c.size)
+		t.i64(7, c.size)
+		t.i64(9, c.offset)
+		t.structEnd()
+		t.elemEnd()
+	}
+	t.i64(2, total)
+	t.i64(3, int64(rows))
+	t.elemEnd()
+
+	t.binary(6, "chronos")
+	t.stop()
+	return t.buf.Bytes()
+}
+
+// thrift compact protocol 的写出子集
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+type thriftCompact struct {
+	buf   bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (t *thriftCompact) field(id int16, typ byte) {
+	if d := id - t.last; d > 0 && d <= 15 {
+		t.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.rawVarint(zigzag(int64(id)))
+	}
+	t.last = id
+}
+
+func (t *thriftCompact) rawVarint(v uint64) { t.buf.Write(binary.AppendUvarint(nil, v)) }
+
+func (t *thriftCompact) rawBinary(s string) {
+	t.rawVarint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftCompact) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.rawVarint(zigzag(int64(v)))
+}
+
+func (t *thriftCompact) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.rawVarint(zigzag(v))
+}
+
+func (t *thriftCompact) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.rawBinary(s)
+}
+
+func (t *thriftCompact) listBegin(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.rawVarint(uint64(n))
+	}
+}
+
+func (t *thriftCompact) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftCompact) structEnd() { t.elemEnd() }
+
+// 列表中的结构体元素没有字段头，只需重置字段 id 基准
+func (t *thriftCompact) elemBegin() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftCompact) elemEnd() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftCompact) stop() { t.buf.WriteByte(0) }
+
+func zigzag(v int64) uint64 { return uint64(v<<1) ^ uint64(v>>63) }
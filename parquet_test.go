@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 测试用的 thrift compact 读取：结构体解码为 map[字段 id]值，整数统一为 int64
+type thriftReader struct {
+	b   []byte
+	pos int
+	t   *testing.T
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("坏的 varint @%d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		u := r.uvarint()
+		return int64(u>>1) ^ -int64(u&1)
+	case thriftBinary:
+		n := int(r.uvarint())
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		h := r.b[r.pos]
+		r.pos++
+		n, elem := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		out := make([]any, n)
+		for i := range out {
+			out[i] = r.value(elem)
+		}
+		return out
+	case thriftStruct:
+		return r.structure()
+	}
+	r.t.Fatalf("未支持的 thrift 类型 %d", typ)
+	return nil
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	out := map[int16]any{}
+	var last int16
+	for {
+		h := r.b[r.pos]
+		r.pos++
+		if h == 0 {
+			return out
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			u := r.uvarint()
+			id = int16(int64(u>>1) ^ -int64(u&1))
+		}
+		out[id] = r.value(h & 0x0f)
+		last = id
+	}
+}
+
+func TestWriteMatrixParquet(t *testing.T) {
+	labels := []string{"600000.SH", "000001.SZ", "00700.HK"}
+	nan := math.NaN()
+	m := [][]float64{
+		{1, 0.25, nan},
+		{0.25, 1, -0.5},
+		{nan, -0.5, 1},
+	}
+	path := filepath.Join(t.TempDir(), "corr.parquet")
+	if err := writeMatrixParquet(path, labels, m); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatalf("缺少 PAR1 魔数")
+	}
+	metaLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	metaStart := len(data) - 8 - metaLen
+	meta := (&thriftReader{b: data[:len(data)-8], pos: metaStart, t: t}).structure()
+
+	if meta[3] != int64(3) {
+		t.Fatalf("num_rows = %v", meta[3])
+	}
+	schema := meta[2].([]any)
+	wantNames := append([]string{"schema", "symbol"}, labels...)
+	if len(schema) != len(wantNames) {
+		t.Fatalf("schema 长度 %d", len(schema))
+	}
+	for i, el := range schema {
+		if name := el.(map[int16]any)[4]; name != wantNames[i] {
+			t.Fatalf("schema[%d] = %v, 期望 %s", i, name, wantNames[i])
+		}
+	}
+
+	chunks := meta[4].([]any)[0].(map[int16]any)[1].([]any)
+	if len(chunks) != len(labels)+1 {
+		t.Fatalf("列块数 %d", len(chunks))
+	}
+	pageBody := func(c int) ([]byte, int64) {
+		cm := chunks[c].(map[int16]any)[3].(map[int16]any)
+		r := &thriftReader{b: data, pos: int(cm[9].(int64)), t: t}
+		ph := r.structure()
+		size := int(ph[3].(int64))
+		if int64(r.pos-int(cm[9].(int64))+size) != cm[7].(int64) {
+			t.Fatalf("列 %d: total_compressed_size 与页大小不符", c)
+		}
+		return data[r.pos : r.pos+size], ph[5].(map[int16]any)[1].(int64)
+	}
+
+	body, n := pageBody(0)
+	if n != 3 {
+		t.Fatalf("symbol 列 num_values = %d", n)
+	}
+	for _, want := range labels {
+		l := int(binary.LittleEndian.Uint32(body))
+		if got := string(body[4 : 4+l]); got != want {
+			t.Fatalf("symbol = %q, 期望 %q", got, want)
+		}
+		body = body[4+l:]
+	}
+
+	for j := range labels {
+		body, _ := pageBody(j + 1)
+		levelsLen := int(binary.LittleEndian.Uint32(body))
+		levels, values := body[4:4+levelsLen], body[4+levelsLen:]
+		if levels[0] != 1<<1|1 {
+			t.Fatalf("列 %d: 定义级别应为单组 bit-packed, 得到 %#x", j, levels[0])
+		}
+		for i := range m {
+			v := m[i][j]
+			defined := levels[1]>>i&1 == 1
+			if defined == math.IsNaN(v) {
+				t.Fatalf("[%d][%d]: defined=%v, 值 %v", i, j, defined, v)
+			}
+			if !defined {
+				continue
+			}
+			got := math.Float64frombits(binary.LittleEndian.Uint64(values))
+			if got != v {
+				t.Fatalf("[%d][%d] = %v, 期望 %v", i, j, got, v)
+			}
+			values = values[8:]
+		}
+		if len(values) != 0 {
+			t.Fatalf("列 %d: 多余 %d 字节", j, len(values))
+		}
+	}
+}
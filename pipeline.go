@@ -0,0 +1,546 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ---------------------------------------------------------
+// CSV 导入流水线
+// ---------------------------------------------------------
+// importCSVFiles 的解析与写入分开：若干解析 goroutine 各自处理一个文件 (分隔符识别、CSV 解析、映射清洗)，
+// 把映射好的行按块放进该文件的有界 channel；写入在调用方的 goroutine 中按文件顺序逐个消费。
+// 写入顺序与串行执行时完全相同 (ON CONFLICT 聚合、"以后导入的为准" 等语义不变)，
+// 同时解析与写库重叠进行。channel 有界，解析领先写入太多时自动阻塞，内存占用有上限。
+// 映射函数会在多个 goroutine 中同时调用，不能修改共享状态 (每个文件各有一个 rowArena，见 rowmap.go)。
+
+// 每块的行数
+const importChunkRows = 1000
+
+var (
+	// 解析 goroutine 数：留一个核给写入
+	importParseWorkers = min(max(runtime.NumCPU()-1, 1), 8)
+	// 每个文件最多缓冲的块数 (设置内存预算时可能减少，见 memory.go)
+	importFileChunks = 4
+)
+
+// 大文件读取 (单个文件动辄几 GB)：超过 big_file 的文件映射到内存 (mmap) 读取，不支持或失败时
+// 改用 read_buffer 大小的缓冲区顺序读取；小文件仍用 file_buffer 大小的普通缓冲读取。
+// 识别分隔符时最多看表头的前 header_limit 字节 (读缓冲至少为这么大)。在 sources.yaml 中调整：
+//
+//	import:
+//	  big_file: 1GB
+//	  read_buffer: 16MB
+//	  file_buffer: 256KB
+//	  header_limit: 1MB
+//	  mmap: true
+//	  csv: auto          # 解析方式，见 fastcsv.go
+//	  max_memory: 4GB    # 内存预算，见 memory.go
+//	  temp_dir: D:\tmp   # SQLite 与排序的临时文件目录 (默认系统临时目录，见 tempdir.go)
+//	  skip: [vacuum]     # 跳过的收尾步骤，见 finalizeSteps
+//	  checkpoint_rows: 5000000  # 有大文件时每多少行提交一次 (断点续传，见 checkpoint.go)，0 为不分段
+//	  auto_vacuum: incremental  # 新建数据库的 auto_vacuum 模式 (见 vacuum.go)
+//	  on_error: skip     # 数据出错时跳过该行，abort 为终止 (见 importerr.go)
+//	  max_errors: 1000   # 累计出错超过该数即终止，0 为不限
+//	  batch_rows: 500    # 多行 INSERT 每批行数，默认随驱动 (见 batch.go)，1 为逐行
+//	  strict: false      # 任何数据问题都终止 (同 --strict，见 strict.go)
+//	  report: quality_report.html  # 运行结束时写出的数据质量报告 (见 report.go)
+//	  files:             # 按文件名 (glob) 覆盖缓冲设置，第一个匹配的生效
+//	    - match: "*宏观*.csv"
+//	      header_limit: 16MB
+//	      read_buffer: 64MB
+var importOptions = struct {
+	bigFile     int64
+	readBuffer  int
+	fileBuffer  int
+	headerLimit int
+	mmap        bool
+	csv         string
+	maxMemory   int64
+	tempDir     string
+	skip        map[string]bool
+	files       []fileBufferOverride
+	autoVacuum  string
+	onError     string
+	maxErrors   int
+	batchRows   int
+	strict      bool
+	report      string
+}{bigFile: 1 << 30, readBuffer: 16 << 20, fileBuffer: 256 << 10, headerLimit: 1 << 20, mmap: true, csv: csvAuto, onError: onErrorSkip, batchRows: insertBatchRows, report: "quality_report.html"}
+
+// import.files 中的一项，为 0 的字段沿用全局设置
+type fileBufferOverride struct {
+	match       string
+	readBuffer  int
+	fileBuffer  int
+	headerLimit int
+}
+
+// 缓冲设置的键与下限
+var bufferOptionKeys = []struct {
+	key string
+	min int64
+}{{"read_buffer", 4 << 10}, {"file_buffer", 4 << 10}, {"header_limit", 1 << 10}}
+
+// 读取 m 中的缓冲设置，没有写的项为 0
+func parseBufferOptions(m map[string]any) (readBuffer, fileBuffer, headerLimit int, err error) {
+	dst := []*int{&readBuffer, &fileBuffer, &headerLimit}
+	for k, opt := range bufferOptionKeys {
+		s := yamlString(m, opt.key)
+		if s == "" {
+			continue
+		}
+		n, err := parseByteSize(s)
+		if err != nil || n < opt.min || n > math.MaxInt32 {
+			return 0, 0, 0, fmt.Errorf("%s 必须在 %s 到 2GB 之间: %q", opt.key, formatBytes(float64(opt.min)), s)
+		}
+		*dst[k] = int(n)
+	}
+	return readBuffer, fileBuffer, headerLimit, nil
+}
+
+// path 适用的缓冲设置 (import.files 中第一个按文件名匹配的项覆盖全局设置)
+func fileBufferOptions(path string) (readBuffer, fileBuffer, headerLimit int) {
+	readBuffer, fileBuffer, headerLimit = importOptions.readBuffer, importOptions.fileBuffer, importOptions.headerLimit
+	for _, o := range importOptions.files {
+		if ok, _ := filepath.Match(o.match, filepath.Base(path)); !ok {
+			continue
+		}
+		if o.readBuffer > 0 {
+			readBuffer = o.readBuffer
+		}
+		if o.fileBuffer > 0 {
+			fileBuffer = o.fileBuffer
+		}
+		if o.headerLimit > 0 {
+			headerLimit = o.headerLimit
+		}
+		break
+	}
+	return readBuffer, fileBuffer, headerLimit
+}
+
+// 可以跳过的收尾步骤 (开发时反复导入，省掉几十 GB 库的 VACUUM 等长尾)：
+//
+//	vacuum         导入结束后的 VACUUM
+//	drop-staging   删除 staging 表 (合并后已清空，保留表只是多占一点空间)
+//	staging-index  合并前为 staging 表建索引 (SQL 合并时 SQLite 会临时建自动索引代替)
+//	report         运行结束时的数据质量报告 (质量评分照常计算，见 quality.go)
+var finalizeSteps = []string{"vacuum", "drop-staging", "staging-index", "report"}
+
+// 设置跳过的收尾步骤，替换之前的设置
+func setSkipSteps(steps []string) error {
+	skip := map[string]bool{}
+	for _, s := range steps {
+		if !containsString(finalizeSteps, s) {
+			return fmt.Errorf("未知的收尾步骤 %q (可选: %s)", s, strings.Join(finalizeSteps, ", "))
+		}
+		skip[s] = true
+	}
+	importOptions.skip = skip
+	return nil
+}
+
+func skipStep(name string) bool {
+	if importOptions.skip[name] {
+		log.Printf(">>> 按设置跳过: %s", name)
+		return true
+	}
+	return false
+}
+
+func setupImportOptions(configPath string) error {
+	cfg, err := loadSourceConfig(configPath, "import")
+	if err != nil {
+		return err
+	}
+	if s := yamlString(cfg, "big_file"); s != "" {
+		if importOptions.bigFile, err = parseByteSize(s); err != nil {
+			return fmt.Errorf("import.big_file: %v", err)
+		}
+	}
+	readBuffer, fileBuffer, headerLimit, err := parseBufferOptions(cfg)
+	if err != nil {
+		return fmt.Errorf("import.%v", err)
+	}
+	if readBuffer > 0 {
+		importOptions.readBuffer = readBuffer
+	}
+	if fileBuffer > 0 {
+		importOptions.fileBuffer = fileBuffer
+	}
+	if headerLimit > 0 {
+		importOptions.headerLimit = headerLimit
+	}
+	importOptions.files = nil
+	for k, m := range yamlList(cfg, "files") {
+		o := fileBufferOverride{match: yamlString(m, "match")}
+		if _, err := filepath.Match(o.match, ""); o.match == "" || err != nil {
+			return fmt.Errorf("import.files[%d].match 必须是文件名 glob: %q", k, o.match)
+		}
+		if o.readBuffer, o.fileBuffer, o.headerLimit, err = parseBufferOptions(m); err != nil {
+			return fmt.Errorf("import.files[%d].%v", k, err)
+		}
+		importOptions.files = append(importOptions.files, o)
+	}
+	switch s := yamlString(cfg, "csv"); s {
+	case "":
+	case csvAuto, csvFast, csvStrict:
+		importOptions.csv = s
+	default:
+		return fmt.Errorf("import.csv 必须是 auto / fast / strict: %q", s)
+	}
+	if s := yamlString(cfg, "max_memory"); s != "" {
+		if importOptions.maxMemory, err = parseByteSize(s); err != nil {
+			return fmt.Errorf("import.max_memory: %v", err)
+		}
+	}
+	if s := yamlString(cfg, "temp_dir"); s != "" && *tmpDirFlag == "" {
+		if err := useTempDir(s); err != nil {
+			return fmt.Errorf("import.temp_dir: %v", err)
+		}
+	}
+	if steps := yamlStrings(cfg, "skip"); len(steps) > 0 {
+		if err := setSkipSteps(steps); err != nil {
+			return fmt.Errorf("import.skip: %v", err)
+		}
+	}
+	if s := yamlString(cfg, "checkpoint_rows"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return fmt.Errorf("import.checkpoint_rows 必须是非负整数: %q", s)
+		}
+		checkpointRows = n
+	}
+	if s := yamlString(cfg, "auto_vacuum"); s != "" {
+		if err := checkAutoVacuumMode(s); err != nil {
+			return fmt.Errorf("import.%v", err)
+		}
+		importOptions.autoVacuum = s
+	}
+	switch s := yamlString(cfg, "on_error"); s {
+	case "":
+	case onErrorSkip, onErrorAbort:
+		importOptions.onError = s
+	default:
+		return fmt.Errorf("import.on_error 必须是 skip / abort: %q", s)
+	}
+	if s := yamlString(cfg, "max_errors"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return fmt.Errorf("import.max_errors 必须是非负整数: %q", s)
+		}
+		importOptions.maxErrors = n
+	}
+	if s := yamlString(cfg, "batch_rows"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return fmt.Errorf("import.batch_rows 必须是正整数: %q", s)
+		}
+		importOptions.batchRows = n
+	}
+	if s := yamlString(cfg, "strict"); s != "" {
+		on, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("import.strict 必须是 true / false: %q", s)
+		}
+		if on {
+			setStrict()
+		}
+	}
+	if s := yamlString(cfg, "report"); s != "" {
+		importOptions.report = s
+	}
+	if s := yamlString(cfg, "mmap"); s != "" {
+		if importOptions.mmap, err = strconv.ParseBool(s); err != nil {
+			return fmt.Errorf("import.mmap 必须是 true / false: %q", s)
+		}
+	}
+	return setupValidationRules(configPath)
+}
+
+// 打开待解析的文件。大文件优先 mmap，data 为映射的内容 (否则为 nil)
+type csvInput struct {
+	r           *bufio.Reader
+	src         io.ReadSeeker // r 的数据来源 (文件或映射的内容)；不能定位的输入为 nil
+	data        []byte
+	headerLimit int
+	close       func()
+}
+
+// 包装任意输入 (文件之外的来源、测试数据)。缓冲至少能放下 header_limit，识别分隔符时才能一次看到
+func newCSVInput(r io.Reader, bufSize, headerLimit int) *csvInput {
+	in := &csvInput{r: bufio.NewReaderSize(r, max(bufSize, headerLimit)), headerLimit: headerLimit, close: func() {}}
+	in.src, _ = r.(io.ReadSeeker)
+	return in
+}
+
+// 跳到第 off 字节处继续读取 (丢弃 r 中已缓冲的数据)
+func (in *csvInput) seek(off int64) error {
+	if in.src == nil {
+		return errors.New("输入不支持定位")
+	}
+	if _, err := in.src.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	in.r.Reset(in.src)
+	return nil
+}
+
+func openCSVInput(path string) (*csvInput, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	readBuffer, fileBuffer, headerLimit := fileBufferOptions(path)
+	if st.Size() < importOptions.bigFile {
+		in := newCSVInput(f, fileBuffer, headerLimit)
+		in.close = func() { f.Close() }
+		return in, nil
+	}
+	if importOptions.mmap && st.Size() <= math.MaxInt {
+		data, unmap, err := mmapFile(f, int(st.Size()))
+		if err == nil {
+			in := newCSVInput(bytes.NewReader(data), readBuffer, headerLimit)
+			in.data, in.close = data, func() { unmap(); f.Close() }
+			return in, nil
+		}
+		log.Printf("[WARN] %s: 无法映射到内存 (%v)，改用缓冲读取", path, err)
+	}
+	in := newCSVInput(f, readBuffer, headerLimit)
+	in.close = func() { f.Close() }
+	return in, nil
+}
+
+// 不消耗数据，取出表头 (最多 limit 字节) 用于识别分隔符；truncated 表示表头超过了 limit。
+// 列名是含换行的引号字段时表头跨越多行，取到引号字段以外的第一个换行为止
+func peekHeaderLine(br *bufio.Reader, limit int) (line string, truncated bool) {
+	first, _ := br.Peek(min(limit, br.Size()))
+	for off := 0; ; {
+		k := bytes.IndexByte(first[off:], '\n')
+		if k < 0 {
+			break
+		}
+		if !scanCSVQuotes(string(first[:off+k]), func(byte) {}) {
+			return string(first[:off+k]), false
+		}
+		off += k + 1
+	}
+	return string(first), len(first) == min(limit, br.Size())
+}
+
+// 一块映射好的行；end 为块中最后一行之后在文件中的字节位置，lines 为各行的行号，
+// errs 为这一块中解析失败而跳过的记录
+type csvChunk struct {
+	rows  [][]any
+	end   int64
+	lines []int
+	errs  []*importError
+}
+
+// 一个文件的解析结果。chunks 关闭后其余字段才可读
+type parsedFile struct {
+	file    string
+	start   int64 // 从该字节位置开始解析 (断点续传，0 为从头开始；表头总是从文件开头读取)
+	chunks  chan csvChunk
+	skipped bool         // 打不开、没有表头，或表头不匹配
+	unknown bool         // newMapper 返回 nil (表头不匹配)
+	comma   rune         // 识别出的分隔符
+	records int          // 读到的数据行
+	short   int          // 列数不足被丢弃的行
+	debug   []string     // 本文件在写出任何一行之前遇到的第一条列数不足的记录
+	err     *importError // 打不开、读取失败等使整个文件 (或其余部分) 无法导入的错误
+}
+
+// 启动解析 goroutine，按文件顺序返回结果
+func parseCSVFiles(files []string, minCols int, newMapper func(header []string) rowMapper) []*parsedFile {
+	return parseCSVFilesFrom(files, nil, minCols, newMapper)
+}
+
+// 同 parseCSVFiles，starts 中有的文件从给定的字节位置开始解析
+func parseCSVFilesFrom(files []string, starts map[string]int64, minCols int, newMapper func(header []string) rowMapper) []*parsedFile {
+	out := make([]*parsedFile, len(files))
+	for k, f := range files {
+		out[k] = &parsedFile{file: f, start: starts[f], chunks: make(chan csvChunk, importFileChunks)}
+	}
+	// 按顺序分派，保证写入方等待的文件总是已经在解析中
+	jobs := make(chan *parsedFile)
+	go func() {
+		for _, pf := range out {
+			jobs <- pf
+		}
+		close(jobs)
+	}()
+	var wg sync.WaitGroup
+	for range importParseWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pf := range jobs {
+				parseCSVFile(pf, minCols, newMapper)
+			}
+		}()
+	}
+	return out
+}
+
+func parseCSVFile(pf *parsedFile, minCols int, newMapper func(header []string) rowMapper) {
+	defer close(pf.chunks)
+	in, err := openCSVInput(pf.file)
+	if err != nil {
+		pf.skipped, pf.err = true, &importError{at: rowPos{file: pf.file}, err: err}
+		return
+	}
+	defer in.close()
+	parseCSVInput(pf, in, minCols, newMapper)
+}
+
+// 从任意输入解析 CSV：识别分隔符、读表头、映射各行，返回全部映射好的行与解析情况 (skipped、short 等)。
+// 与文件导入走同一条路径 (parseCSVInput)，供测试与非文件来源使用
+func readCSV(r io.Reader, minCols int, newMapper func(header []string) rowMapper) ([][]any, *parsedFile) {
+	pf := &parsedFile{file: "<reader>", chunks: make(chan csvChunk, importFileChunks)}
+	go func() {
+		defer close(pf.chunks)
+		parseCSVInput(pf, newCSVInput(r, importOptions.fileBuffer, importOptions.headerLimit), minCols, newMapper)
+	}()
+	var rows [][]any
+	for c := range pf.chunks {
+		rows = append(rows, c.rows...)
+	}
+	return rows, pf
+}
+
+// 解析 in 的全部内容，按块发送到 pf.chunks (不关闭 channel)。
+// 解析失败的记录随所在的块发送，读取失败时其余部分无法导入，记在 pf.err
+func parseCSVInput(pf *parsedFile, in *csvInput, minCols int, newMapper func(header []string) rowMapper) {
+	fail := func(line int, err error) {
+		pf.err = &importError{at: rowPos{file: pf.file, line: line, from: pf.start}, err: err}
+	}
+	br := in.r
+	bom, err := skipBOM(br)
+	if err != nil {
+		pf.skipped = true
+		fail(0, err)
+		return
+	}
+
+	// 先读取第一行文本，看看哪个分隔符多
+	line, truncated := peekHeaderLine(br, in.headerLimit)
+	if truncated {
+		log.Printf("[WARN] %s: 表头超过 %s，只按前面部分识别分隔符 (可调大 import.header_limit)", pf.file, formatBytes(float64(in.headerLimit)))
+	}
+	if text, ok := decodeGBK(line); ok {
+		line = text // GBK 表头按字符识别分隔符 (见 profiles.go)
+	}
+	pf.comma = sniffDelimiter(line)
+
+	r := newRecordReader(br, pf.comma, importOptions.csv)
+	header, err := r.Read()
+	if err == io.EOF {
+		pf.skipped = true
+		if importOptions.strict {
+			fail(0, errors.New("空文件"))
+			return
+		}
+		log.Printf("[WARN] %s: 空文件，已跳过", pf.file)
+		return
+	}
+	if err != nil {
+		pf.skipped = true
+		fail(1, fmt.Errorf("读取表头失败: %w", err))
+		return
+	}
+	mapper := newMapper(header)
+	if mapper == nil {
+		pf.skipped, pf.unknown = true, true
+		if importOptions.strict {
+			fail(1, fmt.Errorf("表头不匹配: %s", strings.Join(header, string(pf.comma))))
+		}
+		return
+	}
+	// 续传：读过表头后跳到上次提交的位置，换一个从该处开始计数的解析器
+	base := int64(bom)
+	if pf.start > base+r.Offset() {
+		if err := in.seek(pf.start); err != nil {
+			pf.skipped = true
+			fail(0, fmt.Errorf("无法跳到第 %d 字节: %w", pf.start, err))
+			return
+		}
+		r, base = newRecordReader(br, pf.comma, importOptions.csv), pf.start
+	}
+
+	arena := &rowArena{}
+	chunk := csvChunk{rows: make([][]any, 0, importChunkRows)}
+	send := func() {
+		chunk.end = base + r.Offset()
+		pf.chunks <- chunk
+		chunk = csvChunk{rows: make([][]any, 0, importChunkRows)}
+	}
+	// --strict 时列数不足、被映射函数拒绝的行也作为错误 (否则只计数)
+	reject := func(fields [][]byte, reason string) {
+		if importOptions.strict {
+			chunk.errs = append(chunk.errs, &importError{rowPos{file: pf.file, line: r.Line(), from: pf.start}, strings.Join(fieldStrings(fields), " | "), errors.New(reason)})
+		}
+	}
+	mapped := false
+	for {
+		fields, err := r.ReadFields()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// 引号等格式错误只影响这一条记录；其他错误 (I/O) 之后的内容都读不到了
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				chunk.errs = append(chunk.errs, &importError{at: rowPos{file: pf.file, line: r.Line(), from: pf.start}, err: err})
+				continue
+			}
+			fail(r.Line(), fmt.Errorf("读取失败: %w", err))
+			break
+		}
+		pf.records++
+		if len(fields) < minCols {
+			pf.short++
+			if !mapped && pf.debug == nil {
+				pf.debug = fieldStrings(fields)
+			}
+			reject(fields, fmt.Sprintf("列数不足 (%d 列, 需要 %d 列)", len(fields), minCols))
+			continue
+		}
+		args := mapper(arena, fields)
+		if args == nil {
+			reject(fields, "无效的行 (列数不足或代码、日期为空)")
+			continue
+		}
+		if len(args) == 0 {
+			continue // filteredRow
+		}
+		mapped = true
+		chunk.rows = append(chunk.rows, args)
+		chunk.lines = append(chunk.lines, r.Line())
+		if len(chunk.rows) == importChunkRows {
+			send()
+			arena.reset()
+		}
+	}
+	if len(chunk.rows) > 0 || len(chunk.errs) > 0 {
+		send()
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 时点对齐 (point-in-time)
+// ---------------------------------------------------------
+// 季度数据 (股东户数、财报等) 只有在公告之后才能被使用。每行保存 avail_date (可用日期)：
+// 有公告日期时即公告日；缺失时按法定披露截止日保守估计——宁可晚用，也不能提前用。
+// 对齐到日频时，取当天已公告的最新一期 (按报告期)，等价于按公告日前向填充。
+
+// 报告期 -> 法定披露截止日：一季报 4/30、半年报 8/31、三季报 10/31、年报次年 4/30
+func disclosureDeadline(period string) string {
+	if len(period) != 10 {
+		return period
+	}
+	year := period[:4]
+	switch period[5:] {
+	case "03-31":
+		return year + "-04-30"
+	case "06-30":
+		return year + "-08-31"
+	case "09-30":
+		return year + "-10-31"
+	case "12-31":
+		y, err := strconv.Atoi(year)
+		if err != nil {
+			return period
+		}
+		return strconv.Itoa(y+1) + "-04-30"
+	}
+	return period
+}
+
+// 可用日期：公告日优先，缺失时取披露截止日
+func availDate(period, announce any) any {
+	if announce != nil {
+		return announce
+	}
+	if p, ok := period.(string); ok {
+		return disclosureDeadline(p)
+	}
+	return nil
+}
+
+// 可时点对齐的季度字段：因子名 -> (表, 列/表达式)
+type pitField struct {
+	Table string
+	Expr  string
+}
+
+var pitFields = map[string]pitField{}
+
+func registerPITField(name, table, expr string) {
+	pitFields[name] = pitField{Table: table, Expr: expr}
+}
+
+// 产出 (symbol, date, value) 的子查询：把 table 中的季度值按可用日期前向填充到 stock_history 的每个交易日。
+// table 需要有 symbol、report_period、announce_date、avail_date 列；
+// 同一报告期有更正公告时，取当天已可见的最新版本。
+func pitSource(table, expr string) string {
+	return fmt.Sprintf(`SELECT h.symbol, h.date,
+		(SELECT %s FROM %s t
+			WHERE t.symbol = h.symbol AND t.avail_date <= h.date
+			ORDER BY t.report_period DESC, t.announce_date DESC LIMIT 1) AS value
+	FROM stock_history h`, expr, table)
+}
+
+// ---------------------------------------------------------
+// 财务数据时点视图
+// ---------------------------------------------------------
+// v_pit_fundamentals：stock_history 的每个 (symbol, date) 上，各张财务报表当天已公告的最新一期。
+// 只按公告日 (avail_date) 对齐，绝不按报告期末对齐——后者是回测中最常见的未来函数。
+//
+//	SELECT date, symbol, net_profit / NULLIF(total_equity, 0) AS roe
+//	FROM v_pit_fundamentals WHERE date = '2024-01-02'
+//
+// 各报表的字段在 financials.yaml 中配置 (见 financials.go)。
+
+type finTable struct {
+	Name   string   // fin_income
+	Fields []string // 数值列
+}
+
+// 库中已有的财务报表及其字段
+func finTables(db *sql.DB) ([]finTable, error) {
+	rows, err := db.Query(`SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name LIKE 'fin\_%' ESCAPE '\' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	rows.Close()
+
+	fixed := map[string]bool{"symbol": true, "report_period": true, "announce_date": true, "avail_date": true}
+	var out []finTable
+	for _, n := range names {
+		cols, err := db.Query("SELECT name FROM pragma_table_info(?)", n)
+		if err != nil {
+			return nil, err
+		}
+		t := finTable{Name: n}
+		for cols.Next() {
+			var c string
+			if err := cols.Scan(&c); err != nil {
+				cols.Close()
+				return nil, err
+			}
+			if !fixed[c] {
+				t.Fields = append(t.Fields, c)
+			}
+		}
+		cols.Close()
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// 生成 v_pit_fundamentals 的定义；字段重名时后出现的报表加表名前缀 (balance_xxx)
+func pitViewSQL(tables []finTable) string {
+	selects := []string{"h.symbol", "h.date"}
+	var joins []string
+	used := map[string]bool{"symbol": true, "date": true}
+	for k, t := range tables {
+		alias := fmt.Sprintf("f%d", k)
+		stmt := strings.TrimPrefix(t.Name, "fin_")
+		selects = append(selects, fmt.Sprintf("%s.report_period AS %s_period", alias, stmt))
+		for _, f := range t.Fields {
+			name := f
+			if used[name] {
+				name = stmt + "_" + f
+			}
+			used[name] = true
+			selects = append(selects, fmt.Sprintf("%s.%s AS %s", alias, f, name))
+		}
+		joins = append(joins, fmt.Sprintf(`LEFT JOIN %[1]s %[2]s ON %[2]s.symbol = h.symbol
+		AND (%[2]s.report_period, %[2]s.announce_date) = (
+			SELECT t.report_period, t.announce_date FROM %[1]s t
+			WHERE t.symbol = h.symbol AND t.avail_date <= h.date
+			ORDER BY t.report_period DESC, t.announce_date DESC LIMIT 1)`, t.Name, alias))
+	}
+	return fmt.Sprintf("CREATE VIEW v_pit_fundamentals AS\n\tSELECT %s\n\tFROM stock_history h\n\t%s;",
+		strings.Join(selects, ", "), strings.Join(joins, "\n\t"))
+}
+
+// 重建时点视图；还没有导入任何财务报表时不创建
+func createPITView(db *sql.DB) {
+	tables, err := finTables(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mustExec(db, "DROP VIEW IF EXISTS v_pit_fundamentals;")
+	if len(tables) == 0 {
+		return
+	}
+	mustExec(db, pitViewSQL(tables))
+}
+
+// 财务字段也可以直接当因子名使用 (如 --factor net_profit)：
+// 在库中的财务报表里查找该列，找到则注册为时点字段
+func registerFinField(db *sql.DB, name string) bool {
+	tables, err := finTables(db)
+	if err != nil {
+		return false
+	}
+	for _, t := range tables {
+		for _, f := range t.Fields {
+			if f == name {
+				registerPITField(name, t.Name, f)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// chronos pit [--materialize]：重建时点视图，可选物化为 pit_fundamentals 表
+func runPIT(args []string) {
+	fs := flag.NewFlagSet("pit", flag.ExitOnError)
+	materialize := fs.Bool("materialize", false, "把 v_pit_fundamentals 物化为 pit_fundamentals 表 (查询快但占空间)")
+	fs.Parse(args)
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+
+	tables, err := finTables(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(tables) == 0 {
+		log.Fatal("[ERROR] 库中没有财务报表，请先配置 financials.yaml 并执行导入")
+	}
+	createPITView(db)
+	for _, t := range tables {
+		log.Printf(">>> %s: %s", t.Name, strings.Join(t.Fields, ", "))
+	}
+
+	if *materialize {
+		log.Println(">>> 正在物化 pit_fundamentals...")
+		mustExec(db, "BEGIN TRANSACTION;")
+		mustExec(db, "DROP TABLE IF EXISTS pit_fundamentals;")
+		mustExec(db, "CREATE TABLE pit_fundamentals AS SELECT * FROM v_pit_fundamentals;")
+		mustExec(db, "CREATE UNIQUE INDEX idx_pit_fundamentals ON pit_fundamentals(symbol, date);")
+		mustExec(db, "COMMIT;")
+	}
+	log.Printf(">>> ✅ 时点视图已重建, 耗时: %s", time.Since(start))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+)
+
+// ---------------------------------------------------------
+// 性能剖析
+// ---------------------------------------------------------
+// 写在子命令之前的全局参数，用于定位导入等长时间任务的性能问题：
+//
+//	chronos --cpuprofile cpu.out --memprofile mem.out import --merge sort
+//	chronos --pprof localhost:6060 update files
+//	go tool pprof -http :8080 cpu.out
+//
+// profile 文件在子命令正常结束时写入 (因错误退出时不写)。--pprof 在运行期间提供 /debug/pprof/，
+// 可随时抓取 CPU、堆与 goroutine 信息。
+
+var (
+	cpuProfile = flag.String("cpuprofile", "", "CPU profile 输出文件")
+	memProfile = flag.String("memprofile", "", "结束时的堆 profile 输出文件")
+	pprofAddr  = flag.String("pprof", "", "运行期间在该地址提供 /debug/pprof/ (如 localhost:6060)")
+)
+
+// 按全局参数开始剖析，返回结束时调用的函数
+func startProfiling() func() {
+	if *pprofAddr != "" {
+		// 单独的 mux，不把调试接口挂到其他服务上
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		go func() {
+			log.Printf(">>> pprof: http://%s/debug/pprof/", *pprofAddr)
+			if err := http.ListenAndServe(*pprofAddr, mux); err != nil {
+				log.Printf("[WARN] pprof 监听失败: %v", err)
+			}
+		}()
+	}
+
+	var cpu *os.File
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		if err := rpprof.StartCPUProfile(f); err != nil {
+			log.Fatalf("[ERROR] 无法开始 CPU profile: %v", err)
+		}
+		cpu = f
+	}
+
+	return func() {
+		if cpu != nil {
+			rpprof.StopCPUProfile()
+			cpu.Close()
+			log.Printf(">>> CPU profile 已写入 %s", *cpuProfile)
+		}
+		if *memProfile != "" {
+			f, err := os.Create(*memProfile)
+			if err != nil {
+				log.Printf("[WARN] %v", err)
+				return
+			}
+			defer f.Close()
+			runtime.GC() // 统计到最近一次 GC 为止的存活对象
+			if err := rpprof.WriteHeapProfile(f); err != nil {
+				log.Printf("[WARN] 写入堆 profile 失败: %v", err)
+				return
+			}
+			log.Printf(">>> 堆 profile 已写入 %s", *memProfile)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// ---------------------------------------------------------
+// 表头指纹与列布局
+// ---------------------------------------------------------
+// 每个 CSV 读完表头后计算指纹 (列名去空白、转小写后的哈希)，按指纹选择列布局，而不是一律按列号假设
+// 技术因子 19 列、每日指标 15 列。内置布局包括 chronos mock 生成的文件与各终端导出格式 (vendor.go)；
+// 其他文件可以在 sources.yaml 中登记：
+//
+//	profiles:
+//	  my-tech:
+//	    fingerprint: 3f2a9c1d0b7e    # 导入时未知表头的警告里会打印指纹；也可以写 header: 代码,日期,...
+//	    table: staging_tech          # staging_tech / staging_daily / stock_history
+//	    encoding: GBK                # 表头为 GBK (GB18030) 编码，先解码再计算指纹
+//	    columns:
+//	      symbol: 0
+//	      date: 1
+//	      close_raw: 2
+//	      close_adj: 5
+//
+// columns 的键为目标表的列 (stock_history 用 vendor 的字段名: symbol, date, open, high, low, close,
+// adj_close, adj_factor, volume, pe，另可设 volume_unit 与 null)。分隔符 (逗号、Tab、分号、竖线) 自动识别。
+// 指纹未知时打印警告；列数足够时仍按原有默认布局导入，否则跳过该文件。
+//
+// 终端导出的文件通常是 GBK 编码 (没有 BOM)。表头不是合法 UTF-8 时按 GB18030 (GBK 的超集) 解码后再识别
+// 分隔符 (parseCSVInput)，与声明了 encoding: GBK 的布局比对解码后的指纹；指纹写在 header 中时按 UTF-8 填写即可。
+// 数据行不转码：GBK 的多字节字符不含逗号、Tab、分号、引号与换行，按字节切分不受影响，证券名称等文本列
+// 按位置跳过、不入库。分隔符为竖线时，个别汉字的第二个字节与竖线相同，这样的文件请先转成 UTF-8。
+
+type headerProfile struct {
+	Name        string
+	Table       string
+	Fingerprint string
+	Encoding    string
+	MinCols     int
+	Mapper      rowMapper
+}
+
+// 各 staging 表的列顺序 (与 createTables 一致)
+var stagingColumns = map[string][]string{
+	"staging_tech":  {"symbol", "date", "close_raw", "close_adj", "open_adj", "high_adj", "low_adj", "volume"},
+	"staging_daily": {"symbol", "date", "pe"},
+}
+
+func headerFingerprint(header []string) string {
+	parts := make([]string, len(header))
+	for k, h := range header {
+		if k == 0 {
+			h = strings.TrimPrefix(h, "\ufeff")
+		}
+		parts[k] = strings.ToLower(strings.Join(strings.Fields(h), ""))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// encoding 是否为 GBK 系列 (GBK / GB18030 / GB2312 / CP936，不区分大小写)
+func isGBKEncoding(encoding string) bool {
+	switch strings.ToUpper(strings.ReplaceAll(encoding, "-", "")) {
+	case "GBK", "GB18030", "GB2312", "CP936":
+		return true
+	}
+	return false
+}
+
+// s 不是合法 UTF-8 时按 GB18030 解码，ok 为 true；已是 UTF-8 时原样返回
+func decodeGBK(s string) (out string, ok bool) {
+	if utf8.ValidString(s) {
+		return s, false
+	}
+	out, err := simplifiedchinese.GB18030.NewDecoder().String(s)
+	if err != nil {
+		return s, false
+	}
+	return out, true
+}
+
+// 逐列解码表头 (见 decodeGBK)；表头已是 UTF-8 时 ok 为 false
+func decodeGBKHeader(header []string) (out []string, ok bool) {
+	out = make([]string, len(header))
+	for k, h := range header {
+		d, decoded := decodeGBK(h)
+		out[k], ok = d, ok || decoded
+	}
+	return out, ok
+}
+
+// 按列号映射到 staging 表 (cols: 目标列 -> 源列号，缺少的列为 NULL)
+func stagingMapper(table string, cols map[string]int) (rowMapper, int, error) {
+	targets, ok := stagingColumns[table]
+	if !ok {
+		return nil, 0, fmt.Errorf("不支持的目标表: %s", table)
+	}
+	minCols := 0
+	for name, k := range cols {
+		if !containsString(targets, name) {
+			return nil, 0, fmt.Errorf("%s 没有 %s 列", table, name)
+		}
+		minCols = max(minCols, k+1)
+	}
+	if _, ok := cols["symbol"]; !ok {
+		return nil, 0, fmt.Errorf("缺少 symbol 列")
+	}
+	if _, ok := cols["date"]; !ok {
+		return nil, 0, fmt.Errorf("缺少 date 列")
+	}
+	return func(a *rowArena, fields [][]byte) []any {
+		if len(fields) < minCols {
+			return nil
+		}
+		row := a.row(len(targets))
+		for i, name := range targets {
+			k, ok := cols[name]
+			switch {
+			case !ok:
+			case name == "symbol" || name == "date":
+				row[i] = a.intern(fields[k])
+			default:
+				row[i] = normNumBytes(fields[k]) // 其余都是数值列，与 mapTechFactors 一样清洗
+			}
+		}
+		return row
+	}, minCols, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func builtinHeaderProfiles() []headerProfile {
+	tech, daily := mockImportHeaders()
+	profiles := []headerProfile{
+		{Name: "chronos-mock-tech", Table: "staging_tech", Fingerprint: headerFingerprint(tech), MinCols: 19, Mapper: mapTechFactors},
+		{Name: "chronos-mock-daily", Table: "staging_daily", Fingerprint: headerFingerprint(daily), MinCols: 15, Mapper: mapDailyMetrics},
+	}
+	for _, p := range vendorProfiles {
+		profiles = append(profiles, headerProfile{Name: p.Name, Table: "stock_history", Fingerprint: headerFingerprint(strings.Split(p.Header, ",")),
+			Encoding: p.Encoding, MinCols: p.minCols(), Mapper: p.mapper})
+	}
+	return profiles
+}
+
+// 内置布局加上 sources.yaml 中 profiles 节登记的布局 (同指纹时用户布局优先)
+func loadHeaderProfiles(configPath string) ([]headerProfile, error) {
+	cfg, err := loadSourceConfig(configPath, "profiles")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(cfg))
+	for name := range cfg {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var profiles []headerProfile
+	for _, name := range names {
+		m, ok := cfg[name].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("profiles.%s 必须是映射", name)
+		}
+		p, err := userHeaderProfile(name, m)
+		if err != nil {
+			return nil, fmt.Errorf("profiles.%s: %v", name, err)
+		}
+		profiles = append(profiles, p)
+	}
+	return append(profiles, builtinHeaderProfiles()...), nil
+}
+
+func userHeaderProfile(name string, m map[string]any) (headerProfile, error) {
+	p := headerProfile{Name: name, Table: yamlString(m, "table"), Fingerprint: yamlString(m, "fingerprint"), Encoding: yamlString(m, "encoding")}
+	if p.Encoding != "" && !isGBKEncoding(p.Encoding) && !strings.EqualFold(strings.ReplaceAll(p.Encoding, "-", ""), "UTF8") {
+		return p, fmt.Errorf("不支持的 encoding: %q (可选 UTF-8、GBK)", p.Encoding)
+	}
+	if h := yamlString(m, "header"); h != "" && p.Fingerprint == "" {
+		p.Fingerprint = headerFingerprint(strings.Split(h, ","))
+	}
+	if p.Fingerprint == "" {
+		return p, fmt.Errorf("需要 fingerprint 或 header")
+	}
+	colMap, _ := m["columns"].(map[string]any)
+	cols := map[string]int{}
+	for key, v := range colMap {
+		s, _ := v.(string)
+		k, err := strconv.Atoi(s)
+		if err != nil || k < 0 {
+			return p, fmt.Errorf("columns.%s 必须是非负整数列号", key)
+		}
+		cols[key] = k
+	}
+
+	if p.Table != "stock_history" {
+		var err error
+		p.Mapper, p.MinCols, err = stagingMapper(p.Table, cols)
+		return p, err
+	}
+	// stock_history 复用终端导出格式的映射 (复权换算、空值标记、成交量单位)
+	v := vendorProfile{Name: name, Encoding: p.Encoding, NullMarkers: yamlStrings(m, "null"), VolumeUnit: 1}
+	c := &v.Columns
+	for key, dst := range map[string]*int{"symbol": &c.Symbol, "date": &c.Date, "open": &c.Open, "high": &c.High, "low": &c.Low,
+		"close": &c.Close, "adj_close": &c.AdjClose, "adj_factor": &c.AdjFactor, "volume": &c.Volume, "pe": &c.PE} {
+		*dst = -1
+		if k, ok := cols[key]; ok {
+			*dst = k
+			delete(cols, key)
+		}
+	}
+	for key := range cols {
+		return p, fmt.Errorf("stock_history 布局没有 %s 字段", key)
+	}
+	if c.Symbol < 0 || c.Date < 0 || c.Close < 0 {
+		return p, fmt.Errorf("至少需要 symbol、date、close 列")
+	}
+	if s := yamlString(m, "volume_unit"); s != "" {
+		u, err := strconv.ParseFloat(s, 64)
+		if err != nil || u <= 0 {
+			return p, fmt.Errorf("volume_unit 必须是正数: %q", s)
+		}
+		v.VolumeUnit = u
+	}
+	p.Mapper, p.MinCols = v.mapper, v.minCols()
+	return p, nil
+}
+
+// 生成 importCSVFiles 的 newMapper：按表头指纹选择 table 的布局。表头不是 UTF-8 时，
+// 声明为 GBK 的布局按解码后的指纹比对。未知指纹时警告 (同一指纹只警告一次)；
+// fallback 不为空且列数足够时按它导入，否则跳过该文件
+func profileMapper(profiles []headerProfile, table string, fallback rowMapper, fallbackCols int) func(header []string) rowMapper {
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	return func(header []string) rowMapper {
+		fp := headerFingerprint(header)
+		decoded, gbk := decodeGBKHeader(header)
+		gbkFP := ""
+		if gbk {
+			gbkFP = headerFingerprint(decoded)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, p := range profiles {
+			if p.Table == table && (p.Fingerprint == fp || gbk && isGBKEncoding(p.Encoding) && p.Fingerprint == gbkFP) {
+				if !seen[fp] {
+					log.Printf(">>> 表头指纹 %s -> 布局 %s", p.Fingerprint, p.Name)
+					seen[fp] = true
+				}
+				return p.Mapper
+			}
+		}
+		if !seen[fp] {
+			seen[fp] = true
+			if gbk {
+				log.Printf("[WARN] 未知表头 (GBK 编码, 指纹 %s, %d 列): %s；登记时写 encoding: GBK", gbkFP, len(header), strings.Join(decoded, ","))
+			} else {
+				log.Printf("[WARN] 未知表头 (指纹 %s, %d 列): %s", fp, len(header), strings.Join(header, ","))
+			}
+			if fallback != nil && len(header) >= fallbackCols {
+				log.Printf("[WARN] 按默认布局导入 %s；确认无误后可在 sources.yaml 的 profiles 中登记该指纹", table)
+			}
+		}
+		if fallback != nil && len(header) >= fallbackCols {
+			return fallback
+		}
+		return nil
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+// 读取 path，按 newMapper 解析 (同导入)
+func readCSVFile(t *testing.T, path string, minCols int, newMapper func([]string) rowMapper) ([][]any, *parsedFile) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return readCSV(f, minCols, newMapper)
+}
+
+// GBK 编码的表头解码后识别分隔符、计算指纹；只有声明了 encoding: GBK 的布局才按解码后的指纹匹配
+func TestGBKHeaderProfile(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	p, err := userHeaderProfile("gbk-daily", map[string]any{
+		"header":   "证券代码,证券简称,交易日期,市盈率TTM",
+		"table":    "staging_daily",
+		"encoding": "GBK",
+		"columns":  map[string]any{"symbol": "0", "date": "2", "pe": "3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const fixture = "testdata/profiles/gbk-daily.tsv"
+	rows, pf := readCSVFile(t, fixture, 2, profileMapper([]headerProfile{p}, "staging_daily", nil, 0))
+	if pf.skipped || pf.comma != '\t' || len(rows) != 2 {
+		t.Fatalf("skipped=%v comma=%q rows=%d, want 按 Tab 分隔读出 2 行", pf.skipped, pf.comma, len(rows))
+	}
+	if got, want := formatRowValues(rows[0]), "600000.SH | 20240102 | 5.12"; got != want {
+		t.Errorf("第一行 %q, want %q", got, want)
+	}
+
+	p.Encoding = ""
+	if _, pf := readCSVFile(t, fixture, 2, profileMapper([]headerProfile{p}, "staging_daily", nil, 0)); !pf.unknown {
+		t.Error("没有声明 GBK 的布局不应按解码后的指纹匹配")
+	}
+	if _, err := userHeaderProfile("x", map[string]any{"header": "a,b", "table": "staging_daily", "encoding": "Big5"}); err == nil {
+		t.Error("不支持的 encoding 应当报错")
+	}
+}
+
+// 终端导出的 GBK 文件不指定 --profile 时按解码后的表头自动识别 (chronos vendor)
+func TestVendorGBKAutoDetect(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	rows, pf := readCSVFile(t, "testdata/vendor/wind-daily-gbk.csv", 2, profileMapper(builtinHeaderProfiles(), "stock_history", nil, 0))
+	if pf.skipped {
+		t.Fatal("GBK 表头没有识别为 wind-daily")
+	}
+	// 末尾的说明行因日期无法解析被跳过；复权开高低按 后复权收盘 / 收盘 换算
+	want := []string{
+		"600000.SH | 2024-01-02 | 7.05 | 70.5 | 70 | 71 | 69.5 | 5.1 | 1e+06 | CN",
+		"600000.SH | 2024-01-03 | 7.1 | 71 | 70.5 | 72 | 70 | NULL | 800000 | CN",
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("%d 行, want %d", len(rows), len(want))
+	}
+	for k, r := range rows {
+		if got := formatRowValues(r); got != want[k] {
+			t.Errorf("第 %d 行 %q, want %q", k+1, got, want[k])
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 逐只股票的数据质量评分
+// ---------------------------------------------------------
+// 构建股票池时要能自动剔除数据不可靠的股票 (供应商历史断档、大段缺失、价格错乱)。import 与 update
+// 结束时重建 symbol_quality，每只股票一行：
+//
+//	coverage         覆盖率：实有行数 / 所在市场在其起止日期之间的交易日数
+//	null_rate        价格列 (qualityNullColumns) 的平均缺失率；PE 亏损时本来就为空，不计入
+//	anomalies        异常行数 (见 historyAnomalyChecks)，ohlc_violations 为其中 OHLC 不一致的行数
+//	score            0~100 = 100 × coverage × (1 - null_rate) × max(0, 1 - 10 × 异常行占比)
+//
+// 异常行占比超过 10% 即为 0 分。ic / quantile 的 --min-quality 按此过滤，Go 中用 Store.QualitySymbols：
+//
+//	chronos ic --factor pe --min-quality 80
+//
+// 覆盖率依赖全市场的交易日 (新的交易日会让没有更新的股票覆盖率下降)，所以每次都整表重建，
+// 与数据质量报告 (report.go) 共用一次扫描。
+
+// 计入 null_rate 的列
+var qualityNullColumns = []string{"close", "close_adj", "open_adj", "high_adj", "low_adj"}
+
+func ensureSymbolQualityTable(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS symbol_quality (
+		symbol          TEXT NOT NULL PRIMARY KEY,
+		market          TEXT NOT NULL,
+		first_date      TEXT NOT NULL,
+		last_date       TEXT NOT NULL,
+		rows            INTEGER NOT NULL,
+		expected_days   INTEGER NOT NULL,  -- 所在市场在起止日期之间的交易日数
+		coverage        REAL NOT NULL,
+		null_rate       REAL NOT NULL,
+		anomalies       INTEGER NOT NULL,
+		ohlc_violations INTEGER NOT NULL,
+		score           REAL NOT NULL,     -- 0~100
+		updated_at      TEXT NOT NULL      -- 本地时间
+	) WITHOUT ROWID, STRICT;`)
+}
+
+// 一只股票的质量
+type symbolQuality struct {
+	SymbolStats
+	expected  int
+	anomalies []int // 各项异常检查的行数 (与 historyAnomalyChecks 对应)
+}
+
+func (q *symbolQuality) coverage() float64 {
+	return min(coverageRate(q.Rows, q.expected), 1)
+}
+
+func (q *symbolQuality) nullRate() float64 {
+	sum := 0.0
+	for _, c := range qualityNullColumns {
+		sum += q.NullRate(c)
+	}
+	return sum / float64(len(qualityNullColumns))
+}
+
+func (q *symbolQuality) anomalyRows() int {
+	n := 0
+	for _, k := range q.anomalies {
+		n += k
+	}
+	return n
+}
+
+func (q *symbolQuality) score() float64 {
+	penalty := 0.0
+	if q.Rows > 0 {
+		penalty = 10 * float64(q.anomalyRows()) / float64(q.Rows)
+	}
+	return 100 * q.coverage() * (1 - q.nullRate()) * max(0, 1-penalty)
+}
+
+// 计算全部股票的质量 (history_stats 与一遍 stock_history 的扫描)；anomalies 中保留各项检查的样本，供报告使用
+func scanSymbolQuality(db *sql.DB) ([]*symbolQuality, *historyAnomalies, error) {
+	stats, err := newStore(db).SymbolStats(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	expected := expectedTradingDays(db, stats)
+	anomalies, err := scanHistoryAnomalies(db, reportSamples)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := make([]*symbolQuality, len(stats))
+	for k, s := range stats {
+		q := &symbolQuality{SymbolStats: s, expected: expected[k], anomalies: anomalies.bySymbol[s.Symbol]}
+		if q.anomalies == nil {
+			q.anomalies = make([]int, len(historyAnomalyChecks))
+		}
+		out[k] = q
+	}
+	return out, anomalies, nil
+}
+
+// 整表重建 symbol_quality
+func saveSymbolQuality(db *sql.DB, quality []*symbolQuality) error {
+	ensureSymbolQualityTable(db)
+	now := time.Now().Format(time.DateTime)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM symbol_quality"); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT INTO symbol_quality VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, q := range quality {
+		if _, err := stmt.Exec(q.Symbol, q.Market, q.FirstDate, q.LastDate, q.Rows, q.expected, q.coverage(), q.nullRate(),
+			q.anomalyRows(), q.anomalies[anomalyOHLC], q.score(), now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// 运行结束时重建评分并返回扫描结果 (报告使用)；评分不影响数据，失败时提示后继续，返回 nil
+func refreshSymbolQuality(db *sql.DB) ([]*symbolQuality, *historyAnomalies) {
+	start := time.Now()
+	if !hasHistoryStats(db) {
+		mustRefreshHistoryStats(db, nil)
+	}
+	quality, anomalies, err := scanSymbolQuality(db)
+	if err == nil {
+		err = saveSymbolQuality(db, quality)
+	}
+	if err != nil {
+		log.Printf("[WARN] 计算数据质量评分失败: %v", err)
+		return nil, nil
+	}
+	low := 0
+	for _, q := range quality {
+		if q.coverage() < reportLowCoverage || q.anomalyRows() > 0 {
+			low++
+		}
+	}
+	log.Printf(">>> 已计算 %d 只股票的数据质量评分 (覆盖率低或有异常行的 %d 只，见 symbol_quality), 耗时: %s",
+		len(quality), low, time.Since(start).Round(time.Millisecond))
+	return quality, anomalies
+}
+
+// 过滤条件：alias 表的 symbol 的质量评分不低于 minScore；minScore <= 0 时不过滤。没有评分的股票被剔除
+func qualityCond(minScore float64, alias string) string {
+	if minScore <= 0 {
+		return "1=1"
+	}
+	return fmt.Sprintf("%s.symbol IN (SELECT symbol FROM symbol_quality WHERE score >= %g)", alias, minScore)
+}
+
+// 质量评分不低于 minScore 的股票 (按代码排序)；还没有评分表时返回错误
+func (s *Store) QualitySymbols(ctx context.Context, minScore float64) ([]string, error) {
+	return s.queryStrings(ctx, "SELECT symbol FROM symbol_quality WHERE score >= ? ORDER BY symbol", minScore)
+}
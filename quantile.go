@@ -0,0 +1,270 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 分层回测：每个调仓日按因子值把股票分成 N 组，
+// 持有到下一个调仓日，计算各组 (等权或市值加权) 收益及多空组合 (最高组 - 最低组)。
+// 结果写入 quantile_returns 表，同时输出 CSV 和净值曲线 SVG。
+func runQuantile(args []string) {
+	fs := flag.NewFlagSet("quantile", flag.ExitOnError)
+	factor := fs.String("factor", "pe", "因子名 (stock_history 列名或 factor_values 中的因子名)")
+	universe := fs.String("universe", "", "股票池：指数代码 (按当日成分股过滤，如 000300.SH) 或 tag:<概念>")
+	minListing := fs.Int("min-listing-days", 0, "剔除上市不足 N 个自然日的新股 (需导入新股发行信息)")
+	minQuality := fs.Float64("min-quality", 0, "剔除数据质量评分 (0~100，见 quality.go) 低于该值的股票")
+	groups := fs.Int("groups", 5, "分组数")
+	rebalance := fs.Int("rebalance", 20, "调仓间隔 (交易日)")
+	weight := fs.String("weight", "equal", "组内加权方式: equal | cap")
+	capFactor := fs.String("cap-factor", "", "市值加权时使用的市值因子名 (如 free_float_cap)")
+	market := fs.String("market", MarketCN, "市场: CN | HK | US (决定交易日历和股票范围)")
+	out := fs.String("out", "", "输出文件前缀 (默认 quantile_<factor>)，生成 .csv 和 .svg")
+	fs.Parse(args)
+
+	if *groups < 2 || *rebalance <= 0 {
+		log.Fatalf("[ERROR] 参数非法: groups=%d rebalance=%d", *groups, *rebalance)
+	}
+	if *weight != "equal" && *weight != "cap" {
+		log.Fatalf("[ERROR] 未知加权方式: %s", *weight)
+	}
+	if *weight == "cap" && *capFactor == "" {
+		log.Fatal("[ERROR] 市值加权需要指定 --cap-factor")
+	}
+	if *out == "" {
+		*out = "quantile_" + *factor
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+	mustHaveFactor(db, *factor)
+
+	// 调仓日：交易日历上每隔 rebalance 天取一天，持有到下一个调仓日；
+	// 远期收益按同一日历计算，停牌股票也比较同一持有窗口
+	dates := tradingDates(db, *market)
+	var rebDates []string
+	for k := 0; k < len(dates); k += *rebalance {
+		rebDates = append(rebDates, sqlQuote(dates[k]))
+	}
+	if len(rebDates) == 0 {
+		log.Fatal("[ERROR] stock_history 为空")
+	}
+
+	capCol, capJoin := "NULL", ""
+	if *weight == "cap" {
+		mustHaveFactor(db, *capFactor)
+		capCol = "c.value"
+		capJoin = fmt.Sprintf("INNER JOIN (%s) c ON c.symbol = f.symbol AND c.date = f.date", factorSource(*capFactor))
+	}
+
+	log.Printf(">>> 正在分层回测: 因子 %s, %d 组, 每 %d 天调仓, %s 加权", *factor, *groups, *rebalance, *weight)
+	query := fmt.Sprintf(`
+	SELECT f.date, f.value, r.value, %s
+	FROM (%s) f
+	INNER JOIN (%s) r ON r.symbol = f.symbol AND r.date = f.date
+	%s
+	WHERE f.date IN (%s) AND f.value IS NOT NULL AND r.value IS NOT NULL AND %s AND %s AND %s AND %s = %s
+	ORDER BY f.date`, capCol, factorSource(*factor), calendarReturnSource(calendarPeriods(dates, *rebalance, *rebalance)), capJoin, strings.Join(rebDates, ","),
+		universeCond(*universe, "f"), listingCond(*minListing, "f"), qualityCond(*minQuality, "f"), marketSQL("f.symbol"), sqlQuote(*market))
+
+	rows, err := db.Query(query)
+	if err != nil {
+		log.Fatalf("SQL Error: %v | Query: %s", err, query)
+	}
+
+	var periods []string
+	var returns [][]float64 // [期][组]，最后一列为多空
+	var curDate string
+	var cross []quantileObs
+	flush := func() {
+		if curDate == "" || len(cross) < *groups {
+			return
+		}
+		periods = append(periods, curDate)
+		returns = append(returns, quantileReturns(cross, *groups))
+	}
+	for rows.Next() {
+		var date string
+		var o quantileObs
+		var cp *float64
+		if err := rows.Scan(&date, &o.value, &o.ret, &cp); err != nil {
+			log.Fatal(err)
+		}
+		o.weight = 1
+		if *weight == "cap" {
+			if cp == nil || *cp <= 0 {
+				continue
+			}
+			o.weight = *cp
+		}
+		if date != curDate {
+			flush()
+			curDate, cross = date, cross[:0]
+		}
+		cross = append(cross, o)
+	}
+	flush()
+	rows.Close()
+
+	if len(periods) == 0 {
+		log.Fatal("[ERROR] 没有可用的调仓期 (样本不足或远期收益为空)")
+	}
+
+	buckets := make([]string, *groups+1)
+	for g := 0; g < *groups; g++ {
+		buckets[g] = fmt.Sprintf("Q%d", g+1)
+	}
+	buckets[*groups] = "LS"
+
+	// 累计净值
+	navs := make([][]float64, len(periods))
+	nav := make([]float64, len(buckets))
+	for g := range nav {
+		nav[g] = 1
+	}
+	for p := range periods {
+		navs[p] = make([]float64, len(buckets))
+		for g := range buckets {
+			nav[g] *= 1 + returns[p][g]
+			navs[p][g] = nav[g]
+		}
+	}
+
+	saveQuantileReturns(db, *factor, periods, buckets, returns, navs)
+	writeQuantileCSV(*out+".csv", periods, buckets, returns, navs)
+
+	series := make([]chartSeries, len(buckets))
+	for g, name := range buckets {
+		series[g] = chartSeries{Name: name, Values: make([]float64, len(periods))}
+		for p := range periods {
+			series[g].Values[p] = navs[p][g]
+		}
+	}
+	title := fmt.Sprintf("%s 分层净值 (%d 组, %d 日调仓, %s)", *factor, *groups, *rebalance, *weight)
+	if err := writeLineChartSVG(*out+".svg", title, periods, series); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf(">>> ✅ 分层回测完成: %d 期, 耗时: %s", len(periods), time.Since(start))
+	for g, name := range buckets {
+		depth, longest := maxDrawdown(computeDrawdown(periods, series[g].Values))
+		log.Printf(">>> %-3s 累计净值: %.4f | 最大回撤: %.2f%% | 最长回撤: %d 期", name, nav[g], 100*depth, longest)
+	}
+}
+
+type quantileObs struct {
+	value, ret, weight float64
+}
+
+// 单个调仓日的分组收益，返回 groups+1 个值 (最后一个为多空)
+func quantileReturns(cross []quantileObs, groups int) []float64 {
+	sort.Slice(cross, func(a, b int) bool { return cross[a].value < cross[b].value })
+
+	sumW := make([]float64, groups)
+	sumR := make([]float64, groups)
+	for k, o := range cross {
+		g := k * groups / len(cross)
+		sumW[g] += o.weight
+		sumR[g] += o.weight * o.ret
+	}
+	out := make([]float64, groups+1)
+	for g := 0; g < groups; g++ {
+		if sumW[g] > 0 {
+			out[g] = sumR[g] / sumW[g]
+		} else {
+			out[g] = math.NaN()
+		}
+	}
+	out[groups] = out[groups-1] - out[0]
+	return out
+}
+
+func saveQuantileReturns(db *sql.DB, factor string, periods, buckets []string, returns, navs [][]float64) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS quantile_returns (
+		factor  TEXT NOT NULL,
+		date    TEXT NOT NULL,
+		bucket  TEXT NOT NULL,
+		ret     REAL,
+		nav     REAL,
+		PRIMARY KEY (factor, date, bucket)
+	) WITHOUT ROWID, STRICT;`)
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := tx.Exec("DELETE FROM quantile_returns WHERE factor = ?", factor); err != nil {
+		log.Fatal(err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO quantile_returns VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for p, date := range periods {
+		for g, b := range buckets {
+			if _, err := stmt.Exec(factor, date, b, returns[p][g], navs[p][g]); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func writeQuantileCSV(path string, periods, buckets []string, returns, navs [][]float64) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"date"}
+	for _, b := range buckets {
+		header = append(header, "ret_"+b)
+	}
+	for _, b := range buckets {
+		header = append(header, "nav_"+b)
+	}
+	for _, b := range buckets {
+		header = append(header, "dd_"+b)
+	}
+	w.Write(header)
+
+	drawdowns := make([][]DrawdownPoint, len(buckets))
+	for g := range buckets {
+		values := make([]float64, len(periods))
+		for p := range periods {
+			values[p] = navs[p][g]
+		}
+		drawdowns[g] = computeDrawdown(periods, values)
+	}
+	for p, date := range periods {
+		row := []string{date}
+		for g := range buckets {
+			row = append(row, formatFloat(returns[p][g]))
+		}
+		for g := range buckets {
+			row = append(row, formatFloat(navs[p][g]))
+		}
+		for g := range buckets {
+			row = append(row, formatFloat(drawdowns[g][p].Drawdown))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+}
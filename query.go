@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// 直接对数据库执行 SQL，结果以 CSV 输出 (可使用 udf.go 中的自定义函数)
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	out := fs.String("out", "", "输出文件 (默认标准输出)")
+	fs.Parse(args)
+
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		log.Fatal("[ERROR] 用法: chronos query [--out file.csv] \"SELECT ...\"")
+	}
+
+	db := openDB()
+	defer db.Close()
+
+	var dst io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		dst = f
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		log.Fatalf("SQL Error: %v | Query: %s", err, query)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := csv.NewWriter(dst)
+	w.Write(cols)
+
+	values := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for k := range values {
+		ptrs[k] = &values[k]
+	}
+	record := make([]string, len(cols))
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			log.Fatal(err)
+		}
+		for k, v := range values {
+			record[k] = formatSQLValue(v)
+		}
+		w.Write(record)
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatal(err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func formatSQLValue(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return formatFloat(x)
+	case []byte:
+		return string(x)
+	case string:
+		return x
+	default:
+		return fmt.Sprint(x)
+	}
+}
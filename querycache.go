@@ -0,0 +1,164 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 查询结果缓存
+// ---------------------------------------------------------
+// 研究笔记本反复请求同一段日线、同一天的截面，每次都要从 stock_history 重新读几千行。
+// OpenStore 打开的 Store 把 History / Symbols / CrossSection 的结果放进 LRU 缓存，
+// 键为方法与参数加上数据版本：
+//
+//	dataset_version   stock_history 每次变化 (合并 staging、直接导入、API 写入、全量导入) 后加一 (见 historyChanged)
+//
+// 读取前先查当前版本 (单行主键查询)，导入完成后旧版本的结果自然不再命中，随 LRU 淘汰。
+// 缓存容量按缓存的行数计 (StoreOptions.CacheRows)；返回的切片是副本，调用方可以修改。
+// newStore 包装的连接 (命令行子命令) 不缓存。
+
+func createDatasetVersionTable(db execer) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS dataset_version (
+		id         INTEGER PRIMARY KEY CHECK (id = 1),
+		version    INTEGER NOT NULL,
+		updated_at TEXT NOT NULL
+	) STRICT;`)
+	return err
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// 数据版本加一
+func bumpDatasetVersion(db execer) error {
+	if err := createDatasetVersionTable(db); err != nil {
+		return err
+	}
+	_, err := db.Exec(`INSERT INTO dataset_version VALUES (1, 1, ?1)
+		ON CONFLICT (id) DO UPDATE SET version = version + 1, updated_at = ?1`, time.Now().Format(time.DateTime))
+	return err
+}
+
+// stock_history 写入后调用：按校验规则检查 symbols (见 validate.go)，数据版本加一，
+// 并刷新 symbols 的统计 (nil 为整表重建；没有统计表时跳过，见 histstats.go)
+func historyChanged(db *sql.DB, symbols []string) {
+	validateHistory(db, symbols)
+	if err := bumpDatasetVersion(db); err != nil {
+		log.Printf("[WARN] 更新 dataset_version 失败: %v", err)
+	}
+	if symbols == nil || hasHistoryStats(db) {
+		mustRefreshHistoryStats(db, symbols)
+	}
+}
+
+var datasetVersionSQL = `SELECT version FROM dataset_version WHERE id = 1`
+
+// 当前数据版本；还没有版本表时为 0
+func (s *Store) datasetVersion(ctx context.Context) int64 {
+	var v int64
+	s.queryRow(ctx, datasetVersionSQL).Scan(&v)
+	return v
+}
+
+type cacheEntry struct {
+	key  string
+	val  any
+	rows int
+}
+
+// 按行数计容量的 LRU
+type resultCache struct {
+	mu       sync.Mutex
+	capacity int
+	rows     int
+	order    *list.List // 最近使用的在前
+	entries  map[string]*list.Element
+	hits     int64
+	misses   int64
+}
+
+func newResultCache(capacity int) *resultCache {
+	return &resultCache{capacity: capacity, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *resultCache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(e)
+	return e.Value.(*cacheEntry).val, true
+}
+
+// 超过容量的结果不缓存
+func (c *resultCache) put(key string, val any, rows int) {
+	rows = max(rows, 1)
+	if rows > c.capacity {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.rows -= e.Value.(*cacheEntry).rows
+		c.order.Remove(e)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, val, rows})
+	c.rows += rows
+	for c.rows > c.capacity {
+		e := c.order.Back()
+		ent := e.Value.(*cacheEntry)
+		c.order.Remove(e)
+		delete(c.entries, ent.key)
+		c.rows -= ent.rows
+	}
+}
+
+// 缓存命中情况：查询结果与预编译语句 (见 readpool.go)
+type CacheStats struct {
+	Entries, Rows int
+	Hits, Misses  int64
+
+	Statements int   // 已预编译的语句
+	StmtHits   int64 // 复用已编译语句的次数
+}
+
+func (s *Store) CacheStats() CacheStats {
+	s.mu.Lock()
+	st := CacheStats{Statements: len(s.stmts), StmtHits: s.stmtHits}
+	s.mu.Unlock()
+	if c := s.cache; c != nil {
+		c.mu.Lock()
+		st.Entries, st.Rows, st.Hits, st.Misses = len(c.entries), c.rows, c.hits, c.misses
+		c.mu.Unlock()
+	}
+	return st
+}
+
+// 以 (当前数据版本, key) 查缓存，未命中时调用 load 并放入缓存；没有缓存的 Store 直接 load
+func cachedRows[T any](ctx context.Context, s *Store, key string, load func() ([]T, error)) ([]T, error) {
+	if s.cache == nil {
+		return load()
+	}
+	key = fmt.Sprintf("%d|%s", s.datasetVersion(ctx), key)
+	if v, ok := s.cache.get(key); ok {
+		return slices.Clone(v.([]T)), nil
+	}
+	out, err := load()
+	if err != nil {
+		return nil, err
+	}
+	s.cache.put(key, out, len(out))
+	return slices.Clone(out), nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 不经 staging 直接写入日线 (vendor / tdx / metastock) 后，常驻 Store 的缓存失效、统计随之刷新
+func TestDirectImportInvalidatesCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "store.db")
+	registerUDFs()
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	createTables(db)
+	if _, err := refreshHistoryStats(db, nil); err != nil {
+		t.Fatal(err)
+	}
+	// tdx 日线: 代码, 日期, 开, 高, 低, 收, 量
+	read := func(string) ([][]string, error) {
+		return [][]string{{"600000.SH", "20240102", "10", "10.5", "9.8", "10.2", "1000"}}, nil
+	}
+	if _, err := importRecordFiles(db, []string{"600000.day"}, "stock_history", "ON CONFLICT DO NOTHING", read, mapTdxDayStock); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := OpenStore(path, StoreOptions{MaxConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	if bars, err := store.History(ctx, "600000.SH", DateRange{}); err != nil || len(bars) != 1 {
+		t.Fatalf("History = %d 行, %v, want 1", len(bars), err)
+	}
+
+	// MetaStock ASCII 经 importCSVFiles 写入下一天
+	csv := filepath.Join(dir, "600000.txt")
+	if err := os.WriteFile(csv, []byte("<TICKER>,<PER>,<DTYYYYMMDD>,<OPEN>,<HIGH>,<LOW>,<CLOSE>,<VOL>\n600000.SH,D,20240103,10.2,10.4,10.1,10.3,800\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	importCSVFiles(db, csv, "stock_history", "ON CONFLICT DO NOTHING", 2, recordMappers(newASCIIBarMapper("")))
+	if bars, err := store.History(ctx, "600000.SH", DateRange{}); err != nil || len(bars) != 2 {
+		t.Errorf("导入后 History = %d 行, %v, want 2 (缓存没有失效)", len(bars), err)
+	}
+	var rows int
+	var last string
+	db.QueryRow("SELECT rows, last_date FROM history_stats WHERE symbol = '600000.SH'").Scan(&rows, &last)
+	if rows != 2 || last != "2024-01-03" {
+		t.Errorf("history_stats = %d 行, 截至 %s, want 2 行截至 2024-01-03", rows, last)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 实时行情轮询
+// ---------------------------------------------------------
+// 按固定间隔抓取自选股的最新报价，追加写入 quotes_rt。ts 为行情时间 (UTC 时间戳，与 stock_minute 一致)，
+// 两次轮询之间行情没有更新时 (午休、停牌) 主键冲突，自动跳过，不会写入重复快照。
+// 盘中监控脚本可以直接轮询该表的最新行：
+//
+//	SELECT * FROM quotes_rt WHERE symbol = '600000.SH' ORDER BY ts DESC LIMIT 1
+
+func ensureQuoteTables(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS quotes_rt (
+		symbol      TEXT NOT NULL,
+		ts          INTEGER NOT NULL,   -- 行情时间，UTC 时间戳
+		price       REAL,
+		open        REAL,
+		high        REAL,
+		low         REAL,
+		prev_close  REAL,
+		volume      REAL,               -- 当日累计成交量 (股)
+		amount      REAL,               -- 当日累计成交额
+		source      TEXT NOT NULL,
+		PRIMARY KEY (symbol, ts)
+	) WITHOUT ROWID, STRICT;`)
+}
+
+type quote struct {
+	Symbol                            string // 600000.SH
+	TS                                int64
+	Price, Open, High, Low, PrevClose float64
+	Volume, Amount                    float64
+}
+
+type quoteSource interface {
+	Name() string
+	Fetch(ctx context.Context, symbols []string) ([]quote, error)
+}
+
+// 东方财富批量报价接口 (价格为元，成交量为手)
+type eastmoneyQuotes struct{}
+
+func (eastmoneyQuotes) Name() string { return "eastmoney" }
+
+func (eastmoneyQuotes) Fetch(ctx context.Context, symbols []string) ([]quote, error) {
+	secids := make([]string, 0, len(symbols))
+	canonical := map[string]string{}
+	for _, s := range symbols {
+		secid, c, err := emSecID(s)
+		if err != nil {
+			return nil, err
+		}
+		secids = append(secids, secid)
+		canonical[secid] = c
+	}
+	q := url.Values{
+		"secids": {strings.Join(secids, ",")},
+		"fltt":   {"2"}, // 价格直接返回浮点数
+		"fields": {"f12,f13,f2,f5,f6,f15,f16,f17,f18,f124"},
+	}
+	var resp struct {
+		Data *struct {
+			Diff []map[string]any `json:"diff"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, "https://push2.eastmoney.com/api/qt/ulist.np/get?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, nil
+	}
+	var out []quote
+	for _, d := range resp.Data.Diff {
+		secid := fmt.Sprintf("%v.%v", d["f13"], d["f12"])
+		c, ok := canonical[secid]
+		ts := int64(jsonFloat(d["f124"]))
+		if !ok || ts == 0 {
+			continue
+		}
+		out = append(out, quote{
+			Symbol: c, TS: ts,
+			Price: jsonFloat(d["f2"]), Open: jsonFloat(d["f17"]), High: jsonFloat(d["f15"]), Low: jsonFloat(d["f16"]),
+			PrevClose: jsonFloat(d["f18"]), Volume: jsonFloat(d["f5"]) * 100, Amount: jsonFloat(d["f6"]),
+		})
+	}
+	return out, nil
+}
+
+// 新浪行情接口：var hq_str_sh600000="名称,今开,昨收,现价,最高,最低,买一,卖一,成交量(股),成交额,...,日期,时间,...";
+// 名称为 GBK 编码，这里不解析
+type sinaQuotes struct{}
+
+func (sinaQuotes) Name() string { return "sina" }
+
+func (sinaQuotes) Fetch(ctx context.Context, symbols []string) ([]quote, error) {
+	codes := make([]string, 0, len(symbols))
+	canonical := map[string]string{}
+	for _, s := range symbols {
+		_, c, err := emSecID(s)
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(c[7:]) + c[:6] // 600000.SH -> sh600000
+		codes = append(codes, code)
+		canonical[code] = c
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://hq.sinajs.cn/list="+strings.Join(codes, ","), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Referer", "https://finance.sina.com.cn") // 没有 Referer 会返回 403
+	resp, err := cryptoHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sina: HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []quote
+	for _, line := range strings.Split(string(body), "\n") {
+		code, data, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "var hq_str_"), "=")
+		c, known := canonical[code]
+		if !ok || !known {
+			continue
+		}
+		f := strings.Split(strings.Trim(data, `";`), ",")
+		if len(f) < 32 {
+			continue // 停牌或代码不存在时返回空串
+		}
+		ts, ok := localToEpoch(f[30]+" "+f[31], symbolTimezone(c))
+		if !ok {
+			continue
+		}
+		out = append(out, quote{
+			Symbol: c, TS: ts,
+			Price: jsonFloat(f[3]), Open: jsonFloat(f[1]), High: jsonFloat(f[4]), Low: jsonFloat(f[5]),
+			PrevClose: jsonFloat(f[2]), Volume: jsonFloat(f[8]), Amount: jsonFloat(f[9]),
+		})
+	}
+	return out, nil
+}
+
+func saveQuotes(db *sql.DB, source string, quotes []quote) (int, error) {
+	n := 0
+	for _, q := range quotes {
+		res, err := db.Exec(`INSERT INTO quotes_rt VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+			q.Symbol, q.TS, q.Price, q.Open, q.High, q.Low, q.PrevClose, q.Volume, q.Amount, source)
+		if err != nil {
+			return n, err
+		}
+		if k, _ := res.RowsAffected(); k > 0 {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// chronos quotes --symbols 600000.SH,000001.SZ [--interval 3s] [--source eastmoney|sina]
+// 一直运行到 Ctrl-C 或 --duration 到期
+func runQuotes(args []string) {
+	fs := flag.NewFlagSet("quotes", flag.ExitOnError)
+	symbolsArg := fs.String("symbols", "", "自选股：代码列表文件或逗号分隔的代码")
+	interval := fs.Duration("interval", 3*time.Second, "轮询间隔")
+	duration := fs.Duration("duration", 0, "运行时长，0 表示直到手动中断")
+	sourceName := fs.String("source", "eastmoney", "行情源: eastmoney | sina")
+	fs.Parse(args)
+	if err := setupSources(DefaultSourcesConfig); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	symbols, err := parseSymbols(*symbolsArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(symbols) == 0 {
+		log.Fatal("[ERROR] 需要指定 --symbols")
+	}
+	var source quoteSource
+	switch *sourceName {
+	case "eastmoney":
+		source = eastmoneyQuotes{}
+	case "sina":
+		source = sinaQuotes{}
+	default:
+		log.Fatalf("[ERROR] 未知行情源: %s", *sourceName)
+	}
+
+	db := openOrCreateDB()
+	defer db.Close()
+	ensureQuoteTables(db)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	log.Printf(">>> 开始轮询 %d 只股票 (%s, 每 %s)，Ctrl-C 结束", len(symbols), source.Name(), *interval)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	total := 0
+	for {
+		quotes, err := source.Fetch(ctx, symbols)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[WARN] 抓取失败: %v", err) // 网络抖动不退出，下一轮重试
+		}
+		n, err := saveQuotes(db, source.Name(), quotes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		total += n
+
+		select {
+		case <-ctx.Done():
+			log.Printf(">>> ✅ 轮询结束，共写入 %d 条快照", total)
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSniffDelimiter(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want rune
+	}{
+		{"a,b,c", ','},
+		{"a\tb\tc", '\t'},
+		{"a;b;c", ';'},
+		{"a|b|c", '|'},
+		{"", ','},
+		// 引号字段内的逗号不计
+		{`"成交额(元,万)";"a,b,c";d`, ';'},
+		{`"x""y,z,w";a;b`, ';'},
+		{"\"多行\n列名,含逗号\"\tb\tc", '\t'},
+		// 字段中间的引号按字面处理，后面的分隔符照常计数
+		{`a"b,c"d;e`, ','},
+	} {
+		if got := sniffDelimiter(tc.line); got != tc.want {
+			t.Errorf("sniffDelimiter(%q) = %q, want %q", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestPeekHeaderLine(t *testing.T) {
+	for _, tc := range []struct {
+		in, want  string
+		truncated bool
+	}{
+		{"a,b\n1,2\n", "a,b", false},
+		{"a,\"b\nc\",d\n1,2,3\n", "a,\"b\nc\",d", false},
+		{"a,\"b\r\n\"\"c\"\"\",d\r\n1,2,3\r\n", "a,\"b\r\n\"\"c\"\"\",d\r", false},
+		{"a,b", "a,b", false},
+		{"a,\"b\nc,d", "a,\"b\nc,d", false},
+	} {
+		br := bufio.NewReaderSize(strings.NewReader(tc.in), 64)
+		line, truncated := peekHeaderLine(br, 64)
+		if line != tc.want || truncated != tc.truncated {
+			t.Errorf("peekHeaderLine(%q) = %q, %v; want %q, %v", tc.in, line, truncated, tc.want, tc.truncated)
+		}
+		// 只是预读，不消耗数据
+		if rest, _ := io.ReadAll(br); string(rest) != tc.in {
+			t.Errorf("peekHeaderLine(%q) 消耗了数据", tc.in)
+		}
+	}
+	long := strings.Repeat("x", 100) + "\n"
+	if line, truncated := peekHeaderLine(bufio.NewReaderSize(strings.NewReader(long), 16), 32); len(line) != 16 || !truncated {
+		t.Errorf("超长表头: %d 字节, truncated %v", len(line), truncated)
+	}
+}
+
+// 跨行的引号字段：两种解析方式的记录相同，Offset 与实际读过的字节一致 (断点续传依赖它)
+func TestMultiLineRecords(t *testing.T) {
+	data := "code,note,v\r\n" +
+		"A,\"第一行\r\n第二行, 含逗号\",1\r\n" +
+		"B,\"\"\"引号\"\"\n\n空行之后\",2\n" +
+		"C,plain,3\n" +
+		"D,\"未闭合\n到文件尾,4\n"
+	want := [][]string{
+		{"code", "note", "v"},
+		{"A", "第一行\r\n第二行, 含逗号", "1"},
+		{"B", "\"引号\"\n\n空行之后", "2"},
+		{"C", "plain", "3"},
+		{"D", "未闭合\n到文件尾,4\n"},
+	}
+	// encoding/csv 把引号字段内的 \r\n 规整为 \n
+	want[1][1] = "第一行\n第二行, 含逗号"
+	for _, mode := range []string{csvAuto, csvStrict} {
+		r := newRecordReader(bufio.NewReader(strings.NewReader(data)), ',', mode)
+		var got [][]string
+		var offsets []int64
+		for {
+			fields, err := r.ReadFields()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", mode, err)
+			}
+			got = append(got, fieldStrings(fields))
+			offsets = append(offsets, r.Offset())
+		}
+		if !slices.EqualFunc(got, want, slices.Equal[[]string]) {
+			t.Errorf("%s:\ngot  %q\nwant %q", mode, got, want)
+		}
+		// 每条记录之后的位置都落在行首，从该处续读得到剩下的记录
+		for k, off := range offsets[:len(offsets)-1] {
+			rest := newRecordReader(bufio.NewReader(strings.NewReader(data[off:])), ',', mode)
+			next, err := rest.ReadFields()
+			if err != nil || !slices.Equal(fieldStrings(next), want[k+1]) {
+				t.Errorf("%s: 从第 %d 字节续读得到 %q (%v), want %q", mode, off, fieldStrings(next), err, want[k+1])
+			}
+		}
+		if offsets[len(offsets)-1] != int64(len(data)) {
+			t.Errorf("%s: 最后的 Offset %d, 数据 %d 字节", mode, offsets[len(offsets)-1], len(data))
+		}
+	}
+}
+
+// 表头跨行、引号内含分隔符时，整条导入路径 (识别分隔符、读表头、映射) 按列名找到正确的列
+func TestReadCSVMultiLineHeader(t *testing.T) {
+	in := "\"代码\";\"成交额\n(元,万)\";\"收盘价, 复权\"\n" +
+		"A;\"1,0\";\"2\n\"\n" +
+		"B;3;4\n"
+	var header []string
+	rows, pf := readCSV(strings.NewReader(in), 3, func(h []string) rowMapper {
+		header = slices.Clone(h)
+		return func(a *rowArena, f [][]byte) []any {
+			return []any{a.intern(f[0]), a.text(f[2])}
+		}
+	})
+	if pf.comma != ';' || pf.skipped {
+		t.Fatalf("comma %q, skipped %v", pf.comma, pf.skipped)
+	}
+	if want := []string{"代码", "成交额\n(元,万)", "收盘价, 复权"}; !slices.Equal(header, want) {
+		t.Errorf("header %q, want %q", header, want)
+	}
+	if len(rows) != 2 || rows[0][1] != "2\n" || rows[1][1] != "4" || pf.records != 2 {
+		t.Errorf("rows %q, records %d", rows, pf.records)
+	}
+}
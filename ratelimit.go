@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 在线数据源限频与额度
+// ---------------------------------------------------------
+// 每个数据源一个限速器：请求之间至少间隔 1 分钟 / 每分钟次数，超出部分自动等待；
+// 当天调用次数记在数据库旁的 api_usage.json，进程重启、分几次跑也不会超出每日额度。
+// (不记在库里：拉取时 staging 事务一直持有写锁，另开连接记账会被锁住。)
+// 额度用完时返回错误，已合并的批次不受影响，第二天重新执行即可从断点续拉。
+//
+// 可在 sources.yaml 对应节中覆盖默认值 (0 表示不限)：
+//
+//	tushare:
+//	  token: xxxxxxxx
+//	  rate_per_minute: 200
+//	  daily_quota: 50000
+
+var defaultRateLimits = map[string]struct{ perMinute, daily int }{
+	"tushare":   {200, 0}, // 免费积分档大多数接口每分钟 200 次
+	"eastmoney": {120, 0},
+	"yahoo":     {60, 0},
+	"baostock":  {0, 0},
+	"binance":   {300, 0},
+	"okx":       {300, 0}, // history-candles 每 2 秒 20 次
+}
+
+type rateLimiter struct {
+	interval time.Duration
+	daily    int
+	next     time.Time
+}
+
+// 各数据源当日调用次数：source -> {date, calls}
+type apiUsageFile map[string]struct {
+	Date  string `json:"date"`
+	Calls int    `json:"calls"`
+}
+
+var (
+	rateMu       sync.Mutex
+	rateLimiters = map[string]*rateLimiter{}
+	apiUsage     = apiUsageFile{}
+)
+
+func apiUsagePath() string {
+	return filepath.Join(filepath.Dir(DBPath), "api_usage.json")
+}
+
+// 按配置建立各数据源的限速器并读入已用次数；未调用时 apiWait 不做限制
+func setupRateLimits(configPath string) error {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	for source, def := range defaultRateLimits {
+		cfg, err := loadSourceConfig(configPath, source)
+		if err != nil {
+			return err
+		}
+		perMinute, daily := def.perMinute, def.daily
+		for key, v := range map[string]*int{"rate_per_minute": &perMinute, "daily_quota": &daily} {
+			if s := yamlString(cfg, key); s != "" {
+				n, err := strconv.Atoi(s)
+				if err != nil || n < 0 {
+					return fmt.Errorf("%s.%s 必须是非负整数: %q", source, key, s)
+				}
+				*v = n
+			}
+		}
+		l := &rateLimiter{daily: daily}
+		if perMinute > 0 {
+			l.interval = time.Minute / time.Duration(perMinute)
+		}
+		rateLimiters[source] = l
+	}
+
+	data, err := os.ReadFile(apiUsagePath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &apiUsage)
+}
+
+// 今日已用次数 (调用方持有 rateMu)
+func usedToday(source string) int {
+	if u := apiUsage[source]; u.Date == time.Now().Format(time.DateOnly) {
+		return u.Calls
+	}
+	return 0
+}
+
+// 每次请求前调用：检查当日额度，按间隔等待，并记一次调用
+func apiWait(ctx context.Context, source string) error {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	l := rateLimiters[source]
+	if l == nil {
+		return nil
+	}
+	used := usedToday(source)
+	if l.daily > 0 && used >= l.daily {
+		return fmt.Errorf("%s 今日额度已用完 (%d/%d 次)，请明天再继续", source, used, l.daily)
+	}
+	if wait := time.Until(l.next); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	l.next = time.Now().Add(l.interval)
+
+	apiUsage[source] = struct {
+		Date  string `json:"date"`
+		Calls int    `json:"calls"`
+	}{time.Now().Format(time.DateOnly), used + 1}
+	data, err := json.Marshal(apiUsage)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(apiUsagePath(), data, 0o644)
+}
+
+// 打印各数据源今日用量
+func logAPIUsage() {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	sources := make([]string, 0, len(rateLimiters))
+	for source := range rateLimiters {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		used, l := usedToday(source), rateLimiters[source]
+		switch {
+		case used == 0:
+		case l.daily > 0:
+			log.Printf(">>> %s 今日已调用 %d/%d 次", source, used, l.daily)
+		default:
+			log.Printf(">>> %s 今日已调用 %d 次", source, used)
+		}
+	}
+}
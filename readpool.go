@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 只读连接池
+// ---------------------------------------------------------
+// 命令行子命令各自 openDB 一次、串行查询即可；把 Store 嵌进常驻服务时，几十个并发请求共用一个
+// 默认配置的 *sql.DB 会出问题：导入写库期间读连接立即返回 SQLITE_BUSY，空闲连接被反复关闭重开，
+// 每个请求都要重新 prepare。OpenStore 建立专供读取的连接池：
+//
+//	store, err := OpenStore(`D:\quant\chronos.db`, StoreOptions{MaxConns: 16})
+//	defer store.Close()
+//	bars, err := store.History(ctx, "600000.SH", DateRange{From: "2020-01-01"})
+//
+// 每个连接以只读方式打开 (mode=ro、query_only)，设置 busy_timeout；数据库为 WAL 模式时读取不会
+// 阻塞导入，导入提交时读取者最多等待 BusyTimeout。Store 的方法可在多个 goroutine 中同时调用，
+// 固定的查询语句在池上预编译一次后复用 (sql.Stmt 自行在各连接上准备)。
+//
+// 热点语句 (storeHotQueries) 在 OpenStore 时就预编译，并用 EXPLAIN QUERY PLAN 检查执行计划：
+// 按主键查找的语句退化为全表扫描 (如库是旧版本建的、缺主键) 时打印警告，而不是等请求变慢才发现。
+// 执行计划在预编译时确定，之后只有表结构变化 (导入建表、ANALYZE) 时 SQLite 才会自动重新编译。
+
+type StoreOptions struct {
+	MaxConns    int           // 最多同时打开的连接，默认为 CPU 核数
+	BusyTimeout time.Duration // 遇到写锁时最多等待多久，默认 5 秒
+	IdleTimeout time.Duration // 空闲连接保留多久，默认 10 分钟
+	CacheRows   int           // 查询结果缓存的行数上限，默认 100 万行，负数不缓存 (见 querycache.go)
+}
+
+// 以只读连接池打开已构建的数据库
+func OpenStore(path string, opt StoreOptions) (*Store, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("数据库不存在: %s", path)
+	}
+	if opt.MaxConns <= 0 {
+		opt.MaxConns = runtime.NumCPU()
+	}
+	if opt.BusyTimeout <= 0 {
+		opt.BusyTimeout = 5 * time.Second
+	}
+	if opt.IdleTimeout <= 0 {
+		opt.IdleTimeout = 10 * time.Minute
+	}
+	if opt.CacheRows == 0 {
+		opt.CacheRows = 1 << 20
+	}
+	registerUDFs()
+	db, err := sql.Open(sqliteDriver, readOnlyDSN(path, opt.BusyTimeout))
+	if err != nil {
+		return nil, err
+	}
+	// 空闲连接与上限相同，突发请求过后不必重新打开连接、重新准备语句
+	db.SetMaxOpenConns(opt.MaxConns)
+	db.SetMaxIdleConns(opt.MaxConns)
+	db.SetConnMaxIdleTime(opt.IdleTimeout)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	s := newStore(db)
+	s.stmts = map[string]*sql.Stmt{}
+	if opt.CacheRows > 0 {
+		s.cache = newResultCache(opt.CacheRows)
+	}
+	if err := s.prepareHot(context.Background()); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// 热点语句及其查询的表；search 为 true 的语句应按主键查找
+var storeHotQueries = []struct {
+	table, query string
+	search       bool
+}{
+	{"stock_history", historySQL, true},
+	{"stock_history", crossSectionSQL, false},
+	{"stock_history", symbolsSQL, false},
+	{"dataset_version", datasetVersionSQL, true},
+	{"index_members", indexMembersSQL, true},
+	{"industry_class", industryOfSQL, true},
+	{"concept_tags", tagsSQL, false}, // 主键以 tag 开头，按代码查要扫描 (表很小)
+	{"concept_tags", tagMembersSQL, true},
+	{"fx_rates", fxLookupSQL, true},
+}
+
+// 预编译库中已有的表上的热点语句，检查执行计划
+func (s *Store) prepareHot(ctx context.Context) error {
+	tables := map[string]bool{}
+	rows, err := s.db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table'")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		tables[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, h := range storeHotQueries {
+		if !tables[h.table] {
+			continue // 没有导入的数据集
+		}
+		if _, err := s.stmt(ctx, h.query); err != nil {
+			return fmt.Errorf("预编译 %s 上的查询: %v", h.table, err)
+		}
+		if h.search {
+			if plan := queryPlan(ctx, s.db, h.query); strings.Contains(plan, "SCAN "+h.table) {
+				log.Printf("[WARN] %s 上的查询没有走主键 (%s)，请检查表结构或重新导入", h.table, plan)
+			}
+		}
+	}
+	return nil
+}
+
+var sqlParamRe = regexp.MustCompile(`\?(\d*)`)
+
+// EXPLAIN QUERY PLAN 的各步 (参数按 NULL 处理，不影响索引选择)
+func queryPlan(ctx context.Context, db *sql.DB, query string) string {
+	n := 0
+	for _, m := range sqlParamRe.FindAllStringSubmatch(query, -1) {
+		if k, err := strconv.Atoi(m[1]); err == nil {
+			n = max(n, k)
+		} else {
+			n++
+		}
+	}
+	rows, err := db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, make([]any, n)...)
+	if err != nil {
+		return ""
+	}
+	defer rows.Close()
+	var steps []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if rows.Scan(&id, &parent, &unused, &detail) == nil {
+			steps = append(steps, detail)
+		}
+	}
+	return strings.Join(steps, "; ")
+}
+
+// 关闭 OpenStore 打开的连接池与预编译语句；newStore 包装的连接由调用方关闭
+func (s *Store) Close() error {
+	if s.stmts == nil {
+		return nil
+	}
+	s.mu.Lock()
+	for _, stmt := range s.stmts {
+		stmt.Close()
+	}
+	s.stmts = nil
+	s.mu.Unlock()
+	return s.db.Close()
+}
+
+// 执行查询；连接池上的 Store 复用预编译的语句
+func (s *Store) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	stmt, err := s.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if stmt == nil {
+		return s.db.QueryContext(ctx, query, args...)
+	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+func (s *Store) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	stmt, err := s.stmt(ctx, query)
+	if stmt == nil || err != nil {
+		// 预编译失败时直接执行，错误在 Scan 时返回
+		return s.db.QueryRowContext(ctx, query, args...)
+	}
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+func (s *Store) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stmts == nil {
+		return nil, nil
+	}
+	if stmt, ok := s.stmts[query]; ok {
+		s.stmtHits++
+		return stmt, nil
+	}
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	s.stmts[query] = stmt
+	return stmt, nil
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 多数据源对账
+// ---------------------------------------------------------
+// 同一区间从多个数据源各拉一份日线 (不写入 stock_history)，对同一 (股票, 日期) 逐列比较：
+//
+//	chronos reconcile --sources tushare,eastmoney,baostock --from 2024-01-01 --symbols 600000,000001
+//	chronos reconcile --sources db,eastmoney --from 2024-01-01        # db 表示库中现有数据
+//
+// 报告各列在每个数据源上的不一致率与差异最大的记录。--sources 的顺序即优先级 (默认取 chains.daily)，
+// 每个 (股票, 日期) 由有数据的第一个源“胜出”，胜出源及不一致的列记入 source_reconcile。
+// 价格列相对误差超过 --tol、成交量与市盈率超过 10 倍 --tol 视为不一致，任一方缺失不计。
+
+var reconcileColumns = []string{"close", "close_adj", "open_adj", "high_adj", "low_adj", "pe", "volume"}
+
+func ensureReconcileTables(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS source_reconcile (
+		symbol      TEXT NOT NULL,
+		date        TEXT NOT NULL,
+		winner      TEXT NOT NULL,      -- 按优先级胜出的数据源
+		sources     TEXT NOT NULL,      -- 提供了该行的全部数据源
+		disagree    TEXT NOT NULL,      -- 与胜出源不一致的 源:列，逗号分隔，空串表示完全一致
+		checked_at  TEXT NOT NULL,
+		PRIMARY KEY (symbol, date)
+	) WITHOUT ROWID, STRICT;`)
+	mustExec(db, `CREATE TABLE IF NOT EXISTS staging_sources (
+		source TEXT, symbol TEXT, date TEXT,
+		close REAL, close_adj REAL, open_adj REAL, high_adj REAL, low_adj REAL, pe REAL, volume REAL
+	);`)
+}
+
+// 把 staging 表中 (staging_symbols 范围内的) 数据记为 source 的一份
+func captureStaging(db *sql.DB, source string) error {
+	_, err := db.Exec(`INSERT INTO staging_sources
+		SELECT ?, t.symbol, substr(t.date, 1, 4) || '-' || substr(t.date, 5, 2) || '-' || substr(t.date, 7, 2),
+			CAST(t.close_raw AS REAL), CAST(t.close_adj AS REAL), CAST(t.open_adj AS REAL), CAST(t.high_adj AS REAL),
+			CAST(t.low_adj AS REAL), CAST(NULLIF(trim(d.pe), '') AS REAL), CAST(NULLIF(trim(t.volume), '') AS REAL)
+		FROM staging_tech t JOIN staging_daily d ON d.symbol = t.symbol AND d.date = t.date
+		WHERE t.symbol IN (SELECT symbol FROM staging_symbols)`, source)
+	mustExec(db, "DELETE FROM staging_tech;")
+	mustExec(db, "DELETE FROM staging_daily;")
+	return err
+}
+
+type reconcileDiff struct {
+	Symbol, Date, Column, Source string
+	Value, Winner                float64
+	Rel                          float64
+}
+
+type reconcileStat struct{ compared, disagreed int }
+
+// chronos reconcile --sources a,b[,c] --from 2024-01-01 [--to ...] [--market CN] [--symbols ...] [--tol 0.001] [--top 20]
+func runReconcile(args []string) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件")
+	sourcesArg := fs.String("sources", "", "按优先级排列的数据源，至少两个 (db 表示库中现有数据)；默认取 chains.daily")
+	market := fs.String("market", MarketCN, "市场: CN / HK / US")
+	symbolsArg := fs.String("symbols", "", "代码列表文件或逗号分隔的代码 (默认库中该市场全部代码)")
+	from := fs.String("from", "", "起始日期 (YYYY-MM-DD)")
+	to := fs.String("to", time.Now().Format(time.DateOnly), "结束日期 (YYYY-MM-DD，含)")
+	tol := fs.Float64("tol", 0.001, "价格列允许的相对误差")
+	top := fs.Int("top", 20, "列出差异最大的记录数")
+	fs.Parse(args)
+
+	if *from == "" {
+		log.Fatal("[ERROR] 需要指定 --from")
+	}
+	chainName := "daily"
+	if *market != MarketCN {
+		chainName += "_" + strings.ToLower(*market)
+	}
+	sources := splitList(*sourcesArg)
+	for _, s := range sources {
+		if _, ok := historySources[s]; !ok && s != "db" {
+			log.Fatalf("[ERROR] 未知数据源: %s", s)
+		}
+	}
+	if len(sources) == 0 {
+		chain, err := loadChain(*config, chainName, "")
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		sources = chain
+	}
+	if len(sources) < 2 {
+		log.Fatal("[ERROR] 对账至少需要两个数据源 (--sources)")
+	}
+
+	start := time.Now()
+	db := openOrCreateDB()
+	defer db.Close()
+	if err := setupSources(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	createTables(db)
+	ensureProvenanceTable(db)
+	ensureReconcileTables(db)
+	mustExec(db, "DELETE FROM staging_sources;")
+	mustExec(db, "DELETE FROM staging_symbols;")
+
+	var symbols []string
+	var err error
+	if *symbolsArg != "" {
+		if symbols, err = parseSymbols(*symbolsArg); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		symbols = marketSymbols(db, *market)
+	}
+	var gaps []historyGap
+	for _, s := range symbols {
+		s = canonicalSymbol(s)
+		gaps = append(gaps, historyGap{Symbol: s, From: *from, To: *to})
+		if _, err := db.Exec("INSERT OR IGNORE INTO staging_symbols VALUES (?)", s); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(gaps) == 0 {
+		log.Fatal("[ERROR] 没有要对账的代码 (请指定 --symbols)")
+	}
+
+	ctx := context.Background()
+	for _, name := range sources {
+		log.Printf(">>> [%s] 拉取 %d 只股票...", name, len(gaps))
+		if name == "db" {
+			_, err = db.Exec(`INSERT INTO staging_sources SELECT 'db', symbol, date, close, close_adj, open_adj, high_adj, low_adj, pe, volume
+				FROM stock_history WHERE symbol IN (SELECT symbol FROM staging_symbols) AND date BETWEEN ? AND ?`, *from, *to)
+		} else if err = historySources[name](ctx, db, *config, gaps); err == nil {
+			err = captureStaging(db, name)
+		}
+		if err != nil {
+			log.Printf("[WARN] %s 拉取失败，不参与对账: %v", name, err)
+			mustExec(db, "DELETE FROM staging_tech;")
+			mustExec(db, "DELETE FROM staging_daily;")
+		}
+	}
+
+	// 读出全部数据，按 (股票, 日期) 分组，组内按优先级排列
+	priority := map[string]int{}
+	for k, s := range sources {
+		priority[s] = k
+	}
+	rows, err := db.Query(`SELECT source, symbol, date, `+strings.Join(reconcileColumns, ", ")+`
+		FROM staging_sources WHERE date BETWEEN ? AND ? ORDER BY symbol, date`, *from, *to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	type sourceRow struct {
+		source string
+		values []sql.NullFloat64
+	}
+	type group struct {
+		symbol, date string
+		rows         []sourceRow
+	}
+	var groups []*group
+	for rows.Next() {
+		var r sourceRow
+		var symbol, date string
+		r.values = make([]sql.NullFloat64, len(reconcileColumns))
+		dest := []any{&r.source, &symbol, &date}
+		for k := range r.values {
+			dest = append(dest, &r.values[k])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			log.Fatal(err)
+		}
+		if n := len(groups); n == 0 || groups[n-1].symbol != symbol || groups[n-1].date != date {
+			groups = append(groups, &group{symbol: symbol, date: date})
+		}
+		g := groups[len(groups)-1]
+		g.rows = append(g.rows, r)
+	}
+	rows.Close()
+
+	// 胜出源不一定是第一优先级 (它可能缺这一行)，各源与该行的胜出源比较，统计记在非胜出源名下
+	stats := map[string]map[string]*reconcileStat{} // 源 -> 列 -> 统计
+	for _, s := range sources {
+		stats[s] = map[string]*reconcileStat{}
+		for _, c := range reconcileColumns {
+			stats[s][c] = &reconcileStat{}
+		}
+	}
+	var diffs []reconcileDiff
+	wins := map[string]int{}
+	overlapped := 0
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO source_reconcile VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		log.Fatal(err)
+	}
+	checkedAt := time.Now().Format(time.DateTime)
+	for _, g := range groups {
+		if len(g.rows) < 2 {
+			continue
+		}
+		overlapped++
+		sort.SliceStable(g.rows, func(a, b int) bool { return priority[g.rows[a].source] < priority[g.rows[b].source] })
+		winner := g.rows[0]
+		wins[winner.source]++
+		var names, disagree []string
+		names = append(names, winner.source)
+		for _, r := range g.rows[1:] {
+			names = append(names, r.source)
+			for k, c := range reconcileColumns {
+				a, b := r.values[k], winner.values[k]
+				if !a.Valid || !b.Valid {
+					continue
+				}
+				st := stats[r.source][c]
+				st.compared++
+				limit := *tol
+				if c == "volume" || c == "pe" {
+					limit *= 10
+				}
+				rel := math.Abs(a.Float64-b.Float64) / math.Max(math.Abs(b.Float64), 1e-9)
+				if rel > limit {
+					st.disagreed++
+					disagree = append(disagree, r.source+":"+c)
+					diffs = append(diffs, reconcileDiff{g.symbol, g.date, c, r.source, a.Float64, b.Float64, rel})
+				}
+			}
+		}
+		if _, err := stmt.Exec(g.symbol, g.date, winner.source, strings.Join(names, ","), strings.Join(disagree, ","), checkedAt); err != nil {
+			log.Fatal(err)
+		}
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	mustExec(db, "DROP TABLE IF EXISTS staging_sources;")
+	mustExec(db, "DROP TABLE IF EXISTS staging_symbols;")
+	mustExec(db, "DROP TABLE IF EXISTS staging_tech;")
+	mustExec(db, "DROP TABLE IF EXISTS staging_daily;")
+
+	// 报告
+	fmt.Printf("\n%d 个 (股票, 日期) 有两个以上数据源; 胜出:", overlapped)
+	for _, s := range sources {
+		fmt.Printf(" %s=%d", s, wins[s])
+	}
+	fmt.Printf("\n\n不一致率 (与胜出源比较, 不一致/比较次数)\n%-10s", "数据源")
+	for _, c := range reconcileColumns {
+		fmt.Printf(" %16s", c)
+	}
+	fmt.Println()
+	for _, s := range sources {
+		total := 0
+		for _, c := range reconcileColumns {
+			total += stats[s][c].compared
+		}
+		if total == 0 {
+			continue
+		}
+		fmt.Printf("%-10s", s)
+		for _, c := range reconcileColumns {
+			st := stats[s][c]
+			if st.compared == 0 {
+				fmt.Printf(" %16s", "-")
+				continue
+			}
+			fmt.Printf(" %6.2f%% %8s", 100*float64(st.disagreed)/float64(st.compared), fmt.Sprintf("%d/%d", st.disagreed, st.compared))
+		}
+		fmt.Println()
+	}
+
+	sort.Slice(diffs, func(a, b int) bool { return diffs[a].Rel > diffs[b].Rel })
+	if len(diffs) > 0 {
+		fmt.Printf("\n差异最大的 %d 条\n", min(*top, len(diffs)))
+		for _, d := range diffs[:min(*top, len(diffs))] {
+			fmt.Printf("%-12s %s %-10s %-10s %14.4f  胜出源 %14.4f  (%.2f%%)\n", d.Symbol, d.Date, d.Column, d.Source, d.Value, d.Winner, 100*d.Rel)
+		}
+	}
+	logAPIUsage()
+	log.Printf(">>> ✅ 对账完成: %d 处不一致, 明细见 source_reconcile, 耗时: %s", len(diffs), time.Since(start))
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 盘中录制
+// ---------------------------------------------------------
+// 常驻运行：交易时段内按间隔把自选股报价写入 quotes_rt (同 quotes 子命令)，收盘 closingGrace 之后
+// 再补抓一笔快照 (收盘集合竞价的成交与收盘价此时才出现在报价里)，用当天最后一笔快照生成临时日线写入
+// recorded_daily。官方数据 (供应商文件经 update 导入 stock_history) 到达后自动核对，
+// 收盘价或成交量不一致的记入 record_discrepancies：
+//
+//	chronos record --symbols watchlist.txt            # 常驻，Ctrl-C 结束
+//	chronos record --reconcile                        # 只核对一次并列出差异
+//
+// update 结束时也会自动核对一次。
+// 节假日不单独判断：休市时报价时间不变，快照主键冲突不会重复写入，当天也不会生成日线。
+// recorded_daily.status：pending 等待官方数据，ok 一致，mismatch 有差异。
+// 库为 auto_vacuum = incremental 时每隔 --vacuum-every 归还一部分空闲页 (见 vacuum.go)。
+
+// 各市场交易时段 (交易所本地时间，含集合竞价)
+var tradingSessions = map[string][][2]string{
+	MarketCN: {{"09:15", "11:30"}, {"13:00", "15:00"}},
+	MarketHK: {{"09:30", "12:00"}, {"13:00", "16:00"}},
+	MarketUS: {{"09:30", "16:00"}},
+}
+
+// 收盘后等多久再抓最后一笔快照。A 股 14:57–15:00 为收盘集合竞价，官方收盘价和这段成交量
+// 在 15:00 撮合后才由行情源推出，时段内的最后一笔快照还是竞价前的价格。
+const closingGrace = 2 * time.Minute
+
+// 核对时允许的相对误差
+const (
+	defaultPriceTol  = 0.001
+	defaultVolumeTol = 0.01
+)
+
+func ensureRecordTables(db *sql.DB) {
+	ensureQuoteTables(db)
+	mustExec(db, `CREATE TABLE IF NOT EXISTS recorded_daily (
+		symbol      TEXT NOT NULL,
+		date        TEXT NOT NULL,
+		open        REAL,
+		high        REAL,
+		low         REAL,
+		close       REAL,               -- 收盘后最后一笔快照的现价
+		prev_close  REAL,
+		volume      REAL,               -- 股
+		amount      REAL,
+		source      TEXT NOT NULL,
+		snapshots   INTEGER NOT NULL,   -- 当天录到的快照数
+		status      TEXT NOT NULL DEFAULT 'pending',
+		PRIMARY KEY (symbol, date)
+	) WITHOUT ROWID, STRICT;`)
+	mustExec(db, `CREATE TABLE IF NOT EXISTS record_discrepancies (
+		symbol      TEXT NOT NULL,
+		date        TEXT NOT NULL,
+		field       TEXT NOT NULL,      -- close / volume / missing (官方数据没有该股票)
+		recorded    REAL,
+		official    REAL,
+		checked_at  TEXT NOT NULL,
+		PRIMARY KEY (symbol, date, field)
+	) WITHOUT ROWID, STRICT;`)
+}
+
+// t 是否在 market 的交易时段内 (只看周一至周五与时刻)
+func inTradingSession(market string, t time.Time) bool {
+	t = t.In(loadLocation(marketTimezone(market)))
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	hm := t.Format("15:04")
+	for _, s := range tradingSessions[market] {
+		if hm >= s[0] && hm < s[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// t 所在交易所本地日期，以及当天是否已收盘 (收盘后 closingGrace 起才算)
+func sessionDay(market string, t time.Time) (string, bool) {
+	t = t.In(loadLocation(marketTimezone(market)))
+	sessions := tradingSessions[market]
+	date := t.Format(time.DateOnly)
+	end, _ := time.ParseInLocation("2006-01-02 15:04", date+" "+sessions[len(sessions)-1][1], t.Location())
+	return date, !t.Before(end.Add(closingGrace))
+}
+
+// 收盘后补抓最后一笔快照 (含收盘集合竞价)，再生成 date 当天的临时日线，返回生成的股票数。
+// 补抓失败只告警，仍用已有的快照生成日线。
+func closeRecordedDay(ctx context.Context, db *sql.DB, source quoteSource, symbols []string, market, date string) (int, error) {
+	quotes, err := source.Fetch(ctx, symbols)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("[WARN] 收盘快照抓取失败: %v", err)
+	}
+	if _, err := saveQuotes(db, source.Name(), quotes); err != nil {
+		return 0, err
+	}
+	return buildRecordedDaily(db, market, date)
+}
+
+// 用 date 当天各股票最后一笔快照生成临时日线，返回生成的股票数
+func buildRecordedDaily(db *sql.DB, market, date string) (int, error) {
+	tz := marketTimezone(market)
+	start, _ := localToEpoch(date+" 00:00:00", tz)
+	end, _ := localToEpoch(date+" 23:59:59", tz)
+	res, err := db.Exec(`INSERT OR REPLACE INTO recorded_daily
+		SELECT q.symbol, ?3, q.open, q.high, q.low, q.price, q.prev_close, q.volume, q.amount, q.source, d.n, 'pending'
+		FROM quotes_rt q
+		JOIN (SELECT symbol, max(ts) AS ts, count(*) AS n FROM quotes_rt WHERE ts BETWEEN ?1 AND ?2 GROUP BY symbol) d
+			ON d.symbol = q.symbol AND d.ts = q.ts`, start, end, date)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// 与 stock_history 核对尚未核对的临时日线 (只核对官方数据已到达的日期)，返回 (核对的行数, 有差异的行数)
+func reconcileRecorded(db *sql.DB, priceTol, volumeTol float64) (int, int, error) {
+	rows, err := db.Query(fmt.Sprintf(`SELECT r.symbol, r.date, r.close, r.volume, h.close, h.volume, h.symbol IS NOT NULL
+		FROM recorded_daily r
+		LEFT JOIN stock_history h ON h.symbol = r.symbol AND h.date = r.date
+		WHERE r.status = 'pending'
+			AND EXISTS (SELECT 1 FROM stock_history a WHERE a.date = r.date AND a.market = %s)`, marketSQL("r.symbol")))
+	if err != nil {
+		return 0, 0, err
+	}
+	type check struct {
+		symbol, date        string
+		close, volume       sql.NullFloat64
+		offClose, offVolume sql.NullFloat64
+		found               bool
+	}
+	var checks []check
+	for rows.Next() {
+		var c check
+		if err := rows.Scan(&c.symbol, &c.date, &c.close, &c.volume, &c.offClose, &c.offVolume, &c.found); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		checks = append(checks, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	now := time.Now().Format(time.DateTime)
+	note := func(c check, field string, recorded, official sql.NullFloat64) error {
+		_, err := tx.Exec("INSERT OR REPLACE INTO record_discrepancies VALUES (?, ?, ?, ?, ?, ?)",
+			c.symbol, c.date, field, nullFloat(recorded), nullFloat(official), now)
+		return err
+	}
+	// 相对误差超过 tol 即视为不一致 (任一方缺失时不比较)
+	differs := func(a, b sql.NullFloat64, tol float64) bool {
+		return a.Valid && b.Valid && math.Abs(a.Float64-b.Float64) > tol*math.Max(math.Abs(b.Float64), 1e-9)
+	}
+	mismatched := 0
+	for _, c := range checks {
+		var errs []error
+		switch {
+		case !c.found:
+			errs = append(errs, note(c, "missing", c.close, sql.NullFloat64{}))
+		default:
+			if differs(c.close, c.offClose, priceTol) {
+				errs = append(errs, note(c, "close", c.close, c.offClose))
+			}
+			if differs(c.volume, c.offVolume, volumeTol) {
+				errs = append(errs, note(c, "volume", c.volume, c.offVolume))
+			}
+		}
+		for _, err := range errs {
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+		status := "ok"
+		if len(errs) > 0 {
+			status = "mismatch"
+			mismatched++
+		}
+		if _, err := tx.Exec("UPDATE recorded_daily SET status = ? WHERE symbol = ? AND date = ?", status, c.symbol, c.date); err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(checks), mismatched, tx.Commit()
+}
+
+func printDiscrepancies(db *sql.DB, since string) {
+	rows, err := db.Query(`SELECT symbol, date, field, recorded, official FROM record_discrepancies
+		WHERE checked_at >= ? ORDER BY date, symbol, field`, since)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var symbol, date, field string
+		var recorded, official sql.NullFloat64
+		rows.Scan(&symbol, &date, &field, &recorded, &official)
+		fmt.Printf("%-12s %s %-8s 录制 %-12s 官方 %s\n", symbol, date, field, fmtNull(recorded), fmtNull(official))
+	}
+}
+
+func fmtNull(v sql.NullFloat64) string {
+	if !v.Valid {
+		return "-"
+	}
+	return fmt.Sprintf("%.4g", v.Float64)
+}
+
+// chronos record --symbols 600000.SH,000001.SZ [--interval 3s] [--source eastmoney|sina]
+// chronos record --reconcile
+func runRecord(args []string) {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	symbolsArg := fs.String("symbols", "", "自选股：代码列表文件或逗号分隔的代码")
+	interval := fs.Duration("interval", 3*time.Second, "盘中快照间隔")
+	sourceName := fs.String("source", "eastmoney", "行情源: eastmoney | sina")
+	reconcileOnly := fs.Bool("reconcile", false, "只与已导入的官方数据核对一次，不录制")
+	priceTol := fs.Float64("price-tol", defaultPriceTol, "收盘价允许的相对误差")
+	volumeTol := fs.Float64("volume-tol", defaultVolumeTol, "成交量允许的相对误差 (快照截止时刻与官方统计口径略有出入)")
+	vacuumEvery := fs.Duration("vacuum-every", time.Hour, "auto_vacuum = incremental 的库每隔多久归还空闲页 (0 为不归还，见 vacuum.go)")
+	vacuumPages := fs.Int("vacuum-pages", 1000, "每次最多归还的空闲页数")
+	fs.Parse(args)
+
+	db := openOrCreateDB()
+	defer db.Close()
+	ensureRecordTables(db)
+
+	since := time.Now().Format(time.DateTime)
+	if *reconcileOnly {
+		n, bad, err := reconcileRecorded(db, *priceTol, *volumeTol)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printDiscrepancies(db, since)
+		log.Printf(">>> ✅ 核对 %d 行, %d 行与官方数据不一致", n, bad)
+		return
+	}
+
+	symbols, err := parseSymbols(*symbolsArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(symbols) == 0 {
+		log.Fatal("[ERROR] 需要指定 --symbols")
+	}
+	var source quoteSource
+	switch *sourceName {
+	case "eastmoney":
+		source = eastmoneyQuotes{}
+	case "sina":
+		source = sinaQuotes{}
+	default:
+		log.Fatalf("[ERROR] 未知行情源: %s", *sourceName)
+	}
+	if err := setupSources(DefaultSourcesConfig); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	market := marketOf(symbols[0]) // 报价源目前只支持 A 股
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	log.Printf(">>> 开始录制 %d 只股票 (%s, 盘中每 %s)，Ctrl-C 结束", len(symbols), source.Name(), *interval)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	built := map[string]bool{} // 已生成日线的日期
+	var lastCheck time.Time
+	incremental := autoVacuumMode(db) == "incremental"
+	if *vacuumEvery > 0 && !incremental {
+		log.Println("[WARN] 数据库不是 auto_vacuum = incremental，不会定期归还空闲页 (可用 chronos vacuum --auto incremental 转换)")
+	}
+	lastVacuum := time.Now()
+	snapshots := 0
+	for {
+		now := time.Now()
+		if inTradingSession(market, now) {
+			quotes, err := source.Fetch(ctx, symbols)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("[WARN] 抓取失败: %v", err)
+			}
+			n, err := saveQuotes(db, source.Name(), quotes)
+			if err != nil {
+				log.Fatal(err)
+			}
+			snapshots += n
+		} else if date, closed := sessionDay(market, now); closed && !built[date] {
+			n, err := closeRecordedDay(ctx, db, source, symbols, market, date)
+			if err != nil {
+				log.Fatal(err)
+			}
+			built[date] = true
+			if n > 0 {
+				log.Printf(">>> %s 收盘: 共录制 %d 条快照, 生成 %d 只股票的临时日线", date, snapshots, n)
+			}
+			snapshots = 0
+		}
+		// 每 10 分钟检查一次官方数据是否已导入
+		if now.Sub(lastCheck) >= 10*time.Minute {
+			lastCheck = now
+			n, bad, err := reconcileRecorded(db, *priceTol, *volumeTol)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if n > 0 {
+				log.Printf(">>> 与官方数据核对 %d 行, %d 行不一致 (见 record_discrepancies)", n, bad)
+			}
+		}
+
+		// 定期归还空闲页，每次只做一小段，不长时间占用写锁
+		if incremental && *vacuumEvery > 0 && now.Sub(lastVacuum) >= *vacuumEvery {
+			lastVacuum = now
+			n, err := incrementalVacuum(db, *vacuumPages)
+			if err != nil {
+				log.Printf("[WARN] incremental_vacuum: %v", err)
+			} else if n > 0 {
+				log.Printf(">>> 已归还 %d 个空闲页", n)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println(">>> ✅ 录制结束")
+			return
+		case <-ticker.C:
+		}
+	}
+}
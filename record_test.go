@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// 固定返回同一批报价的行情源
+type fixedQuotes []quote
+
+func (fixedQuotes) Name() string { return "fixture" }
+
+func (q fixedQuotes) Fetch(context.Context, []string) ([]quote, error) { return q, nil }
+
+func TestSessionDayClosingGrace(t *testing.T) {
+	sh := loadLocation(marketTimezone(MarketCN))
+	for _, tc := range []struct {
+		hm     string
+		closed bool
+	}{
+		{"14:59", false}, {"15:00", false}, {"15:01", false}, {"15:02", true}, {"23:59", true}, {"00:01", false},
+	} {
+		at, _ := time.ParseInLocation(time.DateTime, "2024-03-15 "+tc.hm+":00", sh)
+		if date, closed := sessionDay(MarketCN, at); date != "2024-03-15" || closed != tc.closed {
+			t.Errorf("%s: got %s %v, want closed %v", tc.hm, date, closed, tc.closed)
+		}
+	}
+}
+
+// 盘中最后一笔快照在收盘集合竞价之前；收盘后补抓的快照带上竞价成交与官方收盘价
+func TestCloseRecordedDayTakesClosingAuction(t *testing.T) {
+	db := openTestDB(t, "record.db")
+	createTables(db)
+	ensureRecordTables(db)
+	epoch := func(ts string) int64 {
+		v, _ := localToEpoch(ts, marketTimezone(MarketCN))
+		return v
+	}
+	if _, err := saveQuotes(db, "fixture", []quote{
+		{Symbol: "600000.SH", TS: epoch("2024-03-15 14:50:00"), Price: 10.02, Open: 10, High: 10.1, Low: 9.9, PrevClose: 10, Volume: 1_000_000},
+		{Symbol: "600000.SH", TS: epoch("2024-03-15 14:56:57"), Price: 10.05, Open: 10, High: 10.1, Low: 9.9, PrevClose: 10, Volume: 1_200_000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	final := fixedQuotes{
+		{Symbol: "600000.SH", TS: epoch("2024-03-15 15:00:03"), Price: 10.08, Open: 10, High: 10.1, Low: 9.9, PrevClose: 10, Volume: 1_350_000},
+	}
+	n, err := closeRecordedDay(context.Background(), db, final, []string{"600000.SH"}, MarketCN, "2024-03-15")
+	if err != nil || n != 1 {
+		t.Fatalf("closeRecordedDay = %d, %v", n, err)
+	}
+	got := dumpTable(t, db, "SELECT symbol, date, close, volume, snapshots FROM recorded_daily")
+	if want := "600000.SH|2024-03-15|10.08|1.35e+06|3\n"; got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// 官方日线与竞价后的收盘价、成交量一致，核对不报差异
+	mustExec(db, "INSERT INTO stock_history (symbol, date, close, volume, market) VALUES ('600000.SH', '2024-03-15', 10.08, 1350000, 'CN')")
+	if checked, bad, err := reconcileRecorded(db, defaultPriceTol, defaultVolumeTol); err != nil || checked != 1 || bad != 0 {
+		t.Errorf("reconcileRecorded = %d, %d, %v", checked, bad, err)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 历史回放
+// ---------------------------------------------------------
+// 把库中的历史 K 线按时间顺序推送出去，就像实时行情一样陆续到达，用来测试下游的信号与下单服务：
+//
+//	chronos replay --speed 60x --from 2020-01-01 --listen :8765       # WebSocket: ws://host:8765/ws，gRPC: host:8765
+//	chronos replay --freq 1 --speed max --symbols 600000 > bars.jsonl # 不监听时逐行输出 JSON
+//
+// 相邻两根 K 线之间按行情时间差 / 倍速等待 (60x 表示 1 分钟行情 1 秒推完，max 表示不等待)，
+// 同一时刻的多只股票连续推送。日线的行情时间取交易所收盘时刻。
+// WebSocket 与标准输出的每条消息是一根 K 线的 JSON (字段见 replayBar)，回放结束时推送 {"type":"end"} 并断开；
+// gRPC 订阅者调用 chronos.Replay/Stream 收到同样内容的 protobuf 消息 (见 grpc.go)。
+
+type replayBar struct {
+	Type   string   `json:"type"` // bar / end
+	Symbol string   `json:"symbol,omitempty"`
+	Freq   int      `json:"freq,omitempty"` // 分钟数，0 为日线
+	TS     int64    `json:"ts,omitempty"`   // UTC 时间戳 (K 线结束时刻)
+	Time   string   `json:"time,omitempty"` // 交易所本地时间
+	Open   *float64 `json:"open,omitempty"` // 日线为后复权价，另带不复权收盘 close_raw
+	High   *float64 `json:"high,omitempty"`
+	Low    *float64 `json:"low,omitempty"`
+	Close  *float64 `json:"close,omitempty"`
+	Raw    *float64 `json:"close_raw,omitempty"`
+	Volume *float64 `json:"volume,omitempty"`
+}
+
+// 各市场日线收盘时刻 (交易所本地时间)
+var marketCloseTimes = map[string]string{
+	MarketCN: "15:00:00",
+	MarketHK: "16:00:00",
+	MarketUS: "16:00:00",
+}
+
+// "60x"、"60" 或 "max" (不等待，返回 0)
+func parseReplaySpeed(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "x")
+	if s == "max" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("倍速格式错误: %q (如 60x、max)", s)
+	}
+	return v, nil
+}
+
+func nullFloat(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// 按时间顺序逐根读出 K 线交给 emit；emit 返回错误时停止
+func replayBars(ctx context.Context, db *sql.DB, market string, freq int, symbols []string, from, to string, emit func(replayBar) error) error {
+	tz := marketTimezone(market)
+	filter, args := "", []any{}
+	if len(symbols) > 0 {
+		filter = " AND symbol IN (" + strings.TrimSuffix(strings.Repeat("?,", len(symbols)), ",") + ")"
+		for _, s := range symbols {
+			args = append(args, canonicalSymbol(s))
+		}
+	}
+
+	var rows *sql.Rows
+	var err error
+	if freq == 0 {
+		rows, err = db.QueryContext(ctx, `SELECT symbol, date, open_adj, high_adj, low_adj, close_adj, close, volume
+			FROM stock_history WHERE market = ? AND date BETWEEN ? AND ?`+filter+` ORDER BY date, symbol`,
+			append([]any{market, from, to}, args...)...)
+	} else {
+		start, _ := localToEpoch(from+" 00:00:00", tz)
+		end, _ := localToEpoch(to+" 23:59:59", tz)
+		rows, err = db.QueryContext(ctx, `SELECT symbol, ts, open, high, low, close, NULL, volume
+			FROM stock_minute WHERE freq = ? AND tz = ? AND ts BETWEEN ? AND ?`+filter+` ORDER BY ts, symbol`,
+			append([]any{freq, tz, start, end}, args...)...)
+	}
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var symbol, at string
+		var open, high, low, cl, raw, volume sql.NullFloat64
+		if err := rows.Scan(&symbol, &at, &open, &high, &low, &cl, &raw, &volume); err != nil {
+			return err
+		}
+		b := replayBar{Type: "bar", Symbol: symbol, Freq: freq, Open: nullFloat(open), High: nullFloat(high),
+			Low: nullFloat(low), Close: nullFloat(cl), Raw: nullFloat(raw), Volume: nullFloat(volume)}
+		if freq == 0 {
+			ts, ok := localToEpoch(at+" "+marketCloseTimes[market], tz)
+			if !ok {
+				continue
+			}
+			b.TS = ts
+		} else if b.TS, err = strconv.ParseInt(at, 10, 64); err != nil {
+			continue
+		}
+		b.Time = epochToLocal(b.TS, tz)
+		if err := emit(b); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// chronos replay [--from 2020-01-01] [--to ...] [--speed 60x] [--freq 0|1|5] [--market CN] [--symbols ...] [--listen :8765]
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	from := fs.String("from", "", "起始日期 (YYYY-MM-DD)")
+	to := fs.String("to", time.Now().Format(time.DateOnly), "结束日期 (YYYY-MM-DD，含)")
+	speedArg := fs.String("speed", "60x", "回放倍速 (如 60x、3600x)，max 表示不等待")
+	freq := fs.Int("freq", 0, "K 线周期: 0 日线 (stock_history) / 1 / 5 分钟线 (stock_minute)")
+	market := fs.String("market", MarketCN, "市场: CN / HK / US")
+	symbolsArg := fs.String("symbols", "", "代码列表文件或逗号分隔的代码，默认该市场全部")
+	listen := fs.String("listen", "", "WebSocket / gRPC 监听地址 (如 :8765)，为空时输出到标准输出")
+	wait := fs.Bool("wait", true, "监听时等第一个订阅者接入后再开始回放")
+	fs.Parse(args)
+
+	if *from == "" {
+		log.Fatal("[ERROR] 需要指定 --from")
+	}
+	speed, err := parseReplaySpeed(*speedArg)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if _, ok := marketCloseTimes[*market]; !ok {
+		log.Fatalf("[ERROR] 未知市场: %s", *market)
+	}
+	var symbols []string
+	if *symbolsArg != "" {
+		if symbols, err = parseSymbols(*symbolsArg); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	db := openDB()
+	defer db.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var send func(replayBar) error
+	var hub *wsHub
+	var ghub *grpcHub
+	if *listen != "" {
+		hub, ghub = newWSHub(), newGRPCHub()
+		mux := http.NewServeMux()
+		mux.Handle("/ws", hub)
+		mux.Handle("/chronos.Replay/", ghub)
+		srv := &http.Server{Addr: *listen, Handler: mux, Protocols: new(http.Protocols)}
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetUnencryptedHTTP2(true) // gRPC 客户端以 prior knowledge 方式直连
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("[ERROR] %v", err)
+			}
+		}()
+		defer srv.Close()
+		log.Printf(">>> WebSocket 推送地址: ws://%s/ws, gRPC: %s (chronos.Replay/Stream)", *listen, *listen)
+		if *wait {
+			log.Println(">>> 等待订阅者接入...")
+			select {
+			case <-hub.joined:
+			case <-ghub.joined:
+			case <-ctx.Done():
+				return
+			}
+		}
+		send = func(b replayBar) error {
+			msg, err := json.Marshal(b)
+			if err != nil {
+				return err
+			}
+			hub.broadcast(msg)
+			ghub.broadcast(b.protobuf())
+			return nil
+		}
+	} else {
+		send = func(b replayBar) error {
+			msg, err := json.Marshal(b)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(append(msg, '\n'))
+			return err
+		}
+	}
+
+	// 行情时间 t 对应的推送时刻 = 开始时刻 + (t - 首根行情时间) / 倍速
+	start := time.Now()
+	var first int64
+	n := 0
+	err = replayBars(ctx, db, *market, *freq, symbols, *from, *to, func(b replayBar) error {
+		if n == 0 {
+			first = b.TS
+			log.Printf(">>> 开始回放: %s 起, 倍速 %s", b.Time, *speedArg)
+		}
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(b.TS-first) * float64(time.Second) / speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+		n++
+		return send(b)
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	send(replayBar{Type: "end"})
+	if hub != nil {
+		hub.closeAll(5 * time.Second)
+		ghub.closeAll(5 * time.Second)
+	}
+	log.Printf(">>> ✅ 回放结束: %d 根 K 线, 耗时: %s", n, time.Since(start))
+}
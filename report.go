@@ -0,0 +1,420 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 数据质量报告
+// ---------------------------------------------------------
+// 每月的数据集导好后要告诉大家 "新数据可以用了"，附上一份能直接打开的报告。import 与 update 结束时
+// 写出一个自包含的 HTML 文件 (不引用外部样式、脚本)，内容为：
+//
+//	数据源     本次导入的各个表：文件数、写入行数、出错跳过的行数、耗时
+//	耗时       各阶段的开始时间与耗时
+//	校验       本次运行的校验规则与违规 (validation_results，见 validate.go)
+//	异常       OHLC 不一致、非正价格、单日涨跌过大、复权因子倒退的行，覆盖率过低的股票
+//	逐只股票   质量评分、覆盖率与各列缺失率 (见 quality.go)
+//
+// 路径在 sources.yaml 的 import.report 中设置 (默认 quality_report.html)，import.skip 中写 report 则不生成。
+// 之后随时可以按库中的数据重新生成 (没有本次运行的数据源与耗时两节)：
+//
+//	chronos report --out quality_report.html
+
+// 覆盖率低于该值的股票列入异常
+const reportLowCoverage = 0.9
+
+// 每类异常列出的样本数
+const reportSamples = 20
+
+// 本次运行的记录 (报告的数据源与耗时两节)
+type runStats struct {
+	started time.Time
+	sources []sourceRun
+	stages  []stageRun
+}
+
+type sourceRun struct {
+	table, pattern string
+	files          int
+	rows, skipped  int
+	elapsed        time.Duration
+}
+
+type stageRun struct {
+	name           string
+	start, elapsed time.Duration // start 为相对运行开始的时间
+}
+
+var currentRun = &runStats{started: time.Now()}
+
+// 记录一次 CSV 导入 (importCSVFiles、流式合并)
+func recordSource(table, pattern string, rows, skipped int, elapsed time.Duration) {
+	files, _ := filepath.Glob(pattern)
+	currentRun.sources = append(currentRun.sources, sourceRun{table, pattern, len(files), rows, skipped, elapsed})
+}
+
+// 执行 f 并记录耗时
+func runStage(name string, f func()) {
+	start := time.Now()
+	f()
+	currentRun.stages = append(currentRun.stages, stageRun{name, start.Sub(currentRun.started), time.Since(start)})
+}
+
+// 运行结束时重建质量评分 (见 quality.go) 并写出报告；报告只是附带的产物，失败时提示后继续
+func writeRunReport(db *sql.DB) {
+	quality, anomalies := refreshSymbolQuality(db)
+	if quality == nil || skipStep("report") {
+		return
+	}
+	start := time.Now()
+	if err := writeQualityReport(db, importOptions.report, quality, anomalies, currentRun, validationRunAt); err != nil {
+		log.Printf("[WARN] 写数据质量报告失败: %v", err)
+		return
+	}
+	log.Printf(">>> 数据质量报告已写入 %s, 耗时: %s", importOptions.report, time.Since(start).Round(time.Millisecond))
+}
+
+// historyAnomalyChecks 中 OHLC 不一致一项的下标
+const anomalyOHLC = 0
+
+// stock_history 中的异常行：cond 为对一行的判断，prev_close / prev_close_adj 为同一代码前一个交易日的收盘价与复权收盘价。
+//
+// 复权价为后复权，隐含的复权因子 close_adj / close 只会在除权除息日变大，不会变小 (库中没有除权除息记录，
+// 只能检查方向)。因子变小说明复权价与原始价对不上，常见于混用了不同批次的供应商文件 (各批次复权基准不同)。
+// 价格保留两位小数，两天各按 ±0.005 的舍入取因子的最大、最小值，仍然变小才算异常
+var historyAnomalyChecks = []struct{ name, cond string }{
+	{"OHLC 不一致 (复权最高价低于最低价，或开盘、收盘超出最高最低)",
+		"high_adj < low_adj OR open_adj NOT BETWEEN low_adj * 0.9999 AND high_adj * 1.0001 OR close_adj NOT BETWEEN low_adj * 0.9999 AND high_adj * 1.0001"},
+	{"价格为零或负数", "close <= 0 OR close_adj <= 0 OR open_adj <= 0 OR high_adj <= 0 OR low_adj <= 0"},
+	{"复权收盘价单日涨跌超过 50%", "abs(close_adj / prev_close_adj - 1) > 0.5"},
+	{"复权因子 (close_adj / close) 比前一日小 (复权价与原始价不一致)",
+		"close > 0.005 AND prev_close > 0.005 AND (close_adj + 0.005) / (close - 0.005) < (prev_close_adj - 0.005) / (prev_close + 0.005)"},
+}
+
+// 扫描一遍 stock_history 的结果
+type historyAnomalies struct {
+	counts   []int            // 各项检查的行数 (与 historyAnomalyChecks 对应)
+	samples  [][]string       // 各项检查的前 limit 行
+	bySymbol map[string][]int // 代码 -> 各项检查的行数 (只含有异常的代码)
+}
+
+func scanHistoryAnomalies(db *sql.DB, limit int) (*historyAnomalies, error) {
+	var flags, conds []string
+	for _, c := range historyAnomalyChecks {
+		flags = append(flags, "coalesce("+c.cond+", 0)")
+		conds = append(conds, "("+c.cond+")")
+	}
+	rows, err := db.Query(`SELECT symbol, date, close, close_adj, open_adj, high_adj, low_adj, prev_close, prev_close_adj, ` + strings.Join(flags, ", ") + `
+		FROM (SELECT symbol, date, close, close_adj, open_adj, high_adj, low_adj,
+			lag(close) OVER w AS prev_close, lag(close_adj) OVER w AS prev_close_adj
+			FROM stock_history WINDOW w AS (PARTITION BY symbol ORDER BY date))
+		WHERE ` + strings.Join(conds, " OR "))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	a := &historyAnomalies{
+		counts:   make([]int, len(historyAnomalyChecks)),
+		samples:  make([][]string, len(historyAnomalyChecks)),
+		bySymbol: map[string][]int{},
+	}
+	vals := make([]any, 9)
+	hit := make([]int, len(historyAnomalyChecks))
+	dst := make([]any, 0, len(vals)+len(hit))
+	for k := range vals {
+		dst = append(dst, &vals[k])
+	}
+	for k := range hit {
+		dst = append(dst, &hit[k])
+	}
+	for rows.Next() {
+		if err := rows.Scan(dst...); err != nil {
+			return nil, err
+		}
+		symbol := fmt.Sprint(vals[0])
+		for k, h := range hit {
+			if h == 0 {
+				continue
+			}
+			a.counts[k]++
+			if len(a.samples[k]) < limit {
+				a.samples[k] = append(a.samples[k], formatRowValues(vals))
+			}
+			if a.bySymbol[symbol] == nil {
+				a.bySymbol[symbol] = make([]int, len(historyAnomalyChecks))
+			}
+			a.bySymbol[symbol][k]++
+		}
+	}
+	return a, rows.Err()
+}
+
+// 各股票应有的行数：所在市场的交易日 (tradingDates) 中落在其起止日期之间的天数
+func expectedTradingDays(db *sql.DB, stats []SymbolStats) []int {
+	calendars := map[string][]string{}
+	out := make([]int, len(stats))
+	for k, s := range stats {
+		dates, ok := calendars[s.Market]
+		if !ok {
+			dates = tradingDates(db, s.Market)
+			calendars[s.Market] = dates
+		}
+		out[k] = sort.SearchStrings(dates, s.LastDate+"\x00") - sort.SearchStrings(dates, s.FirstDate)
+	}
+	return out
+}
+
+// 覆盖率 (0~1)
+func coverageRate(rows, expected int) float64 {
+	if expected == 0 {
+		return 1
+	}
+	return float64(rows) / float64(expected)
+}
+
+// 一次运行的校验结果
+type validationResult struct {
+	stage, table, column, rule, action string
+	violations                         int
+	samples                            string
+}
+
+// runAt 那次运行的校验结果，runAt 为空时取最近一次；还没有结果表时返回空
+func loadValidationResults(db *sql.DB, runAt string) (string, []validationResult, error) {
+	var exists int
+	db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'validation_results'").Scan(&exists)
+	if exists == 0 {
+		return runAt, nil, nil
+	}
+	if runAt == "" {
+		db.QueryRow("SELECT coalesce(max(run_at), '') FROM validation_results").Scan(&runAt)
+	}
+	rows, err := db.Query("SELECT stage, table_name, column_name, rule, action, violations, samples FROM validation_results WHERE run_at = ? ORDER BY rowid", runAt)
+	if err != nil {
+		return runAt, nil, err
+	}
+	defer rows.Close()
+	var out []validationResult
+	for rows.Next() {
+		var r validationResult
+		if err := rows.Scan(&r.stage, &r.table, &r.column, &r.rule, &r.action, &r.violations, &r.samples); err != nil {
+			return runAt, nil, err
+		}
+		out = append(out, r)
+	}
+	return runAt, out, rows.Err()
+}
+
+// 生成报告写到 path (quality、anomalies 为 scanSymbolQuality 的结果)。
+// run 为 nil 时没有数据源与耗时两节；validationRun 为空时用最近一次的校验结果
+func writeQualityReport(db *sql.DB, path string, quality []*symbolQuality, anomalies *historyAnomalies, run *runStats, validationRun string) error {
+	validationRun, results, err := loadValidationResults(db, validationRun)
+	if err != nil {
+		return err
+	}
+
+	b := &htmlReport{}
+	b.WriteString(`<!DOCTYPE html>
+<html lang="zh-CN"><head><meta charset="utf-8"><title>数据质量报告</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "Microsoft YaHei", sans-serif; font-size: 14px; margin: 24px; color: #222; }
+h1 { font-size: 22px; } h2 { font-size: 18px; margin-top: 32px; border-bottom: 1px solid #ddd; padding-bottom: 4px; }
+table { border-collapse: collapse; margin: 8px 0; } th, td { border: 1px solid #ddd; padding: 3px 8px; vertical-align: top; }
+th { background: #f4f4f4; } td.n { text-align: right; font-variant-numeric: tabular-nums; }
+tr.bad td { background: #fdecea; } .muted { color: #888; } pre { margin: 0; font-size: 12px; white-space: pre-wrap; }
+nav a { margin-right: 12px; }
+</style></head><body>
+`)
+	now := time.Now()
+	b.printf("<h1>数据质量报告</h1>\n<p>%s 生成，数据库 %s", now.Format(time.DateTime), html.EscapeString(DBPath))
+	if run != nil {
+		b.printf("，命令 <code>%s</code>，运行耗时 %s", html.EscapeString(strings.Join(append([]string{"chronos"}, os.Args[1:]...), " ")), now.Sub(run.started).Round(time.Second))
+	}
+	b.WriteString("</p>\n<nav>")
+	if run != nil {
+		b.WriteString(`<a href="#sources">数据源</a><a href="#timings">耗时</a>`)
+	}
+	b.WriteString(`<a href="#validation">校验</a><a href="#anomalies">异常</a><a href="#markets">缺失率</a><a href="#symbols">逐只股票</a></nav>` + "\n")
+
+	if run != nil {
+		b.WriteString(`<h2 id="sources">数据源</h2>` + "\n")
+		var rows [][]string
+		for _, s := range run.sources {
+			rows = append(rows, []string{s.table, s.pattern, strconv.Itoa(s.files), strconv.Itoa(s.rows), strconv.Itoa(s.skipped), s.elapsed.Round(time.Millisecond).String()})
+		}
+		b.table([]string{"表", "文件", "文件数", "写入行数", "出错跳过", "耗时"}, "llnnnn", rows, func(k int) bool { return run.sources[k].skipped > 0 })
+
+		b.WriteString(`<h2 id="timings">耗时</h2>` + "\n")
+		stages := append([]stageRun(nil), run.stages...)
+		sort.SliceStable(stages, func(i, j int) bool { return stages[i].start < stages[j].start })
+		rows = nil
+		for _, s := range stages {
+			rows = append(rows, []string{s.name, s.start.Round(time.Second).String(), s.elapsed.Round(time.Millisecond).String()})
+		}
+		b.table([]string{"阶段", "开始于", "耗时"}, "lnn", rows, nil)
+	}
+
+	b.WriteString(`<h2 id="validation">校验</h2>` + "\n")
+	if len(results) == 0 {
+		b.WriteString(`<p class="muted">没有校验结果 (sources.yaml 中没有 validate 规则)</p>` + "\n")
+	} else {
+		b.printf("<p>%s 那次运行的结果 (validation_results)</p>\n", html.EscapeString(validationRun))
+		var rows [][]string
+		for _, r := range results {
+			rows = append(rows, []string{r.stage, r.table + "." + r.column, r.rule, r.action, strconv.Itoa(r.violations), r.samples})
+		}
+		b.table([]string{"阶段", "列", "规则", "处理", "违规", "样本"}, "llllnp", rows, func(k int) bool { return results[k].violations > 0 })
+	}
+
+	b.WriteString(`<h2 id="anomalies">异常</h2>` + "\n")
+	var rows [][]string
+	for k, c := range historyAnomalyChecks {
+		rows = append(rows, []string{c.name, strconv.Itoa(anomalies.counts[k]), strings.Join(anomalies.samples[k], "\n")})
+	}
+	var low []string
+	for _, q := range quality {
+		if c := q.coverage(); c < reportLowCoverage {
+			low = append(low, fmt.Sprintf("%s %.1f%% (%d / %d 天)", q.Symbol, 100*c, q.Rows, q.expected))
+		}
+	}
+	rows = append(rows, []string{fmt.Sprintf("覆盖率低于 %.0f%% 的股票", 100*reportLowCoverage), strconv.Itoa(len(low)), strings.Join(low[:min(len(low), reportSamples)], "\n")})
+	b.table([]string{"检查", "数量", "样本 (代码 | 日期 | close | close_adj | open_adj | high_adj | low_adj | 前一日 close | 前一日 close_adj)"}, "lnp", rows, func(k int) bool { return rows[k][1] != "0" })
+
+	b.WriteString(`<h2 id="markets">缺失率</h2>` + "\n")
+	type total struct {
+		symbols, rows int
+		nulls         map[string]int
+	}
+	byMarket := map[string]*total{}
+	var markets []string
+	for _, s := range quality {
+		t := byMarket[s.Market]
+		if t == nil {
+			t = &total{nulls: map[string]int{}}
+			byMarket[s.Market] = t
+			markets = append(markets, s.Market)
+		}
+		t.symbols++
+		t.rows += s.Rows
+		for c, n := range s.Nulls {
+			t.nulls[c] += n
+		}
+	}
+	sort.Strings(markets)
+	header := []string{"市场", "股票数", "行数"}
+	align := "lnn"
+	for _, c := range historyStatColumns {
+		header = append(header, c)
+		align += "n"
+	}
+	rows = nil
+	for _, m := range markets {
+		t := byMarket[m]
+		row := []string{m, strconv.Itoa(t.symbols), strconv.Itoa(t.rows)}
+		for _, c := range historyStatColumns {
+			row = append(row, formatPercent(t.nulls[c], t.rows))
+		}
+		rows = append(rows, row)
+	}
+	b.table(header, align, rows, nil)
+
+	b.WriteString(`<h2 id="symbols">逐只股票</h2>` + "\n")
+	b.printf("<p>按质量评分 (symbol_quality) 从低到高；覆盖率低于 %.0f%% 或有异常行的标红。缺失率各列为该列为 NULL 的比例</p>\n", 100*reportLowCoverage)
+	order := slices.Clone(quality)
+	sort.SliceStable(order, func(i, j int) bool { return order[i].score() < order[j].score() })
+	header = []string{"代码", "市场", "起始", "最后", "行数", "交易日", "覆盖率", "异常行", "评分"}
+	align = "llllnnnnn"
+	for _, c := range historyStatColumns {
+		header = append(header, c)
+		align += "n"
+	}
+	rows = nil
+	for _, q := range order {
+		row := []string{q.Symbol, q.Market, q.FirstDate, q.LastDate, strconv.Itoa(q.Rows), strconv.Itoa(q.expected),
+			fmt.Sprintf("%.1f%%", 100*q.coverage()), strconv.Itoa(q.anomalyRows()), fmt.Sprintf("%.1f", q.score())}
+		for _, c := range historyStatColumns {
+			row = append(row, formatPercent(q.Nulls[c], q.Rows))
+		}
+		rows = append(rows, row)
+	}
+	b.table(header, align, rows, func(k int) bool {
+		return order[k].coverage() < reportLowCoverage || order[k].anomalyRows() > 0
+	})
+	b.WriteString("</body></html>\n")
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+func formatPercent(n, total int) string {
+	return fmt.Sprintf("%.2f%%", 100*float64(n)/float64(max(total, 1)))
+}
+
+type htmlReport struct {
+	strings.Builder
+}
+
+func (b *htmlReport) printf(format string, args ...any) {
+	fmt.Fprintf(b, format, args...)
+}
+
+// align 每列一个字符：l 文本，n 数字 (右对齐)，p 多行文本；bad(k) 为真的行标红
+func (b *htmlReport) table(header []string, align string, rows [][]string, bad func(k int) bool) {
+	if len(rows) == 0 {
+		b.WriteString(`<p class="muted">(无)</p>` + "\n")
+		return
+	}
+	b.WriteString("<table>\n<tr>")
+	for _, h := range header {
+		b.WriteString("<th>" + html.EscapeString(h) + "</th>")
+	}
+	b.WriteString("</tr>\n")
+	for k, row := range rows {
+		if bad != nil && bad(k) {
+			b.WriteString(`<tr class="bad">`)
+		} else {
+			b.WriteString("<tr>")
+		}
+		for i, cell := range row {
+			switch align[i] {
+			case 'n':
+				b.WriteString(`<td class="n">` + html.EscapeString(cell) + "</td>")
+			case 'p':
+				b.WriteString("<td><pre>" + html.EscapeString(cell) + "</pre></td>")
+			default:
+				b.WriteString("<td>" + html.EscapeString(cell) + "</td>")
+			}
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+}
+
+// chronos report [--out quality_report.html]
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	out := fs.String("out", "quality_report.html", "输出的 HTML 文件")
+	fs.Parse(args)
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+	quality, anomalies := refreshSymbolQuality(db)
+	if quality == nil {
+		log.Fatal("[ERROR] 无法计算数据质量评分")
+	}
+	if err := writeQualityReport(db, *out, quality, anomalies, nil, ""); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf(">>> ✅ 报告已写入 %s, 耗时: %s", *out, time.Since(start))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// 异常行按检查项与代码计数；覆盖率按所在市场在起止日期之间的交易日计算
+func TestHistoryAnomaliesAndCoverage(t *testing.T) {
+	db := openTestDB(t, "report.db")
+	createTables(db)
+	// 代码, 日期, close, close_adj, open_adj, high_adj, low_adj
+	for _, r := range [][]any{
+		{"A", "2024-01-02", 10, 10, 10, 10.5, 9.5},
+		{"A", "2024-01-03", 10, 10.2, 10, 10.1, 9.9}, // 收盘高于最高
+		{"A", "2024-01-05", 10, 16, 15, 16.5, 14.9},  // 涨 57%
+		{"B", "2024-01-02", 0, 8, 8, 8, 8},           // close 为 0
+		{"B", "2024-01-03", 8, 8, 8, 8, 8},
+		{"B", "2024-01-04", 8, 8, 8, 8, 8},
+		{"B", "2024-01-05", 8, nil, nil, nil, nil}, // 缺失不算异常
+		{"C", "2024-01-04", 5, 5, 5, 5.1, 4.9},
+		{"C", "2024-01-05", 5, 5.2, 5, 5.3, 4.9}, // 除权，复权因子变大
+		{"D", "2024-01-02", 10, 20, 20, 20, 20},
+		{"D", "2024-01-03", 10.01, 20, 20, 20, 20}, // 因子略小，在舍入误差之内
+		{"D", "2024-01-04", 10, 15, 15, 15, 15},    // 因子从 2 变为 1.5 (混用了不同批次的文件)
+	} {
+		mustExec(db, "INSERT INTO stock_history VALUES (?, ?, ?, ?, ?, ?, ?, NULL, NULL, 'CN')", r...)
+	}
+	a, err := scanHistoryAnomalies(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 1, 1, 1}; !slices.Equal(a.counts, want) {
+		t.Errorf("counts %v, want %v", a.counts, want)
+	}
+	if want := "A | 2024-01-03 | 10 | 10.2 | 10 | 10.1 | 9.9 | 10 | 10"; len(a.samples[0]) != 1 || a.samples[0][0] != want {
+		t.Errorf("samples %q, want %q", a.samples[0], want)
+	}
+	if !slices.Equal(a.bySymbol["A"], []int{1, 0, 1, 0}) || !slices.Equal(a.bySymbol["B"], []int{0, 1, 0, 0}) ||
+		!slices.Equal(a.bySymbol["D"], []int{0, 0, 0, 1}) {
+		t.Errorf("bySymbol %v", a.bySymbol)
+	}
+
+	stats := []SymbolStats{
+		{Symbol: "A", Market: "CN", FirstDate: "2024-01-02", LastDate: "2024-01-05", Rows: 3},
+		{Symbol: "B", Market: "CN", FirstDate: "2024-01-03", LastDate: "2024-01-04", Rows: 2},
+	}
+	if got := expectedTradingDays(db, stats); !slices.Equal(got, []int{4, 2}) {
+		t.Errorf("expectedTradingDays %v, want [4 2]", got)
+	}
+
+	// 评分：A 有两行异常 (占 2/3) 为 0；B 缺一行价格 (缺失率 0.8/4)、一行异常 (占 1/4) 为 0；C 从上市起没有缺口；D 一行因子倒退 (占 1/3) 为 0
+	if _, err := refreshHistoryStats(db, nil); err != nil {
+		t.Fatal(err)
+	}
+	quality, _, err := scanSymbolQuality(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scores []float64
+	for _, q := range quality {
+		scores = append(scores, q.score())
+	}
+	if want := []float64{0, 0, 100, 0}; !slices.Equal(scores, want) {
+		t.Errorf("scores %v, want %v", scores, want)
+	}
+	if err := saveSymbolQuality(db, quality); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := newStore(db).QualitySymbols(context.Background(), 80); err != nil || !slices.Equal(got, []string{"C"}) {
+		t.Errorf("QualitySymbols = %v, %v", got, err)
+	}
+}
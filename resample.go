@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 重采样频率：N 个交易日 / N 周 / N 月 / N 季 / N 年
+type Freq struct {
+	Unit byte // 'D' 'W' 'M' 'Q' 'Y'
+	N    int
+}
+
+var (
+	FreqDaily     = Freq{'D', 1}
+	FreqWeekly    = Freq{'W', 1}
+	FreqMonthly   = Freq{'M', 1}
+	FreqQuarterly = Freq{'Q', 1}
+	FreqYearly    = Freq{'Y', 1}
+)
+
+// 解析 "W"、"M"、"5D"、"2W" 这类写法
+func ParseFreq(s string) (Freq, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return Freq{}, fmt.Errorf("频率为空")
+	}
+	unit := s[len(s)-1]
+	if !strings.ContainsRune("DWMQY", rune(unit)) {
+		return Freq{}, fmt.Errorf("未知频率: %s (可用 D/W/M/Q/Y，可加倍数如 5D、2W)", s)
+	}
+	n := 1
+	if len(s) > 1 {
+		v, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || v <= 0 {
+			return Freq{}, fmt.Errorf("频率倍数非法: %s", s)
+		}
+		n = v
+	}
+	return Freq{unit, n}, nil
+}
+
+func (f Freq) String() string {
+	if f.N == 1 {
+		return string(f.Unit)
+	}
+	return fmt.Sprintf("%d%c", f.N, f.Unit)
+}
+
+// 计算第 k 根日线所属的桶编号。
+// 周/月/季/年按自然日历分桶 (周从周一开始)，跨节假日也不会错位；
+// N 日按交易日序号分桶。
+func (f Freq) bucket(k int, date string) (int, error) {
+	if f.Unit == 'D' {
+		return k / f.N, nil
+	}
+	t, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return 0, fmt.Errorf("日期格式错误: %s", date)
+	}
+	switch f.Unit {
+	case 'W':
+		// 以 1970-01-05 (周一) 为起点的周序号
+		days := int(t.Sub(time.Date(1970, 1, 5, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+		return floorDiv(floorDiv(days, 7), f.N), nil
+	case 'M':
+		return floorDiv(t.Year()*12+int(t.Month())-1, f.N), nil
+	case 'Q':
+		return floorDiv(t.Year()*4+(int(t.Month())-1)/3, f.N), nil
+	default:
+		return floorDiv(t.Year(), f.N), nil
+	}
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// 把日线聚合为低频 K 线：开盘取首根、最高取最大、最低取最小、收盘和 PE 取末根、成交量求和，
+// 日期标记为桶内最后一个交易日。
+// 成交量只在部分日期上有值 (见 stock_history.volume)，缺失的日期不计入；桶内全部缺失时为 NaN。
+func ResampleBars(bars []Bar, f Freq) ([]Bar, error) {
+	var out []Bar
+	cur := -1
+	for k, b := range bars {
+		key, err := f.bucket(k, b.Date)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) == 0 || key != cur {
+			out = append(out, b)
+			cur = key
+			continue
+		}
+		agg := &out[len(out)-1]
+		if math.IsNaN(agg.OpenAdj) {
+			agg.OpenAdj = b.OpenAdj
+		}
+		agg.HighAdj = nanMax(agg.HighAdj, b.HighAdj)
+		agg.LowAdj = nanMin(agg.LowAdj, b.LowAdj)
+		agg.Volume = nanSum(agg.Volume, b.Volume)
+		agg.Date, agg.Close, agg.CloseAdj, agg.PE = b.Date, b.Close, b.CloseAdj, b.PE
+	}
+	return out, nil
+}
+
+func nanMax(a, b float64) float64 {
+	if math.IsNaN(a) {
+		return b
+	}
+	if math.IsNaN(b) {
+		return a
+	}
+	return math.Max(a, b)
+}
+
+func nanSum(a, b float64) float64 {
+	if math.IsNaN(a) {
+		return b
+	}
+	if math.IsNaN(b) {
+		return a
+	}
+	return a + b
+}
+
+func nanMin(a, b float64) float64 {
+	if math.IsNaN(a) {
+		return b
+	}
+	if math.IsNaN(b) {
+		return a
+	}
+	return math.Min(a, b)
+}
+
+// 单只股票按指定频率重采样
+func (s *Store) Resample(ctx context.Context, symbol string, f Freq, r DateRange) ([]Bar, error) {
+	bars, err := s.History(ctx, symbol, r)
+	if err != nil {
+		return nil, err
+	}
+	return ResampleBars(bars, f)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 周线成交量为桶内有值日期之和，全部缺失时为 NaN
+func TestResampleBarsVolume(t *testing.T) {
+	nan := math.NaN()
+	bar := func(date string, close, volume float64) Bar {
+		return Bar{Symbol: "000001", Date: date, Close: close, CloseAdj: close, OpenAdj: close,
+			HighAdj: close, LowAdj: close, PE: nan, Volume: volume}
+	}
+	bars := []Bar{
+		bar("2024-01-02", 10, 100), bar("2024-01-03", 11, nan), bar("2024-01-05", 12, 50), // 第一周
+		bar("2024-01-08", 13, nan), bar("2024-01-09", 12, nan), // 第二周无成交量
+		bar("2024-01-15", 14, nan), bar("2024-01-16", 15, 30), // 第三周首日缺失
+	}
+	weeks, err := ResampleBars(bars, Freq{Unit: 'W', N: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{150, nan, 30}
+	if len(weeks) != len(want) {
+		t.Fatalf("得到 %d 根周线，期望 %d", len(weeks), len(want))
+	}
+	for k, w := range weeks {
+		if got := w.Volume; got != want[k] && !(math.IsNaN(got) && math.IsNaN(want[k])) {
+			t.Errorf("%s: volume = %v, want %v", w.Date, got, want[k])
+		}
+	}
+	if weeks[0].Date != "2024-01-05" || weeks[0].HighAdj != 12 || weeks[0].OpenAdj != 10 {
+		t.Errorf("第一周 = %+v", weeks[0])
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// 交易日历上的持有期：从第 0 天起每隔 step 天买入一次，持有 horizon 个交易日，超出日历末尾的丢弃
+func calendarPeriods(dates []string, horizon, step int) [][2]string {
+	var periods [][2]string
+	for k := 0; k+horizon < len(dates); k += step {
+		periods = append(periods, [2]string{dates[k], dates[k+horizon]})
+	}
+	return periods
+}
+
+// 按交易日历计算的持有期收益子查询，产出 (symbol, date, value)：periods 为 (买入日, 卖出日) 对，
+// 所有股票共用同一组日期，口径与 labels.go 一致：
+//   - 卖出日停牌：使用卖出日当天或之前最后一个收盘价
+//   - 卖出日之后再无任何数据 (退市或至今停牌)：不产出该行
+func calendarReturnSource(periods [][2]string) string {
+	values := make([]string, len(periods))
+	for k, p := range periods {
+		values[k] = fmt.Sprintf("(%s, %s)", sqlQuote(p[0]), sqlQuote(p[1]))
+	}
+	if len(values) == 0 {
+		values = []string{"(NULL, NULL)"}
+	}
+	return fmt.Sprintf(`SELECT h.symbol, h.date,
+		(SELECT x.close_adj FROM stock_history x
+			WHERE x.symbol = h.symbol AND x.date <= p.exit_date ORDER BY x.date DESC LIMIT 1) / h.close_adj - 1 AS value
+	FROM stock_history h
+	INNER JOIN (SELECT column1 AS date, column2 AS exit_date FROM (VALUES %s)) p ON p.date = h.date
+	WHERE EXISTS (SELECT 1 FROM stock_history x WHERE x.symbol = h.symbol AND x.date >= p.exit_date)`, strings.Join(values, ", "))
+}
+
+// 按市场日历对齐的日收益矩阵：[股票][日期]，dates[0] 仅作为基准日，因此每行长度为 len(dates)-1。
+// 停牌或缺失的日期为 NaN；复牌首日收益相对停牌前最后一个收盘价计算。
+func returnMatrix(ctx context.Context, store *Store, symbols []string, dates []string) ([][]float64, error) {
+	if len(dates) < 2 {
+		return nil, fmt.Errorf("日期数不足: %d", len(dates))
+	}
+	index := make(map[string]int, len(dates))
+	for k, d := range dates {
+		index[d] = k
+	}
+	r := DateRange{From: dates[0], To: dates[len(dates)-1]}
+
+	out := make([][]float64, len(symbols))
+	for s, sym := range symbols {
+		row := make([]float64, len(dates)-1)
+		for k := range row {
+			row[k] = math.NaN()
+		}
+		bars, err := store.History(ctx, sym, r)
+		if err != nil {
+			return nil, err
+		}
+		prev := math.NaN()
+		for _, b := range bars {
+			k, ok := index[b.Date]
+			if !ok {
+				continue
+			}
+			if k > 0 && !math.IsNaN(prev) && prev > 0 {
+				row[k-1] = b.CloseAdj/prev - 1
+			}
+			prev = b.CloseAdj
+		}
+		out[s] = row
+	}
+	return out, nil
+}
+
+// 截止 to (含) 的最近 n 个交易日；to 为空表示到最新
+func lastTradingDates(all []string, to string, n int) []string {
+	end := len(all)
+	if to != "" {
+		end = sort.SearchStrings(all, to)
+		if end < len(all) && all[end] == to {
+			end++
+		}
+	}
+	start := end - n
+	if start < 0 {
+		start = 0
+	}
+	return all[start:end]
+}
+
+// 两个序列在双方都非 NaN 的位置上的 Pearson 相关和样本数
+func pairwiseCorr(x, y []float64) (float64, int) {
+	var xs, ys []float64
+	for k := range x {
+		if !math.IsNaN(x[k]) && !math.IsNaN(y[k]) {
+			xs = append(xs, x[k])
+			ys = append(ys, y[k])
+		}
+	}
+	return pearson(xs, ys), len(xs)
+}
+
+// 按市场日历对齐的后复权收盘价矩阵：[股票][日期]，缺失为 NaN
+func priceMatrix(ctx context.Context, store *Store, symbols []string, dates []string) ([][]float64, error) {
+	index := make(map[string]int, len(dates))
+	for k, d := range dates {
+		index[d] = k
+	}
+	r := DateRange{From: dates[0], To: dates[len(dates)-1]}
+
+	out := make([][]float64, len(symbols))
+	for s, sym := range symbols {
+		row := make([]float64, len(dates))
+		for k := range row {
+			row[k] = math.NaN()
+		}
+		bars, err := store.History(ctx, sym, r)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bars {
+			if k, ok := index[b.Date]; ok {
+				row[k] = b.CloseAdj
+			}
+		}
+		out[s] = row
+	}
+	return out, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 停牌跨过卖出日时用停牌前最后一个收盘价，卖出日之后没有数据的不产出；所有股票共用同一持有窗口
+func TestCalendarReturnSource(t *testing.T) {
+	db := openTestDB(t, "returns.db")
+	createTables(db)
+	for _, r := range []struct {
+		symbol, date string
+		close        float64
+	}{
+		{"000001", "2024-01-02", 10}, {"000001", "2024-01-03", 11}, {"000001", "2024-01-04", 12}, {"000001", "2024-01-05", 13},
+		{"000002", "2024-01-02", 20}, {"000002", "2024-01-03", 22}, {"000002", "2024-01-08", 30}, // 01-04 起停牌
+		{"000003", "2024-01-02", 5}, {"000003", "2024-01-03", 6}, // 01-03 后退市
+	} {
+		mustExec(db, "INSERT INTO stock_history (symbol, date, close_adj, market) VALUES (?, ?, ?, 'CN')", r.symbol, r.date, r.close)
+	}
+
+	got := dumpTable(t, db, "SELECT symbol, date, ROUND(value, 4) FROM ("+
+		calendarReturnSource([][2]string{{"2024-01-02", "2024-01-04"}})+") ORDER BY symbol")
+	want := "000001|2024-01-02|0.2\n000002|2024-01-02|0.1\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCalendarPeriods(t *testing.T) {
+	dates := []string{"d0", "d1", "d2", "d3", "d4", "d5"}
+	got := fmt.Sprint(calendarPeriods(dates, 2, 1), calendarPeriods(dates, 2, 2), calendarPeriods(dates, 6, 1))
+	want := "[[d0 d2] [d1 d3] [d2 d4] [d3 d5]] [[d0 d2] [d2 d4]] []"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"math"
+	"time"
+)
+
+// 风险模型：收益协方差矩阵估计
+//
+//	sample  样本协方差
+//	lw      Ledoit-Wolf 收缩 (收缩目标为 μ·I，收缩强度按 Ledoit & Wolf 2004 的解析公式估计)
+//	ewma    指数加权 (半衰期 --halflife 个交易日)
+//
+// 缺失收益 (停牌) 在去均值后按 0 处理，保证矩阵半正定，可直接交给优化器使用。
+func runRisk(args []string) {
+	fs := flag.NewFlagSet("risk", flag.ExitOnError)
+	symbolsArg := fs.String("symbols", "", "股票代码列表文件 (每行一个)，或逗号分隔的代码")
+	window := fs.Int("window", 250, "回看窗口 (交易日)")
+	to := fs.String("to", "", "截止日期 (YYYY-MM-DD，默认最新)")
+	method := fs.String("method", "lw", "估计方法: sample | lw | ewma")
+	halflife := fs.Float64("halflife", 60, "EWMA 半衰期 (交易日)")
+	annualize := fs.Bool("annualize", true, "是否年化 (×252)")
+	out := fs.String("out", "cov.csv", "输出 CSV 文件 (方阵)")
+	fs.Parse(args)
+
+	symbols, err := parseSymbols(*symbolsArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(symbols) < 2 {
+		log.Fatal("[ERROR] 至少需要两只股票 (--symbols)")
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+
+	dates := lastTradingDates(tradingDates(db, symbolsMarket(symbols)), *to, *window+1)
+	if len(dates) < 3 {
+		log.Fatalf("[ERROR] 截止 %q 的交易日不足", *to)
+	}
+	rets, err := returnMatrix(context.Background(), newStore(db), symbols, dates)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var cov [][]float64
+	switch *method {
+	case "sample":
+		cov = sampleCov(demeanFill(rets))
+	case "lw":
+		var shrink float64
+		cov, shrink = ledoitWolfCov(demeanFill(rets))
+		log.Printf(">>> Ledoit-Wolf 收缩强度: %.4f", shrink)
+	case "ewma":
+		cov = ewmaCov(rets, *halflife)
+	default:
+		log.Fatalf("[ERROR] 未知估计方法: %s", *method)
+	}
+
+	if *annualize {
+		for i := range cov {
+			for j := range cov[i] {
+				cov[i][j] *= 252
+			}
+		}
+	}
+	if err := writeMatrixCSV(*out, symbols, cov); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf(">>> ✅ 协方差矩阵 (%s, %d 只股票, %s ~ %s) 已写入 %s, 耗时: %s",
+		*method, len(symbols), dates[0], dates[len(dates)-1], *out, time.Since(start))
+}
+
+// 每只股票去均值，缺失值置 0；返回 [股票][日期]
+func demeanFill(rets [][]float64) [][]float64 {
+	out := make([][]float64, len(rets))
+	for i, row := range rets {
+		m := mean(dropNaN(row))
+		out[i] = make([]float64, len(row))
+		for t, v := range row {
+			if !math.IsNaN(v) && !math.IsNaN(m) {
+				out[i][t] = v - m
+			}
+		}
+	}
+	return out
+}
+
+// 已去均值数据的协方差 (除以 T，与 Ledoit-Wolf 推导一致)
+func sampleCov(x [][]float64) [][]float64 {
+	n := len(x)
+	cov := make([][]float64, n)
+	for i := range cov {
+		cov[i] = make([]float64, n)
+	}
+	if n == 0 {
+		return cov
+	}
+	T := float64(len(x[0]))
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			var s float64
+			for t := range x[i] {
+				s += x[i][t] * x[j][t]
+			}
+			cov[i][j], cov[j][i] = s/T, s/T
+		}
+	}
+	return cov
+}
+
+// Ledoit-Wolf 收缩：Σ = (1-δ)·S + δ·μ·I，返回矩阵和收缩强度 δ
+func ledoitWolfCov(x [][]float64) ([][]float64, float64) {
+	n := len(x)
+	S := sampleCov(x)
+	if n == 0 || len(x[0]) == 0 {
+		return S, 0
+	}
+	T := float64(len(x[0]))
+
+	var mu float64
+	for i := 0; i < n; i++ {
+		mu += S[i][i]
+	}
+	mu /= float64(n)
+
+	// d² = ||S - μI||² / n ；b² = (1/T²) Σ_t ||x_t x_t' - S||² / n，截断到 d²
+	var d2 float64
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			v := S[i][j]
+			if i == j {
+				v -= mu
+			}
+			d2 += v * v
+		}
+	}
+	d2 /= float64(n)
+
+	var b2 float64
+	for t := range x[0] {
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				v := x[i][t]*x[j][t] - S[i][j]
+				b2 += v * v
+			}
+		}
+	}
+	b2 /= T * T * float64(n)
+	b2 = math.Min(b2, d2)
+
+	shrink := 0.0
+	if d2 > 0 {
+		shrink = b2 / d2
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			S[i][j] *= 1 - shrink
+		}
+		S[i][i] += shrink * mu
+	}
+	return S, shrink
+}
+
+// 指数加权协方差：越近的日期权重越大，λ = 0.5^(1/halflife)
+func ewmaCov(rets [][]float64, halflife float64) [][]float64 {
+	n := len(rets)
+	cov := make([][]float64, n)
+	for i := range cov {
+		cov[i] = make([]float64, n)
+	}
+	if n == 0 {
+		return cov
+	}
+	T := len(rets[0])
+	lambda := math.Pow(0.5, 1/halflife)
+	w := make([]float64, T)
+	var sumW float64
+	for t := 0; t < T; t++ {
+		w[t] = math.Pow(lambda, float64(T-1-t))
+		sumW += w[t]
+	}
+
+	// 加权均值去均值，缺失置 0
+	x := make([][]float64, n)
+	for i, row := range rets {
+		var m, mw float64
+		for t, v := range row {
+			if !math.IsNaN(v) {
+				m += w[t] * v
+				mw += w[t]
+			}
+		}
+		if mw > 0 {
+			m /= mw
+		}
+		x[i] = make([]float64, T)
+		for t, v := range row {
+			if !math.IsNaN(v) {
+				x[i][t] = v - m
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			var s float64
+			for t := 0; t < T; t++ {
+				s += w[t] * x[i][t] * x[j][t]
+			}
+			cov[i][j], cov[j][i] = s/sumW, s/sumW
+		}
+	}
+	return cov
+}
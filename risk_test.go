@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// 对称且 Cholesky 分解成功 (正定)
+func checkSymmetricPD(t *testing.T, m [][]float64) {
+	t.Helper()
+	n := len(m)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if m[i][j] != m[j][i] {
+				t.Fatalf("不对称: [%d][%d] = %v, [%d][%d] = %v", i, j, m[i][j], j, i, m[j][i])
+			}
+		}
+	}
+	l := make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, n)
+		for j := 0; j <= i; j++ {
+			s := m[i][j]
+			for k := 0; k < j; k++ {
+				s -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if s <= 0 {
+					t.Fatalf("不是正定矩阵: 第 %d 个主元 %v", i, s)
+				}
+				l[i][i] = math.Sqrt(s)
+			} else {
+				l[i][j] = s / l[j][j]
+			}
+		}
+	}
+}
+
+// S = [[2, 1/6], [1/6, 2/3]]，μ = 4/3；d² = ((2/3)² + (2/3)² + 2·(1/6)²) / 2 = 17/36，
+// b² = Σ_t ||x_t x_t' - S||² / (T²·n) = 27/72 = 3/8，δ = b²/d² = 27/34
+func TestLedoitWolfCov(t *testing.T) {
+	x := [][]float64{
+		{1, -1, 1, -1, 2, -2},
+		{1, 0, -1, 1, 0, -1},
+	}
+	cov, shrink := ledoitWolfCov(x)
+	if math.Abs(shrink-27.0/34) > 1e-12 {
+		t.Errorf("shrink = %v, want %v", shrink, 27.0/34)
+	}
+	want := [][]float64{{25.0 / 17, 7.0 / 204}, {7.0 / 204, 61.0 / 51}}
+	for i := range want {
+		for j := range want[i] {
+			if math.Abs(cov[i][j]-want[i][j]) > 1e-12 {
+				t.Errorf("cov[%d][%d] = %v, want %v", i, j, cov[i][j], want[i][j])
+			}
+		}
+	}
+	checkSymmetricPD(t, cov)
+
+	// 股票数多于天数时样本协方差奇异，收缩后仍正定
+	rng := rand.New(rand.NewSource(1))
+	rets := make([][]float64, 8)
+	for i := range rets {
+		rets[i] = make([]float64, 5)
+		for k := range rets[i] {
+			rets[i][k] = rng.NormFloat64() * 0.02
+		}
+	}
+	cov, shrink = ledoitWolfCov(demeanFill(rets))
+	if shrink <= 0 || shrink > 1 {
+		t.Errorf("shrink = %v, want (0, 1]", shrink)
+	}
+	checkSymmetricPD(t, cov)
+}
+
+// 半衰期 1 天：权重依次为 1/4、1/2、1。同样大小的冲击出现在最近一天时方差是出现在最早一天时的两倍
+func TestEwmaCovHalflife(t *testing.T) {
+	cov := ewmaCov([][]float64{
+		{1, 0, 0},
+		{0, 0, 1},
+		{math.NaN(), 0, 1}, // 缺失不参与加权均值
+	}, 1)
+	for _, c := range []struct {
+		i, j int
+		want float64
+	}{
+		// 均值 (1/4)/(7/4) = 1/7，Σw(x-m)² = (1/4)(6/7)² + (1/2)(1/7)² + (1/7)² = 3/14，除以 Σw = 7/4
+		{0, 0, 6.0 / 49},
+		// 均值 4/7，Σw(x-m)² = (1/4 + 1/2)(4/7)² + (3/7)² = 3/7
+		{1, 1, 12.0 / 49},
+		{0, 1, (0.25*(6.0/7)*(-4.0/7) + 0.5*(-1.0/7)*(-4.0/7) + (-1.0/7)*(3.0/7)) / 1.75},
+		// 均值 1/(1/2 + 1) = 2/3，缺失当天记为 0
+		{2, 2, (0.5*(4.0/9) + 1.0/9) / 1.75},
+	} {
+		if math.Abs(cov[c.i][c.j]-c.want) > 1e-12 {
+			t.Errorf("cov[%d][%d] = %v, want %v", c.i, c.j, cov[c.i][c.j], c.want)
+		}
+	}
+	if math.Abs(cov[1][1]/cov[0][0]-2) > 1e-12 {
+		t.Errorf("最近一天的冲击与最早一天之比 = %v, want 2", cov[1][1]/cov[0][0])
+	}
+	checkSymmetricPD(t, cov)
+}
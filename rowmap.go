@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// ---------------------------------------------------------
+// 行映射
+// ---------------------------------------------------------
+// 上亿行的导入中，每行切出的字段字符串、映射结果的 []any 都是短命的小对象，GC 占了相当一部分 CPU。
+// 流水线 (pipeline.go) 的映射函数因此直接处理字节：fields 指向读缓冲区，由解析器逐行复用，
+// 只在本次调用内有效；需要写进结果的文本通过 rowArena 取得：
+//
+//	a.text(b)    复制到当前块的文本存储 (按 64KB 成块分配)
+//	a.intern(b)  同一文件中反复出现的值 (代码、日期) 只分配一次
+//	a.row(n)     从当前块共用的数组中切出一行
+//
+// rowArena 属于单个文件的解析 goroutine，每发出一块就换新的存储 (已发出的块不会再被改写)。
+// 写入量不大的数据集仍用 func([]string) []any 编写，由 recordMapper 转换。
+
+// 返回 nil 表示该行无效 (列数不足、代码或日期为空等，--strict 时终止导入，见 strict.go)；
+// 有意略过的行 (如 update 中库里已有的行) 返回 filteredRow
+type rowMapper func(a *rowArena, fields [][]byte) []any
+
+var filteredRow = []any{}
+
+const arenaTextBlock = 64 << 10
+
+type rowArena struct {
+	vals    []any
+	buf     []byte
+	record  []string
+	interns map[string]any
+	symbols map[string]any
+	dates   map[string]any
+}
+
+// 每个缓存最多保留的条目 (防止某列全是不同的值时无限增长)
+const arenaInternLimit = 1 << 16
+
+// 换新的存储，之前返回的行与文本归已发出的块所有
+func (a *rowArena) reset() {
+	a.vals, a.buf = nil, nil
+}
+
+func (a *rowArena) row(n int) []any {
+	if cap(a.vals)-len(a.vals) < n {
+		a.vals = make([]any, 0, max(n*importChunkRows, n))
+	}
+	k := len(a.vals)
+	a.vals = a.vals[:k+n]
+	return a.vals[k : k+n : k+n]
+}
+
+func (a *rowArena) text(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if cap(a.buf)-len(a.buf) < len(b) {
+		a.buf = make([]byte, 0, max(len(b), arenaTextBlock))
+	}
+	k := len(a.buf)
+	a.buf = append(a.buf, b...)
+	// 块内的字节只追加、不改写，可以直接作为字符串使用
+	return unsafe.String(&a.buf[k], len(b))
+}
+
+// 从缓存取 norm(string(b)) 的结果，没有时计算并缓存
+func (a *rowArena) memo(m *map[string]any, b []byte, norm func(string) any) any {
+	if v, ok := (*m)[string(b)]; ok {
+		return v
+	}
+	if *m == nil || len(*m) >= arenaInternLimit {
+		*m = map[string]any{}
+	}
+	s := string(b)
+	v := norm(s)
+	(*m)[s] = v
+	return v
+}
+
+// 原样的文本 (string)
+func (a *rowArena) intern(b []byte) any {
+	return a.memo(&a.interns, b, func(s string) any { return s })
+}
+
+// 代码：同 normText 并转为大写 (string 或 nil)
+func (a *rowArena) symbol(b []byte) any {
+	return a.memo(&a.symbols, b, func(s string) any {
+		if v, ok := normText(s).(string); ok {
+			return strings.ToUpper(v)
+		}
+		return nil
+	})
+}
+
+// 日期：同 normDate
+func (a *rowArena) date(b []byte) any {
+	return a.memo(&a.dates, b, normDate)
+}
+
+// 转为 []string (复用同一个切片，字符串在块的文本存储中)
+func (a *rowArena) stringFields(fields [][]byte) []string {
+	a.record = a.record[:0]
+	for _, f := range fields {
+		a.record = append(a.record, a.text(f))
+	}
+	return a.record
+}
+
+// 把按字符串编写的映射函数转为 rowMapper
+func recordMapper(m func(record []string) []any) rowMapper {
+	if m == nil {
+		return nil
+	}
+	return func(a *rowArena, fields [][]byte) []any {
+		return m(a.stringFields(fields))
+	}
+}
+
+func recordMappers(newMapper func(header []string) func([]string) []any) func(header []string) rowMapper {
+	return func(header []string) rowMapper {
+		return recordMapper(newMapper(header))
+	}
+}
+
+// 取第 k 列，越界时返回空 (同 col)
+func field(fields [][]byte, k int) []byte {
+	if k >= 0 && k < len(fields) {
+		return fields[k]
+	}
+	return nil
+}
+
+// 同 normNum，直接解析字节
+func normNumBytes(b []byte) any {
+	b = bytes.TrimSpace(b)
+	switch string(b) {
+	case "", "--", "-", "NaN", "nan", "NULL", "null", "None":
+		return nil
+	}
+	if bytes.IndexByte(b, ',') >= 0 {
+		return normNum(string(b))
+	}
+	v, err := strconv.ParseFloat(string(b), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return v
+}
+
+// 复制成独立的字符串 (调试输出等需要保留整条记录时)
+func fieldStrings(fields [][]byte) []string {
+	out := make([]string, len(fields))
+	for k, f := range fields {
+		out[k] = string(f)
+	}
+	return out
+}
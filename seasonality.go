@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 日历效应统计：星期效应、月末月初效应、节前节后效应。
+// 不指定 --symbol 时使用全市场等权日收益。
+func runSeasonality(args []string) {
+	fs := flag.NewFlagSet("seasonality", flag.ExitOnError)
+	symbol := fs.String("symbol", "", "股票代码 (默认全市场等权)")
+	market := fs.String("market", MarketCN, "不指定 --symbol 时统计的市场: CN | HK | US")
+	from := fs.String("from", "", "起始日期 (YYYY-MM-DD)")
+	to := fs.String("to", "", "截止日期 (YYYY-MM-DD)")
+	out := fs.String("out", "seasonality.csv", "输出 CSV 文件")
+	fs.Parse(args)
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+
+	// 日历用全市场交易日，节假日判断不受个股停牌影响
+	if *symbol != "" {
+		*market = marketOf(*symbol)
+	}
+	dates := tradingDates(db, *market)
+	pre, post := holidayFlags(dates)
+	fromStart, fromEnd := monthOffsets(dates)
+
+	rets, err := seasonalityReturns(db, *symbol, *market)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	type key struct{ category, bucket string }
+	// 固定输出顺序
+	order := []key{
+		{"星期", "周一"}, {"星期", "周二"}, {"星期", "周三"}, {"星期", "周四"}, {"星期", "周五"},
+		{"月末月初", "月末最后一天"}, {"月末月初", "月初第1天"}, {"月末月初", "月初第2天"},
+		{"月末月初", "月初第3天"}, {"月末月初", "其他"},
+		{"节假日", "节前最后一天"}, {"节假日", "节后第一天"}, {"节假日", "普通交易日"},
+	}
+	samples := map[key][]float64{}
+	add := func(k key, v float64) { samples[k] = append(samples[k], v) }
+
+	weekdays := []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+	for k, d := range dates {
+		if (*from != "" && d < *from) || (*to != "" && d > *to) {
+			continue
+		}
+		r, ok := rets[d]
+		if !ok {
+			continue
+		}
+		t, _ := time.Parse(time.DateOnly, d)
+		add(key{"星期", weekdays[t.Weekday()]}, r)
+
+		switch {
+		case fromEnd[k] == -1:
+			add(key{"月末月初", "月末最后一天"}, r)
+		case fromStart[k] <= 3:
+			add(key{"月末月初", fmt.Sprintf("月初第%d天", fromStart[k])}, r)
+		default:
+			add(key{"月末月初", "其他"}, r)
+		}
+
+		switch {
+		case pre[k]:
+			add(key{"节假日", "节前最后一天"}, r)
+		case post[k]:
+			add(key{"节假日", "节后第一天"}, r)
+		default:
+			add(key{"节假日", "普通交易日"}, r)
+		}
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"category", "bucket", "n", "mean", "std", "t"})
+
+	target := *symbol
+	if target == "" {
+		target = *market + " 全市场等权"
+	}
+	log.Printf(">>> 日历效应 (%s):", target)
+	for _, k := range order {
+		s := samples[k]
+		if len(s) == 0 {
+			continue
+		}
+		m, sd := mean(s), stddev(s)
+		t := m / (sd / math.Sqrt(float64(len(s))))
+		w.Write([]string{k.category, k.bucket, strconv.Itoa(len(s)), formatFloat(m), formatFloat(sd), formatFloat(t)})
+		log.Printf("    %-6s %-8s n=%-6d 均值=%+.4f%% t=%+.2f", k.category, k.bucket, len(s), 100*m, t)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf(">>> ✅ 报告已写入 %s, 耗时: %s", *out, time.Since(start))
+}
+
+// 日期 -> 日收益
+func seasonalityReturns(db *sql.DB, symbol, market string) (map[string]float64, error) {
+	out := map[string]float64{}
+	if symbol != "" {
+		bars, err := newStore(db).History(context.Background(), symbol, DateRange{})
+		if err != nil {
+			return nil, err
+		}
+		if len(bars) == 0 {
+			return nil, fmt.Errorf("没有 %s 的数据", symbol)
+		}
+		for k := 1; k < len(bars); k++ {
+			out[bars[k].Date] = bars[k].CloseAdj/bars[k-1].CloseAdj - 1
+		}
+		return out, nil
+	}
+
+	rows, err := db.Query(`SELECT date, AVG(ret) FROM (
+		SELECT date, close_adj / LAG(close_adj) OVER (PARTITION BY symbol ORDER BY date) - 1 AS ret
+		FROM stock_history WHERE market = ?
+	) WHERE ret IS NOT NULL GROUP BY date`, market)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d string
+		var r float64
+		if err := rows.Scan(&d, &r); err != nil {
+			return nil, err
+		}
+		out[d] = r
+	}
+	return out, rows.Err()
+}
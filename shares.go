@@ -0,0 +1,41 @@
+package main
+
+// 股本变动历史：每次变动 (送转、增发、解禁等) 记一行，变动日起生效直到下一次变动。
+// 市值按 “当日原始收盘价 × 当日有效股本” 现算 (见视图 v_market_cap)，
+// 不依赖供应商可能滞后的市值列，也支持自由流通市值加权。以下字段可直接作为因子名使用：
+//   - market_cap         总市值
+//   - float_market_cap   流通市值
+//   - free_float_cap     自由流通市值 (未提供自由流通股本时为 NULL)
+//
+// 股本单位为股。
+//
+// 索引：0:代码, 1:变动日期, 2:总股本, 3:流通股本, 4:自由流通股本, 5:变动原因 (4 之后可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "股本变动",
+		Pattern: PathShareStructure,
+		Table:   "share_structure",
+		DDL: `CREATE TABLE IF NOT EXISTS share_structure (
+			symbol             TEXT NOT NULL,
+			change_date        TEXT NOT NULL,
+			total_shares       REAL,
+			float_shares       REAL,
+			free_float_shares  REAL,
+			reason             TEXT,
+			PRIMARY KEY (symbol, change_date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 4,
+		Mapper: func(record []string) []any {
+			symbol := normText(record[0])
+			date := normDate(record[1])
+			if symbol == nil || date == nil {
+				return nil
+			}
+			return []any{symbol, date, normNum(record[2]), normNum(record[3]), normNum(col(record, 4)), normText(col(record, 5))}
+		},
+	})
+
+	registerDerivedColumn("market_cap", "v_market_cap")
+	registerDerivedColumn("float_market_cap", "v_market_cap")
+	registerDerivedColumn("free_float_cap", "v_market_cap")
+}
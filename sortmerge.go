@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"database/sql"
+	"encoding/binary"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 外部排序归并
+// ---------------------------------------------------------
+// 数据量很大时，mergeStaging 的 SQL 关联 (建索引 + 嵌套查找) 是整个导入中最长、且没有任何输出的一步。
+// import --merge sort (update files 同) 改为：顺序扫描两张 staging 表，各自按 (代码, 日期) 外部排序
+// (每攒满 sortRunRows 行在内存中排好序写成一个临时文件，最后多路归并)，再对两路有序数据做归并关联，
+// 逐行写入 stock_history，过程中定期打印进度。清洗表达式与 mergeStaging 相同 (stagingHistoryColumns)。
+// 临时文件写在 import.temp_dir (默认系统临时目录)，结束后删除。
+
+var mergeMethods = []string{"sql", "sort"}
+
+// 每个排序段的行数 (设置内存预算时按预算计算，见 memory.go)
+var sortRunRows = 1 << 20
+
+// 按 method 合并 staging 表
+func mergeStagingBy(db *sql.DB, method string) {
+	checkStagingStrict(db)
+	if method == "sort" {
+		mergeStagingSorted(db)
+		return
+	}
+	mergeStaging(db)
+}
+
+func checkMergeMethod(method string) {
+	if !containsString(mergeMethods, method) {
+		log.Fatalf("[ERROR] --merge 必须是 sql / sort: %q", method)
+	}
+}
+
+// 行的前两列为代码、日期 (string)，其余为 string 或 nil
+func compareSortKeys(a, b []any) int {
+	if c := cmp.Compare(a[0].(string), b[0].(string)); c != 0 {
+		return c
+	}
+	return cmp.Compare(a[1].(string), b[1].(string))
+}
+
+type externalSorter struct {
+	buf  [][]any
+	runs []string
+	rows int
+}
+
+func (s *externalSorter) add(row []any) error {
+	s.buf = append(s.buf, row)
+	s.rows++
+	if len(s.buf) < sortRunRows {
+		return nil
+	}
+	return s.spill()
+}
+
+// 把内存中的行排序后写成一个临时文件
+func (s *externalSorter) spill() error {
+	slices.SortStableFunc(s.buf, compareSortKeys)
+	f, err := os.CreateTemp(importOptions.tempDir, "chronos-sort-*.run")
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, f.Name())
+	w := bufio.NewWriterSize(f, 1<<20)
+	var scratch []byte
+	for _, row := range s.buf {
+		scratch = scratch[:0]
+		for _, v := range row {
+			// 长度 + 1 后接内容，0 表示 NULL
+			if str, ok := v.(string); ok {
+				scratch = binary.AppendUvarint(scratch, uint64(len(str))+1)
+				scratch = append(scratch, str...)
+			} else {
+				scratch = binary.AppendUvarint(scratch, 0)
+			}
+		}
+		if _, err := w.Write(scratch); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	s.buf = s.buf[:0]
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// 删除临时文件
+func (s *externalSorter) cleanup() {
+	for _, path := range s.runs {
+		os.Remove(path)
+	}
+}
+
+// 按顺序读出全部行 (内存中剩余的行作为最后一段，不再写盘)
+func (s *externalSorter) sorted(width int) (*sortedRows, error) {
+	slices.SortStableFunc(s.buf, compareSortKeys)
+	m := &sortedRows{}
+	for k, path := range s.runs {
+		f, err := os.Open(path)
+		if err != nil {
+			m.close()
+			return nil, err
+		}
+		m.files = append(m.files, f)
+		src := &sortRun{order: k, r: bufio.NewReaderSize(f, 1<<20), width: width}
+		if err := m.push(src); err != nil {
+			m.close()
+			return nil, err
+		}
+	}
+	if err := m.push(&sortRun{order: len(s.runs), mem: s.buf}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// 一个有序段：临时文件或内存中的切片
+type sortRun struct {
+	order int
+	r     *bufio.Reader
+	width int
+	mem   [][]any
+	row   []any
+}
+
+func (s *sortRun) next() error {
+	if s.r == nil {
+		if len(s.mem) == 0 {
+			s.row = nil
+			return nil
+		}
+		s.row, s.mem = s.mem[0], s.mem[1:]
+		return nil
+	}
+	row := make([]any, s.width)
+	for k := range row {
+		n, err := binary.ReadUvarint(s.r)
+		if err == io.EOF && k == 0 {
+			s.row = nil
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			continue
+		}
+		b := make([]byte, n-1)
+		if _, err := io.ReadFull(s.r, b); err != nil {
+			return err
+		}
+		row[k] = string(b)
+	}
+	s.row = row
+	return nil
+}
+
+// 多路归并 (键相同时按段的先后，保持写入顺序)
+type sortedRows struct {
+	heap  runHeap
+	files []*os.File
+	err   error
+}
+
+func (m *sortedRows) push(src *sortRun) error {
+	if err := src.next(); err != nil {
+		return err
+	}
+	if src.row != nil {
+		heap.Push(&m.heap, src)
+	}
+	return nil
+}
+
+// 下一行，结束或出错时返回 nil (错误见 err)
+func (m *sortedRows) next() []any {
+	if m.err != nil || len(m.heap) == 0 {
+		return nil
+	}
+	src := m.heap[0]
+	row := src.row
+	if m.err = src.next(); m.err != nil {
+		return nil
+	}
+	if src.row == nil {
+		heap.Pop(&m.heap)
+	} else {
+		heap.Fix(&m.heap, 0)
+	}
+	return row
+}
+
+func (m *sortedRows) close() {
+	for _, f := range m.files {
+		f.Close()
+	}
+}
+
+type runHeap []*sortRun
+
+func (h runHeap) Len() int { return len(h) }
+func (h runHeap) Less(i, j int) bool {
+	if c := compareSortKeys(h[i].row, h[j].row); c != 0 {
+		return c < 0
+	}
+	return h[i].order < h[j].order
+}
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)   { *h = append(*h, x.(*sortRun)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// 顺序扫描 staging 表并排序；代码或日期为 NULL 的行不参与关联，直接丢弃
+func sortStagingTable(db *sql.DB, table string) (*externalSorter, error) {
+	cols := stagingColumns[table]
+	rows, err := db.Query("SELECT * FROM " + table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	s := &externalSorter{}
+	vals := make([]sql.NullString, len(cols))
+	dest := make([]any, len(cols))
+	for k := range vals {
+		dest[k] = &vals[k]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			s.cleanup()
+			return nil, err
+		}
+		if !vals[0].Valid || !vals[1].Valid {
+			continue
+		}
+		row := make([]any, len(cols))
+		for k, v := range vals {
+			if v.Valid {
+				row[k] = v.String
+			}
+		}
+		if err := s.add(row); err != nil {
+			s.cleanup()
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		s.cleanup()
+		return nil, err
+	}
+	return s, nil
+}
+
+// 与 mergeStaging 结果相同：外部排序两张 staging 表后归并关联写入 stock_history，完成后清空 staging 表
+func mergeStagingSorted(db *sql.DB) {
+	start := time.Now()
+	stale := stagedSymbols(db)
+	log.Println(">>> 正在排序 staging 表 (外部排序归并)...")
+	tech, err := sortStagingTable(db, "staging_tech")
+	if err != nil {
+		log.Fatalf("[ERROR] 读取 staging_tech 失败: %v", err)
+	}
+	defer tech.cleanup()
+	daily, err := sortStagingTable(db, "staging_daily")
+	if err != nil {
+		log.Fatalf("[ERROR] 读取 staging_daily 失败: %v", err)
+	}
+	defer daily.cleanup()
+	log.Printf(">>> 排序完成: 技术因子 %d 行 (%d 段), 每日指标 %d 行 (%d 段), 耗时: %s",
+		tech.rows, len(tech.runs)+1, daily.rows, len(daily.runs)+1, time.Since(start))
+
+	t, err := tech.sorted(len(stagingColumns["staging_tech"]))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer t.close()
+	d, err := daily.sorted(len(stagingColumns["staging_daily"]))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer d.close()
+
+	log.Println(">>> 正在归并关联并写入 stock_history...")
+	ensureProvenanceTable(db)
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	stmt, err := tx.Prepare(streamInsertSQL())
+	if err != nil {
+		log.Fatal(err)
+	}
+	read, written := 0, 0
+	lastReport := time.Now()
+	var args []any
+	var group [][]any
+	trow, drow := t.next(), d.next()
+	for trow != nil && drow != nil {
+		switch c := compareSortKeys(trow, drow); {
+		case c < 0:
+			trow = t.next()
+			read++
+		case c > 0:
+			drow = d.next()
+		default:
+			// 同一 (代码, 日期) 的每日指标可能有多行，与 SQL 关联一样逐一配对
+			group = append(group[:0], drow)
+			for drow = d.next(); drow != nil && compareSortKeys(drow, group[0]) == 0; drow = d.next() {
+				group = append(group, drow)
+			}
+			for ; trow != nil && compareSortKeys(trow, group[0]) == 0; trow = t.next() {
+				read++
+				for _, g := range group {
+					args = append(append(args[:0], trow...), g[2])
+					if _, err := stmt.Exec(args...); err != nil {
+						log.Fatalf("[ERROR] 写入 stock_history 失败: %v", err)
+					}
+					written++
+				}
+			}
+		}
+		if time.Since(lastReport) >= 10*time.Second {
+			lastReport = time.Now()
+			log.Printf(">>> 合并进度: 技术因子 %d / %d 行 (%.1f%%), 已写入 %d 行", read, tech.rows, 100*float64(read)/float64(max(tech.rows, 1)), written)
+		}
+	}
+	if t.err != nil || d.err != nil {
+		tx.Rollback()
+		log.Fatalf("[ERROR] 读取排序临时文件失败: %v", cmp.Or(t.err, d.err))
+	}
+	stmt.Close()
+	if err := clearMinuteProvenance(tx); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := tx.Exec("DELETE FROM staging_tech;"); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := tx.Exec("DELETE FROM staging_daily;"); err != nil {
+		log.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf(">>> 归并完成: 写入 stock_history %d 行, 耗时: %s", written, time.Since(start))
+	historyChanged(db, stale)
+}
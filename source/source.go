@@ -0,0 +1,95 @@
+// Package source 是 chronos 的第三方数据源适配器接口。
+//
+// 私有供应商的数据 (专有文件格式、内部接口) 可以在自己的 Go 模块中实现 DataSource，
+// 在 init() 中调用 Register 注册，再在 chronos 的构建中以空白导入引入该模块：
+//
+//	import _ "example.com/acme/chronos-acme"
+//
+// chronos source run <name> 先 Discover 列出要读的单元 (文件路径、请求参数等)，逐个 Read 成行，
+// 按 Schema 写入目标表，每个单元一个事务。实现了 Configurable 的适配器会先收到 sources.yaml 中
+// 以其名称命名的配置节。
+package source
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// 适配器输出的目标表与列，Next 返回的值与 Columns 一一对应
+type Schema struct {
+	Table    string
+	Columns  []string
+	Conflict string // 可选，追加在 INSERT 之后的 ON CONFLICT 子句；为空时同键覆盖 (INSERT OR REPLACE)
+}
+
+// 逐行读取，读完返回 io.EOF
+type RowIterator interface {
+	Next() ([]any, error)
+	Close() error
+}
+
+type DataSource interface {
+	Name() string
+	Discover(ctx context.Context) ([]string, error)
+	Read(ctx context.Context, item string) (RowIterator, error)
+	Schema() Schema
+}
+
+// 可选接口：接收 sources.yaml 中的配置节 (文件不存在或没有该节时为空映射)
+type Configurable interface {
+	DataSource
+	Configure(cfg map[string]any) error
+}
+
+var (
+	mu      sync.Mutex
+	sources []DataSource
+)
+
+// 注册数据源，名称重复时 panic (通常在 init() 中调用)
+func Register(s DataSource) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, d := range sources {
+		if d.Name() == s.Name() {
+			panic("数据源重复注册: " + s.Name())
+		}
+	}
+	sources = append(sources, s)
+}
+
+// 按名称查找已注册的数据源，没有时返回 nil
+func Lookup(name string) DataSource {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, d := range sources {
+		if d.Name() == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// 已注册的全部数据源 (按注册顺序)
+func All() []DataSource {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]DataSource(nil), sources...)
+}
+
+// 切片形式的 RowIterator，便于一次性读完的适配器使用
+type SliceRows struct {
+	Rows [][]any
+	pos  int
+}
+
+func (r *SliceRows) Next() ([]any, error) {
+	if r.pos >= len(r.Rows) {
+		return nil, io.EOF
+	}
+	r.pos++
+	return r.Rows[r.pos-1], nil
+}
+
+func (r *SliceRows) Close() error { return nil }
@@ -0,0 +1,54 @@
+package source_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"chronos/source"
+)
+
+// 在 chronos 之外实现并注册的适配器
+type ticks struct{}
+
+func (ticks) Name() string { return "test-ticks" }
+
+func (ticks) Discover(ctx context.Context) ([]string, error) { return []string{"a"}, nil }
+
+func (ticks) Read(ctx context.Context, item string) (source.RowIterator, error) {
+	return &source.SliceRows{Rows: [][]any{{item, 1.5}, {item, 2.5}}}, nil
+}
+
+func (ticks) Schema() source.Schema {
+	return source.Schema{Table: "ticks", Columns: []string{"symbol", "price"}}
+}
+
+func TestRegister(t *testing.T) {
+	source.Register(ticks{})
+	s := source.Lookup("test-ticks")
+	if s == nil || source.Lookup("nope") != nil {
+		t.Fatalf("Lookup = %v", s)
+	}
+	it, err := s.Read(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for {
+		if _, err := it.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("读出 %d 行, want 2", n)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("重复注册应当 panic")
+		}
+	}()
+	source.Register(ticks{})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// 在线数据源的配置 (API token 等) 统一放在 sources.yaml，按数据源名分节：
+//
+//	tushare:
+//	  token: xxxxxxxx
+//	baostock:
+//	  user: anonymous
+//
+// 配置文件不存在时视为空配置，各数据源再回退到环境变量。
+const DefaultSourcesConfig = "sources.yaml"
+
+// 在线数据源子命令启动时调用：按配置建立限速器 (ratelimit.go)、响应缓存 (cache.go) 与代理 (proxy.go)
+func setupSources(configPath string) error {
+	if err := setupRateLimits(configPath); err != nil {
+		return err
+	}
+	if err := setupResponseCache(configPath); err != nil {
+		return err
+	}
+	return setupProxy(configPath)
+}
+
+func loadSourceConfig(path, name string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	root, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: 顶层必须是映射", path)
+	}
+	section, _ := root[name].(map[string]any)
+	if section == nil {
+		section = map[string]any{}
+	}
+	return section, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// ---------------------------------------------------------
+// 基础统计函数 (截面/时序分析共用)
+// ---------------------------------------------------------
+
+func mean(x []float64) float64 {
+	if len(x) == 0 {
+		return math.NaN()
+	}
+	var s float64
+	for _, v := range x {
+		s += v
+	}
+	return s / float64(len(x))
+}
+
+// 样本标准差 (n-1)
+func stddev(x []float64) float64 {
+	if len(x) < 2 {
+		return math.NaN()
+	}
+	m := mean(x)
+	var ss float64
+	for _, v := range x {
+		ss += (v - m) * (v - m)
+	}
+	return math.Sqrt(ss / float64(len(x)-1))
+}
+
+// Pearson 相关系数，任一序列方差为 0 时返回 NaN
+func pearson(x, y []float64) float64 {
+	if len(x) != len(y) || len(x) < 2 {
+		return math.NaN()
+	}
+	mx, my := mean(x), mean(y)
+	var sxy, sxx, syy float64
+	for k := range x {
+		dx, dy := x[k]-mx, y[k]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return math.NaN()
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+// Spearman 秩相关 = 秩次上的 Pearson
+func spearman(x, y []float64) float64 {
+	return pearson(ranks(x), ranks(y))
+}
+
+// 计算秩次 (从 1 开始)，并列值取平均秩
+func ranks(x []float64) []float64 {
+	idx := make([]int, len(x))
+	for k := range idx {
+		idx[k] = k
+	}
+	sort.Slice(idx, func(a, b int) bool { return x[idx[a]] < x[idx[b]] })
+
+	r := make([]float64, len(x))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && x[idx[j+1]] == x[idx[i]] {
+			j++
+		}
+		avg := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			r[idx[k]] = avg
+		}
+		i = j + 1
+	}
+	return r
+}
+
+// 过滤掉 NaN，返回剩余值
+func dropNaN(x []float64) []float64 {
+	out := make([]float64, 0, len(x))
+	for _, v := range x {
+		if !math.IsNaN(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// 最小二乘回归 y = Xβ + ε (X 每行一个样本，需自带常数列)
+// 返回系数、系数标准误；X'X 奇异时返回 ok=false
+func ols(X [][]float64, y []float64) (beta, se []float64, ok bool) {
+	n := len(X)
+	if n == 0 {
+		return nil, nil, false
+	}
+	k := len(X[0])
+	if n <= k {
+		return nil, nil, false
+	}
+	xtx := make([][]float64, k)
+	xty := make([]float64, k)
+	for i := range xtx {
+		xtx[i] = make([]float64, k)
+	}
+	for r := 0; r < n; r++ {
+		for i := 0; i < k; i++ {
+			xty[i] += X[r][i] * y[r]
+			for j := 0; j < k; j++ {
+				xtx[i][j] += X[r][i] * X[r][j]
+			}
+		}
+	}
+	inv, ok := invertMatrix(xtx)
+	if !ok {
+		return nil, nil, false
+	}
+	beta = make([]float64, k)
+	for i := 0; i < k; i++ {
+		for j := 0; j < k; j++ {
+			beta[i] += inv[i][j] * xty[j]
+		}
+	}
+	var rss float64
+	for r := 0; r < n; r++ {
+		fit := 0.0
+		for i := 0; i < k; i++ {
+			fit += X[r][i] * beta[i]
+		}
+		rss += (y[r] - fit) * (y[r] - fit)
+	}
+	sigma2 := rss / float64(n-k)
+	se = make([]float64, k)
+	for i := 0; i < k; i++ {
+		se[i] = math.Sqrt(sigma2 * inv[i][i])
+	}
+	return beta, se, true
+}
+
+// Gauss-Jordan 求逆 (部分主元)，矩阵接近奇异时返回 ok=false
+func invertMatrix(a [][]float64) ([][]float64, bool) {
+	n := len(a)
+	m := make([][]float64, n)
+	for i := range a {
+		m[i] = make([]float64, 2*n)
+		copy(m[i], a[i])
+		m[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(m[r][col]) > math.Abs(m[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		p := m[col][col]
+		for j := range m[col] {
+			m[col][j] /= p
+		}
+		for r := 0; r < n; r++ {
+			if r == col || m[r][col] == 0 {
+				continue
+			}
+			f := m[r][col]
+			for j := range m[r] {
+				m[r][j] -= f * m[col][j]
+			}
+		}
+	}
+	inv := make([][]float64, n)
+	for i := range m {
+		inv[i] = m[i][n:]
+	}
+	return inv, true
+}
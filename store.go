@@ -0,0 +1,130 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"math"
+	"sync"
+)
+
+// ---------------------------------------------------------
+// Store：面向 Go 代码的查询接口 (因子插件、分析命令共用)
+// ---------------------------------------------------------
+
+// 日期区间 (闭区间，YYYY-MM-DD)，空字符串表示不限
+type DateRange struct {
+	From, To string
+}
+
+// 生成 "col >= ? AND col <= ?" 形式的条件，无限制时返回 "1=1"
+func (r DateRange) where(col string) (string, []any) {
+	cond := "1=1"
+	var args []any
+	if r.From != "" {
+		cond += " AND " + col + " >= ?"
+		args = append(args, r.From)
+	}
+	if r.To != "" {
+		cond += " AND " + col + " <= ?"
+		args = append(args, r.To)
+	}
+	return cond, args
+}
+
+// 闭区间的上下界，不限时分别为 "" 与 "9999-12-31" (用于固定形状的语句)
+func (r DateRange) bounds() (from, to string) {
+	return r.From, cmp.Or(r.To, "9999-12-31")
+}
+
+// 一根日线，缺失值为 NaN
+type Bar struct {
+	Symbol   string
+	Date     string
+	Close    float64 // 不复权收盘
+	CloseAdj float64
+	OpenAdj  float64
+	HighAdj  float64
+	LowAdj   float64
+	PE       float64
+	Volume   float64 // 股
+}
+
+type Store struct {
+	db *sql.DB
+
+	mu       sync.Mutex
+	stmts    map[string]*sql.Stmt // 只在 OpenStore 打开的连接池上缓存 (见 readpool.go)
+	stmtHits int64                // 复用已编译语句的次数
+	cache    *resultCache         // 只在 OpenStore 打开的 Store 上缓存 (见 querycache.go)
+}
+
+func newStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// 底层连接，供需要自定义 SQL 的场景使用
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Store 的固定查询 (连接池上预编译，见 readpool.go)。
+// 日期区间写成固定的上下界而不是按 DateRange 拼接条件，各种区间共用一条语句和同一个执行计划
+var (
+	symbolsSQL = `SELECT DISTINCT symbol FROM stock_history ORDER BY symbol`
+	historySQL = `SELECT symbol, date, close, close_adj, open_adj, high_adj, low_adj, pe, volume
+	FROM stock_history WHERE symbol = ? AND date >= ? AND date <= ? ORDER BY date`
+	crossSectionSQL = `SELECT symbol, date, close, close_adj, open_adj, high_adj, low_adj, pe, volume
+	FROM stock_history WHERE date = ? ORDER BY symbol`
+)
+
+// 全部股票代码
+func (s *Store) Symbols(ctx context.Context) ([]string, error) {
+	return cachedRows(ctx, s, "symbols", func() ([]string, error) {
+		return s.queryStrings(ctx, symbolsSQL)
+	})
+}
+
+// 单只股票的日线，按日期升序
+func (s *Store) History(ctx context.Context, symbol string, r DateRange) ([]Bar, error) {
+	return cachedRows(ctx, s, "history|"+symbol+"|"+r.From+"|"+r.To, func() ([]Bar, error) {
+		from, to := r.bounds()
+		return s.queryBars(ctx, historySQL, symbol, from, to)
+	})
+}
+
+// 某一交易日全部股票的日线截面，按代码排序 (stock_history 没有日期索引，未命中缓存时扫描全表)
+func (s *Store) CrossSection(ctx context.Context, date string) ([]Bar, error) {
+	return cachedRows(ctx, s, "xs|"+date, func() ([]Bar, error) {
+		return s.queryBars(ctx, crossSectionSQL, date)
+	})
+}
+
+// 读取 (symbol, date, close, close_adj, open_adj, high_adj, low_adj, pe, volume) 各行
+func (s *Store) queryBars(ctx context.Context, query string, args ...any) ([]Bar, error) {
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Bar
+	for rows.Next() {
+		var b Bar
+		var v [7]sql.NullFloat64
+		if err := rows.Scan(&b.Symbol, &b.Date, &v[0], &v[1], &v[2], &v[3], &v[4], &v[5], &v[6]); err != nil {
+			return nil, err
+		}
+		b.Close, b.CloseAdj, b.OpenAdj = nullToNaN(v[0]), nullToNaN(v[1]), nullToNaN(v[2])
+		b.HighAdj, b.LowAdj, b.PE, b.Volume = nullToNaN(v[3]), nullToNaN(v[4]), nullToNaN(v[5]), nullToNaN(v[6])
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+func nullToNaN(v sql.NullFloat64) float64 {
+	if !v.Valid {
+		return math.NaN()
+	}
+	return v.Float64
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 流式合并 (不经过 staging 表)
+// ---------------------------------------------------------
+// 默认流程先把技术因子与每日指标原样写进 staging_tech / staging_daily，建索引后再用一条 SQL 关联、
+// 清洗进 stock_history，同样的数据要写两遍。import --streaming (update files 同) 改为在 Go 中关联：
+// 先读入每日指标，按 (代码, 日期) 建立 PE 的索引；再逐行读取技术因子，找到同一天的 PE 后直接写入
+// stock_history (按列缓存、成块排序后写入，见 columnar.go)。清洗用与 mergeStaging 相同的 SQL 表达式
+// (stagingHistoryColumns)，结果相同。
+// PE 索引常驻内存 (每行约 100 字节)，设置了内存预算时超出即退出 (见 memory.go)。
+
+type stagingKey struct {
+	symbol string
+	date   string
+}
+
+// 逐行写入 stock_history 的语句：参数依次为 staging_tech 的各列与 pe
+func streamInsertSQL() string {
+	cols := append(slices.Clone(stagingColumns["staging_tech"]), "pe")
+	return "INSERT OR REPLACE INTO stock_history SELECT " + stagingHistoryColumns(func(col string) string {
+		return "?" + strconv.Itoa(slices.Index(cols, col)+1)
+	})
+}
+
+// techMapper / dailyMapper 产生与 staging_tech / staging_daily 相同的行
+func streamMergeStaging(db *sql.DB, techPattern, dailyPattern string, techMapper, dailyMapper func(header []string) rowMapper) {
+	log.Println(">>> 正在读取每日指标 (流式合并)...")
+	start := time.Now()
+	pe := map[stagingKey]any{}
+	dates := map[string]string{} // 各文件的日期字符串共用一份
+	// staging 表的校验规则同样适用 (见 validate.go)
+	dailyCheck := newRowValidator("staging_daily", stagingColumns["staging_daily"])
+	techCheck := newRowValidator("staging_tech", stagingColumns["staging_tech"])
+	n, bad, found := scanCSVFiles(dailyPattern, 2, dailyMapper, func(row []any, at rowPos) {
+		if !dailyCheck.check(row, at) {
+			return
+		}
+		symbol, ok1 := row[0].(string)
+		date, ok2 := row[1].(string)
+		if !ok1 || !ok2 {
+			return // 与 SQL 关联相同：代码或日期为 NULL 的行不参与
+		}
+		if d, ok := dates[date]; ok {
+			date = d
+		} else {
+			dates[date] = date
+		}
+		key := stagingKey{symbol, date}
+		if old, ok := pe[key]; ok && importOptions.strict && old != row[2] {
+			importErrs.add(&importError{at, formatRowValues(row), fmt.Errorf("同一 (代码, 日期) 之前的 PE 为 %v", old)})
+		}
+		pe[key] = row[2]
+		if streamIndexOverBudget(len(pe)) {
+			fmt.Println()
+			log.Fatalf("[ERROR] 每日指标的索引超出内存预算 (%d 行)，请去掉 --streaming 改用 --merge sort", len(pe))
+		}
+	})
+	if len(bad) > 0 {
+		fmt.Println()
+		log.Fatalf("[ERROR] %s 的表头格式发生变化: %s", dailyPattern, strings.Join(bad, "; "))
+	}
+	if !found {
+		return
+	}
+	fmt.Printf("\n>>> 每日指标: %d 行, %d 个 (代码, 日期)\n", n, len(pe))
+	recordSource("每日指标 (流式)", dailyPattern, n, 0, time.Since(start))
+	start = time.Now()
+	errsBefore := importErrs.count
+
+	log.Println(">>> 正在合并技术因子并写入 stock_history...")
+	// 覆盖分钟线聚合而来的行时删掉其来源记录 (见 intraday.go)
+	minute, err := loadMinuteKeys(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var replaced []stagingKey
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	stmt, err := tx.Prepare(streamInsertSQL())
+	if err != nil {
+		log.Fatal(err)
+	}
+	written, unmatched := 0, 0
+	// 写入过的代码 (合并后刷新统计，见 histstats.go)；没有统计表时为 nil
+	var stale map[string]bool
+	if hasHistoryStats(db) {
+		stale = map[string]bool{}
+	}
+	// 按主键排好序再成块写入 (见 columnar.go)
+	out := newColumnBuffer(len(stagingColumns["staging_tech"])+1, func(row []any) {
+		// 行已按主键重新排序，错误中只有该行的值 (代码、日期在最前)
+		if _, err := stmt.Exec(row...); err != nil {
+			importErrs.write("stock_history", &importError{values: formatRowValues(row), err: err})
+			return
+		}
+		if stale != nil {
+			stale[row[0].(string)] = true
+		}
+		if key := (stagingKey{row[0].(string), row[1].(string)}); minute[key] {
+			replaced = append(replaced, key)
+		}
+		written++
+	})
+	// 严格模式 (见 strict.go)：检查重复冲突，用过的每日指标从索引中删去，剩下的即没有对应技术因子的
+	var strictRows *strictRowSet
+	var unmatchedSamples []string
+	if importOptions.strict {
+		strictRows = newStrictRowSet()
+	}
+	args := make([]any, 0, len(stagingColumns["staging_tech"])+1)
+	n, bad, found = scanCSVFiles(techPattern, 2, techMapper, func(row []any, at rowPos) {
+		if !techCheck.check(row, at) {
+			return
+		}
+		symbol, ok1 := row[0].(string)
+		date, ok2 := row[1].(string)
+		key := stagingKey{symbol, date}
+		if strictRows != nil && ok1 && ok2 {
+			first, err := strictRows.add(key, row)
+			if err != nil {
+				importErrs.add(&importError{at, formatRowValues(row), err})
+			}
+			if !first {
+				return // 完全相同的重复行
+			}
+		}
+		v, ok := pe[key]
+		if !ok1 || !ok2 || !ok {
+			if strictRows != nil && len(unmatchedSamples) < strictSamples {
+				unmatchedSamples = append(unmatchedSamples, fmt.Sprintf("%v (%s)", at, formatRowValues(row[:2])))
+			}
+			unmatched++
+			return
+		}
+		if strictRows != nil {
+			delete(pe, key)
+		}
+		out.add(append(append(args[:0], row...), v))
+	})
+	out.flush()
+	stmt.Close()
+	for _, k := range replaced {
+		if _, err := tx.Exec("DELETE FROM history_provenance WHERE symbol = ? AND replace(date, '-', '') = ? AND source = ?",
+			k.symbol, k.date, minuteSource); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(bad) > 0 {
+		tx.Rollback()
+		fmt.Println()
+		log.Fatalf("[ERROR] %s 的表头格式发生变化，已回滚: %s", techPattern, strings.Join(bad, "; "))
+	}
+	if strictRows != nil {
+		var problems []string
+		if unmatched > 0 {
+			problems = append(problems, fmt.Sprintf("技术因子没有对应的每日指标: %d 行，如 %s", unmatched, strings.Join(unmatchedSamples, "; ")))
+		}
+		if len(pe) > 0 {
+			problems = append(problems, fmt.Sprintf("每日指标没有对应的技术因子: %d 处，如 %s", len(pe), strings.Join(unusedDailySamples(pe, strictSamples), "; ")))
+		}
+		if len(problems) > 0 {
+			tx.Rollback()
+			strictFail("流式合并未通过检查，已回滚", problems)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	if found {
+		fmt.Printf("\n>>> 技术因子: %d 行, 写入 stock_history %d 行 (%d 行没有对应的每日指标)\n", n, written, unmatched)
+		recordSource("技术因子 → stock_history (流式)", techPattern, written, importErrs.count-errsBefore, time.Since(start))
+	}
+	dailyCheck.finish(db)
+	techCheck.finish(db)
+	historyChanged(db, mapKeys(stale))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"log"
+	"sort"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// 严格模式
+// ---------------------------------------------------------
+// 生产环境的月度数据集宁可不出，也不能悄悄少了数据。import --strict (update files 同，或 sources.yaml
+// 的 import.strict: true) 时任何数据问题都终止导入 (退出码非 0，事务回滚)，并指出问题所在：
+//
+//	解析失败、写入时违反约束   同 import.on_error: abort，给出文件、行号与该行的值 (见 importerr.go)
+//	被拒绝的行                 列数不足、映射函数拒绝 (代码或日期为空等)、空文件、表头不匹配的文件
+//	重复冲突                   同一 (代码, 日期) 有多行且值不同 (完全相同的重复行不算)
+//	无法关联的行               技术因子与每日指标没有对应的行 (合并时会被丢弃)
+//
+// 重复与关联在合并前检查 staging 表 (checkStagingStrict)；--streaming 时在读取过程中检查。
+
+// 每类问题列出的样本数
+const strictSamples = 10
+
+// 打开严格模式 (出错即终止)
+func setStrict() {
+	importOptions.strict = true
+	importOptions.onError = onErrorAbort
+}
+
+// 严格模式下终止的原因 (写进错误信息)，否则为空
+func strictReason() string {
+	if importOptions.strict {
+		return "--strict"
+	}
+	return ""
+}
+
+// 列出发现的问题后终止
+func strictFail(title string, problems []string) {
+	fmt.Println()
+	log.Printf("[ERROR] %s (--strict):", title)
+	for _, p := range problems {
+		log.Printf("[ERROR]   %s", p)
+	}
+	log.Fatalf("[ERROR] 严格模式: 发现 %d 类数据问题，已终止", len(problems))
+}
+
+var stagingStrictChecks = []struct{ name, query string }{
+	{"staging_tech 中同一 (代码, 日期) 的值不同",
+		`SELECT symbol, date, count(*) || ' 行' FROM (SELECT DISTINCT * FROM staging_tech) GROUP BY symbol, date HAVING count(*) > 1 ORDER BY symbol, date`},
+	{"staging_daily 中同一 (代码, 日期) 的值不同",
+		`SELECT symbol, date, count(*) || ' 行' FROM (SELECT DISTINCT * FROM staging_daily) GROUP BY symbol, date HAVING count(*) > 1 ORDER BY symbol, date`},
+	{"技术因子没有对应的每日指标",
+		`SELECT symbol, date FROM staging_tech t WHERE NOT EXISTS (SELECT 1 FROM staging_daily d WHERE d.symbol = t.symbol AND d.date = t.date)`},
+	{"每日指标没有对应的技术因子",
+		`SELECT symbol, date FROM staging_daily d WHERE NOT EXISTS (SELECT 1 FROM staging_tech t WHERE t.symbol = d.symbol AND t.date = d.date)`},
+}
+
+// 合并 staging 表之前检查重复冲突与无法关联的行 (不是严格模式时什么也不做)
+func checkStagingStrict(db *sql.DB) {
+	if !importOptions.strict {
+		return
+	}
+	log.Println(">>> 严格模式: 正在检查 staging 表的重复与关联...")
+	// 与 mergeStaging 相同的索引 (已建过时跳过)，没有索引时 NOT EXISTS 对每一行都要扫描另一张表
+	mustExec(db, "CREATE INDEX IF NOT EXISTS idx_st_tech_sd ON staging_tech(symbol, date);")
+	mustExec(db, "CREATE INDEX IF NOT EXISTS idx_st_daily_sd ON staging_daily(symbol, date);")
+	var problems []string
+	for _, c := range stagingStrictChecks {
+		n, samples, err := querySamples(db, c.query, strictSamples)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %d 处，如 %s", c.name, n, strings.Join(samples, "; ")))
+		}
+	}
+	if len(problems) > 0 {
+		strictFail("staging 表未通过检查，未合并", problems)
+	}
+}
+
+// 执行 query，返回结果的行数与前 limit 行 (各列用 | 连接)
+func querySamples(db *sql.DB, query string, limit int, args ...any) (int, []string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	cols, _ := rows.Columns()
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for k := range vals {
+		ptrs[k] = &vals[k]
+	}
+	n := 0
+	var samples []string
+	for rows.Next() {
+		n++
+		if len(samples) >= limit {
+			continue
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return 0, nil, err
+		}
+		samples = append(samples, formatRowValues(vals))
+	}
+	return n, samples, rows.Err()
+}
+
+// 流式合并 (stream.go) 中的技术因子：记录每个 (代码, 日期) 的值的摘要，发现值不同的重复行
+type strictRowSet struct {
+	seed maphash.Seed
+	seen map[stagingKey]uint64
+}
+
+func newStrictRowSet() *strictRowSet {
+	return &strictRowSet{seed: maphash.MakeSeed(), seen: map[stagingKey]uint64{}}
+}
+
+// 第一次出现时返回 (true, nil)；与之前完全相同的重复行返回 (false, nil)，值不同时返回错误
+func (s *strictRowSet) add(key stagingKey, row []any) (bool, error) {
+	var h maphash.Hash
+	h.SetSeed(s.seed)
+	for _, v := range row {
+		if v == nil {
+			h.WriteByte(0)
+			continue
+		}
+		h.WriteByte(1)
+		h.WriteString(fmt.Sprint(v))
+		h.WriteByte(0)
+	}
+	sum := h.Sum64()
+	if old, ok := s.seen[key]; ok {
+		if old != sum {
+			return false, errors.New("同一 (代码, 日期) 之前出现过不同的值")
+		}
+		return false, nil
+	}
+	s.seen[key] = sum
+	return true, nil
+}
+
+// 流式合并结束时没有用到的每日指标 (按代码、日期排序的前 limit 个)
+func unusedDailySamples(pe map[stagingKey]any, limit int) []string {
+	keys := make([]stagingKey, 0, len(pe))
+	for k := range pe {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].symbol != keys[j].symbol {
+			return keys[i].symbol < keys[j].symbol
+		}
+		return keys[i].date < keys[j].date
+	})
+	var out []string
+	for _, k := range keys[:min(len(keys), limit)] {
+		out = append(out, k.symbol+" | "+k.date)
+	}
+	return out
+}
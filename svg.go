@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// 极简 SVG 折线图 (不依赖任何绘图库，浏览器直接打开即可)
+// ---------------------------------------------------------
+
+type chartSeries struct {
+	Name   string
+	Values []float64 // 与 x 轴标签一一对应，NaN 表示缺失
+}
+
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+func writeLineChartSVG(path, title string, labels []string, series []chartSeries) error {
+	const width, height = 960.0, 480.0
+	const left, right, top, bottom = 70.0, 150.0, 40.0, 40.0
+	plotW, plotH := width-left-right, height-top-bottom
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, v := range s.Values {
+			if !math.IsNaN(v) {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+	}
+	if math.IsInf(lo, 0) {
+		lo, hi = 0, 1
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	xAt := func(k int) float64 {
+		if len(labels) < 2 {
+			return left
+		}
+		return left + plotW*float64(k)/float64(len(labels)-1)
+	}
+	yAt := func(v float64) float64 { return top + plotH*(hi-v)/(hi-lo) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" font-family="sans-serif" font-size="12">`+"\n", width, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(&b, `<text x="%.0f" y="24" font-size="16">%s</text>`+"\n", left, html.EscapeString(title))
+
+	// 坐标轴与 y 轴刻度
+	fmt.Fprintf(&b, `<rect x="%.0f" y="%.0f" width="%.0f" height="%.0f" fill="none" stroke="#ccc"/>`+"\n", left, top, plotW, plotH)
+	for k := 0; k <= 4; k++ {
+		v := lo + (hi-lo)*float64(k)/4
+		y := yAt(v)
+		fmt.Fprintf(&b, `<line x1="%.0f" x2="%.0f" y1="%.1f" y2="%.1f" stroke="#eee"/>`+"\n", left, left+plotW, y, y)
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.1f" text-anchor="end">%.3g</text>`+"\n", left-6, y+4, v)
+	}
+	// x 轴只标首尾和中间
+	for _, k := range []int{0, len(labels) / 2, len(labels) - 1} {
+		if k >= 0 && k < len(labels) {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.0f" text-anchor="middle">%s</text>`+"\n", xAt(k), top+plotH+18, html.EscapeString(labels[k]))
+		}
+	}
+
+	for n, s := range series {
+		color := chartColors[n%len(chartColors)]
+		var pts strings.Builder
+		for k, v := range s.Values {
+			if math.IsNaN(v) {
+				continue
+			}
+			fmt.Fprintf(&pts, "%.1f,%.1f ", xAt(k), yAt(v))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`+"\n", color, pts.String())
+		ly := top + 16*float64(n)
+		fmt.Fprintf(&b, `<line x1="%.0f" x2="%.0f" y1="%.0f" y2="%.0f" stroke="%s" stroke-width="3"/>`+"\n", left+plotW+10, left+plotW+30, ly, ly, color)
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.0f">%s</text>`+"\n", left+plotW+36, ly+4, html.EscapeString(s.Name))
+	}
+	b.WriteString("</svg>\n")
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
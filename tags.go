@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+)
+
+// 概念板块 / 题材标签 (新能源、AI 等)：区间 [in_date, out_date)，out_date 为空表示至今仍属该概念。
+// 一只股票可以同时属于多个概念。ic / quantile 的 --universe 写作 tag:新能源 即按当日概念成分过滤。
+//
+// 索引：0:代码, 1:概念名称, 2:纳入日期, 3:剔除日期 (可缺省)
+func init() {
+	registerDataset(dataset{
+		Name:    "概念板块",
+		Pattern: PathConceptTags,
+		Table:   "concept_tags",
+		DDL: `CREATE TABLE IF NOT EXISTS concept_tags (
+			symbol    TEXT NOT NULL,
+			tag       TEXT NOT NULL,
+			in_date   TEXT NOT NULL,
+			out_date  TEXT,
+			PRIMARY KEY (tag, symbol, in_date)
+		) WITHOUT ROWID, STRICT;`,
+		MinCols: 3,
+		Mapper: func(record []string) []any {
+			symbol := normText(record[0])
+			tag := normText(record[1])
+			inDate := normDate(record[2])
+			if symbol == nil || tag == nil || inDate == nil {
+				return nil
+			}
+			return []any{symbol, tag, inDate, normDate(col(record, 3))}
+		},
+	})
+}
+
+const tagUniversePrefix = "tag:"
+
+// 股票池过滤条件：alias 表的 symbol 在 alias.date 当天带有该标签
+func tagCond(tag, alias string) string {
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM concept_tags t
+		WHERE t.tag = %s AND t.symbol = %[2]s.symbol
+		AND t.in_date <= %[2]s.date AND (t.out_date IS NULL OR t.out_date > %[2]s.date))`, sqlQuote(tag), alias)
+}
+
+var (
+	tagsSQL = `SELECT tag FROM concept_tags
+	WHERE symbol = ?1 AND in_date <= ?2 AND (out_date IS NULL OR out_date > ?2)
+	ORDER BY tag`
+	tagMembersSQL = `SELECT symbol FROM concept_tags
+	WHERE tag = ?1 AND in_date <= ?2 AND (out_date IS NULL OR out_date > ?2)
+	ORDER BY symbol`
+)
+
+// 股票在指定日期的全部概念标签
+func (s *Store) Tags(ctx context.Context, symbol, date string) ([]string, error) {
+	return s.queryStrings(ctx, tagsSQL, symbol, date)
+}
+
+// 某概念在指定日期的成分股 (时点查询)
+func (s *Store) TagMembers(ctx context.Context, tag, date string) ([]string, error) {
+	return s.queryStrings(ctx, tagMembersSQL, tag, date)
+}
+
+func (s *Store) queryStrings(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// chronos tags --tag 新能源 --date 2023-06：列出概念成分股
+// chronos tags --symbol 300750.SZ：列出股票所属概念
+func runTags(args []string) {
+	fs := flag.NewFlagSet("tags", flag.ExitOnError)
+	tag := fs.String("tag", "", "概念名称")
+	symbol := fs.String("symbol", "", "股票代码")
+	date := fs.String("date", time.Now().Format(time.DateOnly), "查询日期 (YYYY-MM-DD 或 YYYY-MM)")
+	fs.Parse(args)
+
+	if (*tag == "") == (*symbol == "") {
+		log.Fatal("[ERROR] 需要且只能指定 --tag 或 --symbol 之一")
+	}
+	asOf, err := asOfDate(*date)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	db := openDB()
+	defer db.Close()
+	store := newStore(db)
+	ctx := context.Background()
+
+	var list []string
+	if *tag != "" {
+		list, err = store.TagMembers(ctx, *tag, asOf)
+	} else {
+		list, err = store.Tags(ctx, *symbol, asOf)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, v := range list {
+		fmt.Println(v)
+	}
+	log.Printf(">>> %s%s 截至 %s 共 %d 条", *tag, *symbol, asOf, len(list))
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 通达信本地数据 (vipdoc)
+// ---------------------------------------------------------
+// 目录结构：vipdoc/{sh,sz,bj}/lday/sh600000.day，每条记录 32 字节小端：
+//
+//	0:日期 uint32 (YYYYMMDD), 4:开盘, 8:最高, 12:最低, 16:收盘 (uint32，价格 × 100，基金/债券 × 1000),
+//	20:成交额 float32, 24:成交量 uint32 (股), 28:保留
+//
+// 分钟线 vipdoc/{sh,sz,bj}/minline/*.lc1 (1 分钟)、fzline/*.lc5 (5 分钟)，同为 32 字节小端：
+//
+//	0:日期 uint16 ((年-2004)×2048 + 月×100 + 日), 2:分钟数 uint16 (自零点起，K 线结束时刻),
+//	4:开盘, 8:最高, 12:最低, 16:收盘 (float32), 20:成交额 float32, 24:成交量 uint32 (股), 28:保留
+//
+// 分钟线写入 stock_minute (指数也一并写入)。
+//
+// .day 为不复权价格：新股票的复权价暂按原始价写入，已有的行只更新原始收盘价和成交量，不覆盖其他来源的复权价。
+// 指数文件 (sh000xxx、sh880xxx、sz399xxx) 写入 index_history。
+//
+// 二进制记录先转成与 CSV 相同的字符串记录，再交给映射函数，与 CSV 导入共用映射逻辑。
+
+const tdxRecordSize = 32
+
+// sh600000.day -> 600000.SH
+func tdxSymbol(path string) (string, bool) {
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	if len(name) != 8 || !isDigits(name[2:]) {
+		return "", false
+	}
+	switch name[:2] {
+	case "sh", "sz", "bj":
+		return name[2:] + "." + strings.ToUpper(name[:2]), true
+	}
+	return "", false
+}
+
+func tdxIsIndex(symbol string) bool {
+	code, exch := symbol[:6], symbol[7:]
+	switch exch {
+	case "SH":
+		return strings.HasPrefix(code, "000") || strings.HasPrefix(code, "880") || strings.HasPrefix(code, "999")
+	case "SZ":
+		return strings.HasPrefix(code, "399")
+	}
+	return false
+}
+
+// 价格放大倍数：股票、指数为 100，基金 (ETF/LOF)、债券为 1000
+func tdxPriceScale(symbol string) float64 {
+	code, exch := symbol[:6], symbol[7:]
+	switch {
+	case exch == "SH" && (code[0] == '5' || code[:2] == "11" || code[:2] == "12"):
+		return 1000
+	case exch == "SZ" && (code[:2] == "15" || code[:2] == "16" || code[:2] == "18" || code[:2] == "12"):
+		return 1000
+	}
+	return 100
+}
+
+// 读取整个文件，校验长度为 32 字节的整数倍
+func readTdxFile(path string) (symbol string, data []byte, err error) {
+	symbol, ok := tdxSymbol(path)
+	if !ok {
+		return "", nil, fmt.Errorf("无法从文件名识别代码: %s", path)
+	}
+	if data, err = os.ReadFile(path); err != nil {
+		return "", nil, err
+	}
+	if len(data)%tdxRecordSize != 0 {
+		return "", nil, fmt.Errorf("%s: 文件长度 %d 不是 %d 的整数倍", path, len(data), tdxRecordSize)
+	}
+	return symbol, data, nil
+}
+
+func tdxFloat32(b []byte) string {
+	return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), 'f', -1, 32)
+}
+
+// 读取 .day 文件，返回记录：0:代码, 1:日期, 2:开盘, 3:最高, 4:最低, 5:收盘, 6:成交量, 7:成交额
+func readTdxDay(path string) ([][]string, error) {
+	symbol, data, err := readTdxFile(path)
+	if err != nil {
+		return nil, err
+	}
+	scale := tdxPriceScale(symbol)
+	price := func(b []byte) string {
+		return strconv.FormatFloat(float64(binary.LittleEndian.Uint32(b))/scale, 'f', -1, 64)
+	}
+
+	records := make([][]string, 0, len(data)/tdxRecordSize)
+	for off := 0; off < len(data); off += tdxRecordSize {
+		r := data[off : off+tdxRecordSize]
+		records = append(records, []string{
+			symbol,
+			strconv.FormatUint(uint64(binary.LittleEndian.Uint32(r[0:])), 10),
+			price(r[4:]),
+			price(r[8:]),
+			price(r[12:]),
+			price(r[16:]),
+			strconv.FormatUint(uint64(binary.LittleEndian.Uint32(r[24:])), 10),
+			tdxFloat32(r[20:]),
+		})
+	}
+	return records, nil
+}
+
+// 读取 .lc1/.lc5 文件，返回与分钟线 CSV 相同的记录：0:代码, 1:时间, 2:开盘, 3:最高, 4:最低, 5:收盘, 6:成交量, 7:成交额
+func readTdxMinute(path string) ([][]string, error) {
+	symbol, data, err := readTdxFile(path)
+	if err != nil {
+		return nil, err
+	}
+	records := make([][]string, 0, len(data)/tdxRecordSize)
+	for off := 0; off < len(data); off += tdxRecordSize {
+		r := data[off : off+tdxRecordSize]
+		d, m := int(binary.LittleEndian.Uint16(r[0:])), int(binary.LittleEndian.Uint16(r[2:]))
+		records = append(records, []string{
+			symbol,
+			fmt.Sprintf("%04d-%02d-%02d %02d:%02d:00", d/2048+2004, d%2048/100, d%2048%100, m/60, m%60),
+			tdxFloat32(r[4:]),
+			tdxFloat32(r[8:]),
+			tdxFloat32(r[12:]),
+			tdxFloat32(r[16:]),
+			strconv.FormatUint(uint64(binary.LittleEndian.Uint32(r[24:])), 10),
+			tdxFloat32(r[20:]),
+		})
+	}
+	return records, nil
+}
+
+func mapTdxDayStock(record []string) []any {
+	date := normDate(record[1])
+	if date == nil {
+		return nil
+	}
+	return adjustedHistoryRow(record[0], date, normNum(record[2]), normNum(record[3]), normNum(record[4]),
+		normNum(record[5]), nil, normNum(record[6]))
+}
+
+func mapTdxDayIndex(record []string) []any {
+	date := normDate(record[1])
+	if date == nil {
+		return nil
+	}
+	return []any{record[0], date, normNum(record[2]), normNum(record[3]), normNum(record[4]),
+		normNum(record[5]), normNum(record[6]), normNum(record[7])}
+}
+
+// 二进制文件导入：read 把一个文件解析成字符串记录，之后与 importCSVFiles 一样逐行映射写入
+func importRecordFiles(db *sql.DB, files []string, tableName string, conflict string, read func(path string) ([][]string, error), mapper func([]string) []any) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var batch *batchInserter
+	var touched map[string]bool // 直接写入日线时涉及的代码 (见 importCSVFiles)
+	if tableName == "stock_history" {
+		touched = map[string]bool{}
+	}
+
+	rowCount := 0
+	for _, file := range files {
+		records, err := read(file)
+		if err != nil {
+			log.Printf("[WARN] 跳过文件: %v", err)
+			continue
+		}
+		for _, record := range records {
+			args := mapper(record)
+			if args == nil {
+				continue
+			}
+			if batch == nil {
+				if batch, err = newBatchInserter(tx, "INSERT", tableName, nil, conflict, len(args)); err != nil {
+					return 0, err
+				}
+				defer batch.close()
+			}
+			if err := batch.add(args); err != nil {
+				return rowCount, fmt.Errorf("%s: %v", file, err)
+			}
+			if symbol, ok := args[0].(string); ok && touched != nil {
+				touched[symbol] = true
+			}
+			rowCount++
+		}
+		if batch != nil {
+			if err := batch.flush(); err != nil { // 每个文件写完再换下一个，出错时能指出文件
+				return rowCount, fmt.Errorf("%s: %v", file, err)
+			}
+		}
+		fmt.Printf(".")
+	}
+	if err := tx.Commit(); err != nil {
+		return rowCount, err
+	}
+	fmt.Printf("\n>>> %s 导入完成: %d 行\n", tableName, rowCount)
+	if touched != nil {
+		historyChanged(db, mapKeys(touched))
+	}
+	return rowCount, nil
+}
+
+// chronos tdx --vipdoc "C:\new_tdx\vipdoc" [--markets sh,sz,bj] [--minutes=false]
+func runTdx(args []string) {
+	fs := flag.NewFlagSet("tdx", flag.ExitOnError)
+	vipdoc := fs.String("vipdoc", "C:\\new_tdx\\vipdoc", "通达信 vipdoc 目录")
+	markets := fs.String("markets", "sh,sz,bj", "要导入的市场目录")
+	minutes := fs.Bool("minutes", true, "同时导入 1 分钟 (minline) 和 5 分钟 (fzline) 线")
+	fs.Parse(args)
+	if err := setupValidationRules(DefaultSourcesConfig); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	var stocks, indexes, minute1, minute5 []string
+	for _, m := range strings.Split(*markets, ",") {
+		dir := filepath.Join(*vipdoc, strings.TrimSpace(m))
+		if *minutes {
+			f1, _ := filepath.Glob(filepath.Join(dir, "minline", "*.lc1"))
+			f5, _ := filepath.Glob(filepath.Join(dir, "fzline", "*.lc5"))
+			minute1, minute5 = append(minute1, f1...), append(minute5, f5...)
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "lday", "*.day"))
+		for _, f := range files {
+			if symbol, ok := tdxSymbol(f); ok {
+				if tdxIsIndex(symbol) {
+					indexes = append(indexes, f)
+				} else {
+					stocks = append(stocks, f)
+				}
+			}
+		}
+	}
+	if len(stocks)+len(indexes)+len(minute1)+len(minute5) == 0 {
+		log.Fatalf("[ERROR] 未找到通达信数据文件: %s", *vipdoc)
+	}
+
+	start := time.Now()
+	db := openOrCreateDB()
+	defer db.Close()
+	ensureDatasetTable(db, "index_history")
+	ensureDatasetTable(db, "stock_minute")
+
+	log.Printf(">>> 正在导入通达信日线: %d 个股票文件, %d 个指数文件...", len(stocks), len(indexes))
+	if _, err := importRecordFiles(db, stocks, "stock_history",
+		"ON CONFLICT (symbol, date) DO UPDATE SET close = excluded.close, volume = excluded.volume",
+		readTdxDay, mapTdxDayStock); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := importRecordFiles(db, indexes, "index_history", "ON CONFLICT DO NOTHING", readTdxDay, mapTdxDayIndex); err != nil {
+		log.Fatal(err)
+	}
+	for _, src := range []struct {
+		freq  int
+		files []string
+	}{{1, minute1}, {5, minute5}} {
+		if len(src.files) == 0 {
+			continue
+		}
+		freq := src.freq
+		log.Printf(">>> 正在导入通达信 %d 分钟线: %d 个文件...", freq, len(src.files))
+		if _, err := importRecordFiles(db, src.files, "stock_minute", "ON CONFLICT DO NOTHING", readTdxMinute,
+			func(record []string) []any { return mapMinuteRecord(record, freq) }); err != nil {
+			log.Fatal(err)
+		}
+	}
+	createViews(db)
+	log.Printf(">>> ✅ 通达信导入完成, 耗时: %s", time.Since(start))
+}
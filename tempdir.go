@@ -0,0 +1,68 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// 临时目录
+// ---------------------------------------------------------
+// 大规模合并时 SQLite 的临时文件 (排序、临时索引) 和外部排序的分段文件默认都写在系统临时目录，
+// Windows 上就是 C 盘。全局参数 --tmpdir (或 sources.yaml 的 import.temp_dir，参数优先) 把它们
+// 都改到指定目录，便于放到单独的 SSD 上：
+//
+//	chronos --tmpdir D:\scratch import --merge sort
+//
+// 同时设置 TMPDIR / TMP / TEMP / SQLITE_TMPDIR，并在打开数据库时执行 PRAGMA temp_store_directory；
+// 指定了目录时 SQLite 的临时数据也改为写文件 (temp_store = FILE)，而不是放在内存中。
+
+var tmpDirFlag = flag.String("tmpdir", "", "SQLite 临时文件与排序分段文件的目录 (覆盖 import.temp_dir)")
+
+// 按全局参数设置临时目录 (在子命令之前调用)
+func setupTempDir() {
+	if *tmpDirFlag == "" {
+		return
+	}
+	if err := useTempDir(*tmpDirFlag); err != nil {
+		log.Fatalf("[ERROR] --tmpdir: %v", err)
+	}
+}
+
+// 检查目录可写，并设为本进程的临时目录
+func useTempDir(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "chronos-probe-*")
+	if err != nil {
+		return fmt.Errorf("目录不可写: %v", err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	importOptions.tempDir = dir
+	for _, key := range []string{"TMPDIR", "TMP", "TEMP", "SQLITE_TMPDIR"} {
+		os.Setenv(key, dir)
+	}
+	return nil
+}
+
+// 让 SQLite 的临时文件写到设置的目录 (temp_store_directory 对整个进程生效)；未设置时不做改动
+func applyTempDir(db *sql.DB) {
+	dir := importOptions.tempDir
+	if dir == "" {
+		return
+	}
+	mustExec(db, fmt.Sprintf("PRAGMA temp_store_directory = '%s';", strings.ReplaceAll(dir, "'", "''")))
+	mustExec(db, "PRAGMA temp_store = FILE;")
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+)
+
+// 逐笔成交 (tick)。
+// 默认保留原始逐笔写入 stock_tick；指定 --tick-bucket 时在导入阶段降采样为
+// 固定秒数的 K 线写入 stock_tick_agg，体积通常能缩小一到两个数量级。
+//
+// 索引：0:代码, 1:时间戳 (或日期), 2:成交价, 3:成交量, 4:成交额, 5:买卖方向 (B/S，可缺省)
+// 与分钟线相同，若第 1、2 列分别是日期和时间会自动合并，其余列顺延一位；时间按交易所本地时间解释。
+
+func importTicks(db *sql.DB, bucket time.Duration) {
+	if bucket <= 0 {
+		log.Println(">>> 正在导入逐笔成交 (原始)...")
+		importRawTicks(db, PathTicks)
+		return
+	}
+
+	if bucket%time.Second != 0 {
+		log.Fatalf("[ERROR] tick-bucket 必须是整秒: %s", bucket)
+	}
+	secs := int(bucket / time.Second)
+	mustExec(db, `CREATE TABLE IF NOT EXISTS stock_tick_agg (
+		symbol       TEXT NOT NULL,
+		bucket_secs  INTEGER NOT NULL,
+		ts           INTEGER NOT NULL,   -- 桶起始时刻 (UTC 秒级时间戳，按交易所本地时间对齐)
+		tz           TEXT NOT NULL,
+		open         REAL,
+		high         REAL,
+		low          REAL,
+		close        REAL,
+		volume       REAL,
+		amount       REAL,
+		buy_volume   REAL,
+		sell_volume  REAL,
+		ticks        INTEGER NOT NULL,
+		PRIMARY KEY (symbol, bucket_secs, ts)
+	) WITHOUT ROWID, STRICT;`)
+
+	// 同一个桶的后续成交通过 ON CONFLICT 累加 (要求文件内按时间排序，收盘价取最后一笔)
+	conflict := `ON CONFLICT (symbol, bucket_secs, ts) DO UPDATE SET
+		high = max(high, excluded.high),
+		low = min(low, excluded.low),
+		close = excluded.close,
+		volume = volume + excluded.volume,
+		amount = amount + excluded.amount,
+		buy_volume = buy_volume + excluded.buy_volume,
+		sell_volume = sell_volume + excluded.sell_volume,
+		ticks = ticks + 1`
+
+	log.Printf(">>> 正在导入逐笔成交 (降采样为 %s)...", bucket)
+	importCSVUpsert(db, PathTicks, "stock_tick_agg", conflict, 4, func(record []string) []any {
+		t, ok := parseTick(record)
+		if !ok {
+			return nil
+		}
+		local := time.Unix(t.epoch, 0).In(loadLocation(t.tz))
+		sinceMidnight := int64(local.Hour()*3600 + local.Minute()*60 + local.Second())
+		start := t.epoch - sinceMidnight%int64(secs)
+
+		var buy, sell float64
+		switch t.side {
+		case "B":
+			buy = t.volumeOrZero()
+		case "S":
+			sell = t.volumeOrZero()
+		}
+		return []any{t.symbol, secs, start, t.tz, t.price, t.price, t.price, t.price,
+			t.volumeOrZero(), t.amountOrZero(), buy, sell, 1}
+	})
+}
+
+// 原始逐笔写入 stock_tick
+func importRawTicks(db *sql.DB, pattern string) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS stock_tick (
+		symbol  TEXT NOT NULL,
+		ts      INTEGER NOT NULL,   -- UTC 秒级时间戳
+		tz      TEXT NOT NULL,      -- 交易所时区
+		seq     INTEGER NOT NULL,   -- 文件内的行序号，区分同一秒内的多笔成交
+		price   REAL,
+		volume  REAL,
+		amount  REAL,
+		side    TEXT,               -- B 主动买 / S 主动卖 / N 未知
+		PRIMARY KEY (symbol, ts, seq)
+	) WITHOUT ROWID, STRICT;`)
+	// seq 取该行在文件内的序号 (映射在多个解析 goroutine 中并发进行，只能在按文件顺序写入时补上)：
+	// 只取决于文件内容，续传或重复导入同一文件得到相同的主键，重复的行直接覆盖
+	conflict := `ON CONFLICT (symbol, ts, seq) DO UPDATE SET
+		tz = excluded.tz, price = excluded.price, volume = excluded.volume,
+		amount = excluded.amount, side = excluded.side`
+	mapTick := func(record []string) []any {
+		t, ok := parseTick(record)
+		if !ok {
+			return nil
+		}
+		return []any{t.symbol, t.epoch, t.tz, nil, t.price, t.volume, t.amount, t.side}
+	}
+	importCSVFilesFill(db, pattern, "stock_tick", conflict, 4, func([]string) rowMapper { return recordMapper(mapTick) },
+		func(row []any, at rowPos) { row[3] = int64(at.row) })
+}
+
+type tickRecord struct {
+	epoch          int64
+	tz             string
+	symbol, price  any
+	volume, amount any
+	side           any
+}
+
+func parseTick(record []string) (tickRecord, bool) {
+	ts, _ := normDateTime(record[1]).(string)
+	fields := record[2:]
+	if ts == "" && len(record) > 2 {
+		ts, _ = normDateTime(record[1] + " " + record[2]).(string)
+		fields = record[3:]
+	}
+	if ts == "" || len(fields) < 2 {
+		return tickRecord{}, false
+	}
+	price := normNum(fields[0])
+	symbol := strings.TrimSpace(record[0])
+	tz := symbolTimezone(symbol)
+	epoch, ok := localToEpoch(ts, tz)
+	if price == nil || symbol == "" || !ok {
+		return tickRecord{}, false
+	}
+	return tickRecord{
+		symbol: symbol,
+		epoch:  epoch,
+		tz:     tz,
+		price:  price,
+		volume: normNum(fields[1]),
+		amount: normNum(col(fields, 2)),
+		side:   normSide(col(fields, 3)),
+	}, true
+}
+
+// 买卖方向统一为 B / S / N
+func normSide(s string) any {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "":
+		return nil
+	case "B", "BUY", "买", "买入", "买盘", "1":
+		return "B"
+	case "S", "SELL", "卖", "卖出", "卖盘", "-1", "2":
+		return "S"
+	}
+	return "N"
+}
+
+func (t tickRecord) volumeOrZero() float64 {
+	if v, ok := t.volume.(float64); ok {
+		return v
+	}
+	return 0
+}
+
+func (t tickRecord) amountOrZero() float64 {
+	if v, ok := t.amount.(float64); ok {
+		return v
+	}
+	return 0
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// Tushare Pro
+// ---------------------------------------------------------
+// 没有网盘数据时可以直接从 Tushare 建库或补数：按交易日拉取全市场日线、复权因子和每日指标，
+// 写入与 CSV 导入相同的 staging 表，再走同一套合并逻辑 (mergeStaging)。
+// 复权价为后复权 (原始价 × 复权因子)，与网盘数据一致；市盈率取 TTM；成交量由手换算为股。
+//
+// token 配置在 sources.yaml 的 tushare.token，环境变量 TUSHARE_TOKEN 优先。
+
+const tushareURL = "http://api.tushare.pro"
+
+type tushareClient struct {
+	token string
+	url   string
+	http  *http.Client
+}
+
+func newTushareClient(configPath string) (*tushareClient, error) {
+	cfg, err := loadSourceConfig(configPath, "tushare")
+	if err != nil {
+		return nil, err
+	}
+	c := &tushareClient{
+		token: yamlString(cfg, "token"),
+		url:   yamlString(cfg, "url"),
+		http:  &http.Client{Timeout: 60 * time.Second, Transport: sourceTransport},
+	}
+	if t := os.Getenv("TUSHARE_TOKEN"); t != "" {
+		c.token = t
+	}
+	if c.url == "" {
+		c.url = tushareURL
+	}
+	if c.token == "" {
+		return nil, fmt.Errorf("缺少 Tushare token (在 %s 中配置 tushare.token，或设置环境变量 TUSHARE_TOKEN)", configPath)
+	}
+	return c, nil
+}
+
+// Tushare 超出每分钟次数时返回的错误码
+const tushareRateLimited = 40203
+
+// 调用一个接口，返回按字段名索引的行；被限频时等一分钟重试
+func (c *tushareClient) query(ctx context.Context, api string, params map[string]any, fields string) ([]map[string]any, error) {
+	for retry := 0; ; retry++ {
+		rows, code, err := c.queryOnce(ctx, api, params, fields)
+		if code != tushareRateLimited || retry == 3 {
+			return rows, err
+		}
+		log.Printf("[WARN] %v，1 分钟后重试", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Minute):
+		}
+	}
+}
+
+func (c *tushareClient) queryOnce(ctx context.Context, api string, params map[string]any, fields string) ([]map[string]any, int, error) {
+	// 缓存键不含 token
+	key, err := json.Marshal(map[string]any{"api_name": api, "params": params, "fields": fields})
+	if err != nil {
+		return nil, 0, err
+	}
+	raw, err := cachedFetch(ctx, "tushare", string(key), func() ([]byte, error) {
+		if err := apiWait(ctx, "tushare"); err != nil {
+			return nil, err
+		}
+		return c.post(ctx, api, params, fields)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var out struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			Fields []string `json:"fields"`
+			Items  [][]any  `json:"items"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		cacheDrop("tushare", string(key))
+		return nil, 0, fmt.Errorf("tushare %s: %v", api, err)
+	}
+	if out.Code != 0 {
+		cacheDrop("tushare", string(key))
+		return nil, out.Code, fmt.Errorf("tushare %s: %s (code %d)", api, out.Msg, out.Code)
+	}
+	rows := make([]map[string]any, len(out.Data.Items))
+	for k, item := range out.Data.Items {
+		row := make(map[string]any, len(out.Data.Fields))
+		for j, f := range out.Data.Fields {
+			if j < len(item) {
+				row[f] = item[j]
+			}
+		}
+		rows[k] = row
+	}
+	return rows, 0, nil
+}
+
+func (c *tushareClient) post(ctx context.Context, api string, params map[string]any, fields string) ([]byte, error) {
+	body, err := json.Marshal(map[string]any{"api_name": api, "token": c.token, "params": params, "fields": fields})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tushare %s: HTTP %d", api, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func ensureStockBasic(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS stock_basic (
+		symbol       TEXT NOT NULL PRIMARY KEY,
+		name         TEXT,
+		area         TEXT,
+		industry     TEXT,
+		board        TEXT,            -- 主板 / 创业板 / 科创板 / 北交所
+		list_status  TEXT,            -- L 上市 / D 退市 / P 暂停
+		list_date    TEXT,
+		delist_date  TEXT
+	) WITHOUT ROWID, STRICT;`)
+}
+
+// 股票列表 (含已退市，避免幸存者偏差)
+func (c *tushareClient) syncStockBasic(ctx context.Context, db *sql.DB) (int, error) {
+	ensureStockBasic(db)
+	n := 0
+	for _, status := range []string{"L", "D", "P"} {
+		rows, err := c.query(ctx, "stock_basic", map[string]any{"list_status": status},
+			"ts_code,name,area,industry,market,list_status,list_date,delist_date")
+		if err != nil {
+			return n, err
+		}
+		for _, r := range rows {
+			_, err := db.Exec("INSERT OR REPLACE INTO stock_basic VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				r["ts_code"], r["name"], r["area"], r["industry"], r["market"], r["list_status"],
+				tushareDate(r["list_date"]), tushareDate(r["delist_date"]))
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// 区间内的 A 股交易日 (YYYYMMDD)
+func (c *tushareClient) tradeDates(ctx context.Context, from, to string) ([]string, error) {
+	rows, err := c.query(ctx, "trade_cal",
+		map[string]any{"exchange": "SSE", "start_date": from, "end_date": to, "is_open": "1"}, "cal_date")
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, r := range rows {
+		if d, ok := r["cal_date"].(string); ok {
+			out = append(out, d)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// 拉取一个交易日的全市场数据写入 staging 表，返回股票数
+func (c *tushareClient) stageDay(ctx context.Context, tx *sql.Tx, date string) (int, error) {
+	params := map[string]any{"trade_date": date}
+	bars, err := c.query(ctx, "daily", params, "ts_code,open,high,low,close,vol")
+	if err != nil {
+		return 0, err
+	}
+	adjRows, err := c.query(ctx, "adj_factor", params, "ts_code,adj_factor")
+	if err != nil {
+		return 0, err
+	}
+	basicRows, err := c.query(ctx, "daily_basic", params, "ts_code,pe_ttm")
+	if err != nil {
+		return 0, err
+	}
+	adj := map[any]float64{}
+	for _, r := range adjRows {
+		adj[r["ts_code"]] = jsonFloat(r["adj_factor"])
+	}
+	pe := map[any]any{}
+	for _, r := range basicRows {
+		pe[r["ts_code"]] = r["pe_ttm"]
+	}
+
+	techStmt, err := tx.Prepare("INSERT INTO staging_tech VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer techStmt.Close()
+	dailyStmt, err := tx.Prepare("INSERT INTO staging_daily VALUES (?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer dailyStmt.Close()
+
+	scale := func(v any, k float64) any {
+		if x, ok := v.(float64); ok {
+			return x * k
+		}
+		return nil
+	}
+	n := 0
+	for _, b := range bars {
+		f, ok := adj[b["ts_code"]]
+		if !ok || f == 0 {
+			continue // 没有复权因子的 (极少数停牌首日等) 跳过，避免复权价断档
+		}
+		if _, err := techStmt.Exec(b["ts_code"], date, b["close"],
+			scale(b["close"], f), scale(b["open"], f), scale(b["high"], f), scale(b["low"], f), scale(b["vol"], 100)); err != nil {
+			return n, err
+		}
+		// 合并时与 staging_tech 内连接，缺 PE 也要写一行
+		if _, err := dailyStmt.Exec(b["ts_code"], date, pe[b["ts_code"]]); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Tushare 日期 YYYYMMDD -> YYYY-MM-DD
+func tushareDate(v any) any {
+	s, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	return normDate(s)
+}
+
+// chronos tushare [--from 20100101] [--to 20241231] [--basic]
+func runTushare(args []string) {
+	fs := flag.NewFlagSet("tushare", flag.ExitOnError)
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件")
+	from := fs.String("from", "", "起始日期 (YYYYMMDD 或 YYYY-MM-DD)，默认从库中 A 股最后一个交易日之后续拉")
+	to := fs.String("to", time.Now().Format("20060102"), "结束日期 (含)")
+	basic := fs.Bool("basic", true, "同步股票列表 (stock_basic)")
+	batch := fs.Int("batch", 20, "每拉取多少个交易日合并一次 (中断后已合并的部分不会丢失)")
+	fs.Parse(args)
+
+	client, err := newTushareClient(*config)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	start := time.Now()
+	db := openOrCreateDB()
+	defer db.Close()
+	if err := setupSources(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	ctx := context.Background()
+
+	if *basic {
+		n, err := client.syncStockBasic(ctx, db)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		log.Printf(">>> 股票列表: %d 只", n)
+	}
+
+	begin := strings.ReplaceAll(*from, "-", "")
+	if begin == "" {
+		var last sql.NullString
+		db.QueryRow("SELECT MAX(date) FROM stock_history WHERE market = ?", MarketCN).Scan(&last)
+		begin = "19901219"
+		if last.Valid {
+			t, _ := time.Parse(time.DateOnly, last.String)
+			begin = t.AddDate(0, 0, 1).Format("20060102")
+		}
+	}
+	end := strings.ReplaceAll(*to, "-", "")
+	dates, err := client.tradeDates(ctx, begin, end)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	log.Printf(">>> Tushare: %s ~ %s 共 %d 个交易日", begin, end, len(dates))
+	// 交易日历留作参照 (见 verify.go)
+	if err := saveTradeCalendar(db, MarketCN, dates); err != nil {
+		log.Fatalf("[ERROR] 保存交易日历失败: %v", err)
+	}
+
+	total := 0
+	for k := 0; k < len(dates); k += *batch {
+		chunk := dates[k:min(k+*batch, len(dates))]
+		tx, err := db.Begin()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, d := range chunk {
+			n, err := client.stageDay(ctx, tx, d)
+			if err != nil {
+				tx.Rollback()
+				log.Fatalf("[ERROR] %s: %v", d, err)
+			}
+			total += n
+		}
+		if err := tx.Commit(); err != nil {
+			log.Fatal(err)
+		}
+		mergeStaging(db)
+		log.Printf(">>> 已合并至 %s", chunk[len(chunk)-1])
+	}
+
+	mustExec(db, "DROP TABLE IF EXISTS staging_tech;")
+	mustExec(db, "DROP TABLE IF EXISTS staging_daily;")
+	createViews(db)
+	logAPIUsage()
+	log.Printf(">>> ✅ Tushare 拉取完成: %d 行, 耗时: %s", total, time.Since(start))
+}
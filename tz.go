@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"time"
+	_ "time/tzdata" // 内嵌时区数据库，Windows 或精简容器上也能加载 Asia/Shanghai 等时区
+)
+
+// ---------------------------------------------------------
+// 时区
+// ---------------------------------------------------------
+// 分钟线、逐笔等日内数据的 ts 存为 UTC 秒级时间戳，另存交易所时区名 (tz)。
+// 供应商文件里的时间一律按交易所本地时间解释，与导入机器的本地时区无关，
+// 不同市场的数据放在同一张表里也不会错位；需要本地时间时用 local_time(ts, tz)。
+// 数字货币 K 线 (crypto_bars) 同样如此，tz 恒为 UTC；旧库中的文本 ts 在 ensureCryptoTables 时自动转换。
+
+var (
+	locationMu    sync.Mutex
+	locationCache = map[string]*time.Location{}
+)
+
+// 按 IANA 名称加载时区 (带缓存)，未知名称回退为 UTC
+func loadLocation(name string) *time.Location {
+	locationMu.Lock()
+	defer locationMu.Unlock()
+	if loc, ok := locationCache[name]; ok {
+		return loc
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = time.UTC
+	}
+	locationCache[name] = loc
+	return loc
+}
+
+// 代码所属交易所的时区名
+func symbolTimezone(symbol string) string {
+	return marketTimezone(marketOf(symbol))
+}
+
+// 把交易所本地时间 "YYYY-MM-DD HH:MM:SS" 转为 UTC 时间戳
+func localToEpoch(ts, tz string) (int64, bool) {
+	t, err := time.ParseInLocation(time.DateTime, ts, loadLocation(tz))
+	if err != nil {
+		return 0, false
+	}
+	return t.Unix(), true
+}
+
+// UTC 时间戳 -> 交易所本地时间 "YYYY-MM-DD HH:MM:SS"
+func epochToLocal(epoch int64, tz string) string {
+	return time.Unix(epoch, 0).In(loadLocation(tz)).Format(time.DateTime)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ---------------------------------------------------------
+// 自定义 SQL 函数 (金融计算)
+// ---------------------------------------------------------
+// 在 openDB 打开的连接上可用 (query 命令、factors.yaml 等)：
+//
+//	log_return(p0, p1)          ln(p1 / p0)
+//	pct_change(p0, p1)          p1 / p0 - 1
+//	winsorize(x, lo, hi)        把 x 截断到 [lo, hi]
+//	quantile(x, q)              聚合/窗口函数：分位数 (线性插值)
+//	ema(x, span)                聚合/窗口函数：指数移动平均，alpha = 2 / (span + 1)
+//	local_time(ts, tz)          UTC 时间戳转为 tz 时区的 "YYYY-MM-DD HH:MM:SS"
+//	regexp(pattern, s)          s 是否匹配 Go 正则 pattern (1 / 0)，也可写作 s REGEXP pattern
+//
+// 例：
+//
+//	SELECT symbol, date,
+//	       ema(close_adj, 20) OVER (PARTITION BY symbol ORDER BY date) AS ema20,
+//	       log_return(LAG(close_adj) OVER (PARTITION BY symbol ORDER BY date), close_adj) AS r
+//	FROM stock_history
+//
+// 任一参数为 NULL 或非数值时返回 NULL。
+
+// 函数本身与驱动无关，由 driver_*.go 中的 registerUDFs 注册到所用的驱动
+
+// 聚合/窗口函数：step 加入一行，inverse 移除窗口中最早的一行，value 返回当前结果
+type windowAgg interface {
+	step(args []driver.Value) error
+	inverse() error
+	value() driver.Value
+}
+
+// 把 SQLite 传入的值转为 float64，NULL 或无法解析时 ok=false
+func udfFloat(v driver.Value) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, !math.IsNaN(x)
+	case int64:
+		return float64(x), true
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		return f, err == nil
+	case []byte:
+		f, err := strconv.ParseFloat(string(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func udfLogReturn(args []driver.Value) (driver.Value, error) {
+	p0, ok0 := udfFloat(args[0])
+	p1, ok1 := udfFloat(args[1])
+	if !ok0 || !ok1 || p0 <= 0 || p1 <= 0 {
+		return nil, nil
+	}
+	return math.Log(p1 / p0), nil
+}
+
+func udfPctChange(args []driver.Value) (driver.Value, error) {
+	p0, ok0 := udfFloat(args[0])
+	p1, ok1 := udfFloat(args[1])
+	if !ok0 || !ok1 || p0 == 0 {
+		return nil, nil
+	}
+	return p1/p0 - 1, nil
+}
+
+func udfWinsorize(args []driver.Value) (driver.Value, error) {
+	x, ok := udfFloat(args[0])
+	if !ok {
+		return nil, nil
+	}
+	if lo, ok := udfFloat(args[1]); ok && x < lo {
+		x = lo
+	}
+	if hi, ok := udfFloat(args[2]); ok && x > hi {
+		x = hi
+	}
+	return x, nil
+}
+
+// 分位数：窗口滑动时 SQLite 按进入顺序移除最早的行，因此用队列保存
+type quantileAgg struct {
+	values []float64
+	valid  []bool
+	q      float64
+}
+
+func (a *quantileAgg) step(args []driver.Value) error {
+	x, ok := udfFloat(args[0])
+	q, qok := udfFloat(args[1])
+	if !qok || q < 0 || q > 1 {
+		return fmt.Errorf("quantile: q 必须在 [0, 1] 之间")
+	}
+	a.q = q
+	a.values = append(a.values, x)
+	a.valid = append(a.valid, ok)
+	return nil
+}
+
+func (a *quantileAgg) inverse() error {
+	a.values, a.valid = a.values[1:], a.valid[1:]
+	return nil
+}
+
+func (a *quantileAgg) value() driver.Value {
+	var xs []float64
+	for k, v := range a.values {
+		if a.valid[k] {
+			xs = append(xs, v)
+		}
+	}
+	if len(xs) == 0 {
+		return nil
+	}
+	sort.Float64s(xs)
+	return quantileSorted(xs, a.q)
+}
+
+// 已排序序列的分位数 (线性插值，与 numpy 默认一致)
+func quantileSorted(xs []float64, q float64) float64 {
+	pos := q * float64(len(xs)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return xs[lo] + (xs[hi]-xs[lo])*(pos-float64(lo))
+}
+
+// EMA 只能向前累积，窗口帧必须从 UNBOUNDED PRECEDING 开始 (即默认帧)
+type emaAgg struct {
+	ema  float64
+	seen bool
+}
+
+func (a *emaAgg) step(args []driver.Value) error {
+	x, ok := udfFloat(args[0])
+	span, sok := udfFloat(args[1])
+	if !sok || span < 1 {
+		return fmt.Errorf("ema: span 必须 >= 1")
+	}
+	if !ok {
+		return nil
+	}
+	if !a.seen {
+		a.ema, a.seen = x, true
+		return nil
+	}
+	alpha := 2 / (span + 1)
+	a.ema = alpha*x + (1-alpha)*a.ema
+	return nil
+}
+
+func (a *emaAgg) inverse() error {
+	return fmt.Errorf("ema: 不支持滑动窗口帧，请使用默认帧 (ROWS UNBOUNDED PRECEDING)")
+}
+
+func (a *emaAgg) value() driver.Value {
+	if !a.seen {
+		return nil
+	}
+	return a.ema
+}
+
+func udfLocalTime(args []driver.Value) (driver.Value, error) {
+	epoch, ok := args[0].(int64)
+	tz, ok2 := args[1].(string)
+	if !ok || !ok2 {
+		return nil, nil
+	}
+	return epochToLocal(epoch, tz), nil
+}
+
+// 编译过的正则 (同一条语句对每行用同一个 pattern)
+var udfRegexps sync.Map
+
+func udfRegexp(args []driver.Value) (driver.Value, error) {
+	pattern, ok := args[0].(string)
+	if !ok || args[1] == nil {
+		return nil, nil
+	}
+	var s string
+	switch x := args[1].(type) {
+	case string:
+		s = x
+	case []byte:
+		s = string(x)
+	default:
+		s = fmt.Sprint(x)
+	}
+	re, ok := udfRegexps.Load(pattern)
+	if !ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		re, _ = udfRegexps.LoadOrStore(pattern, compiled)
+	}
+	if re.(*regexp.Regexp).MatchString(s) {
+		return int64(1), nil
+	}
+	return int64(0), nil
+}
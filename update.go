@@ -0,0 +1,249 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 每日增量更新
+// ---------------------------------------------------------
+// chronos update 按 sources.yaml 中 update 节列出的顺序依次执行各数据源，只补库中最后一天之后的数据，
+// 最后打印各市场新增的行数。适合放进每晚的定时任务：
+//
+//	update:
+//	  sources:
+//	    - files                       # 网盘技术因子/每日指标 CSV 中比库里更新的日期
+//	    - tushare
+//	    - eastmoney --index 000300.SH # 名称后可以跟该子命令的参数
+//	    - yahoo
+//
+// 在线数据源本身就是从每只股票 (或全市场) 的最后一天之后续拉，这里原样调用对应子命令；
+// 可用的名称见 updateSources。没有配置时默认只跑 files。
+// 除 files 外还可用 datasets：重新扫描附加数据集目录，已存在的主键自动跳过。
+// backfill 放在最后可顺带回补历史缺口 (见 backfill.go)，verify 检查参照完整性 (见 verify.go)。
+
+// config 表示该子命令接受 --config，update 会把自己的 --config 传下去
+var updateSources = map[string]struct {
+	run    func(args []string)
+	config bool
+}{
+	"files":     {updateFromFiles, true},
+	"datasets":  {updateDatasets, false},
+	"tushare":   {runTushare, true},
+	"eastmoney": {runEastmoney, false},
+	"yahoo":     {runYahoo, false},
+	"baostock":  {runBaostock, true},
+	"tdx":       {runTdx, false},
+	"backfill":  {runBackfill, true},
+	"fetch":     {runFetch, true},
+	"verify":    {runVerify, false},
+}
+
+// 库中各市场的行数与最后日期
+type historySnapshot map[string]struct {
+	rows int
+	last string
+}
+
+func takeHistorySnapshot(db *sql.DB) historySnapshot {
+	snap := historySnapshot{}
+	rows, err := db.Query("SELECT market, count(*), max(date) FROM stock_history GROUP BY market")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var market, last string
+		var n int
+		if err := rows.Scan(&market, &n, &last); err != nil {
+			log.Fatal(err)
+		}
+		snap[market] = struct {
+			rows int
+			last string
+		}{n, last}
+	}
+	return snap
+}
+
+// 网盘 CSV 增量：只把日期晚于库中 A 股最后一天的行 (--filter keys 时为库中没有的行) 放进 staging 再合并。
+// 分钟线聚合补齐的行不计入已有数据，同一 (代码, 日期) 的供应商日线照常导入并覆盖它们
+func updateFromFiles(args []string) {
+	fs := flag.NewFlagSet("update files", flag.ExitOnError)
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件")
+	streaming := fs.Bool("streaming", false, "不经过 staging 表，在内存中关联后直接写入 (见 stream.go)")
+	merge := fs.String("merge", "sql", "staging 合并方式: sql 或 sort (外部排序归并，见 sortmerge.go)")
+	maxMemory := fs.String("max-memory", "", "内存预算 (如 4GB，见 memory.go)")
+	filter := fs.String("filter", "date", "增量过滤: date (A 股最后一天之后的行) 或 keys (库中没有的 (代码, 日期)，中间缺的日子也会补上，见 keyset.go)")
+	strict := fs.Bool("strict", false, "任何数据问题 (解析失败、被拒绝的行、重复冲突) 都终止 (见 strict.go)")
+	fs.Parse(args)
+	checkMergeMethod(*merge)
+	profiles, err := loadHeaderProfiles(*config)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if err := setupImportOptions(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if *strict {
+		setStrict()
+	}
+	if err := setMaxMemory(*maxMemory); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	db := openOrCreateDB()
+	defer db.Close()
+	applyMemoryBudget(db)
+
+	// 按表头选好布局后再过滤 (映射结果前两列为代码、日期)
+	var keep func(row []any) bool
+	var skipped atomic.Int64
+	// 分钟线聚合而来的行 (见 intraday.go) 不算已有，两种过滤都放行，由供应商日线覆盖
+	minute, err := loadMinuteKeys(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch *filter {
+	case "date":
+		last := ""
+		db.QueryRow("SELECT coalesce(replace(max(h.date), '-', ''), '') FROM stock_history h WHERE h.market = 'CN' AND " +
+			notMinuteRowSQL).Scan(&last)
+		keep = func(row []any) bool {
+			symbol, _ := row[0].(string)
+			date := strings.ReplaceAll(strings.TrimSpace(fmt.Sprint(row[1])), "-", "")
+			return date > last || minute[stagingKey{symbol, date}]
+		}
+		log.Printf(">>> 正在导入 %s 之后的网盘日线...", last)
+	case "keys":
+		keys := mustLoadHistoryKeys(db)
+		keep = func(row []any) bool {
+			symbol, _ := row[0].(string)
+			date, _ := row[1].(string)
+			return !keys.has(symbol, date) || minute[stagingKey{symbol, strings.ReplaceAll(date, "-", "")}]
+		}
+		log.Println(">>> 正在导入库中没有的网盘日线...")
+	default:
+		log.Fatalf("[ERROR] 未知过滤方式: %s (可用 date, keys)", *filter)
+	}
+	newer := func(newMapper func([]string) rowMapper) func([]string) rowMapper {
+		return func(header []string) rowMapper {
+			mapper := newMapper(header)
+			if mapper == nil {
+				return nil
+			}
+			return func(a *rowArena, fields [][]byte) []any {
+				row := mapper(a, fields)
+				if row == nil {
+					return nil
+				}
+				if !keep(row) {
+					skipped.Add(1)
+					return filteredRow
+				}
+				return row
+			}
+		}
+	}
+	techMapper := newer(profileMapper(profiles, "staging_tech", mapTechFactors, 19))
+	dailyMapper := newer(profileMapper(profiles, "staging_daily", mapDailyMetrics, 15))
+	loadStockHistory(db, PathTechFactors, PathDailyMetrics, techMapper, dailyMapper, *merge, *streaming)
+	log.Printf(">>> 解析时跳过库中已有的行 %d 行", skipped.Load())
+	if !skipStep("drop-staging") {
+		mustExec(db, "DROP TABLE IF EXISTS staging_tech;")
+		mustExec(db, "DROP TABLE IF EXISTS staging_daily;")
+	}
+}
+
+func updateDatasets(args []string) {
+	db := openOrCreateDB()
+	defer db.Close()
+	importDatasets(db)
+	ensureMacroMeta(db)
+}
+
+// chronos update [--sources files,tushare] [--config sources.yaml]
+func runUpdate(args []string) {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件")
+	sourcesArg := fs.String("sources", "", "逗号分隔的数据源，覆盖配置文件 (如 files,eastmoney)")
+	fs.Parse(args)
+	if err := setupImportOptions(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	var steps []string
+	if *sourcesArg != "" {
+		steps = splitList(*sourcesArg)
+	} else {
+		cfg, err := loadSourceConfig(*config, "update")
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		steps = yamlStrings(cfg, "sources")
+	}
+	if len(steps) == 0 {
+		steps = []string{"files"}
+	}
+	// 先检查全部名称，避免跑了一半才发现配置写错
+	for _, step := range steps {
+		name := strings.Fields(step)[0]
+		if _, ok := updateSources[name]; !ok {
+			log.Fatalf("[ERROR] 未知数据源: %s", name)
+		}
+	}
+
+	start := time.Now()
+	db := openOrCreateDB()
+	before := takeHistorySnapshot(db)
+	db.Close()
+
+	for _, step := range steps {
+		f := strings.Fields(step)
+		log.Printf(">>> [update] %s", step)
+		src, stepArgs := updateSources[f[0]], f[1:]
+		if src.config {
+			stepArgs = append([]string{"--config", *config}, stepArgs...)
+		}
+		runStage("update "+step, func() { src.run(stepArgs) })
+	}
+
+	db = openOrCreateDB()
+	defer db.Close()
+	createViews(db)
+	createPITView(db)
+	if !hasHistoryStats(db) {
+		historyChanged(db, nil) // 之前没有统计表的库：建立统计并检查整表 (之后随合并增量进行)
+	}
+	after := takeHistorySnapshot(db)
+	var recorded int
+	db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'recorded_daily'").Scan(&recorded)
+	if recorded > 0 {
+		n, bad, err := reconcileRecorded(db, defaultPriceTol, defaultVolumeTol)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n > 0 {
+			log.Printf(">>> 盘中录制核对 %d 行, %d 行与官方数据不一致 (见 record_discrepancies)", n, bad)
+		}
+	}
+
+	fmt.Println("\n市场   新增行数   最后日期 (更新前 -> 更新后)")
+	total := 0
+	for _, m := range []string{MarketCN, MarketHK, MarketUS} {
+		b, a := before[m], after[m]
+		if a.rows == 0 {
+			continue
+		}
+		fmt.Printf("%-6s %8d   %s -> %s\n", m, a.rows-b.rows, b.last, a.last)
+		total += a.rows - b.rows
+	}
+	log.Printf(">>> ✅ 增量更新完成: 新增 %d 行, 耗时: %s", total, time.Since(start))
+	writeRunReport(db)
+}
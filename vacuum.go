@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 空间回收
+// ---------------------------------------------------------
+// 长期增量更新的库 (update 每天追加、record 不断写入快照) 删除或改写的页留在空闲列表里，文件不会缩小；
+// 对几十 GB 的库做一次完整 VACUUM 要几个小时。auto_vacuum = INCREMENTAL 的库可以随时用
+// PRAGMA incremental_vacuum 归还空闲页，不必重写整个文件：
+//
+//	import:
+//	  auto_vacuum: incremental   # 新建数据库时的设置: none (默认) / full / incremental
+//
+//	chronos vacuum                          # incremental 库归还全部空闲页，其他模式做完整 VACUUM
+//	chronos vacuum --auto incremental       # 转换已有的库 (需要做一次完整 VACUUM)
+//	chronos record --vacuum-every 1h        # 常驻录制时定期归还 (每次最多 --vacuum-pages 页)
+//
+// auto_vacuum 只能在建表之前设置，之后修改要经过一次完整 VACUUM 才生效。
+
+var autoVacuumModes = []string{"none", "full", "incremental"}
+
+func checkAutoVacuumMode(mode string) error {
+	if !containsString(autoVacuumModes, mode) {
+		return fmt.Errorf("auto_vacuum 必须是 none / full / incremental: %q", mode)
+	}
+	return nil
+}
+
+// 新建的空库：在建表之前按 import.auto_vacuum 设置。
+// 已切换到 WAL 的库只改 PRAGMA 不会写进文件头，空库上的 VACUUM 瞬间完成
+func applyAutoVacuum(db *sql.DB) {
+	if importOptions.autoVacuum == "" {
+		return
+	}
+	mustExec(db, "PRAGMA auto_vacuum = "+strings.ToUpper(importOptions.autoVacuum)+";")
+	mustExec(db, "VACUUM;")
+}
+
+// 库当前的 auto_vacuum 模式
+func autoVacuumMode(db *sql.DB) string {
+	var n int
+	db.QueryRow("PRAGMA auto_vacuum").Scan(&n)
+	if n >= 0 && n < len(autoVacuumModes) {
+		return autoVacuumModes[n]
+	}
+	return "none"
+}
+
+// 空闲页数与页大小
+func freePages(db *sql.DB) (pages, pageSize int64) {
+	db.QueryRow("PRAGMA freelist_count").Scan(&pages)
+	db.QueryRow("PRAGMA page_size").Scan(&pageSize)
+	return pages, pageSize
+}
+
+// 归还最多 pages 个空闲页 (0 为全部)，返回归还的页数；非 incremental 库什么都不做
+func incrementalVacuum(db *sql.DB, pages int) (int64, error) {
+	before, _ := freePages(db)
+	if before == 0 {
+		return 0, nil
+	}
+	// incremental_vacuum 每归还一页返回一行，要读完才会执行完
+	rows, err := db.Query(fmt.Sprintf("PRAGMA incremental_vacuum(%d)", pages))
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	after, _ := freePages(db)
+	return before - after, nil
+}
+
+// chronos vacuum [--auto none|full|incremental] [--full]
+func runVacuum(args []string) {
+	fs := flag.NewFlagSet("vacuum", flag.ExitOnError)
+	auto := fs.String("auto", "", "把库转换为该 auto_vacuum 模式: none / full / incremental (做一次完整 VACUUM)")
+	full := fs.Bool("full", false, "incremental 库也做完整 VACUUM (同时整理碎片)")
+	fs.Parse(args)
+	if *auto != "" {
+		if err := checkAutoVacuumMode(*auto); err != nil {
+			log.Fatalf("[ERROR] --auto: %v", err)
+		}
+	}
+
+	db := openDB()
+	defer db.Close()
+	start := time.Now()
+	sizeBefore := fileSize(DBPath)
+	pages, pageSize := freePages(db)
+	mode := autoVacuumMode(db)
+	log.Printf(">>> %s: %s, auto_vacuum = %s, 空闲 %d 页 (%s)", DBPath, formatBytes(float64(sizeBefore)),
+		mode, pages, formatBytes(float64(pages*pageSize)))
+
+	switch {
+	case *auto != "" && *auto != mode:
+		log.Printf(">>> 正在转换为 auto_vacuum = %s (完整 VACUUM)...", *auto)
+		mustExec(db, "PRAGMA auto_vacuum = "+strings.ToUpper(*auto)+";")
+		mustExec(db, "VACUUM;")
+	case mode == "incremental" && !*full:
+		n, err := incrementalVacuum(db, 0)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf(">>> 已归还 %d 个空闲页", n)
+	default:
+		log.Println(">>> 正在执行完整 VACUUM...")
+		mustExec(db, "VACUUM;")
+	}
+	mustExec(db, "PRAGMA wal_checkpoint(TRUNCATE);")
+	log.Printf(">>> ✅ %s -> %s, auto_vacuum = %s, 耗时: %s", formatBytes(float64(sizeBefore)),
+		formatBytes(float64(fileSize(DBPath))), autoVacuumMode(db), time.Since(start).Round(time.Millisecond))
+}
+
+func fileSize(path string) int64 {
+	st, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return st.Size()
+}
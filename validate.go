@@ -0,0 +1,414 @@
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 数据校验规则
+// ---------------------------------------------------------
+// 以前的清洗是写死在合并 SQL 里的个别处理 (如 PE 的空串转 NULL)。sources.yaml 的 validate 节按表、按列
+// 声明规则，导入与合并时统一检查，违规按规则计数、取样，写进 validation_results 并打印汇总：
+//
+//	validate:
+//	  staging_daily:                   # 导入时逐行检查 (任何经 importCSVFiles / --streaming 导入的表)
+//	    - column: pe
+//	      min: -10000
+//	      max: 10000
+//	      action: null                 # 超出范围的 PE 置为 NULL
+//	  staging_tech:
+//	    - column: symbol
+//	      regex: '^\d{6}\.(SH|SZ|BJ)$' # 正则用单引号
+//	      action: drop                 # 丢弃该行
+//	    - column: date
+//	      monotone: true               # 同一代码 (by，默认 symbol) 的日期按读取顺序严格递增
+//	  stock_history:                   # 合并或直接导入 (vendor / tdx / metastock / source run) 后对本次写入的代码检查
+//	                                   # (合并时没有统计表则只在全量导入结束时检查整表)
+//	    - column: close_adj
+//	      not_null: true
+//	      action: fatal                # 终止 (退出码非 0)
+//
+// 一项中可以同时写 min / max、regex、not_null、monotone，各算一条规则。action：
+//
+//	warn   只计数、取样 (默认)
+//	null   把违规的值置为 NULL
+//	drop   丢弃该行
+//	fatal  终止；--strict 时所有规则都按 fatal 处理 (见 strict.go)
+//
+// 导入时的规则在写入前检查 (映射后的值，staging 表中为原始文本，数值按 normNum 解析)，出错时给出文件与行号；
+// stock_history 的规则用 SQL 检查，monotone 不适用 (主键已保证日期有序)。
+
+const (
+	validateWarn  = "warn"
+	validateNull  = "null"
+	validateDrop  = "drop"
+	validateFatal = "fatal"
+)
+
+type validationRule struct {
+	table, column string
+	kind          string  // range / regex / not_null / monotone
+	min, max      float64 // range，没写的一侧为 ±Inf
+	re            *regexp.Regexp
+	by            string // monotone 的分组列
+	action        string
+}
+
+// 规则的文字描述 (报告与 validation_results 中使用)
+func (r *validationRule) String() string {
+	switch r.kind {
+	case "range":
+		return fmt.Sprintf("range [%g, %g]", r.min, r.max)
+	case "regex":
+		return "regex " + r.re.String()
+	case "monotone":
+		return "monotone by " + r.by
+	}
+	return r.kind
+}
+
+// 当前生效的规则 (setupValidationRules)
+var validationRules []*validationRule
+
+// 本次运行开始的时间 (validation_results 中同一次运行的各行相同)
+var validationRunAt = time.Now().Format(time.DateTime)
+
+func setupValidationRules(configPath string) error {
+	cfg, err := loadSourceConfig(configPath, "validate")
+	if err != nil {
+		return err
+	}
+	var rules []*validationRule
+	for table := range cfg {
+		for k, m := range yamlList(cfg, table) {
+			items, err := parseValidationItem(table, m)
+			if err != nil {
+				return fmt.Errorf("validate.%s[%d]: %v", table, k, err)
+			}
+			rules = append(rules, items...)
+		}
+	}
+	validationRules = rules
+	return nil
+}
+
+// 一项配置展开为若干条规则
+func parseValidationItem(table string, m map[string]any) ([]*validationRule, error) {
+	column := yamlString(m, "column")
+	if column == "" {
+		return nil, fmt.Errorf("缺少 column")
+	}
+	action := cmp.Or(yamlString(m, "action"), validateWarn)
+	if !containsString([]string{validateWarn, validateNull, validateDrop, validateFatal}, action) {
+		return nil, fmt.Errorf("action 必须是 warn / null / drop / fatal: %q", action)
+	}
+	base := validationRule{table: table, column: column, action: action}
+	var rules []*validationRule
+	if lo, hi := yamlString(m, "min"), yamlString(m, "max"); lo != "" || hi != "" {
+		r := base
+		r.kind, r.min, r.max = "range", math.Inf(-1), math.Inf(1)
+		for _, b := range []struct {
+			s   string
+			dst *float64
+		}{{lo, &r.min}, {hi, &r.max}} {
+			if b.s == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(b.s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("min / max 必须是数值: %q", b.s)
+			}
+			*b.dst = v
+		}
+		rules = append(rules, &r)
+	}
+	if s := yamlString(m, "regex"); s != "" {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("regex: %v", err)
+		}
+		r := base
+		r.kind, r.re = "regex", re
+		rules = append(rules, &r)
+	}
+	if yamlString(m, "not_null") == "true" {
+		r := base
+		r.kind = "not_null"
+		rules = append(rules, &r)
+	}
+	if yamlString(m, "monotone") == "true" {
+		if table == "stock_history" {
+			return nil, fmt.Errorf("monotone 只能用于导入时检查的表")
+		}
+		r := base
+		r.kind, r.by = "monotone", cmp.Or(yamlString(m, "by"), "symbol")
+		rules = append(rules, &r)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%s: 没有规则 (min / max、regex、not_null、monotone)", column)
+	}
+	return rules, nil
+}
+
+func hasValidationRules(table string) bool {
+	for _, r := range validationRules {
+		if r.table == table {
+			return true
+		}
+	}
+	return false
+}
+
+// 规则实际的处理方式
+func (r *validationRule) effectiveAction() string {
+	if importOptions.strict {
+		return validateFatal
+	}
+	return r.action
+}
+
+// 一条规则在某一阶段的结果
+type ruleTally struct {
+	rule       *validationRule
+	violations int
+	samples    []string
+}
+
+func (t *ruleTally) record(sample string) {
+	t.violations++
+	if len(t.samples) < strictSamples {
+		t.samples = append(t.samples, sample)
+	}
+}
+
+// 导入时逐行检查一张表的规则
+type rowValidator struct {
+	table   string
+	tallies []*ruleTally
+	cols    []int
+	by      []int
+	last    []map[string]any // monotone：各分组最后的值
+}
+
+// columns 为行中各值对应的列名；没有该表的规则时返回 nil
+func newRowValidator(table string, columns []string) *rowValidator {
+	v := &rowValidator{table: table}
+	for _, r := range validationRules {
+		if r.table != table {
+			continue
+		}
+		col, by := slices.Index(columns, r.column), slices.Index(columns, r.by)
+		if missing := r.column; col < 0 || (r.kind == "monotone" && by < 0) {
+			if col >= 0 {
+				missing = r.by
+			}
+			log.Fatalf("[ERROR] validate.%s: 表中没有列 %s (可用: %s)", table, missing, strings.Join(columns, ", "))
+		}
+		v.tallies = append(v.tallies, &ruleTally{rule: r})
+		v.cols = append(v.cols, col)
+		v.by = append(v.by, by)
+		v.last = append(v.last, map[string]any{})
+	}
+	if len(v.tallies) == 0 {
+		return nil
+	}
+	return v
+}
+
+// 检查一行 (可能按规则把值置为 NULL)，返回 false 表示丢弃该行
+func (v *rowValidator) check(row []any, at rowPos) bool {
+	if v == nil {
+		return true
+	}
+	for k, t := range v.tallies {
+		r, val := t.rule, row[v.cols[k]]
+		ok := true
+		switch r.kind {
+		case "range":
+			if val != nil {
+				x, isNum := validationFloat(val)
+				ok = isNum && x >= r.min && x <= r.max
+			}
+		case "regex":
+			ok = val == nil || r.re.MatchString(fmt.Sprint(val))
+		case "not_null":
+			ok = val != nil
+		case "monotone":
+			if val != nil {
+				key := fmt.Sprint(row[v.by[k]])
+				if prev, seen := v.last[k][key]; seen && compareValues(val, prev) <= 0 {
+					ok = false
+				} else {
+					v.last[k][key] = val
+				}
+			}
+		}
+		if ok {
+			continue
+		}
+		e := &importError{at, formatRowValues(row), fmt.Errorf("%s.%s 违反规则 %v", v.table, r.column, r)}
+		t.record(e.Error())
+		switch r.effectiveAction() {
+		case validateNull:
+			row[v.cols[k]] = nil
+		case validateDrop:
+			return false
+		case validateFatal:
+			fmt.Println()
+			log.Fatalf("[ERROR] %v (%s)", e, cmp.Or(strictReason(), "action: fatal"))
+		}
+	}
+	return true
+}
+
+// 表的列名 (按表中的顺序)
+func tableColumns(db *sql.DB, table string) []string {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+	var cols []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			log.Fatal(err)
+		}
+		cols = append(cols, c)
+	}
+	return cols
+}
+
+// 导入结束后打印并保存结果
+func (v *rowValidator) finish(db *sql.DB) {
+	if v != nil {
+		saveValidationResults(db, "import", v.tallies)
+	}
+}
+
+// 校验用的数值：staging 表中的原始文本按 normNum 解析
+func validationFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case string:
+		f, ok := normNum(x).(float64)
+		return f, ok
+	case []byte:
+		f, ok := normNumBytes(x).(float64)
+		return f, ok
+	}
+	return udfFloat(v)
+}
+
+// 两边都是数值时按数值比较，否则按文本
+func compareValues(a, b any) int {
+	x, ok1 := validationFloat(a)
+	y, ok2 := validationFloat(b)
+	if ok1 && ok2 {
+		return cmp.Compare(x, y)
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// 违反规则的 stock_history 行的条件与参数
+func (r *validationRule) violationSQL() (string, []any) {
+	c := r.column
+	switch r.kind {
+	case "range":
+		var conds []string
+		var args []any
+		if !math.IsInf(r.min, -1) {
+			conds, args = append(conds, c+" < ?"), append(args, r.min)
+		}
+		if !math.IsInf(r.max, 1) {
+			conds, args = append(conds, c+" > ?"), append(args, r.max)
+		}
+		return fmt.Sprintf("%s IS NOT NULL AND (%s)", c, strings.Join(conds, " OR ")), args
+	case "regex":
+		return fmt.Sprintf("%s IS NOT NULL AND NOT regexp(?, %s)", c, c), []any{r.re.String()}
+	}
+	return c + " IS NULL", nil
+}
+
+// 合并后检查 stock_history 的规则；symbols 为本次写入的代码 (nil 为整表，空则不检查)
+func validateHistory(db *sql.DB, symbols []string) {
+	if !hasValidationRules("stock_history") || (symbols != nil && len(symbols) == 0) {
+		return
+	}
+	scope, scopeArgs := "1", []any(nil)
+	if symbols != nil {
+		list, _ := json.Marshal(symbols)
+		scope, scopeArgs = "symbol IN (SELECT value FROM json_each(?))", []any{string(list)}
+	}
+	var tallies []*ruleTally
+	var fatal []string
+	for _, r := range validationRules {
+		if r.table != "stock_history" {
+			continue
+		}
+		cond, args := r.violationSQL()
+		where := scope + " AND " + cond
+		args = append(slices.Clone(scopeArgs), args...)
+		n, samples, err := querySamples(db, "SELECT symbol, date, "+r.column+" FROM stock_history WHERE "+where, strictSamples, args...)
+		if err != nil {
+			log.Fatalf("[ERROR] 校验 stock_history.%s: %v", r.column, err)
+		}
+		tallies = append(tallies, &ruleTally{rule: r, violations: n, samples: samples})
+		if n == 0 {
+			continue
+		}
+		switch r.effectiveAction() {
+		case validateNull:
+			mustExec(db, "UPDATE stock_history SET "+r.column+" = NULL WHERE "+where, args...)
+		case validateDrop:
+			mustExec(db, "DELETE FROM stock_history WHERE "+where, args...)
+		case validateFatal:
+			fatal = append(fatal, fmt.Sprintf("stock_history.%s %v: %d 行，如 %s", r.column, r, n, strings.Join(samples, "; ")))
+		}
+	}
+	saveValidationResults(db, "merge", tallies)
+	if len(fatal) > 0 {
+		strictFail("stock_history 未通过校验 (数据已写入)", fatal)
+	}
+}
+
+func ensureValidationResultsTable(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS validation_results (
+		run_at      TEXT NOT NULL,     -- 运行开始的时间 (本地时间)
+		stage       TEXT NOT NULL,     -- import (导入时逐行) / merge (合并后)
+		table_name  TEXT NOT NULL,
+		column_name TEXT NOT NULL,
+		rule        TEXT NOT NULL,
+		action      TEXT NOT NULL,
+		violations  INTEGER NOT NULL,
+		samples     TEXT NOT NULL      -- 前几处违规，每行一处
+	) STRICT;`)
+}
+
+// 打印有违规的规则并写进 validation_results (没有违规的规则也记一行，表示检查过)
+func saveValidationResults(db *sql.DB, stage string, tallies []*ruleTally) {
+	if len(tallies) == 0 {
+		return
+	}
+	ensureValidationResultsTable(db)
+	for _, t := range tallies {
+		r := t.rule
+		if t.violations > 0 {
+			log.Printf("[WARN] 校验 %s.%s %v: %d 处违规 (%s)", r.table, r.column, r, t.violations, r.effectiveAction())
+			for _, s := range t.samples[:min(len(t.samples), 3)] {
+				log.Printf("[WARN]   %s", s)
+			}
+		}
+		mustExec(db, "INSERT INTO validation_results VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			validationRunAt, stage, r.table, r.column, r.String(), r.effectiveAction(), t.violations, strings.Join(t.samples, "\n"))
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 终端导出格式 (Wind / Choice)
+// ---------------------------------------------------------
+// 内置常见终端导出文件的列布局，按名称选择即可导入 stock_history，不必自己数列号：
+//
+//	chronos vendor --profile wind-daily --path "D:\wind\*.csv"
+//
+// 不指定 --profile 时按表头指纹自动识别 (profiles.go)，未知表头的文件跳过。
+// Excel 导出请先另存为 CSV。终端导出通常是 GBK 编码：内置格式都声明为 GBK，表头解码后再识别分隔符、
+// 比对指纹 (profiles.go)；证券名称等文本列按位置跳过、不入库，数据行无需转码。
+// 文件末尾的“数据来源：Wind”等说明行因日期无法解析会被自动跳过。
+// 复权价按 复权收盘 / 原始收盘 换算开高低 (见 adjustedHistoryRow)，提供复权因子时复权收盘 = 收盘 × 因子。
+
+type vendorProfile struct {
+	Name        string
+	Description string
+	Header      string // 期望的表头 (仅用于 --list 展示，便于核对导出设置)
+	Encoding    string
+	Columns     vendorColumns
+	NullMarkers []string // 除 normNum 已识别的空值外，额外视为缺失的标记
+	VolumeUnit  float64  // 成交量乘数：股为 1，手为 100
+}
+
+// 各字段所在列 (从 0 开始)，-1 表示文件中没有该列
+type vendorColumns struct {
+	Symbol, Date, Open, High, Low, Close, AdjClose, AdjFactor, Volume, PE int
+}
+
+var vendorProfiles = []vendorProfile{
+	{
+		Name:        "wind-daily",
+		Description: "Wind 行情序列 (多证券, 后复权收盘价单列)",
+		Header:      "代码,名称,日期,开盘价,最高价,最低价,收盘价,成交量(股),成交额(元),后复权收盘价,市盈率PE(TTM)",
+		Encoding:    "GBK",
+		Columns:     vendorColumns{Symbol: 0, Date: 2, Open: 3, High: 4, Low: 5, Close: 6, AdjClose: 9, AdjFactor: -1, Volume: 7, PE: 10},
+		NullMarkers: []string{"N/A", "#N/A"},
+		VolumeUnit:  1,
+	},
+	{
+		Name:        "wind-excel",
+		Description: "Wind Excel 插件数据表 (WSS/WSD 平铺导出, 复权因子单列)",
+		Header:      "证券代码,日期,开盘价,最高价,最低价,收盘价,成交量,复权因子,市盈率TTM",
+		Encoding:    "GBK",
+		Columns:     vendorColumns{Symbol: 0, Date: 1, Open: 2, High: 3, Low: 4, Close: 5, AdjClose: -1, AdjFactor: 7, Volume: 6, PE: 8},
+		NullMarkers: []string{"N/A", "#N/A", "#VALUE!"},
+		VolumeUnit:  1,
+	},
+	{
+		Name:        "choice-daily",
+		Description: "Choice 历史行情 (成交量单位为手)",
+		Header:      "证券代码,证券名称,交易日期,前收盘价,开盘价,最高价,最低价,收盘价,成交量(手),成交金额(元),复权因子,市盈率(TTM)",
+		Encoding:    "GBK",
+		Columns:     vendorColumns{Symbol: 0, Date: 2, Open: 4, High: 5, Low: 6, Close: 7, AdjClose: -1, AdjFactor: 10, Volume: 8, PE: 11},
+		NullMarkers: []string{"--", "停牌"},
+		VolumeUnit:  100,
+	},
+	{
+		Name:        "choice-excel",
+		Description: "Choice Excel 插件序列导出 (后复权收盘价单列)",
+		Header:      "代码,日期,开盘价,最高价,最低价,收盘价,后复权收盘价,成交量,PE(TTM)",
+		Encoding:    "GBK",
+		Columns:     vendorColumns{Symbol: 0, Date: 1, Open: 2, High: 3, Low: 4, Close: 5, AdjClose: 6, AdjFactor: -1, Volume: 7, PE: 8},
+		NullMarkers: []string{"--"},
+		VolumeUnit:  1,
+	},
+}
+
+func findVendorProfile(name string) (vendorProfile, bool) {
+	for _, p := range vendorProfiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return vendorProfile{}, false
+}
+
+// 至少要有的列数 (最大列号 + 1)
+func (p vendorProfile) minCols() int {
+	c := p.Columns
+	n := 0
+	for _, k := range []int{c.Symbol, c.Date, c.Open, c.High, c.Low, c.Close, c.AdjClose, c.AdjFactor, c.Volume, c.PE} {
+		n = max(n, k+1)
+	}
+	return n
+}
+
+func (p vendorProfile) mapper(a *rowArena, fields [][]byte) []any {
+	get := func(k int) any {
+		b := bytes.TrimSpace(field(fields, k))
+		for _, m := range p.NullMarkers {
+			if string(b) == m {
+				return nil
+			}
+		}
+		return normNumBytes(b)
+	}
+	c := p.Columns
+	symbol, ok := a.symbol(field(fields, c.Symbol)).(string)
+	date := a.date(field(fields, c.Date))
+	if !ok || date == nil {
+		return nil
+	}
+	closeRaw := get(c.Close)
+	adjClose := get(c.AdjClose)
+	if f, ok := get(c.AdjFactor).(float64); ok {
+		if cl, ok := closeRaw.(float64); ok {
+			adjClose = cl * f
+		}
+	}
+	volume := get(c.Volume)
+	if v, ok := volume.(float64); ok {
+		volume = v * p.VolumeUnit
+	}
+	row := adjustedHistoryRow(symbol, date, get(c.Open), get(c.High), get(c.Low), closeRaw, adjClose, volume)
+	if row != nil {
+		row[7] = get(c.PE)
+	}
+	return row
+}
+
+// chronos vendor [--profile wind-daily] --path "D:\wind\*.csv"
+// chronos vendor --list
+func runVendor(args []string) {
+	fs := flag.NewFlagSet("vendor", flag.ExitOnError)
+	profileName := fs.String("profile", "", "导出格式名称 (--list 查看全部)；为空时按表头指纹自动识别 (见 profiles.go)")
+	path := fs.String("path", "", "导出文件 glob")
+	list := fs.Bool("list", false, "列出内置导出格式")
+	config := fs.String("config", DefaultSourcesConfig, "数据源配置文件 (profiles 节登记自定义布局)")
+	fs.Parse(args)
+
+	if *list {
+		names := make([]string, 0, len(vendorProfiles))
+		for _, p := range vendorProfiles {
+			names = append(names, p.Name)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			p, _ := findVendorProfile(n)
+			fmt.Printf("%-14s %s [%s]\n%14s 表头: %s (指纹 %s)\n", p.Name, p.Description, p.Encoding, "", p.Header, headerFingerprint(strings.Split(p.Header, ",")))
+		}
+		return
+	}
+	if *path == "" {
+		log.Fatal("[ERROR] 需要指定 --path")
+	}
+	var newMapper func([]string) rowMapper
+	minCols := 2
+	if err := setupImportOptions(*config); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if *profileName != "" {
+		p, ok := findVendorProfile(*profileName)
+		if !ok {
+			log.Fatalf("[ERROR] 未知导出格式: %q (用 --list 查看全部)", *profileName)
+		}
+		newMapper = func([]string) rowMapper { return p.mapper }
+		minCols = p.minCols()
+	} else {
+		profiles, err := loadHeaderProfiles(*config)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		newMapper = profileMapper(profiles, "stock_history", nil, 0)
+	}
+
+	start := time.Now()
+	db := openOrCreateDB()
+	defer db.Close()
+	log.Printf(">>> 正在导入 %s...", *path)
+	// 与已有数据重叠时以导出文件为准
+	importCSVFiles(db, *path, "stock_history", `ON CONFLICT (symbol, date) DO UPDATE SET
+		close = excluded.close, close_adj = excluded.close_adj, open_adj = excluded.open_adj,
+		high_adj = excluded.high_adj, low_adj = excluded.low_adj,
+		pe = coalesce(excluded.pe, pe), volume = coalesce(excluded.volume, volume)`, minCols, newMapper)
+	createViews(db)
+	log.Printf(">>> ✅ 导入完成, 耗时: %s", time.Since(start))
+}
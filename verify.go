@@ -0,0 +1,251 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 参照完整性检查
+// ---------------------------------------------------------
+// stock_history 中的行应当能在参照表中找到对应：代码在股票列表中、当天有行业分类、日期是交易日。
+// 对不上的行 (孤儿行) 多半来自供应商的错误 (错填的代码、周末的日期)，或参照表本身不全。
+// chronos verify 逐项统计孤儿行并列出样本；--quarantine 把选中检查项的孤儿行移到 quarantine_history
+// (带原因与时间，可人工核对后放回)，从 stock_history 中删除：
+//
+//	chronos verify                                # 只报告
+//	chronos verify --quarantine symbol,calendar   # 隔离不在股票列表中、日期不是交易日的行
+//	chronos update --sources files,tushare,verify # 作为 update 的最后一步
+//
+// 检查项 (参照表为空时跳过该项)：
+//
+//	symbol     A 股代码不在 stock_basic 中 (股票列表由 tushare --basic 同步，含已退市)
+//	industry   A 股 (代码, 日期) 在 industry_class 中没有 --scheme 口径的行业 (neutralize 等按行业关联时这些行会被丢掉)
+//	calendar   日期是周末，或在 trade_calendar 覆盖的区间内却不是交易日 (交易日历由 tushare 拉取时保存)
+
+// 每项列出的样本数
+const verifySamples = 10
+
+// 交易所交易日历 (tushare trade_cal)，market 与 stock_history 相同
+func ensureTradeCalendar(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS trade_calendar (
+		market TEXT NOT NULL,
+		date   TEXT NOT NULL,
+		PRIMARY KEY (market, date)
+	) WITHOUT ROWID, STRICT;`)
+}
+
+// 保存交易日 (YYYYMMDD 或 YYYY-MM-DD)
+func saveTradeCalendar(db *sql.DB, market string, dates []string) error {
+	ensureTradeCalendar(db)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO trade_calendar VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, d := range dates {
+		if _, err := stmt.Exec(market, normDate(d)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func ensureQuarantineTable(db *sql.DB) {
+	mustExec(db, `CREATE TABLE IF NOT EXISTS quarantine_history (
+		symbol         TEXT NOT NULL,
+		date           TEXT NOT NULL,
+		close          REAL,
+		close_adj      REAL,
+		open_adj       REAL,
+		high_adj       REAL,
+		low_adj        REAL,
+		pe             REAL,
+		volume         REAL,
+		market         TEXT NOT NULL,
+		reason         TEXT NOT NULL,  -- 检查项 (symbol / industry / calendar)
+		quarantined_at TEXT NOT NULL   -- 本地时间
+	) STRICT;`)
+}
+
+// 一项检查：cond 为 stock_history h 的一行是孤儿行的条件
+type verifyCheck struct {
+	name, title string
+	cond        string
+	skip        string // 参照表为空等无法检查的原因，为空时检查
+}
+
+var verifyCheckNames = []string{"symbol", "industry", "calendar"}
+
+func tableRows(db *sql.DB, table string) int {
+	var exists, n int
+	db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&exists)
+	if exists > 0 {
+		db.QueryRow("SELECT count(*) FROM " + table).Scan(&n)
+	}
+	return n
+}
+
+// 按当前库中的参照表生成各项检查
+func verifyChecks(db *sql.DB, scheme industryScheme) []verifyCheck {
+	checks := []verifyCheck{
+		{name: "symbol", title: "代码不在 stock_basic 中",
+			cond: "h.market = 'CN' AND NOT EXISTS (SELECT 1 FROM stock_basic b WHERE b.symbol = h.symbol)"},
+		{name: "industry", title: "没有 " + scheme.String() + " 行业分类",
+			cond: "h.market = 'CN' AND NOT EXISTS (SELECT 1 FROM industry_class i WHERE " + industryJoinCond(scheme) + ")"},
+		{name: "calendar", title: "不是交易日",
+			cond: "strftime('%w', h.date) IN ('0', '6')"},
+	}
+	if tableRows(db, "stock_basic") == 0 {
+		checks[0].skip = "stock_basic 为空 (用 chronos tushare --basic 同步)"
+	}
+	var n int
+	if tableRows(db, "industry_class") > 0 {
+		db.QueryRow("SELECT count(*) FROM industry_class WHERE standard = ? AND level = ?", scheme.Standard, scheme.Level).Scan(&n)
+	}
+	if n == 0 {
+		checks[1].skip = "industry_class 中没有 " + scheme.String() + " 口径的分类"
+	}
+	// 交易日历只在它覆盖的区间内参照
+	if tableRows(db, "trade_calendar") > 0 {
+		rows, err := db.Query("SELECT market, min(date), max(date) FROM trade_calendar GROUP BY market")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var market, first, last string
+			if err := rows.Scan(&market, &first, &last); err != nil {
+				log.Fatal(err)
+			}
+			checks[2].cond += fmt.Sprintf(` OR (h.market = %s AND h.date BETWEEN %s AND %s
+				AND NOT EXISTS (SELECT 1 FROM trade_calendar c WHERE c.market = h.market AND c.date = h.date))`,
+				sqlQuote(market), sqlQuote(first), sqlQuote(last))
+		}
+	}
+	return checks
+}
+
+// 统计、隔离的结果
+type verifyResult struct {
+	check   verifyCheck
+	rows    int
+	symbols []string // 有孤儿行的代码
+	samples []string
+}
+
+// 统计 check 的孤儿行
+func countOrphans(db *sql.DB, check verifyCheck) (*verifyResult, error) {
+	r := &verifyResult{check: check}
+	rows, err := db.Query("SELECT h.symbol, count(*), min(h.date), max(h.date) FROM stock_history h WHERE " + check.cond + " GROUP BY h.symbol ORDER BY h.symbol")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var symbol, first, last string
+		var n int
+		if err := rows.Scan(&symbol, &n, &first, &last); err != nil {
+			return nil, err
+		}
+		r.rows += n
+		r.symbols = append(r.symbols, symbol)
+		if len(r.samples) < verifySamples {
+			r.samples = append(r.samples, fmt.Sprintf("%s %d 行 (%s ~ %s)", symbol, n, first, last))
+		}
+	}
+	return r, rows.Err()
+}
+
+// 把孤儿行移到 quarantine_history，返回移走的行数
+func quarantineOrphans(db *sql.DB, check verifyCheck) (int64, error) {
+	ensureQuarantineTable(db)
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("INSERT INTO quarantine_history SELECT h.*, ?, ? FROM stock_history h WHERE "+check.cond,
+		check.name, time.Now().Format(time.DateTime)); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec("DELETE FROM stock_history AS h WHERE " + check.cond)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
+}
+
+// chronos verify [--scheme SW1] [--quarantine symbol,industry,calendar]
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	schemeArg := fs.String("scheme", DefaultIndustryScheme, "行业分类口径 (SW1~SW3 / CITIC1~CITIC3)")
+	quarantine := fs.String("quarantine", "", "把这些检查项 (逗号分隔: symbol, industry, calendar，或 all) 的孤儿行移到 quarantine_history")
+	fs.Parse(args)
+	scheme, err := parseIndustryScheme(*schemeArg)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	move := map[string]bool{}
+	for _, name := range splitList(*quarantine) {
+		switch {
+		case name == "all":
+			for _, n := range verifyCheckNames {
+				move[n] = true
+			}
+		case containsString(verifyCheckNames, name):
+			move[name] = true
+		default:
+			log.Fatalf("[ERROR] 未知检查项 %q (可选: %s, all)", name, strings.Join(verifyCheckNames, ", "))
+		}
+	}
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+	log.Println(">>> 正在检查 stock_history 的参照完整性...")
+	orphans, moved := 0, 0
+	stale := map[string]bool{}
+	for _, check := range verifyChecks(db, scheme) {
+		if check.skip != "" {
+			log.Printf(">>> %-8s 跳过: %s", check.name, check.skip)
+			continue
+		}
+		r, err := countOrphans(db, check)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if r.rows == 0 {
+			log.Printf(">>> %-8s %s: 无", check.name, check.title)
+			continue
+		}
+		orphans += r.rows
+		log.Printf("[WARN] %-8s %s: %d 行, %d 只股票，如 %s", check.name, check.title, r.rows, len(r.symbols), strings.Join(r.samples, "; "))
+		if !move[check.name] {
+			continue
+		}
+		n, err := quarantineOrphans(db, check)
+		if err != nil {
+			log.Fatal(err)
+		}
+		moved += int(n)
+		for _, s := range r.symbols {
+			stale[s] = true
+		}
+		log.Printf(">>> %-8s 已隔离 %d 行到 quarantine_history", check.name, n)
+	}
+	if len(stale) > 0 {
+		historyChanged(db, mapKeys(stale))
+	}
+	log.Printf(">>> ✅ 检查完成: 孤儿行 %d 行, 隔离 %d 行, 耗时: %s", orphans, moved, time.Since(start))
+}
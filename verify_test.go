@@ -0,0 +1,61 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestVerifyOrphans(t *testing.T) {
+	db := openTestDB(t, "verify.db")
+	createTables(db)
+	for _, r := range [][3]string{
+		{"600000.SH", "2024-01-02", "CN"},
+		{"600000.SH", "2024-01-06", "CN"}, // 周六
+		{"600000.SH", "2024-02-12", "CN"}, // 春节休市，交易日历中没有
+		{"999999.SH", "2024-01-02", "CN"}, // 不在股票列表中，也没有行业
+		{"AAPL", "2024-01-02", "US"},      // 其他市场不参照 stock_basic
+	} {
+		mustExec(db, "INSERT INTO stock_history (symbol, date, market) VALUES (?, ?, ?)", r[0], r[1], r[2])
+	}
+	ensureStockBasic(db)
+	mustExec(db, "INSERT INTO stock_basic (symbol) VALUES ('600000.SH')")
+	ensureIndustryTables(db)
+	mustExec(db, "INSERT INTO industry_class VALUES ('600000.SH', 'SW', 1, '801780', '银行', '2000-01-01', NULL)")
+	if err := saveTradeCalendar(db, MarketCN, []string{"20240102", "20240209", "20240219"}); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := verifyChecks(db, industryScheme{"SW", 1})
+	got := map[string][]string{}
+	for _, c := range checks {
+		if c.skip != "" {
+			t.Fatalf("%s 跳过: %s", c.name, c.skip)
+		}
+		r, err := countOrphans(db, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[c.name] = r.samples
+	}
+	want := map[string][]string{
+		"symbol":   {"999999.SH 1 行 (2024-01-02 ~ 2024-01-02)"},
+		"industry": {"999999.SH 1 行 (2024-01-02 ~ 2024-01-02)"},
+		"calendar": {"600000.SH 2 行 (2024-01-06 ~ 2024-02-12)"},
+	}
+	for name, w := range want {
+		if !slices.Equal(got[name], w) {
+			t.Errorf("%s: %q, want %q", name, got[name], w)
+		}
+	}
+
+	// 隔离后从 stock_history 中移走，带上原因
+	if n, err := quarantineOrphans(db, checks[2]); err != nil || n != 2 {
+		t.Fatalf("quarantine: %d, %v", n, err)
+	}
+	if s := dumpTable(t, db, "SELECT symbol, date, reason FROM quarantine_history ORDER BY date"); s != "600000.SH|2024-01-06|calendar\n600000.SH|2024-02-12|calendar\n" {
+		t.Errorf("quarantine_history:\n%s", s)
+	}
+	if s := dumpTable(t, db, "SELECT symbol, date FROM stock_history ORDER BY symbol, date"); s != "600000.SH|2024-01-02\n999999.SH|2024-01-02\nAAPL|2024-01-02\n" {
+		t.Errorf("stock_history:\n%s", s)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"log"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 滚动窗口辅助视图
+// ---------------------------------------------------------
+// 只用 SQLite 内置函数 (sqrt 需要 3.35+ 的数学函数)，Python/DBeaver 等外部工具也能直接查询。
+// 窗口未满 (例如上市不足 250 天) 时对应列为 NULL，避免用半截窗口误导下游。
+// 成交量只在部分日期上有值 (见 stock_history.volume)，20 日均量要求窗口内 20 天都有成交量，否则为 NULL。
+
+var viewDDL = []string{
+	`DROP VIEW IF EXISTS v_daily_returns;`,
+	`CREATE VIEW v_daily_returns AS
+	SELECT symbol, date, close_adj,
+		close_adj / LAG(close_adj) OVER (PARTITION BY symbol ORDER BY date) - 1 AS ret
+	FROM stock_history;`,
+
+	`DROP VIEW IF EXISTS v_rolling_stats;`,
+	`CREATE VIEW v_rolling_stats AS
+	SELECT symbol, date, close_adj,
+		CASE WHEN COUNT(*) OVER w20 >= 20 THEN AVG(close_adj) OVER w20 END AS ma_20,
+		CASE WHEN COUNT(*) OVER w60 >= 60 THEN AVG(close_adj) OVER w60 END AS ma_60,
+		CASE WHEN COUNT(*) OVER w250 >= 250 THEN MAX(high_adj) OVER w250 END AS high_250,
+		CASE WHEN COUNT(*) OVER w250 >= 250 THEN MIN(low_adj) OVER w250 END AS low_250,
+		-- 60 日年化波动率：样本方差 = (Σx² - (Σx)²/n) / (n-1)
+		CASE WHEN COUNT(ret) OVER w60 >= 60 THEN sqrt(252 * max(0,
+			(SUM(ret * ret) OVER w60 - SUM(ret) OVER w60 * SUM(ret) OVER w60 / COUNT(ret) OVER w60)
+			/ (COUNT(ret) OVER w60 - 1))) END AS vol_60,
+		CASE WHEN COUNT(volume) OVER w20 >= 20 THEN AVG(volume) OVER w20 END AS vol_mean_20
+	FROM (
+		SELECT symbol, date, close_adj, high_adj, low_adj, volume,
+			close_adj / LAG(close_adj) OVER (PARTITION BY symbol ORDER BY date) - 1 AS ret
+		FROM stock_history
+	)
+	WINDOW
+		w20  AS (PARTITION BY symbol ORDER BY date ROWS 19 PRECEDING),
+		w60  AS (PARTITION BY symbol ORDER BY date ROWS 59 PRECEDING),
+		w250 AS (PARTITION BY symbol ORDER BY date ROWS 249 PRECEDING);`,
+
+	// 距上一次/下一次定期报告披露的自然日数 (见 announcements.go)。
+	// 预约日已过但尚未实际披露 (延期) 的报告视为随时可能披露，days_to_earnings 记为 0；
+	// 超过预约日 30 天仍无实际日期的视为数据缺失，不再参与计算。
+	`DROP VIEW IF EXISTS v_earnings_days;`,
+	`CREATE VIEW v_earnings_days AS
+	SELECT h.symbol, h.date,
+		CAST((SELECT min(max(julianday(coalesce(a.scheduled_date, a.actual_date)), julianday(h.date)))
+			FROM announcements a
+			WHERE a.symbol = h.symbol
+				AND (a.actual_date IS NULL OR a.actual_date > h.date)
+				AND coalesce(a.scheduled_date, a.actual_date) >= date(h.date, '-30 days'))
+			- julianday(h.date) AS INTEGER) AS days_to_earnings,
+		CAST(julianday(h.date) - (SELECT max(julianday(a.actual_date)) FROM announcements a
+			WHERE a.symbol = h.symbol AND a.actual_date <= h.date) AS INTEGER) AS days_since_earnings
+	FROM stock_history h;`,
+
+	// 分析师一致预期 (见 forecasts.go)
+	`DROP VIEW IF EXISTS v_consensus;`,
+	consensusViewSQL,
+
+	// 宏观序列按发布滞后对齐到交易日 (见 macro.go)
+	`DROP VIEW IF EXISTS v_macro_daily;`,
+	`CREATE VIEW v_macro_daily AS
+	SELECT d.date, m.series_id,
+		(SELECT s.value FROM macro_series s
+			WHERE s.series_id = m.series_id AND s.date <= date(d.date, '-' || m.release_lag || ' days')
+			ORDER BY s.date DESC LIMIT 1) AS value
+	FROM (SELECT DISTINCT date FROM stock_history) d
+	CROSS JOIN macro_meta m;`,
+
+	// 上市以来的自然日数 (见 ipo.go)
+	`DROP VIEW IF EXISTS v_listing_days;`,
+	`CREATE VIEW v_listing_days AS
+	SELECT h.symbol, h.date,
+		CAST(julianday(h.date) - julianday(coalesce(i.listing_date, h.first_date)) AS INTEGER) AS days_since_listing
+	FROM (SELECT symbol, date, MIN(date) OVER (PARTITION BY symbol) AS first_date FROM stock_history) h
+	LEFT JOIN ipo_info i ON i.symbol = h.symbol;`,
+
+	// 原始收盘价 × 当日有效股本 (见 shares.go)
+	`DROP VIEW IF EXISTS v_market_cap;`,
+	`CREATE VIEW v_market_cap AS
+	SELECT h.symbol, h.date,
+		h.close * s.total_shares AS market_cap,
+		h.close * s.float_shares AS float_market_cap,
+		h.close * s.free_float_shares AS free_float_cap
+	FROM stock_history h
+	LEFT JOIN share_structure s ON s.symbol = h.symbol
+		AND s.change_date = (SELECT max(t.change_date) FROM share_structure t
+			WHERE t.symbol = h.symbol AND t.change_date <= h.date);`,
+
+	// 可转债与正股同日对齐；供应商未给转股价值/溢价率时用正股收盘价现算
+	`DROP VIEW IF EXISTS v_cb_arbitrage;`,
+	`CREATE VIEW v_cb_arbitrage AS
+	SELECT bond_code, date, underlying, close, conv_price, stock_close, conv_value,
+		coalesce(conv_premium, (close / conv_value - 1) * 100) AS conv_premium,
+		ytm
+	FROM (
+		SELECT c.bond_code, c.date, c.underlying, c.close, c.conv_price, c.conv_premium, c.ytm,
+			h.close AS stock_close,
+			coalesce(c.conv_value, 100.0 / c.conv_price * h.close) AS conv_value
+		FROM cb_daily c
+		LEFT JOIN stock_history h ON h.symbol = c.underlying AND h.date = c.date
+	);`,
+}
+
+func createViews(db *sql.DB) {
+	for _, ddl := range viewDDL {
+		mustExec(db, ddl)
+	}
+}
+
+// chronos views [--materialize]：重建视图，可选物化为 rolling_stats 表以加速查询
+func runViews(args []string) {
+	fs := flag.NewFlagSet("views", flag.ExitOnError)
+	materialize := fs.Bool("materialize", false, "把 v_rolling_stats 物化为 rolling_stats 表 (带主键，查询快但占空间)")
+	fs.Parse(args)
+
+	start := time.Now()
+	db := openDB()
+	defer db.Close()
+
+	createViews(db)
+	createPITView(db)
+	log.Println(">>> 视图已重建: v_daily_returns, v_rolling_stats, v_earnings_days, v_consensus, v_macro_daily, v_listing_days, v_market_cap, v_cb_arbitrage")
+
+	if *materialize {
+		log.Println(">>> 正在物化 rolling_stats...")
+		materializeRollingStats(db)
+	}
+	log.Printf(">>> ✅ 完成, 耗时: %s", time.Since(start))
+}
+
+// 列名显式列出，视图加列后这里要同步，否则 SELECT * 会因列数不符直接报错
+func materializeRollingStats(db *sql.DB) {
+	mustExec(db, "BEGIN TRANSACTION;")
+	mustExec(db, "DROP TABLE IF EXISTS rolling_stats;")
+	mustExec(db, `CREATE TABLE rolling_stats (
+		symbol      TEXT NOT NULL,
+		date        TEXT NOT NULL,
+		close_adj   REAL,
+		ma_20       REAL,
+		ma_60       REAL,
+		high_250    REAL,
+		low_250     REAL,
+		vol_60      REAL,
+		vol_mean_20 REAL,
+		PRIMARY KEY (symbol, date)
+	) WITHOUT ROWID, STRICT;`)
+	mustExec(db, `INSERT INTO rolling_stats (symbol, date, close_adj, ma_20, ma_60, high_250, low_250, vol_60, vol_mean_20)
+		SELECT symbol, date, close_adj, ma_20, ma_60, high_250, low_250, vol_60, vol_mean_20 FROM v_rolling_stats;`)
+	mustExec(db, "COMMIT;")
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 物化表与视图列一一对应；窗口未满的行为 NULL
+func TestMaterializeRollingStats(t *testing.T) {
+	db := openTestDB(t, "views.db")
+	createTables(db)
+	for i := 0; i < 21; i++ {
+		date := fmt.Sprintf("2024-01-%02d", i+1)
+		mustExec(db, `INSERT INTO stock_history (symbol, date, close_adj, high_adj, low_adj, volume, market)
+			VALUES ('000001', ?, ?, ?, ?, ?, 'CN')`, date, 10+float64(i), 11+float64(i), 9+float64(i), 100*(i+1))
+	}
+	createViews(db)
+	materializeRollingStats(db)
+
+	got := dumpTable(t, db, `SELECT date, ma_20, vol_mean_20, ma_60 FROM rolling_stats
+		WHERE date >= '2024-01-19' ORDER BY date`)
+	want := "2024-01-19|NULL|NULL|NULL\n2024-01-20|19.5|1050|NULL\n2024-01-21|20.5|1150|NULL\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
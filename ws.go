@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------
+// WebSocket 推送
+// ---------------------------------------------------------
+// 最小的 RFC 6455 服务端：只向订阅者广播文本消息，客户端发来的数据帧丢弃，
+// 只处理 ping 与 close。订阅者处理不过来 (缓冲区满) 时直接断开，不拖慢其他订阅者。
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+type wsClient struct {
+	conn net.Conn
+	send chan []byte
+	once sync.Once
+	wmu  sync.Mutex // 推送与 pong / close 回复可能同时写
+}
+
+func (c *wsClient) write(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return wsWriteFrame(c.conn, op, payload)
+}
+
+func (c *wsClient) close() {
+	c.once.Do(func() {
+		close(c.send)
+		c.conn.Close()
+	})
+}
+
+type wsHub struct {
+	mu      sync.Mutex
+	clients map[*wsClient]bool
+	joined  chan struct{} // 有订阅者连入时通知 (不阻塞)
+}
+
+func newWSHub() *wsHub {
+	return &wsHub{clients: map[*wsClient]bool{}, joined: make(chan struct{}, 1)}
+}
+
+func (h *wsHub) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+func (h *wsHub) remove(c *wsClient) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+	c.close()
+}
+
+// 向所有订阅者发送一条文本消息
+func (h *wsHub) broadcast(msg []byte) {
+	h.mu.Lock()
+	var slow []*wsClient
+	for c := range h.clients {
+		select {
+		case c.send <- msg:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	h.mu.Unlock()
+	for _, c := range slow {
+		log.Printf("[WARN] 订阅者 %s 处理过慢，已断开", c.conn.RemoteAddr())
+		h.remove(c)
+	}
+}
+
+func (h *wsHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "需要 WebSocket 连接", http.StatusBadRequest)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "不支持连接升级", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	c := &wsClient{conn: conn, send: make(chan []byte, 4096)}
+	h.mu.Lock()
+	h.clients[c] = true
+	h.mu.Unlock()
+	select {
+	case h.joined <- struct{}{}:
+	default:
+	}
+	log.Printf(">>> 订阅者接入: %s (共 %d 个)", conn.RemoteAddr(), h.count())
+
+	// 写：发送队列中的消息；读：处理 ping / close，连接断开时注销
+	go func() {
+		for msg := range c.send {
+			if err := c.write(0x1, msg); err != nil {
+				h.remove(c)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer h.remove(c)
+		for {
+			op, payload, err := wsReadFrame(rw.Reader)
+			if err != nil {
+				return
+			}
+			switch op {
+			case 0x8:
+				c.write(0x8, nil)
+				return
+			case 0x9:
+				c.write(0xA, payload)
+			}
+		}
+	}()
+}
+
+// 等各订阅者的发送队列清空 (最多 timeout) 后关闭所有连接
+func (h *wsHub) closeAll(timeout time.Duration) {
+	h.mu.Lock()
+	clients := h.clients
+	h.clients = map[*wsClient]bool{}
+	h.mu.Unlock()
+	deadline := time.Now().Add(timeout)
+	for c := range clients {
+		for len(c.send) > 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		c.write(0x8, nil)
+		c.close()
+	}
+}
+
+// 服务端发出的帧不加掩码
+func wsWriteFrame(w io.Writer, op byte, payload []byte) error {
+	head := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		head = append(head, byte(n))
+	case n <= 0xFFFF:
+		head = binary.BigEndian.AppendUint16(append(head, 126), uint16(n))
+	default:
+		head = binary.BigEndian.AppendUint64(append(head, 127), uint64(n))
+	}
+	_, err := w.Write(append(head, payload...))
+	return err
+}
+
+// 读一帧 (客户端的帧必带掩码)，返回操作码与解掩码后的内容
+func wsReadFrame(r *bufio.Reader) (byte, []byte, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return 0, nil, err
+	}
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		b := make([]byte, 2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b))
+	case 127:
+		b := make([]byte, 8)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b)
+	}
+	if n > 1<<20 {
+		return 0, nil, io.ErrUnexpectedEOF // 订阅端不应发送大消息
+	}
+	var mask []byte
+	if head[1]&0x80 != 0 {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(r, mask); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for k := range mask {
+		for j := k; j < len(payload); j += 4 {
+			payload[j] ^= mask[k]
+		}
+	}
+	return head[0] & 0x0F, payload, nil
+}
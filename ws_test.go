@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 握手后收到一根 K 线、结束消息和 close 帧
+func TestWSHubReplay(t *testing.T) {
+	hub := newWSHub()
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	// RFC 6455 1.3 的示例 key
+	conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("握手响应: %s, accept %q", resp.Status, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+
+	select {
+	case <-hub.joined:
+	case <-time.After(5 * time.Second):
+		t.Fatal("订阅者未登记")
+	}
+	bar := `{"type":"bar","symbol":"600000.SH","ts":1710486000,"close":10.08}`
+	hub.broadcast([]byte(bar))
+	hub.broadcast([]byte(`{"type":"end"}`))
+	go hub.closeAll(5 * time.Second)
+
+	for _, want := range []struct {
+		op      byte
+		payload string
+	}{{0x1, bar}, {0x1, `{"type":"end"}`}, {0x8, ""}} {
+		op, payload, err := wsReadFrame(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op != want.op || string(payload) != want.payload {
+			t.Fatalf("帧 op=%#x %q, 期望 op=%#x %q", op, payload, want.op, want.payload)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// Yahoo Finance (港股 / 美股)
+// ---------------------------------------------------------
+// 使用 yfinance 同款的 chart 接口拉取日线，按与网盘港股/美股文件相同的方式写入 stock_history：
+// 原始 OHLC + Adj Close 换算复权价 (见 adjustedHistoryRow)，代码统一为 00700.HK、AAPL.US。
+// 交易日期按交易所当地时区取。
+//
+// Adj Close 以拉取当天的价格为基准，两次拉取之间发生过分红或拆股时新旧两段的基准不同。续拉时从库中已有的
+// 最后一天 (锚点) 开始拉，把新数据的复权价整体缩放到锚点上库中的复权价 (见 chainAdjusted)，接缝处收益率不变；
+// 拉回的数据里没有锚点那一天时无法衔接，改为重拉全部历史。
+
+const yahooChartURL = "https://query1.finance.yahoo.com/v8/finance/chart/"
+
+// 库内代码 -> Yahoo 代码：00700.HK -> 0700.HK，AAPL.US -> AAPL，BRK-B.US -> BRK-B
+func yahooTicker(symbol string) (ticker, canonical string, err error) {
+	switch marketOf(symbol) {
+	case MarketHK:
+		c, ok := normHKSymbol(symbol).(string)
+		if !ok {
+			break
+		}
+		code := strings.TrimSuffix(c, ".HK")
+		if strings.HasPrefix(code, "0") {
+			code = code[1:] // Yahoo 用 4 位代码
+		}
+		return code + ".HK", c, nil
+	case MarketUS:
+		c, ok := normUSSymbol(symbol).(string)
+		if !ok {
+			break
+		}
+		return strings.TrimSuffix(c, ".US"), c, nil
+	}
+	return "", "", fmt.Errorf("不是港股/美股代码: %s (港股写作 00700.HK，美股写作 AAPL.US)", symbol)
+}
+
+type yahooChart struct {
+	Chart struct {
+		Result []struct {
+			Meta struct {
+				ExchangeTimezoneName string `json:"exchangeTimezoneName"`
+			} `json:"meta"`
+			Timestamp  []int64 `json:"timestamp"`
+			Indicators struct {
+				Quote []struct {
+					Open   []*float64 `json:"open"`
+					High   []*float64 `json:"high"`
+					Low    []*float64 `json:"low"`
+					Close  []*float64 `json:"close"`
+					Volume []*float64 `json:"volume"`
+				} `json:"quote"`
+				AdjClose []struct {
+					AdjClose []*float64 `json:"adjclose"`
+				} `json:"adjclose"`
+			} `json:"indicators"`
+		} `json:"result"`
+		Error *struct {
+			Code        string `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	} `json:"chart"`
+}
+
+// 拉取 [start, end) 的日线，返回 stock_history 行
+func yahooDaily(ctx context.Context, symbol string, start, end time.Time) ([][]any, error) {
+	ticker, canonical, err := yahooTicker(symbol)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{
+		"period1":              {fmt.Sprint(start.Unix())},
+		"period2":              {fmt.Sprint(end.Unix())},
+		"interval":             {"1d"},
+		"events":               {"div,split"},
+		"includeAdjustedClose": {"true"},
+	}
+	u := yahooChartURL + url.PathEscape(ticker) + "?" + q.Encode()
+	body, err := cachedFetch(ctx, "yahoo", u, func() ([]byte, error) {
+		if err := apiWait(ctx, "yahoo"); err != nil {
+			return nil, err
+		}
+		return getBody(ctx, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var resp yahooChart
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if e := resp.Chart.Error; e != nil {
+		return nil, fmt.Errorf("yahoo %s: %s %s", ticker, e.Code, e.Description)
+	}
+	if len(resp.Chart.Result) == 0 || len(resp.Chart.Result[0].Indicators.Quote) == 0 {
+		return nil, nil
+	}
+	res := resp.Chart.Result[0]
+	quote := res.Indicators.Quote[0]
+	var adj []*float64
+	if len(res.Indicators.AdjClose) > 0 {
+		adj = res.Indicators.AdjClose[0].AdjClose
+	}
+	tz := res.Meta.ExchangeTimezoneName
+	if tz == "" {
+		tz = marketTimezone(marketOf(canonical))
+	}
+	at := func(xs []*float64, k int) any {
+		if k < len(xs) && xs[k] != nil {
+			return *xs[k]
+		}
+		return nil
+	}
+
+	var rows [][]any
+	for k, ts := range res.Timestamp {
+		date := epochToLocal(ts, tz)[:10]
+		if row := adjustedHistoryRow(canonical, date, at(quote.Open, k), at(quote.High, k), at(quote.Low, k),
+			at(quote.Close, k), at(adj, k), at(quote.Volume, k)); row != nil {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// chronos yahoo --symbols AAPL.US,00700.HK [--from 2010-01-01] [--to 2024-12-31]
+func runYahoo(args []string) {
+	fs := flag.NewFlagSet("yahoo", flag.ExitOnError)
+	symbolsArg := fs.String("symbols", "", "港股/美股代码列表文件或逗号分隔的代码 (默认库中全部港股、美股)")
+	from := fs.String("from", "", "起始日期 (YYYY-MM-DD)，默认每只股票从库中最后一天之后续拉")
+	to := fs.String("to", "", "结束日期 (YYYY-MM-DD，含)，默认到今天")
+	fs.Parse(args)
+
+	end := time.Now()
+	if *to != "" {
+		t, err := time.Parse(time.DateOnly, *to)
+		if err != nil {
+			log.Fatalf("[ERROR] 日期格式错误: %s", *to)
+		}
+		end = t.AddDate(0, 0, 1)
+	}
+
+	start := time.Now()
+	db := openOrCreateDB()
+	defer db.Close()
+	if err := setupSources(DefaultSourcesConfig); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	ctx := context.Background()
+
+	var symbols []string
+	if *symbolsArg != "" {
+		var err error
+		if symbols, err = parseSymbols(*symbolsArg); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		symbols = marketSymbols(db, MarketHK, MarketUS)
+	}
+	if len(symbols) == 0 {
+		log.Fatal("[ERROR] 没有要拉取的代码 (请指定 --symbols)")
+	}
+
+	total := 0
+	for _, s := range symbols {
+		_, canonical, err := yahooTicker(s)
+		if err != nil {
+			log.Printf("[WARN] %v", err)
+			continue
+		}
+		full := time.Date(1970, 1, 2, 0, 0, 0, 0, time.UTC)
+		begin := full
+		if *from != "" {
+			if begin, err = time.Parse(time.DateOnly, *from); err != nil {
+				log.Fatalf("[ERROR] 日期格式错误: %s", *from)
+			}
+		}
+		// 锚点：起始日期之前 (续拉时为全部) 库中最后一行
+		var anchorDate string
+		var anchorAdj float64
+		before := "9999-12-31"
+		if *from != "" {
+			before = begin.Format(time.DateOnly)
+		}
+		if err := db.QueryRow(`SELECT date, close_adj FROM stock_history
+			WHERE symbol = ? AND date < ? AND close_adj IS NOT NULL ORDER BY date DESC LIMIT 1`,
+			canonical, before).Scan(&anchorDate, &anchorAdj); err == nil {
+			begin, _ = time.Parse(time.DateOnly, anchorDate)
+		}
+		if !begin.Before(end) {
+			continue
+		}
+
+		rows, err := yahooDaily(ctx, s, begin, end)
+		if err != nil {
+			log.Fatalf("[ERROR] %s: %v", s, err)
+		}
+		if anchorDate != "" && !chainAdjusted(rows, anchorDate, anchorAdj) {
+			log.Printf("[WARN] %s: 拉回的数据没有 %s，无法衔接复权价，重拉全部历史", canonical, anchorDate)
+			if rows, err = yahooDaily(ctx, s, full, end); err != nil {
+				log.Fatalf("[ERROR] %s: %v", s, err)
+			}
+		}
+		if err := saveHistoryRows(db, rows); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf(">>> %s: %d 行", canonical, len(rows))
+		total += len(rows)
+	}
+	createViews(db)
+	logAPIUsage()
+	log.Printf(">>> ✅ Yahoo 拉取完成: %d 行, 耗时: %s", total, time.Since(start))
+}
+
+// 把 rows (yahooDaily 的结果) 的复权价整体缩放，使 anchorDate 那一行的复权收盘等于 anchorAdj。
+// rows 中没有 anchorDate 时不做修改，返回 false。
+func chainAdjusted(rows [][]any, anchorDate string, anchorAdj float64) bool {
+	k := 0.0
+	for _, r := range rows {
+		if r[1] == anchorDate {
+			if adj, ok := r[3].(float64); ok && adj != 0 {
+				k = anchorAdj / adj
+			}
+			break
+		}
+	}
+	if k == 0 {
+		return false
+	}
+	for _, r := range rows {
+		for c := 3; c <= 6; c++ { // close_adj, open_adj, high_adj, low_adj
+			if x, ok := r[c].(float64); ok {
+				r[c] = x * k
+			}
+		}
+	}
+	return true
+}
+
+// 直接写入 stock_history (已存在的 (symbol, date) 以新数据为准)
+func saveHistoryRows(db *sql.DB, rows [][]any) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO stock_history VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range rows {
+		if _, err := stmt.Exec(r...); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	symbols := map[string]bool{}
+	for _, r := range rows {
+		symbols[r[0].(string)] = true
+	}
+	historyChanged(db, mapKeys(symbols))
+	return nil
+}
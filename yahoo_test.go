@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 锚点之后发生分红：新拉的复权价整体缩放到库中的基准，锚点当天与库中一致，之后的收益率不变
+func TestChainAdjusted(t *testing.T) {
+	rows := [][]any{
+		adjustedHistoryRow("AAPL.US", "2024-05-09", 100.0, 101.0, 99.0, 100.0, 98.0, 1e6),
+		adjustedHistoryRow("AAPL.US", "2024-05-10", 99.0, 100.0, 98.0, 99.5, 99.5, 1e6), // 除息日
+	}
+	if !chainAdjusted(rows, "2024-05-09", 49.0) {
+		t.Fatal("没有找到锚点")
+	}
+	got := fmt.Sprintf("%.4f %.4f %.4f %.4f", rows[0][3], rows[1][3], rows[1][4], rows[1][2])
+	if want := "49.0000 49.7500 49.5000 99.5000"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if chainAdjusted(rows, "2024-05-08", 49.0) {
+		t.Error("没有锚点那一天时应返回 false")
+	}
+	if rows[0][3] != 49.0 {
+		t.Errorf("没有锚点时不应修改数据: %v", rows[0][3])
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// 极简 YAML 解析器
+// ---------------------------------------------------------
+// 只支持配置文件里用得到的子集：
+//   - 按缩进嵌套的映射 (key: value) 和序列 (- item)
+//   - 单行标量 (可带单/双引号)、行内列表 [a, b]
+//   - 块文本 | 和 > (多行 SQL)
+//   - # 注释
+// 标量一律解析为 string，映射为 map[string]any，序列为 []any
+
+type yamlLine struct {
+	indent int
+	text   string // 去掉缩进后的内容
+	no     int    // 行号 (从 1 开始)，用于报错
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	raw   []string // 原始行，块文本需要保留空行和内部缩进
+	pos   int
+}
+
+func parseYAML(data []byte) (any, error) {
+	p := &yamlParser{raw: strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")}
+	for k, l := range p.raw {
+		if strings.HasPrefix(l, "\t") {
+			return nil, fmt.Errorf("第 %d 行: 缩进不能使用 Tab", k+1)
+		}
+		trimmed := strings.TrimLeft(l, " ")
+		p.lines = append(p.lines, yamlLine{indent: len(l) - len(trimmed), text: strings.TrimRight(trimmed, " "), no: k + 1})
+	}
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return map[string]any{}, nil
+	}
+	return p.parseNode(p.lines[p.pos].indent)
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) {
+		t := p.lines[p.pos].text
+		if t != "" && !strings.HasPrefix(t, "#") {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *yamlParser) parseNode(indent int) (any, error) {
+	if isSeqItem(p.lines[p.pos].text) {
+		return p.parseSeq(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func isSeqItem(t string) bool { return t == "-" || strings.HasPrefix(t, "- ") }
+
+func (p *yamlParser) parseSeq(indent int) ([]any, error) {
+	var out []any
+	for p.skipBlank(); p.pos < len(p.lines); p.skipBlank() {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent || !isSeqItem(l.text) {
+			return nil, fmt.Errorf("第 %d 行: 序列缩进不一致", l.no)
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		switch {
+		case rest == "":
+			p.pos++
+			p.skipBlank()
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				out = append(out, "")
+				continue
+			}
+			v, err := p.parseNode(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		case isMapEntry(rest):
+			// "- key: value" 视为缩进在 rest 起始列的映射
+			childIndent := l.indent + (len(l.text) - len(rest))
+			p.lines[p.pos] = yamlLine{indent: childIndent, text: rest, no: l.no}
+			v, err := p.parseMap(childIndent)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		default:
+			v, err := parseYAMLScalar(rest, l.no)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			p.pos++
+		}
+	}
+	return out, nil
+}
+
+// 判断是否为 key: value 形式 (冒号需在引号外，且后面为空或空格)
+func isMapEntry(t string) bool {
+	if strings.HasPrefix(t, "\"") || strings.HasPrefix(t, "'") || strings.HasPrefix(t, "[") {
+		return false
+	}
+	k := strings.Index(t, ":")
+	return k > 0 && (k == len(t)-1 || t[k+1] == ' ')
+}
+
+func (p *yamlParser) parseMap(indent int) (map[string]any, error) {
+	out := map[string]any{}
+	for p.skipBlank(); p.pos < len(p.lines); p.skipBlank() {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent || !isMapEntry(l.text) {
+			return nil, fmt.Errorf("第 %d 行: 无法解析 %q", l.no, l.text)
+		}
+		k := strings.Index(l.text, ":")
+		key := strings.TrimSpace(l.text[:k])
+		rest := strings.TrimSpace(l.text[k+1:])
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("第 %d 行: 重复的键 %q", l.no, key)
+		}
+		p.pos++
+
+		switch {
+		case rest == "" || strings.HasPrefix(rest, "#"):
+			p.skipBlank()
+			// 子节点：更深的缩进，或同级缩进的序列
+			if p.pos < len(p.lines) && (p.lines[p.pos].indent > indent ||
+				(p.lines[p.pos].indent == indent && isSeqItem(p.lines[p.pos].text))) {
+				v, err := p.parseNode(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				out[key] = v
+			} else {
+				out[key] = ""
+			}
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			out[key] = p.parseBlock(indent, rest[0] == '>')
+		default:
+			v, err := parseYAMLScalar(rest, l.no)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+		}
+	}
+	return out, nil
+}
+
+// 块文本：收集所有缩进大于父级的行 (空行保留)，去掉公共缩进
+func (p *yamlParser) parseBlock(parentIndent int, folded bool) string {
+	var body []string
+	minIndent := -1
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.text != "" && l.indent <= parentIndent {
+			break
+		}
+		if l.text != "" && (minIndent < 0 || l.indent < minIndent) {
+			minIndent = l.indent
+		}
+		body = append(body, p.raw[l.no-1])
+		p.pos++
+	}
+	for len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == "" {
+		body = body[:len(body)-1]
+	}
+	for k, b := range body {
+		if len(b) >= minIndent && minIndent > 0 {
+			body[k] = strings.TrimRight(b[minIndent:], " ")
+		} else {
+			body[k] = strings.TrimSpace(b)
+		}
+	}
+	if folded {
+		return strings.Join(body, " ") + "\n"
+	}
+	return strings.Join(body, "\n") + "\n"
+}
+
+func parseYAMLScalar(s string, lineNo int) (any, error) {
+	switch {
+	case strings.HasPrefix(s, "\""):
+		end := strings.LastIndex(s, "\"")
+		if end == 0 {
+			return nil, fmt.Errorf("第 %d 行: 引号未闭合", lineNo)
+		}
+		v, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %v", lineNo, err)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		end := strings.LastIndex(s, "'")
+		if end == 0 {
+			return nil, fmt.Errorf("第 %d 行: 引号未闭合", lineNo)
+		}
+		return strings.ReplaceAll(s[1:end], "''", "'"), nil
+	case strings.HasPrefix(s, "["):
+		end := strings.LastIndex(s, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("第 %d 行: 行内列表未闭合", lineNo)
+		}
+		var out []any
+		for _, item := range strings.Split(s[1:end], ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			v, err := parseYAMLScalar(item, lineNo)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+	if k := strings.Index(s, " #"); k >= 0 {
+		s = strings.TrimSpace(s[:k])
+	}
+	return s, nil
+}
+
+// ---------------------------------------------------------
+// 读取解析结果的辅助函数
+// ---------------------------------------------------------
+
+func yamlString(m map[string]any, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func yamlStrings(m map[string]any, key string) []string {
+	switch v := m[key].(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []any:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func yamlList(m map[string]any, key string) []map[string]any {
+	items, _ := m[key].([]any)
+	var out []map[string]any
+	for _, item := range items {
+		if mm, ok := item.(map[string]any); ok {
+			out = append(out, mm)
+		}
+	}
+	return out
+}